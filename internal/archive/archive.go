@@ -0,0 +1,161 @@
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/rafalpienkowski/busgopher/internal/asb"
+)
+
+// Archive formats
+const (
+	// FormatJsonLines stores one message per line
+	FormatJsonLines = "jsonl"
+	// FormatDirectory stores every message in its own JSON file
+	FormatDirectory = "dir"
+)
+
+// WriteJsonLines writes every message, with its system properties, as a line of JSON
+func WriteJsonLines(out io.Writer, messages []asb.ReceivedMessage) error {
+	encoder := json.NewEncoder(out)
+	for _, message := range messages {
+		if err := encoder.Encode(message); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PrepareDirectory creates dir when missing and checks files can be written to it, so a failure shows
+// up before messages are removed
+func PrepareDirectory(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	probe, err := os.CreateTemp(dir, ".busgopher-*")
+	if err != nil {
+		return err
+	}
+	probe.Close()
+
+	return os.Remove(probe.Name())
+}
+
+// WriteDirectory writes every message to a numbered file in dir, the directory is created when missing
+func WriteDirectory(dir string, messages []asb.ReceivedMessage) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for i, message := range messages {
+		encoded, err := json.MarshalIndent(message, "", "  ")
+		if err != nil {
+			return err
+		}
+		name := filepath.Join(dir, fmt.Sprintf("message-%06d.json", i+1))
+		if err := os.WriteFile(name, append(encoded, '\n'), 0o644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Read reads messages from a directory written by WriteDirectory or from a JSON Lines file
+func Read(path string) ([]asb.ReceivedMessage, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return ReadDirectory(path)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ReadJsonLines(file)
+}
+
+// ReadJsonLines reads one message per line, empty lines are skipped
+func ReadJsonLines(in io.Reader) ([]asb.ReceivedMessage, error) {
+	messages := []asb.ReceivedMessage{}
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		message, err := decode(scanner.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("line %v: %w", line, err)
+		}
+		messages = append(messages, message)
+	}
+
+	return messages, scanner.Err()
+}
+
+// ReadDirectory reads the JSON files of dir in the order of their names
+func ReadDirectory(dir string) ([]asb.ReceivedMessage, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".json") {
+			names = append(names, entry.Name())
+		}
+	}
+	slices.Sort(names)
+
+	messages := []asb.ReceivedMessage{}
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		message, err := decode(data)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", name, err)
+		}
+		messages = append(messages, message)
+	}
+
+	return messages, nil
+}
+
+// decode keeps integer custom properties integers, JSON would turn them into floats
+func decode(data []byte) (asb.ReceivedMessage, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	message := asb.ReceivedMessage{}
+	if err := decoder.Decode(&message); err != nil {
+		return asb.ReceivedMessage{}, err
+	}
+
+	for key, value := range message.CustomProperties {
+		number, ok := value.(json.Number)
+		if !ok {
+			continue
+		}
+		if integer, err := number.Int64(); err == nil {
+			message.CustomProperties[key] = integer
+		} else {
+			message.CustomProperties[key], _ = number.Float64()
+		}
+	}
+
+	return message, nil
+}
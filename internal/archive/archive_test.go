@@ -0,0 +1,65 @@
+package archive
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/rafalpienkowski/busgopher/internal/asb"
+)
+
+func createTestMessages() []asb.ReceivedMessage {
+	return []asb.ReceivedMessage{
+		{
+			Message: asb.Message{
+				Body:             `{"id": 1}`,
+				MessageID:        "message-1",
+				Subject:          "created",
+				ContentType:      "application/json",
+				CustomProperties: map[string]any{"priority": int64(5), "ratio": 0.5, "tenant": "acme", "urgent": true},
+			},
+			SequenceNumber:   12,
+			EnqueuedTime:     time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC),
+			DeliveryCount:    10,
+			DeadLetterReason: "MaxDeliveryCountExceeded",
+		},
+		{
+			Message:        asb.Message{Body: "plain\ntext", MessageID: "message-2"},
+			SequenceNumber: 13,
+		},
+	}
+}
+
+func Test_Archive_Should_Round_Trip_Json_Lines(t *testing.T) {
+	var buffer bytes.Buffer
+
+	err := WriteJsonLines(&buffer, createTestMessages())
+	assert.NoError(t, err)
+	assert.Len(t, strings.Split(strings.TrimSpace(buffer.String()), "\n"), 2)
+	messages, err := ReadJsonLines(&buffer)
+
+	assert.NoError(t, err)
+	assert.Equal(t, createTestMessages(), messages)
+}
+
+func Test_Archive_Should_Round_Trip_Directory(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "export")
+
+	err := WriteDirectory(dir, createTestMessages())
+	assert.NoError(t, err)
+	assert.FileExists(t, filepath.Join(dir, "message-000001.json"))
+	messages, err := Read(dir)
+
+	assert.NoError(t, err)
+	assert.Equal(t, createTestMessages(), messages)
+}
+
+func Test_Archive_Should_Report_Invalid_Line(t *testing.T) {
+	_, err := ReadJsonLines(strings.NewReader("{\"body\": \"a\"}\n\nnot json\n"))
+
+	assert.ErrorContains(t, err, "line 3:")
+}
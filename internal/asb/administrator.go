@@ -0,0 +1,71 @@
+package asb
+
+import "time"
+
+// Entity kinds
+const (
+	EntityQueue        = "queue"
+	EntityTopic        = "topic"
+	EntitySubscription = "subscription"
+)
+
+// Entity is a queue, topic or subscription of a namespace
+type Entity struct {
+	Kind string `json:"kind"`
+	// Name of the queue, topic or subscription
+	Name string `json:"name"`
+	// Topic of the subscription, empty for queues and topics
+	Topic string `json:"topic,omitempty"`
+}
+
+// Path returns the name of queues and topics, and topic/subscriptions/name for subscriptions
+func (entity Entity) Path() string {
+	if entity.Kind == EntitySubscription {
+		return entity.Topic + "/subscriptions/" + entity.Name
+	}
+	return entity.Name
+}
+
+// EntityStatistics holds the runtime properties of an entity. Counts the entity kind doesn't have
+// are zero: topics only count scheduled messages and subscriptions have no size.
+type EntityStatistics struct {
+	Entity
+	ActiveMessageCount             int32     `json:"activeMessageCount"`
+	DeadLetterMessageCount         int32     `json:"deadLetterMessageCount"`
+	ScheduledMessageCount          int32     `json:"scheduledMessageCount"`
+	TransferMessageCount           int32     `json:"transferMessageCount"`
+	TransferDeadLetterMessageCount int32     `json:"transferDeadLetterMessageCount"`
+	SizeInBytes                    int64     `json:"sizeInBytes"`
+	AccessedAt                     time.Time `json:"accessedAt"`
+}
+
+// Administrator manages the entities of a namespace
+type Administrator interface {
+	// ListEntities returns the queues, the topics and the subscriptions of the topics
+	ListEntities(connection Connection) ([]Entity, error)
+	// ListSubscriptions returns the names of the topic's subscriptions
+	ListSubscriptions(connection Connection, topic string) ([]string, error)
+	// GetStatistics returns the runtime properties of the queues, the topics and their subscriptions
+	GetStatistics(connection Connection) ([]EntityStatistics, error)
+
+	// GetQueue returns nil when the queue doesn't exist
+	GetQueue(connection Connection, name string) (*QueueDefinition, error)
+	CreateQueue(connection Connection, queue QueueDefinition) error
+	// UpdateQueue sets the properties of the definition, properties it doesn't have are kept
+	UpdateQueue(connection Connection, queue QueueDefinition) error
+	// GetTopic returns nil when the topic doesn't exist, subscriptions are not included
+	GetTopic(connection Connection, name string) (*TopicDefinition, error)
+	CreateTopic(connection Connection, topic TopicDefinition) error
+	UpdateTopic(connection Connection, topic TopicDefinition) error
+	// GetSubscription returns nil when the subscription doesn't exist, rules are not included
+	GetSubscription(connection Connection, topic string, name string) (*SubscriptionDefinition, error)
+	CreateSubscription(connection Connection, topic string, subscription SubscriptionDefinition) error
+	UpdateSubscription(connection Connection, topic string, subscription SubscriptionDefinition) error
+	// DeleteEntity deletes the queue, the topic with its subscriptions or the subscription
+	DeleteEntity(connection Connection, entity Entity) error
+
+	ListRules(connection Connection, topic string, subscription string) ([]RuleDefinition, error)
+	CreateRule(connection Connection, topic string, subscription string, rule RuleDefinition) error
+	UpdateRule(connection Connection, topic string, subscription string, rule RuleDefinition) error
+	DeleteRule(connection Connection, topic string, subscription string, name string) error
+}
@@ -0,0 +1,566 @@
+package asb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/messaging/azservicebus/admin"
+)
+
+const adminTimeout = 30 * time.Second
+
+type AsbAdministrator struct {
+	clientPool
+}
+
+func (administrator *AsbAdministrator) ListEntities(connection Connection) ([]Entity, error) {
+	client, err := administrator.getAdminClient(connection)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), adminTimeout)
+	defer cancel()
+
+	entities := []Entity{}
+	queues := client.NewListQueuesPager(nil)
+	for queues.More() {
+		page, err := queues.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, queue := range page.Queues {
+			entities = append(entities, Entity{Kind: EntityQueue, Name: queue.QueueName})
+		}
+	}
+
+	topics := client.NewListTopicsPager(nil)
+	for topics.More() {
+		page, err := topics.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, topic := range page.Topics {
+			entities = append(entities, Entity{Kind: EntityTopic, Name: topic.TopicName})
+
+			subscriptions := client.NewListSubscriptionsPager(topic.TopicName, nil)
+			for subscriptions.More() {
+				page, err := subscriptions.NextPage(ctx)
+				if err != nil {
+					return nil, err
+				}
+				for _, subscription := range page.Subscriptions {
+					entities = append(entities, Entity{
+						Kind:  EntitySubscription,
+						Name:  subscription.SubscriptionName,
+						Topic: topic.TopicName,
+					})
+				}
+			}
+		}
+	}
+
+	return entities, nil
+}
+
+func (administrator *AsbAdministrator) ListSubscriptions(connection Connection, topic string) ([]string, error) {
+	client, err := administrator.getAdminClient(connection)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), adminTimeout)
+	defer cancel()
+
+	names := []string{}
+	pager := client.NewListSubscriptionsPager(topic, nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, subscription := range page.Subscriptions {
+			names = append(names, subscription.SubscriptionName)
+		}
+	}
+
+	return names, nil
+}
+
+func (administrator *AsbAdministrator) GetStatistics(connection Connection) ([]EntityStatistics, error) {
+	client, err := administrator.getAdminClient(connection)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), adminTimeout)
+	defer cancel()
+
+	statistics := []EntityStatistics{}
+	queues := client.NewListQueuesRuntimePropertiesPager(nil)
+	for queues.More() {
+		page, err := queues.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, queue := range page.QueueRuntimeProperties {
+			statistics = append(statistics, EntityStatistics{
+				Entity:                         Entity{Kind: EntityQueue, Name: queue.QueueName},
+				ActiveMessageCount:             queue.ActiveMessageCount,
+				DeadLetterMessageCount:         queue.DeadLetterMessageCount,
+				ScheduledMessageCount:          queue.ScheduledMessageCount,
+				TransferMessageCount:           queue.TransferMessageCount,
+				TransferDeadLetterMessageCount: queue.TransferDeadLetterMessageCount,
+				SizeInBytes:                    queue.SizeInBytes,
+				AccessedAt:                     queue.AccessedAt,
+			})
+		}
+	}
+
+	topics := client.NewListTopicsRuntimePropertiesPager(nil)
+	for topics.More() {
+		page, err := topics.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, topic := range page.TopicRuntimeProperties {
+			statistics = append(statistics, EntityStatistics{
+				Entity:                Entity{Kind: EntityTopic, Name: topic.TopicName},
+				ScheduledMessageCount: topic.ScheduledMessageCount,
+				SizeInBytes:           topic.SizeInBytes,
+				AccessedAt:            topic.AccessedAt,
+			})
+
+			subscriptions := client.NewListSubscriptionsRuntimePropertiesPager(topic.TopicName, nil)
+			for subscriptions.More() {
+				page, err := subscriptions.NextPage(ctx)
+				if err != nil {
+					return nil, err
+				}
+				for _, subscription := range page.SubscriptionRuntimeProperties {
+					statistics = append(statistics, EntityStatistics{
+						Entity: Entity{
+							Kind:  EntitySubscription,
+							Name:  subscription.SubscriptionName,
+							Topic: topic.TopicName,
+						},
+						ActiveMessageCount:             subscription.ActiveMessageCount,
+						DeadLetterMessageCount:         subscription.DeadLetterMessageCount,
+						TransferMessageCount:           subscription.TransferMessageCount,
+						TransferDeadLetterMessageCount: subscription.TransferDeadLetterMessageCount,
+						AccessedAt:                     subscription.AccessedAt,
+					})
+				}
+			}
+		}
+	}
+
+	return statistics, nil
+}
+
+func (administrator *AsbAdministrator) GetQueue(connection Connection, name string) (*QueueDefinition, error) {
+	client, err := administrator.getAdminClient(connection)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), adminTimeout)
+	defer cancel()
+
+	response, err := client.GetQueue(ctx, name, nil)
+	if err != nil || response == nil {
+		return nil, err
+	}
+	properties := response.QueueProperties
+
+	return &QueueDefinition{
+		Name:                             response.QueueName,
+		LockDuration:                     fromISODuration(properties.LockDuration),
+		DefaultMessageTimeToLive:         fromISODuration(properties.DefaultMessageTimeToLive),
+		MaxDeliveryCount:                 valueOrEmpty(properties.MaxDeliveryCount),
+		RequiresSession:                  valueOrEmpty(properties.RequiresSession),
+		DeadLetteringOnMessageExpiration: valueOrEmpty(properties.DeadLetteringOnMessageExpiration),
+		ForwardTo:                        entityName(valueOrEmpty(properties.ForwardTo)),
+	}, nil
+}
+
+func (administrator *AsbAdministrator) CreateQueue(connection Connection, queue QueueDefinition) error {
+	client, err := administrator.getAdminClient(connection)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), adminTimeout)
+	defer cancel()
+
+	properties := admin.QueueProperties{}
+	setQueueProperties(&properties, queue)
+	_, err = client.CreateQueue(ctx, queue.Name, &admin.CreateQueueOptions{Properties: &properties})
+
+	return err
+}
+
+func (administrator *AsbAdministrator) UpdateQueue(connection Connection, queue QueueDefinition) error {
+	client, err := administrator.getAdminClient(connection)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), adminTimeout)
+	defer cancel()
+
+	// Updating replaces all properties, so the current ones are changed
+	response, err := client.GetQueue(ctx, queue.Name, nil)
+	if err != nil {
+		return err
+	}
+	if response == nil {
+		return fmt.Errorf("Can't find queue: %v", queue.Name)
+	}
+	properties := response.QueueProperties
+	setQueueProperties(&properties, queue)
+	_, err = client.UpdateQueue(ctx, queue.Name, properties, nil)
+
+	return err
+}
+
+func setQueueProperties(properties *admin.QueueProperties, queue QueueDefinition) {
+	if lockDuration := toISODuration(queue.LockDuration); lockDuration != nil {
+		properties.LockDuration = lockDuration
+	}
+	if timeToLive := toISODuration(queue.DefaultMessageTimeToLive); timeToLive != nil {
+		properties.DefaultMessageTimeToLive = timeToLive
+	}
+	if queue.MaxDeliveryCount > 0 {
+		properties.MaxDeliveryCount = &queue.MaxDeliveryCount
+	}
+	properties.RequiresSession = &queue.RequiresSession
+	properties.DeadLetteringOnMessageExpiration = &queue.DeadLetteringOnMessageExpiration
+	properties.ForwardTo = optional(queue.ForwardTo)
+}
+
+func (administrator *AsbAdministrator) GetTopic(connection Connection, name string) (*TopicDefinition, error) {
+	client, err := administrator.getAdminClient(connection)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), adminTimeout)
+	defer cancel()
+
+	response, err := client.GetTopic(ctx, name, nil)
+	if err != nil || response == nil {
+		return nil, err
+	}
+
+	return &TopicDefinition{
+		Name:                     response.TopicName,
+		DefaultMessageTimeToLive: fromISODuration(response.DefaultMessageTimeToLive),
+	}, nil
+}
+
+func (administrator *AsbAdministrator) CreateTopic(connection Connection, topic TopicDefinition) error {
+	client, err := administrator.getAdminClient(connection)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), adminTimeout)
+	defer cancel()
+
+	properties := admin.TopicProperties{DefaultMessageTimeToLive: toISODuration(topic.DefaultMessageTimeToLive)}
+	_, err = client.CreateTopic(ctx, topic.Name, &admin.CreateTopicOptions{Properties: &properties})
+
+	return err
+}
+
+func (administrator *AsbAdministrator) UpdateTopic(connection Connection, topic TopicDefinition) error {
+	client, err := administrator.getAdminClient(connection)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), adminTimeout)
+	defer cancel()
+
+	response, err := client.GetTopic(ctx, topic.Name, nil)
+	if err != nil {
+		return err
+	}
+	if response == nil {
+		return fmt.Errorf("Can't find topic: %v", topic.Name)
+	}
+	properties := response.TopicProperties
+	if timeToLive := toISODuration(topic.DefaultMessageTimeToLive); timeToLive != nil {
+		properties.DefaultMessageTimeToLive = timeToLive
+	}
+	_, err = client.UpdateTopic(ctx, topic.Name, properties, nil)
+
+	return err
+}
+
+func (administrator *AsbAdministrator) GetSubscription(
+	connection Connection,
+	topic string,
+	name string,
+) (*SubscriptionDefinition, error) {
+	client, err := administrator.getAdminClient(connection)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), adminTimeout)
+	defer cancel()
+
+	response, err := client.GetSubscription(ctx, topic, name, nil)
+	if err != nil || response == nil {
+		return nil, err
+	}
+	properties := response.SubscriptionProperties
+
+	return &SubscriptionDefinition{
+		Name:                             response.SubscriptionName,
+		LockDuration:                     fromISODuration(properties.LockDuration),
+		DefaultMessageTimeToLive:         fromISODuration(properties.DefaultMessageTimeToLive),
+		MaxDeliveryCount:                 valueOrEmpty(properties.MaxDeliveryCount),
+		RequiresSession:                  valueOrEmpty(properties.RequiresSession),
+		DeadLetteringOnMessageExpiration: valueOrEmpty(properties.DeadLetteringOnMessageExpiration),
+		ForwardTo:                        entityName(valueOrEmpty(properties.ForwardTo)),
+	}, nil
+}
+
+func (administrator *AsbAdministrator) CreateSubscription(
+	connection Connection,
+	topic string,
+	subscription SubscriptionDefinition,
+) error {
+	client, err := administrator.getAdminClient(connection)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), adminTimeout)
+	defer cancel()
+
+	properties := admin.SubscriptionProperties{}
+	setSubscriptionProperties(&properties, subscription)
+	_, err = client.CreateSubscription(ctx, topic, subscription.Name, &admin.CreateSubscriptionOptions{
+		Properties: &properties,
+	})
+
+	return err
+}
+
+func (administrator *AsbAdministrator) UpdateSubscription(
+	connection Connection,
+	topic string,
+	subscription SubscriptionDefinition,
+) error {
+	client, err := administrator.getAdminClient(connection)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), adminTimeout)
+	defer cancel()
+
+	response, err := client.GetSubscription(ctx, topic, subscription.Name, nil)
+	if err != nil {
+		return err
+	}
+	if response == nil {
+		return fmt.Errorf("Can't find subscription: %v/subscriptions/%v", topic, subscription.Name)
+	}
+	properties := response.SubscriptionProperties
+	setSubscriptionProperties(&properties, subscription)
+	_, err = client.UpdateSubscription(ctx, topic, subscription.Name, properties, nil)
+
+	return err
+}
+
+func setSubscriptionProperties(properties *admin.SubscriptionProperties, subscription SubscriptionDefinition) {
+	if lockDuration := toISODuration(subscription.LockDuration); lockDuration != nil {
+		properties.LockDuration = lockDuration
+	}
+	if timeToLive := toISODuration(subscription.DefaultMessageTimeToLive); timeToLive != nil {
+		properties.DefaultMessageTimeToLive = timeToLive
+	}
+	if subscription.MaxDeliveryCount > 0 {
+		properties.MaxDeliveryCount = &subscription.MaxDeliveryCount
+	}
+	properties.RequiresSession = &subscription.RequiresSession
+	properties.DeadLetteringOnMessageExpiration = &subscription.DeadLetteringOnMessageExpiration
+	properties.ForwardTo = optional(subscription.ForwardTo)
+}
+
+func (administrator *AsbAdministrator) DeleteEntity(connection Connection, entity Entity) error {
+	client, err := administrator.getAdminClient(connection)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), adminTimeout)
+	defer cancel()
+
+	switch entity.Kind {
+	case EntityQueue:
+		_, err = client.DeleteQueue(ctx, entity.Name, nil)
+	case EntityTopic:
+		_, err = client.DeleteTopic(ctx, entity.Name, nil)
+	case EntitySubscription:
+		_, err = client.DeleteSubscription(ctx, entity.Topic, entity.Name, nil)
+	default:
+		err = fmt.Errorf("Unknown entity kind: %v", entity.Kind)
+	}
+
+	return err
+}
+
+func (administrator *AsbAdministrator) ListRules(
+	connection Connection,
+	topic string,
+	subscription string,
+) ([]RuleDefinition, error) {
+	client, err := administrator.getAdminClient(connection)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), adminTimeout)
+	defer cancel()
+
+	rules := []RuleDefinition{}
+	pager := client.NewListRulesPager(topic, subscription, nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, rule := range page.Rules {
+			rules = append(rules, fromRuleProperties(rule))
+		}
+	}
+
+	return rules, nil
+}
+
+func (administrator *AsbAdministrator) CreateRule(
+	connection Connection,
+	topic string,
+	subscription string,
+	rule RuleDefinition,
+) error {
+	client, err := administrator.getAdminClient(connection)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), adminTimeout)
+	defer cancel()
+
+	properties := toRuleProperties(rule)
+	_, err = client.CreateRule(ctx, topic, subscription, &admin.CreateRuleOptions{
+		Name:   &properties.Name,
+		Filter: properties.Filter,
+		Action: properties.Action,
+	})
+
+	return err
+}
+
+func (administrator *AsbAdministrator) UpdateRule(
+	connection Connection,
+	topic string,
+	subscription string,
+	rule RuleDefinition,
+) error {
+	client, err := administrator.getAdminClient(connection)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), adminTimeout)
+	defer cancel()
+
+	_, err = client.UpdateRule(ctx, topic, subscription, toRuleProperties(rule))
+
+	return err
+}
+
+func (administrator *AsbAdministrator) DeleteRule(
+	connection Connection,
+	topic string,
+	subscription string,
+	name string,
+) error {
+	client, err := administrator.getAdminClient(connection)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), adminTimeout)
+	defer cancel()
+
+	_, err = client.DeleteRule(ctx, topic, subscription, name, nil)
+
+	return err
+}
+
+func toRuleProperties(rule RuleDefinition) admin.RuleProperties {
+	properties := admin.RuleProperties{Name: rule.Name}
+	if rule.Correlation != nil {
+		correlation := rule.Correlation
+		applicationProperties := map[string]any{}
+		for key, value := range correlation.Properties {
+			// Numbers decoded from the config are floats, whole ones are sent as integers
+			if number, ok := value.(float64); ok && number == float64(int64(number)) {
+				value = int64(number)
+			}
+			applicationProperties[key] = value
+		}
+		properties.Filter = &admin.CorrelationFilter{
+			CorrelationID:         optional(correlation.CorrelationID),
+			MessageID:             optional(correlation.MessageID),
+			To:                    optional(correlation.To),
+			ReplyTo:               optional(correlation.ReplyTo),
+			Subject:               optional(correlation.Subject),
+			SessionID:             optional(correlation.SessionID),
+			ReplyToSessionID:      optional(correlation.ReplyToSessionID),
+			ContentType:           optional(correlation.ContentType),
+			ApplicationProperties: applicationProperties,
+		}
+	} else {
+		properties.Filter = &admin.SQLFilter{Expression: rule.SQL}
+	}
+	if len(rule.Action) > 0 {
+		properties.Action = &admin.SQLAction{Expression: rule.Action}
+	}
+
+	return properties
+}
+
+func fromRuleProperties(properties admin.RuleProperties) RuleDefinition {
+	rule := RuleDefinition{Name: properties.Name}
+	switch filter := properties.Filter.(type) {
+	case *admin.SQLFilter:
+		rule.SQL = filter.Expression
+	case *admin.TrueFilter:
+		rule.SQL = "1=1"
+	case *admin.FalseFilter:
+		rule.SQL = "1=0"
+	case *admin.CorrelationFilter:
+		rule.Correlation = &CorrelationFilter{
+			CorrelationID:    valueOrEmpty(filter.CorrelationID),
+			MessageID:        valueOrEmpty(filter.MessageID),
+			To:               valueOrEmpty(filter.To),
+			ReplyTo:          valueOrEmpty(filter.ReplyTo),
+			Subject:          valueOrEmpty(filter.Subject),
+			SessionID:        valueOrEmpty(filter.SessionID),
+			ReplyToSessionID: valueOrEmpty(filter.ReplyToSessionID),
+			ContentType:      valueOrEmpty(filter.ContentType),
+			Properties:       filter.ApplicationProperties,
+		}
+	}
+	if action, ok := properties.Action.(*admin.SQLAction); ok {
+		rule.Action = action.Expression
+	}
+
+	return rule
+}
+
+func optional(value string) *string {
+	if len(value) == 0 {
+		return nil
+	}
+	return &value
+}
+
+// entityName returns the name of the entity that the administration API may return as an URL
+func entityName(path string) string {
+	return path[strings.LastIndex(path, "/")+1:]
+}
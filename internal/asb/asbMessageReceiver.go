@@ -0,0 +1,267 @@
+package asb
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/messaging/azservicebus"
+)
+
+const peekTimeout = 30 * time.Second
+
+type AsbMessageReceiver struct {
+	clientPool
+}
+
+// entityReceiver is implemented by both azservicebus.Receiver and azservicebus.SessionReceiver
+type entityReceiver interface {
+	PeekMessages(ctx context.Context, maxMessageCount int, options *azservicebus.PeekMessagesOptions) ([]*azservicebus.ReceivedMessage, error)
+	ReceiveMessages(ctx context.Context, maxMessages int, options *azservicebus.ReceiveMessagesOptions) ([]*azservicebus.ReceivedMessage, error)
+	CompleteMessage(ctx context.Context, message *azservicebus.ReceivedMessage, options *azservicebus.CompleteMessageOptions) error
+	AbandonMessage(ctx context.Context, message *azservicebus.ReceivedMessage, options *azservicebus.AbandonMessageOptions) error
+	DeferMessage(ctx context.Context, message *azservicebus.ReceivedMessage, options *azservicebus.DeferMessageOptions) error
+	DeadLetterMessage(ctx context.Context, message *azservicebus.ReceivedMessage, options *azservicebus.DeadLetterOptions) error
+	ReceiveDeferredMessages(ctx context.Context, sequenceNumbers []int64, options *azservicebus.ReceiveDeferredMessagesOptions) ([]*azservicebus.ReceivedMessage, error)
+	Close(ctx context.Context) error
+}
+
+func (messageReceiver *AsbMessageReceiver) newReceiver(
+	connection Connection,
+	source Source,
+	mode azservicebus.ReceiveMode,
+) (entityReceiver, error) {
+	if len(source.SessionID) > 0 || source.NextSession {
+		return messageReceiver.newSessionReceiver(connection, source, mode)
+	}
+
+	client, err := messageReceiver.getClient(connection)
+	if err != nil {
+		return nil, err
+	}
+
+	options := &azservicebus.ReceiverOptions{ReceiveMode: mode}
+	if source.DeadLetter {
+		options.SubQueue = azservicebus.SubQueueDeadLetter
+	}
+
+	if len(source.Subscription) > 0 {
+		return client.NewReceiverForSubscription(source.Destination, source.Subscription, options)
+	}
+	return client.NewReceiverForQueue(source.Destination, options)
+}
+
+// newSessionReceiver accepts the session of the source, or the next available one
+func (messageReceiver *AsbMessageReceiver) newSessionReceiver(
+	connection Connection,
+	source Source,
+	mode azservicebus.ReceiveMode,
+) (*azservicebus.SessionReceiver, error) {
+	if source.DeadLetter {
+		return nil, errors.New("dead-letter queues don't have sessions")
+	}
+	client, err := messageReceiver.getClient(connection)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), peekTimeout)
+	defer cancel()
+	options := &azservicebus.SessionReceiverOptions{ReceiveMode: mode}
+	switch {
+	case source.NextSession && len(source.Subscription) > 0:
+		return client.AcceptNextSessionForSubscription(ctx, source.Destination, source.Subscription, options)
+	case source.NextSession:
+		return client.AcceptNextSessionForQueue(ctx, source.Destination, options)
+	case len(source.Subscription) > 0:
+		return client.AcceptSessionForSubscription(ctx, source.Destination, source.Subscription, source.SessionID, options)
+	}
+	return client.AcceptSessionForQueue(ctx, source.Destination, source.SessionID, options)
+}
+
+func (messageReceiver *AsbMessageReceiver) GetSessionState(connection Connection, source Source) (string, []byte, error) {
+	receiver, err := messageReceiver.newSessionReceiver(connection, source, azservicebus.ReceiveModePeekLock)
+	if err != nil {
+		return "", nil, err
+	}
+	defer receiver.Close(context.TODO())
+
+	ctx, cancel := context.WithTimeout(context.Background(), peekTimeout)
+	defer cancel()
+
+	state, err := receiver.GetSessionState(ctx, nil)
+	return receiver.SessionID(), state, err
+}
+
+func (messageReceiver *AsbMessageReceiver) SetSessionState(connection Connection, source Source, state []byte) error {
+	receiver, err := messageReceiver.newSessionReceiver(connection, source, azservicebus.ReceiveModePeekLock)
+	if err != nil {
+		return err
+	}
+	defer receiver.Close(context.TODO())
+
+	ctx, cancel := context.WithTimeout(context.Background(), peekTimeout)
+	defer cancel()
+
+	return receiver.SetSessionState(ctx, state, nil)
+}
+
+func (messageReceiver *AsbMessageReceiver) Peek(
+	connection Connection,
+	source Source,
+	count int,
+	fromSequenceNumber int64,
+) ([]ReceivedMessage, error) {
+	receiver, err := messageReceiver.newReceiver(connection, source, azservicebus.ReceiveModePeekLock)
+	if err != nil {
+		return nil, err
+	}
+	defer receiver.Close(context.TODO())
+
+	ctx, cancel := context.WithTimeout(context.Background(), peekTimeout)
+	defer cancel()
+
+	messages := []ReceivedMessage{}
+	options := &azservicebus.PeekMessagesOptions{}
+	if fromSequenceNumber > 0 {
+		options.FromSequenceNumber = &fromSequenceNumber
+	}
+	for len(messages) < count {
+		peeked, err := receiver.PeekMessages(ctx, count-len(messages), options)
+		if err != nil {
+			return messages, err
+		}
+		if len(peeked) == 0 {
+			break
+		}
+		for _, message := range peeked {
+			messages = append(messages, fromReceivedMessage(message))
+		}
+		// Next peek continues after the last peeked message
+		options = nil
+	}
+
+	return messages, nil
+}
+
+func (messageReceiver *AsbMessageReceiver) Receive(
+	connection Connection,
+	source Source,
+	count int,
+	wait time.Duration,
+) ([]ReceivedMessage, error) {
+	receiver, err := messageReceiver.newReceiver(connection, source, azservicebus.ReceiveModeReceiveAndDelete)
+	if err != nil {
+		return nil, err
+	}
+	defer receiver.Close(context.TODO())
+
+	ctx, cancel := context.WithTimeout(context.Background(), wait)
+	defer cancel()
+
+	messages := []ReceivedMessage{}
+	for len(messages) < count {
+		received, err := receiver.ReceiveMessages(ctx, count-len(messages), nil)
+		for _, message := range received {
+			messages = append(messages, fromReceivedMessage(message))
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			break
+		}
+		if err != nil {
+			return messages, err
+		}
+	}
+
+	return messages, nil
+}
+
+// ReceiveMatching keeps non-matching messages locked while waiting, so they are not received
+// again, and abandons them at the end. Their delivery count is increased by one.
+func (messageReceiver *AsbMessageReceiver) ReceiveMatching(
+	connection Connection,
+	source Source,
+	match func(ReceivedMessage) bool,
+	count int,
+	wait time.Duration,
+) ([]ReceivedMessage, error) {
+	receiver, err := messageReceiver.newReceiver(connection, source, azservicebus.ReceiveModePeekLock)
+	if err != nil {
+		return nil, err
+	}
+	defer receiver.Close(context.TODO())
+
+	locked := []*azservicebus.ReceivedMessage{}
+	defer func() {
+		for _, message := range locked {
+			receiver.AbandonMessage(context.TODO(), message, nil)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), wait)
+	defer cancel()
+
+	matching := []ReceivedMessage{}
+	for {
+		received, err := receiver.ReceiveMessages(ctx, 10, nil)
+		for i, message := range received {
+			converted := fromReceivedMessage(message)
+			if !match(converted) {
+				locked = append(locked, message)
+				continue
+			}
+			err := receiver.CompleteMessage(context.TODO(), message, nil)
+			if err != nil {
+				locked = append(locked, received[i+1:]...)
+				return matching, err
+			}
+			matching = append(matching, converted)
+			if len(matching) == count {
+				locked = append(locked, received[i+1:]...)
+				return matching, nil
+			}
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			return matching, nil
+		}
+		if err != nil {
+			return matching, err
+		}
+	}
+}
+
+func fromReceivedMessage(received *azservicebus.ReceivedMessage) ReceivedMessage {
+	message := ReceivedMessage{
+		Message: Message{
+			Body:             string(received.Body),
+			CorrelationID:    valueOrEmpty(received.CorrelationID),
+			MessageID:        received.MessageID,
+			ReplayTo:         valueOrEmpty(received.ReplyTo),
+			ReplyToSessionID: valueOrEmpty(received.ReplyToSessionID),
+			SessionID:        valueOrEmpty(received.SessionID),
+			Subject:          valueOrEmpty(received.Subject),
+			ContentType:      valueOrEmpty(received.ContentType),
+			CustomProperties: received.ApplicationProperties,
+		},
+		DeliveryCount:         received.DeliveryCount,
+		DeadLetterReason:      valueOrEmpty(received.DeadLetterReason),
+		DeadLetterDescription: valueOrEmpty(received.DeadLetterErrorDescription),
+		DeadLetterSource:      valueOrEmpty(received.DeadLetterSource),
+	}
+	if received.SequenceNumber != nil {
+		message.SequenceNumber = *received.SequenceNumber
+	}
+	if received.EnqueuedTime != nil {
+		message.EnqueuedTime = *received.EnqueuedTime
+	}
+
+	return message
+}
+
+func valueOrEmpty[T any](value *T) T {
+	var empty T
+	if value == nil {
+		return empty
+	}
+	return *value
+}
@@ -3,64 +3,30 @@ package asb
 import (
 	"context"
 
-	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/Azure/azure-sdk-for-go/sdk/messaging/azservicebus"
 )
 
 type AsbMessageSender struct {
-	credentials *azidentity.DefaultAzureCredential
-	client      *azservicebus.Client
-}
-
-func (sender *AsbMessageSender) getCredentials() error {
-	cred, err := azidentity.NewDefaultAzureCredential(nil)
-	if err != nil {
-		return err
-	}
-	sender.credentials = cred
-
-	return nil
-}
-
-func (sender *AsbMessageSender) getClient(namespace string) error {
-	if credErr := sender.getCredentials(); credErr != nil {
-		return credErr
-	}
-
-	client, err := azservicebus.NewClient(namespace, sender.credentials, nil)
-	if err != nil {
-		return err
-	}
-
-	sender.client = client
-
-	return nil
+	clientPool
 }
 
 func (messageSender *AsbMessageSender) Send(
-	namespace string,
+	connection Connection,
 	destination string,
 	message Message,
 ) error {
-	if messageSender.client == nil {
-		connErr := messageSender.getClient(namespace)
-		if connErr != nil {
-			return connErr
-		}
-	}
-	sender, err := messageSender.client.NewSender(destination, nil)
+	client, err := messageSender.getClient(connection)
 	if err != nil {
 		return err
 	}
-	defer sender.Close(context.TODO())
-
-	body, err := message.TransformBody()
+	sender, err := client.NewSender(destination, nil)
 	if err != nil {
 		return err
 	}
+	defer sender.Close(context.TODO())
 
 	sbMessage := &azservicebus.Message{
-		Body: []byte(body),
+		Body: []byte(message.Body),
 	}
 
 	if message.CorrelationID != "" {
@@ -75,10 +41,22 @@ func (messageSender *AsbMessageSender) Send(
 		sbMessage.ReplyTo = &message.ReplayTo
 	}
 
+	if message.ReplyToSessionID != "" {
+		sbMessage.ReplyToSessionID = &message.ReplyToSessionID
+	}
+
+	if message.SessionID != "" {
+		sbMessage.SessionID = &message.SessionID
+	}
+
 	if message.Subject != "" {
 		sbMessage.Subject = &message.Subject
 	}
 
+	if message.ContentType != "" {
+		sbMessage.ContentType = &message.ContentType
+	}
+
 	if len(message.CustomProperties) > 0 {
 		sbMessage.ApplicationProperties = message.CustomProperties
 	}
@@ -0,0 +1,101 @@
+package asb
+
+import (
+	"sync"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/messaging/azservicebus"
+	"github.com/Azure/azure-sdk-for-go/sdk/messaging/azservicebus/admin"
+)
+
+// clientPool creates Service Bus clients once per namespace or connection string, it can be used
+// from many goroutines
+type clientPool struct {
+	mutex        sync.Mutex
+	credentials  *azidentity.DefaultAzureCredential
+	clients      map[string]*azservicebus.Client
+	adminClients map[string]*admin.Client
+}
+
+// getCredentials is called with the mutex locked
+func (pool *clientPool) getCredentials() error {
+	if pool.credentials != nil {
+		return nil
+	}
+
+	cred, err := azidentity.NewDefaultAzureCredential(nil)
+	if err != nil {
+		return err
+	}
+	pool.credentials = cred
+
+	return nil
+}
+
+func (pool *clientPool) getClient(connection Connection) (*azservicebus.Client, error) {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+
+	key := connection.Namespace
+	if len(connection.ConnectionString) > 0 {
+		key = connection.ConnectionString
+	}
+	if client, ok := pool.clients[key]; ok {
+		return client, nil
+	}
+
+	var client *azservicebus.Client
+	var err error
+	if len(connection.ConnectionString) > 0 {
+		client, err = azservicebus.NewClientFromConnectionString(connection.ConnectionString, nil)
+	} else {
+		if credErr := pool.getCredentials(); credErr != nil {
+			return nil, credErr
+		}
+		client, err = azservicebus.NewClient(connection.Namespace, pool.credentials, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if pool.clients == nil {
+		pool.clients = make(map[string]*azservicebus.Client)
+	}
+	pool.clients[key] = client
+
+	return client, nil
+}
+
+func (pool *clientPool) getAdminClient(connection Connection) (*admin.Client, error) {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+
+	key := connection.Namespace
+	if len(connection.ConnectionString) > 0 {
+		key = connection.ConnectionString
+	}
+	if client, ok := pool.adminClients[key]; ok {
+		return client, nil
+	}
+
+	var client *admin.Client
+	var err error
+	if len(connection.ConnectionString) > 0 {
+		client, err = admin.NewClientFromConnectionString(connection.ConnectionString, nil)
+	} else {
+		if credErr := pool.getCredentials(); credErr != nil {
+			return nil, credErr
+		}
+		client, err = admin.NewClient(connection.Namespace, pool.credentials, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if pool.adminClients == nil {
+		pool.adminClients = make(map[string]*admin.Client)
+	}
+	pool.adminClients[key] = client
+
+	return client, nil
+}
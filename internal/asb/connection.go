@@ -1,10 +1,13 @@
 package asb
 
 type Connection struct {
-	Namespace    string   `json:"namespace"`
-	Destinations []string `json:"destinations"`
+	Namespace string `json:"namespace"`
+	// ConnectionString is used instead of DefaultAzureCredential when set
+	ConnectionString string   `json:"connectionString,omitempty"`
+	Destinations     []string `json:"destinations"`
 }
 
 type MessageSender interface {
-    Send(namespace string, destination string, message Message) error
+	// Send sends the message as it is, body templates must be rendered before
+	Send(connection Connection, destination string, message Message) error
 }
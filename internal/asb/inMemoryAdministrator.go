@@ -0,0 +1,305 @@
+package asb
+
+import (
+	"fmt"
+	"slices"
+)
+
+// InMemoryAdministrator manages entities stored per namespace. Entities and Statistics are only
+// listed, queues, topics, subscriptions and rules are managed in Topologies.
+type InMemoryAdministrator struct {
+	Entities   map[string][]Entity
+	Statistics map[string][]EntityStatistics
+	Topologies map[string]*Topology
+}
+
+// ListEntities lists the Entities followed by the entities of the namespace's topology
+func (administrator *InMemoryAdministrator) ListEntities(connection Connection) ([]Entity, error) {
+	entities := append([]Entity{}, administrator.Entities[connection.Namespace]...)
+	topology, ok := administrator.Topologies[connection.Namespace]
+	if !ok {
+		return entities, nil
+	}
+	for _, queue := range topology.Queues {
+		entities = append(entities, Entity{Kind: EntityQueue, Name: queue.Name})
+	}
+	for _, topic := range topology.Topics {
+		entities = append(entities, Entity{Kind: EntityTopic, Name: topic.Name})
+		for _, subscription := range topic.Subscriptions {
+			entities = append(entities, Entity{Kind: EntitySubscription, Name: subscription.Name, Topic: topic.Name})
+		}
+	}
+
+	return entities, nil
+}
+
+func (administrator *InMemoryAdministrator) ListSubscriptions(connection Connection, topic string) ([]string, error) {
+	entities, err := administrator.ListEntities(connection)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, entity := range entities {
+		if entity.Kind == EntitySubscription && entity.Topic == topic {
+			names = append(names, entity.Name)
+		}
+	}
+
+	return names, nil
+}
+
+func (administrator *InMemoryAdministrator) GetStatistics(connection Connection) ([]EntityStatistics, error) {
+	return append([]EntityStatistics{}, administrator.Statistics[connection.Namespace]...), nil
+}
+
+func (administrator *InMemoryAdministrator) topology(connection Connection) *Topology {
+	if administrator.Topologies == nil {
+		administrator.Topologies = make(map[string]*Topology)
+	}
+	if _, ok := administrator.Topologies[connection.Namespace]; !ok {
+		administrator.Topologies[connection.Namespace] = &Topology{}
+	}
+	return administrator.Topologies[connection.Namespace]
+}
+
+func (administrator *InMemoryAdministrator) GetQueue(connection Connection, name string) (*QueueDefinition, error) {
+	topology := administrator.topology(connection)
+	index := slices.IndexFunc(topology.Queues, func(queue QueueDefinition) bool { return queue.Name == name })
+	if index < 0 {
+		return nil, nil
+	}
+	queue := topology.Queues[index]
+	return &queue, nil
+}
+
+func (administrator *InMemoryAdministrator) CreateQueue(connection Connection, queue QueueDefinition) error {
+	if existing, _ := administrator.GetQueue(connection, queue.Name); existing != nil {
+		return fmt.Errorf("Queue already exists: %v", queue.Name)
+	}
+	topology := administrator.topology(connection)
+	topology.Queues = append(topology.Queues, queue)
+	return nil
+}
+
+func (administrator *InMemoryAdministrator) UpdateQueue(connection Connection, queue QueueDefinition) error {
+	topology := administrator.topology(connection)
+	index := slices.IndexFunc(topology.Queues, func(existing QueueDefinition) bool { return existing.Name == queue.Name })
+	if index < 0 {
+		return fmt.Errorf("Can't find queue: %v", queue.Name)
+	}
+	topology.Queues[index] = queue
+	return nil
+}
+
+func (administrator *InMemoryAdministrator) findTopic(connection Connection, name string) *TopicDefinition {
+	topology := administrator.topology(connection)
+	index := slices.IndexFunc(topology.Topics, func(topic TopicDefinition) bool { return topic.Name == name })
+	if index < 0 {
+		return nil
+	}
+	return &topology.Topics[index]
+}
+
+func (administrator *InMemoryAdministrator) GetTopic(connection Connection, name string) (*TopicDefinition, error) {
+	topic := administrator.findTopic(connection, name)
+	if topic == nil {
+		return nil, nil
+	}
+	return &TopicDefinition{Name: topic.Name, DefaultMessageTimeToLive: topic.DefaultMessageTimeToLive}, nil
+}
+
+func (administrator *InMemoryAdministrator) CreateTopic(connection Connection, topic TopicDefinition) error {
+	if administrator.findTopic(connection, topic.Name) != nil {
+		return fmt.Errorf("Topic already exists: %v", topic.Name)
+	}
+	topology := administrator.topology(connection)
+	topology.Topics = append(topology.Topics, TopicDefinition{
+		Name:                     topic.Name,
+		DefaultMessageTimeToLive: topic.DefaultMessageTimeToLive,
+	})
+	return nil
+}
+
+func (administrator *InMemoryAdministrator) UpdateTopic(connection Connection, topic TopicDefinition) error {
+	existing := administrator.findTopic(connection, topic.Name)
+	if existing == nil {
+		return fmt.Errorf("Can't find topic: %v", topic.Name)
+	}
+	existing.DefaultMessageTimeToLive = topic.DefaultMessageTimeToLive
+	return nil
+}
+
+func (administrator *InMemoryAdministrator) findSubscription(
+	connection Connection,
+	topic string,
+	name string,
+) (*SubscriptionDefinition, error) {
+	existing := administrator.findTopic(connection, topic)
+	if existing == nil {
+		return nil, fmt.Errorf("Can't find topic: %v", topic)
+	}
+	index := slices.IndexFunc(existing.Subscriptions, func(subscription SubscriptionDefinition) bool {
+		return subscription.Name == name
+	})
+	if index < 0 {
+		return nil, nil
+	}
+	return &existing.Subscriptions[index], nil
+}
+
+func (administrator *InMemoryAdministrator) GetSubscription(
+	connection Connection,
+	topic string,
+	name string,
+) (*SubscriptionDefinition, error) {
+	subscription, err := administrator.findSubscription(connection, topic, name)
+	if subscription == nil || err != nil {
+		return nil, nil
+	}
+	found := *subscription
+	found.Rules = nil
+	return &found, nil
+}
+
+func (administrator *InMemoryAdministrator) CreateSubscription(
+	connection Connection,
+	topic string,
+	subscription SubscriptionDefinition,
+) error {
+	existing, err := administrator.findSubscription(connection, topic, subscription.Name)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("Subscription already exists: %v", subscription.Name)
+	}
+	parent := administrator.findTopic(connection, topic)
+	subscription.Rules = []RuleDefinition{{Name: DefaultRuleName, SQL: "1=1"}}
+	parent.Subscriptions = append(parent.Subscriptions, subscription)
+	return nil
+}
+
+func (administrator *InMemoryAdministrator) UpdateSubscription(
+	connection Connection,
+	topic string,
+	subscription SubscriptionDefinition,
+) error {
+	existing, err := administrator.findSubscription(connection, topic, subscription.Name)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return fmt.Errorf("Can't find subscription: %v", subscription.Name)
+	}
+	subscription.Rules = existing.Rules
+	*existing = subscription
+	return nil
+}
+
+func (administrator *InMemoryAdministrator) DeleteEntity(connection Connection, entity Entity) error {
+	topology := administrator.topology(connection)
+	deleted := false
+	switch entity.Kind {
+	case EntityQueue:
+		topology.Queues = slices.DeleteFunc(topology.Queues, func(queue QueueDefinition) bool {
+			deleted = deleted || queue.Name == entity.Name
+			return queue.Name == entity.Name
+		})
+	case EntityTopic:
+		topology.Topics = slices.DeleteFunc(topology.Topics, func(topic TopicDefinition) bool {
+			deleted = deleted || topic.Name == entity.Name
+			return topic.Name == entity.Name
+		})
+	case EntitySubscription:
+		if topic := administrator.findTopic(connection, entity.Topic); topic != nil {
+			topic.Subscriptions = slices.DeleteFunc(topic.Subscriptions, func(subscription SubscriptionDefinition) bool {
+				deleted = deleted || subscription.Name == entity.Name
+				return subscription.Name == entity.Name
+			})
+		}
+	}
+	if !deleted {
+		return fmt.Errorf("Can't find %v: %v", entity.Kind, entity.Path())
+	}
+	return nil
+}
+
+func (administrator *InMemoryAdministrator) ListRules(
+	connection Connection,
+	topic string,
+	subscription string,
+) ([]RuleDefinition, error) {
+	existing, err := administrator.existingSubscription(connection, topic, subscription)
+	if err != nil {
+		return nil, err
+	}
+	return slices.Clone(existing.Rules), nil
+}
+
+func (administrator *InMemoryAdministrator) CreateRule(
+	connection Connection,
+	topic string,
+	subscription string,
+	rule RuleDefinition,
+) error {
+	existing, err := administrator.existingSubscription(connection, topic, subscription)
+	if err != nil {
+		return err
+	}
+	if slices.ContainsFunc(existing.Rules, func(existingRule RuleDefinition) bool { return existingRule.Name == rule.Name }) {
+		return fmt.Errorf("Rule already exists: %v", rule.Name)
+	}
+	existing.Rules = append(existing.Rules, rule)
+	return nil
+}
+
+func (administrator *InMemoryAdministrator) UpdateRule(
+	connection Connection,
+	topic string,
+	subscription string,
+	rule RuleDefinition,
+) error {
+	existing, err := administrator.existingSubscription(connection, topic, subscription)
+	if err != nil {
+		return err
+	}
+	index := slices.IndexFunc(existing.Rules, func(existingRule RuleDefinition) bool { return existingRule.Name == rule.Name })
+	if index < 0 {
+		return fmt.Errorf("Can't find rule: %v", rule.Name)
+	}
+	existing.Rules[index] = rule
+	return nil
+}
+
+func (administrator *InMemoryAdministrator) DeleteRule(
+	connection Connection,
+	topic string,
+	subscription string,
+	name string,
+) error {
+	existing, err := administrator.existingSubscription(connection, topic, subscription)
+	if err != nil {
+		return err
+	}
+	index := slices.IndexFunc(existing.Rules, func(rule RuleDefinition) bool { return rule.Name == name })
+	if index < 0 {
+		return fmt.Errorf("Can't find rule: %v", name)
+	}
+	existing.Rules = slices.Delete(existing.Rules, index, index+1)
+	return nil
+}
+
+func (administrator *InMemoryAdministrator) existingSubscription(
+	connection Connection,
+	topic string,
+	subscription string,
+) (*SubscriptionDefinition, error) {
+	existing, err := administrator.findSubscription(connection, topic, subscription)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		return nil, fmt.Errorf("Can't find subscription: %v/subscriptions/%v", topic, subscription)
+	}
+	return existing, nil
+}
@@ -0,0 +1,286 @@
+package asb
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// InMemoryMessageReceiver serves messages stored per source. Sources with a SessionID hold the
+// messages of sessions, the next session is the first one, by ID, with messages.
+type InMemoryMessageReceiver struct {
+	Messages      map[Source][]ReceivedMessage
+	SessionStates map[Source][]byte
+	// Deferred holds the deferred messages per source
+	Deferred map[Source][]ReceivedMessage
+	// ReceiveError is returned by Receive and ReceiveMatching together with the removed messages
+	ReceiveError error
+
+	mutex sync.Mutex
+}
+
+// session finds the source of the next session, other sources are returned as they are
+func (messageReceiver *InMemoryMessageReceiver) session(source Source) Source {
+	if !source.NextSession {
+		return source
+	}
+
+	sessions := []Source{}
+	for candidate, messages := range messageReceiver.Messages {
+		if len(candidate.SessionID) > 0 && len(messages) > 0 &&
+			candidate.Destination == source.Destination && candidate.Subscription == source.Subscription {
+			sessions = append(sessions, candidate)
+		}
+	}
+	if len(sessions) == 0 {
+		return source
+	}
+
+	return slices.MinFunc(sessions, func(first Source, second Source) int {
+		return strings.Compare(first.SessionID, second.SessionID)
+	})
+}
+
+func (messageReceiver *InMemoryMessageReceiver) GetSessionState(connection Connection, source Source) (string, []byte, error) {
+	messageReceiver.mutex.Lock()
+	defer messageReceiver.mutex.Unlock()
+
+	source = messageReceiver.session(source)
+	if len(source.SessionID) == 0 {
+		return "", nil, errors.New("no session to get the state of")
+	}
+
+	return source.SessionID, messageReceiver.SessionStates[source], nil
+}
+
+func (messageReceiver *InMemoryMessageReceiver) SetSessionState(connection Connection, source Source, state []byte) error {
+	messageReceiver.mutex.Lock()
+	defer messageReceiver.mutex.Unlock()
+
+	source = messageReceiver.session(source)
+	if len(source.SessionID) == 0 {
+		return errors.New("no session to set the state of")
+	}
+	if messageReceiver.SessionStates == nil {
+		messageReceiver.SessionStates = make(map[Source][]byte)
+	}
+	if state == nil {
+		delete(messageReceiver.SessionStates, source)
+	} else {
+		messageReceiver.SessionStates[source] = state
+	}
+
+	return nil
+}
+
+// Add stores messages, it is safe to call while messages are being received
+func (messageReceiver *InMemoryMessageReceiver) Add(source Source, messages ...ReceivedMessage) {
+	messageReceiver.mutex.Lock()
+	defer messageReceiver.mutex.Unlock()
+
+	if messageReceiver.Messages == nil {
+		messageReceiver.Messages = make(map[Source][]ReceivedMessage)
+	}
+	messageReceiver.Messages[source] = append(messageReceiver.Messages[source], messages...)
+}
+
+func (messageReceiver *InMemoryMessageReceiver) Peek(
+	connection Connection,
+	source Source,
+	count int,
+	fromSequenceNumber int64,
+) ([]ReceivedMessage, error) {
+	messageReceiver.mutex.Lock()
+	defer messageReceiver.mutex.Unlock()
+
+	messages := []ReceivedMessage{}
+	for _, message := range messageReceiver.Messages[messageReceiver.session(source)] {
+		if len(messages) == count {
+			break
+		}
+		if message.SequenceNumber >= fromSequenceNumber {
+			messages = append(messages, message)
+		}
+	}
+
+	return messages, nil
+}
+
+func (messageReceiver *InMemoryMessageReceiver) Receive(
+	connection Connection,
+	source Source,
+	count int,
+	wait time.Duration,
+) ([]ReceivedMessage, error) {
+	messageReceiver.mutex.Lock()
+	defer messageReceiver.mutex.Unlock()
+
+	source = messageReceiver.session(source)
+	available := messageReceiver.Messages[source]
+	if len(available) == 0 {
+		return []ReceivedMessage{}, nil
+	}
+	count = min(count, len(available))
+	messageReceiver.Messages[source] = available[count:]
+
+	return available[:count], messageReceiver.ReceiveError
+}
+
+func (messageReceiver *InMemoryMessageReceiver) ReceiveMatching(
+	connection Connection,
+	source Source,
+	match func(ReceivedMessage) bool,
+	count int,
+	wait time.Duration,
+) ([]ReceivedMessage, error) {
+	messageReceiver.mutex.Lock()
+	defer messageReceiver.mutex.Unlock()
+
+	source = messageReceiver.session(source)
+	matching := []ReceivedMessage{}
+	remaining := []ReceivedMessage{}
+	for _, message := range messageReceiver.Messages[source] {
+		if len(matching) < count && match(message) {
+			matching = append(matching, message)
+		} else {
+			remaining = append(remaining, message)
+		}
+	}
+	if len(matching) > 0 {
+		messageReceiver.Messages[source] = remaining
+	}
+
+	return matching, messageReceiver.ReceiveError
+}
+
+// NewLockedReceiver locks messages by taking them out of the source, settling puts them where
+// the action leads: nowhere, back to the source, to Deferred or to the dead-letter queue
+func (messageReceiver *InMemoryMessageReceiver) NewLockedReceiver(connection Connection, source Source) (LockedReceiver, error) {
+	messageReceiver.mutex.Lock()
+	defer messageReceiver.mutex.Unlock()
+
+	return &inMemoryLockedReceiver{
+		owner:  messageReceiver,
+		source: messageReceiver.session(source),
+		locked: make(map[int64]inMemoryLockedMessage),
+	}, nil
+}
+
+type inMemoryLockedMessage struct {
+	message  ReceivedMessage
+	deferred bool
+}
+
+type inMemoryLockedReceiver struct {
+	owner  *InMemoryMessageReceiver
+	source Source
+	locked map[int64]inMemoryLockedMessage
+}
+
+func (lockedReceiver *inMemoryLockedReceiver) Receive(count int, wait time.Duration) ([]ReceivedMessage, error) {
+	messages, err := lockedReceiver.owner.Receive(Connection{}, lockedReceiver.source, count, wait)
+	for _, message := range messages {
+		lockedReceiver.locked[message.SequenceNumber] = inMemoryLockedMessage{message: message}
+	}
+
+	return messages, err
+}
+
+func (lockedReceiver *inMemoryLockedReceiver) ReceiveDeferred(sequenceNumbers []int64) ([]ReceivedMessage, error) {
+	owner := lockedReceiver.owner
+	owner.mutex.Lock()
+	defer owner.mutex.Unlock()
+
+	messages := []ReceivedMessage{}
+	remaining := []ReceivedMessage{}
+	for _, message := range owner.Deferred[lockedReceiver.source] {
+		if slices.Contains(sequenceNumbers, message.SequenceNumber) {
+			messages = append(messages, message)
+			lockedReceiver.locked[message.SequenceNumber] = inMemoryLockedMessage{message: message, deferred: true}
+		} else {
+			remaining = append(remaining, message)
+		}
+	}
+	if len(messages) > 0 {
+		owner.Deferred[lockedReceiver.source] = remaining
+	}
+
+	return messages, nil
+}
+
+func (lockedReceiver *inMemoryLockedReceiver) Settle(sequenceNumber int64, settlement Settlement) error {
+	locked, ok := lockedReceiver.locked[sequenceNumber]
+	if !ok {
+		return fmt.Errorf("message #%v isn't locked", sequenceNumber)
+	}
+	owner := lockedReceiver.owner
+	owner.mutex.Lock()
+	defer owner.mutex.Unlock()
+
+	message := locked.message
+	if len(settlement.Properties) > 0 {
+		message.CustomProperties = maps.Clone(message.CustomProperties)
+		if message.CustomProperties == nil {
+			message.CustomProperties = make(map[string]any)
+		}
+		maps.Copy(message.CustomProperties, settlement.Properties)
+	}
+
+	switch settlement.Action {
+	case SettleComplete:
+	case SettleAbandon:
+		message.DeliveryCount++
+		if locked.deferred {
+			owner.Deferred[lockedReceiver.source] = insertBySequenceNumber(owner.Deferred[lockedReceiver.source], message)
+		} else {
+			owner.Messages[lockedReceiver.source] = insertBySequenceNumber(owner.Messages[lockedReceiver.source], message)
+		}
+	case SettleDefer:
+		if owner.Deferred == nil {
+			owner.Deferred = make(map[Source][]ReceivedMessage)
+		}
+		owner.Deferred[lockedReceiver.source] = insertBySequenceNumber(owner.Deferred[lockedReceiver.source], message)
+	case SettleDeadLetter:
+		message.DeadLetterReason = settlement.Reason
+		message.DeadLetterDescription = settlement.Description
+		deadLetter := Source{
+			Destination:  lockedReceiver.source.Destination,
+			Subscription: lockedReceiver.source.Subscription,
+			DeadLetter:   true,
+		}
+		if owner.Messages == nil {
+			owner.Messages = make(map[Source][]ReceivedMessage)
+		}
+		owner.Messages[deadLetter] = append(owner.Messages[deadLetter], message)
+	default:
+		return fmt.Errorf("unknown settlement action '%v'", settlement.Action)
+	}
+	delete(lockedReceiver.locked, sequenceNumber)
+
+	return nil
+}
+
+func (lockedReceiver *inMemoryLockedReceiver) Close() error {
+	for sequenceNumber := range lockedReceiver.locked {
+		err := lockedReceiver.Settle(sequenceNumber, Settlement{Action: SettleAbandon})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// insertBySequenceNumber keeps the messages ordered by their sequence numbers
+func insertBySequenceNumber(messages []ReceivedMessage, message ReceivedMessage) []ReceivedMessage {
+	index, _ := slices.BinarySearchFunc(messages, message.SequenceNumber, func(candidate ReceivedMessage, sequenceNumber int64) int {
+		return cmp.Compare(candidate.SequenceNumber, sequenceNumber)
+	})
+
+	return slices.Insert(messages, index, message)
+}
@@ -1,20 +1,22 @@
 package asb
 
 type InMemoryMessageSender struct {
+	Connection  Connection
 	Namespace   string
 	Destination string
 	Message     Message
 }
 
 func (messageSender *InMemoryMessageSender) Send(
-	namespace string,
+	connection Connection,
 	destination string,
 	message Message,
 ) error {
 
-    messageSender.Namespace = namespace
-    messageSender.Destination = destination
-    messageSender.Message = message
+	messageSender.Connection = connection
+	messageSender.Namespace = connection.Namespace
+	messageSender.Destination = destination
+	messageSender.Message = message
 
 	return nil
 }
@@ -3,6 +3,7 @@ package asb
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"text/template"
 	"time"
 
@@ -17,6 +18,11 @@ type Message struct {
 	MessageID     string `json:"messageId"`
 	ReplayTo      string `json:"replyTo"`
 	Subject       string `json:"subject"`
+	// ReplyToSessionID is the session of the ReplyTo entity replies should be sent to
+	ReplyToSessionID string `json:"replyToSessionId,omitempty"`
+	// SessionID is the session of the message, required by session-enabled entities
+	SessionID   string `json:"sessionId,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
 
 	CustomProperties map[string]any `json:"customProperties"`
 }
@@ -32,20 +38,61 @@ func (msg *Message) Print() string {
 }
 
 func (msg *Message) TransformBody() (string, error) {
+	return msg.TransformBodyWith(nil)
+}
 
-	t := template.Must(template.New("example").Funcs(template.FuncMap{
-		"utcNow": func() string { return time.Now().UTC().Format(time.RFC3339) },
-		"utcNowPlus": func(minutes int) string {
-			return time.Now().UTC().Add(time.Duration(minutes) * time.Minute).Format(time.RFC3339)
-		},
-		"generateUUID": func() string { return uuid.New().String() },
-	}).Parse(msg.Body))
+// TransformBodyWith renders the body, {{ var "name" "default" }} is replaced by the variable, or
+// the default when the variable isn't given
+func (msg *Message) TransformBodyWith(variables map[string]string) (string, error) {
+
+	t, err := msg.parseBody(variables)
+	if err != nil {
+		return "", err
+	}
 
 	var output bytes.Buffer
-	err := t.Execute(&output, nil)
+	err = t.Execute(&output, nil)
 	if err != nil {
 		return "", err
 	}
 
 	return output.String(), nil
 }
+
+// ParsePropertyValue reads a custom property value given as text. Values that are
+// JSON numbers or booleans keep their type, quoted values and everything else are strings.
+func ParsePropertyValue(text string) any {
+	var value any
+	if err := json.Unmarshal([]byte(text), &value); err == nil {
+		switch value.(type) {
+		case string, bool, float64:
+			return value
+		}
+	}
+	return text
+}
+
+// ValidateBody checks that the body is a valid template without rendering it
+func (msg *Message) ValidateBody() error {
+	_, err := msg.parseBody(nil)
+	return err
+}
+
+func (msg *Message) parseBody(variables map[string]string) (*template.Template, error) {
+	return template.New("example").Funcs(template.FuncMap{
+		"utcNow": func() string { return time.Now().UTC().Format(time.RFC3339) },
+		"utcNowPlus": func(minutes int) string {
+			return time.Now().UTC().Add(time.Duration(minutes) * time.Minute).Format(time.RFC3339)
+		},
+		"generateUUID": func() string { return uuid.New().String() },
+		"var": func(name string, defaults ...string) (string, error) {
+			if value, ok := variables[name]; ok {
+				return value, nil
+			}
+			if len(defaults) == 0 {
+				return "", fmt.Errorf("variable '%v' is not defined", name)
+			}
+			return defaults[0], nil
+		},
+	}).Parse(msg.Body)
+}
@@ -0,0 +1,67 @@
+package asb
+
+import (
+	"time"
+)
+
+// Source points to the entity messages are received from
+type Source struct {
+	// Queue or topic name
+	Destination string
+	// Subscription of the topic, empty for queues
+	Subscription string
+	// DeadLetter selects the dead-letter sub-queue of the entity
+	DeadLetter bool
+	// SessionID selects the session of a session-enabled entity
+	SessionID string
+	// NextSession accepts the next available session of a session-enabled entity, the messages
+	// tell which one it was
+	NextSession bool
+}
+
+func (source Source) String() string {
+	path := source.Destination
+	if len(source.Subscription) > 0 {
+		path += "/subscriptions/" + source.Subscription
+	}
+	if source.DeadLetter {
+		path += "/$deadletterqueue"
+	}
+	if len(source.SessionID) > 0 {
+		path += " (session " + source.SessionID + ")"
+	}
+	if source.NextSession {
+		path += " (next session)"
+	}
+	return path
+}
+
+type ReceivedMessage struct {
+	Message
+
+	//System properties
+	SequenceNumber        int64     `json:"sequenceNumber"`
+	EnqueuedTime          time.Time `json:"enqueuedTime"`
+	DeliveryCount         uint32    `json:"deliveryCount"`
+	DeadLetterReason      string    `json:"deadLetterReason,omitempty"`
+	DeadLetterDescription string    `json:"deadLetterDescription,omitempty"`
+	DeadLetterSource      string    `json:"deadLetterSource,omitempty"`
+}
+
+type MessageReceiver interface {
+	// Peek returns messages without locking or removing them, starting from the
+	// given sequence number (or the oldest message when it is 0)
+	Peek(connection Connection, source Source, count int, fromSequenceNumber int64) ([]ReceivedMessage, error)
+	// Receive removes up to count messages, waiting at most wait for them to arrive
+	Receive(connection Connection, source Source, count int, wait time.Duration) ([]ReceivedMessage, error)
+	// ReceiveMatching removes up to count messages accepted by match, waiting at most wait for them.
+	// Other messages are left in the entity.
+	ReceiveMatching(connection Connection, source Source, match func(ReceivedMessage) bool, count int, wait time.Duration) ([]ReceivedMessage, error)
+	// GetSessionState returns the ID of the accepted session of the source and its state, nil when
+	// it has none
+	GetSessionState(connection Connection, source Source) (string, []byte, error)
+	// SetSessionState replaces the state of the source's session, nil clears it
+	SetSessionState(connection Connection, source Source, state []byte) error
+	// NewLockedReceiver opens a receiver keeping the received messages locked until they are settled
+	NewLockedReceiver(connection Connection, source Source) (LockedReceiver, error)
+}
@@ -0,0 +1,141 @@
+package asb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/messaging/azservicebus"
+)
+
+const (
+	SettleComplete   = "complete"
+	SettleAbandon    = "abandon"
+	SettleDefer      = "defer"
+	SettleDeadLetter = "dead-letter"
+)
+
+// SettleActions lists the actions a locked message can be settled with
+var SettleActions = []string{SettleComplete, SettleAbandon, SettleDefer, SettleDeadLetter}
+
+// Settlement tells what to do with a locked message
+type Settlement struct {
+	Action string
+	// Properties modify the custom properties of abandoned, deferred and dead-lettered messages
+	Properties map[string]any
+	// Reason and Description are set on dead-lettered messages
+	Reason      string
+	Description string
+}
+
+// LockedReceiver receives messages in peek-lock mode and keeps them locked until they are settled
+type LockedReceiver interface {
+	// Receive locks up to count messages, waiting at most wait for them to arrive
+	Receive(count int, wait time.Duration) ([]ReceivedMessage, error)
+	// ReceiveDeferred locks the deferred messages with the given sequence numbers
+	ReceiveDeferred(sequenceNumbers []int64) ([]ReceivedMessage, error)
+	// Settle settles the locked message with the sequence number
+	Settle(sequenceNumber int64, settlement Settlement) error
+	// Close abandons the messages that weren't settled
+	Close() error
+}
+
+// NewLockedReceiver opens a peek-lock receiver of the source, sessions are accepted once and kept
+// until the receiver is closed
+func (messageReceiver *AsbMessageReceiver) NewLockedReceiver(connection Connection, source Source) (LockedReceiver, error) {
+	receiver, err := messageReceiver.newReceiver(connection, source, azservicebus.ReceiveModePeekLock)
+	if err != nil {
+		return nil, err
+	}
+
+	return &asbLockedReceiver{receiver: receiver, locked: make(map[int64]*azservicebus.ReceivedMessage)}, nil
+}
+
+type asbLockedReceiver struct {
+	receiver entityReceiver
+	locked   map[int64]*azservicebus.ReceivedMessage
+}
+
+func (lockedReceiver *asbLockedReceiver) lock(received []*azservicebus.ReceivedMessage) []ReceivedMessage {
+	messages := []ReceivedMessage{}
+	for _, message := range received {
+		converted := fromReceivedMessage(message)
+		lockedReceiver.locked[converted.SequenceNumber] = message
+		messages = append(messages, converted)
+	}
+
+	return messages
+}
+
+func (lockedReceiver *asbLockedReceiver) Receive(count int, wait time.Duration) ([]ReceivedMessage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), wait)
+	defer cancel()
+
+	received, err := lockedReceiver.receiver.ReceiveMessages(ctx, count, nil)
+	messages := lockedReceiver.lock(received)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return messages, nil
+	}
+
+	return messages, err
+}
+
+func (lockedReceiver *asbLockedReceiver) ReceiveDeferred(sequenceNumbers []int64) ([]ReceivedMessage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), peekTimeout)
+	defer cancel()
+
+	received, err := lockedReceiver.receiver.ReceiveDeferredMessages(ctx, sequenceNumbers, nil)
+
+	return lockedReceiver.lock(received), err
+}
+
+func (lockedReceiver *asbLockedReceiver) Settle(sequenceNumber int64, settlement Settlement) error {
+	message, ok := lockedReceiver.locked[sequenceNumber]
+	if !ok {
+		return fmt.Errorf("message #%v isn't locked", sequenceNumber)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), peekTimeout)
+	defer cancel()
+
+	var err error
+	switch settlement.Action {
+	case SettleComplete:
+		err = lockedReceiver.receiver.CompleteMessage(ctx, message, nil)
+	case SettleAbandon:
+		err = lockedReceiver.receiver.AbandonMessage(ctx, message, &azservicebus.AbandonMessageOptions{
+			PropertiesToModify: settlement.Properties,
+		})
+	case SettleDefer:
+		err = lockedReceiver.receiver.DeferMessage(ctx, message, &azservicebus.DeferMessageOptions{
+			PropertiesToModify: settlement.Properties,
+		})
+	case SettleDeadLetter:
+		options := &azservicebus.DeadLetterOptions{PropertiesToModify: settlement.Properties}
+		if len(settlement.Reason) > 0 {
+			options.Reason = &settlement.Reason
+		}
+		if len(settlement.Description) > 0 {
+			options.ErrorDescription = &settlement.Description
+		}
+		err = lockedReceiver.receiver.DeadLetterMessage(ctx, message, options)
+	default:
+		return fmt.Errorf("unknown settlement action '%v'", settlement.Action)
+	}
+	if err != nil {
+		return err
+	}
+	delete(lockedReceiver.locked, sequenceNumber)
+
+	return nil
+}
+
+func (lockedReceiver *asbLockedReceiver) Close() error {
+	for _, message := range lockedReceiver.locked {
+		lockedReceiver.receiver.AbandonMessage(context.TODO(), message, nil)
+	}
+	lockedReceiver.locked = nil
+
+	return lockedReceiver.receiver.Close(context.TODO())
+}
@@ -0,0 +1,31 @@
+package asb
+
+import (
+	"maps"
+	"regexp"
+	"strings"
+)
+
+var (
+	uuidPattern      = regexp.MustCompile(`\b[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}\b`)
+	timestampPattern = regexp.MustCompile(`\b\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})`)
+)
+
+// NewTemplate converts a received message into a message that can be saved and sent again.
+// The body is escaped, so it renders to itself. With templatize the UUIDs and RFC 3339 timestamps
+// in the body are replaced by the generateUUID and utcNow functions and the message ID is
+// cleared, so every sent copy gets new ones.
+func NewTemplate(received ReceivedMessage, templatize bool) Message {
+	message := received.Message
+	message.CustomProperties = maps.Clone(received.CustomProperties)
+
+	// Escape the template delimiters before adding the functions
+	message.Body = strings.ReplaceAll(message.Body, "{{", `{{"{{"}}`)
+	if templatize {
+		message.Body = uuidPattern.ReplaceAllLiteralString(message.Body, "{{generateUUID}}")
+		message.Body = timestampPattern.ReplaceAllLiteralString(message.Body, "{{utcNow}}")
+		message.MessageID = ""
+	}
+
+	return message
+}
@@ -0,0 +1,52 @@
+package asb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func createTestReceivedMessage() ReceivedMessage {
+	return ReceivedMessage{
+		Message: Message{
+			Body:             `{"id": "69a17b86-68d7-4e59-bb2f-09b3590135c8", "at": "2024-10-06T19:34:39.123Z", "note": "{{not a template}}"}`,
+			MessageID:        "69a17b86-68d7-4e59-bb2f-09b3590135c8",
+			Subject:          "created",
+			CustomProperties: map[string]any{"tenant": "acme"},
+		},
+		SequenceNumber: 12,
+	}
+}
+
+func Test_NewTemplate_Should_Render_To_Received_Body(t *testing.T) {
+	received := createTestReceivedMessage()
+
+	message := NewTemplate(received, false)
+	body, err := message.TransformBody()
+
+	assert.NoError(t, err)
+	assert.Equal(t, received.Body, body)
+	assert.Equal(t, received.MessageID, message.MessageID)
+	assert.Equal(t, "created", message.Subject)
+	assert.Equal(t, map[string]any{"tenant": "acme"}, message.CustomProperties)
+}
+
+func Test_NewTemplate_Should_Replace_Ids_And_Timestamps(t *testing.T) {
+	message := NewTemplate(createTestReceivedMessage(), true)
+
+	assert.Equal(t, `{"id": "{{generateUUID}}", "at": "{{utcNow}}", "note": "{{"{{"}}not a template}}"}`, message.Body)
+	assert.Empty(t, message.MessageID)
+	body, err := message.TransformBody()
+	assert.NoError(t, err)
+	assert.Contains(t, body, `"note": "{{not a template}}"`)
+	assert.NotContains(t, body, "69a17b86-68d7-4e59-bb2f-09b3590135c8")
+}
+
+func Test_NewTemplate_Should_Not_Share_Properties(t *testing.T) {
+	received := createTestReceivedMessage()
+
+	message := NewTemplate(received, false)
+	message.CustomProperties["tenant"] = "contoso"
+
+	assert.Equal(t, "acme", received.CustomProperties["tenant"])
+}
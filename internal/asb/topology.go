@@ -0,0 +1,251 @@
+package asb
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Topology is a set of entities that can be applied to any namespace
+type Topology struct {
+	Queues []QueueDefinition `json:"queues,omitempty"`
+	Topics []TopicDefinition `json:"topics,omitempty"`
+}
+
+// QueueDefinition holds the properties of a queue. Empty durations and counts keep the current
+// (or default) values. Durations use Go's format, e.g. 30s or 24h.
+type QueueDefinition struct {
+	Name                             string `json:"name"`
+	LockDuration                     string `json:"lockDuration,omitempty"`
+	DefaultMessageTimeToLive         string `json:"defaultMessageTimeToLive,omitempty"`
+	MaxDeliveryCount                 int32  `json:"maxDeliveryCount,omitempty"`
+	RequiresSession                  bool   `json:"requiresSession,omitempty"`
+	DeadLetteringOnMessageExpiration bool   `json:"deadLetteringOnMessageExpiration,omitempty"`
+	ForwardTo                        string `json:"forwardTo,omitempty"`
+}
+
+type TopicDefinition struct {
+	Name                     string                   `json:"name"`
+	DefaultMessageTimeToLive string                   `json:"defaultMessageTimeToLive,omitempty"`
+	Subscriptions            []SubscriptionDefinition `json:"subscriptions,omitempty"`
+}
+
+type SubscriptionDefinition struct {
+	Name                             string `json:"name"`
+	LockDuration                     string `json:"lockDuration,omitempty"`
+	DefaultMessageTimeToLive         string `json:"defaultMessageTimeToLive,omitempty"`
+	MaxDeliveryCount                 int32  `json:"maxDeliveryCount,omitempty"`
+	RequiresSession                  bool   `json:"requiresSession,omitempty"`
+	DeadLetteringOnMessageExpiration bool   `json:"deadLetteringOnMessageExpiration,omitempty"`
+	ForwardTo                        string `json:"forwardTo,omitempty"`
+	// Rules replace all rules of the subscription, including $Default, when given
+	Rules []RuleDefinition `json:"rules,omitempty"`
+}
+
+// DefaultRuleName is the name of the rule that subscriptions are created with
+const DefaultRuleName = "$Default"
+
+// RuleDefinition is a subscription rule with either a SQL or a correlation filter
+type RuleDefinition struct {
+	Name string `json:"name"`
+	// SQL filter expression, e.g. subject = 'created' AND tenant = 'acme'
+	SQL         string             `json:"sql,omitempty"`
+	Correlation *CorrelationFilter `json:"correlation,omitempty"`
+	// Action is an optional SQL action, e.g. SET priority = 'high'
+	Action string `json:"action,omitempty"`
+}
+
+// CorrelationFilter matches messages whose properties equal all the given values
+type CorrelationFilter struct {
+	CorrelationID    string         `json:"correlationId,omitempty"`
+	MessageID        string         `json:"messageId,omitempty"`
+	To               string         `json:"to,omitempty"`
+	ReplyTo          string         `json:"replyTo,omitempty"`
+	Subject          string         `json:"subject,omitempty"`
+	SessionID        string         `json:"sessionId,omitempty"`
+	ReplyToSessionID string         `json:"replyToSessionId,omitempty"`
+	ContentType      string         `json:"contentType,omitempty"`
+	Properties       map[string]any `json:"properties,omitempty"`
+}
+
+func (queue QueueDefinition) Validate() error {
+	return validateEntity(queue.Name, queue.LockDuration, queue.DefaultMessageTimeToLive, queue.MaxDeliveryCount)
+}
+
+func (topic TopicDefinition) Validate() error {
+	return validateEntity(topic.Name, "", topic.DefaultMessageTimeToLive, 0)
+}
+
+func (subscription SubscriptionDefinition) Validate() error {
+	return validateEntity(
+		subscription.Name,
+		subscription.LockDuration,
+		subscription.DefaultMessageTimeToLive,
+		subscription.MaxDeliveryCount,
+	)
+}
+
+func (rule RuleDefinition) Validate() error {
+	if len(strings.TrimSpace(rule.Name)) == 0 {
+		return errors.New("rule name is required")
+	}
+	if len(rule.SQL) > 0 && rule.Correlation != nil {
+		return errors.New("rule can't have both a SQL and a correlation filter")
+	}
+	if len(strings.TrimSpace(rule.SQL)) == 0 && rule.Correlation == nil {
+		return errors.New("rule needs a SQL or a correlation filter")
+	}
+	if rule.Correlation != nil && rule.Correlation.IsEmpty() {
+		return errors.New("correlation filter needs at least one property")
+	}
+
+	return nil
+}
+
+func (filter CorrelationFilter) IsEmpty() bool {
+	return len(filter.CorrelationID+filter.MessageID+filter.To+filter.ReplyTo+filter.Subject+
+		filter.SessionID+filter.ReplyToSessionID+filter.ContentType) == 0 && len(filter.Properties) == 0
+}
+
+// FilterText describes the filter of the rule, e.g. for lists
+func (rule RuleDefinition) FilterText() string {
+	if rule.Correlation == nil {
+		return rule.SQL
+	}
+
+	correlation := rule.Correlation
+	terms := []string{}
+	for _, term := range []struct{ name, value string }{
+		{"correlationId", correlation.CorrelationID},
+		{"messageId", correlation.MessageID},
+		{"to", correlation.To},
+		{"replyTo", correlation.ReplyTo},
+		{"subject", correlation.Subject},
+		{"sessionId", correlation.SessionID},
+		{"replyToSessionId", correlation.ReplyToSessionID},
+		{"contentType", correlation.ContentType},
+	} {
+		if len(term.value) > 0 {
+			terms = append(terms, term.name+"="+term.value)
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(correlation.Properties)) {
+		terms = append(terms, fmt.Sprintf("app.%v=%v", key, correlation.Properties[key]))
+	}
+
+	return "correlation: " + strings.Join(terms, ", ")
+}
+
+// Set sets a property given as name=value. Names are the ones used by FilterText (case doesn't
+// matter), application properties are prefixed with app. and read with ParsePropertyValue.
+func (filter *CorrelationFilter) Set(term string) error {
+	name, value, ok := strings.Cut(term, "=")
+	name = strings.TrimSpace(name)
+	if !ok || len(name) == 0 {
+		return fmt.Errorf("expected name=value, got '%v'", term)
+	}
+	value = strings.TrimSpace(value)
+
+	if key, ok := strings.CutPrefix(name, "app."); ok {
+		if filter.Properties == nil {
+			filter.Properties = make(map[string]any)
+		}
+		filter.Properties[key] = ParsePropertyValue(value)
+		return nil
+	}
+	fields := map[string]*string{
+		"correlationid":    &filter.CorrelationID,
+		"messageid":        &filter.MessageID,
+		"to":               &filter.To,
+		"replyto":          &filter.ReplyTo,
+		"subject":          &filter.Subject,
+		"sessionid":        &filter.SessionID,
+		"replytosessionid": &filter.ReplyToSessionID,
+		"contenttype":      &filter.ContentType,
+	}
+	field, ok := fields[strings.ToLower(name)]
+	if !ok {
+		return fmt.Errorf("unknown property '%v', prefix application properties with app.", name)
+	}
+	*field = value
+
+	return nil
+}
+
+func validateEntity(name string, lockDuration string, timeToLive string, maxDeliveryCount int32) error {
+	if len(strings.TrimSpace(name)) == 0 {
+		return errors.New("name is required")
+	}
+	if len(lockDuration) > 0 {
+		if _, err := time.ParseDuration(lockDuration); err != nil {
+			return fmt.Errorf("invalid lock duration: %w", err)
+		}
+	}
+	if len(timeToLive) > 0 {
+		if _, err := time.ParseDuration(timeToLive); err != nil {
+			return fmt.Errorf("invalid default message time to live: %w", err)
+		}
+	}
+	if maxDeliveryCount < 0 {
+		return errors.New("max delivery count can't be negative")
+	}
+
+	return nil
+}
+
+// toISODuration converts a Go duration to the ISO 8601 format used by the administration API
+func toISODuration(duration string) *string {
+	if len(duration) == 0 {
+		return nil
+	}
+	parsed, err := time.ParseDuration(duration)
+	if err != nil {
+		return nil
+	}
+	iso := "PT" + strconv.FormatFloat(parsed.Seconds(), 'f', -1, 64) + "S"
+
+	return &iso
+}
+
+var isoDurationPattern = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+
+// fromISODuration converts an ISO 8601 duration to Go's format. Durations too long for Go,
+// like the default time to live, are returned as an empty string.
+func fromISODuration(iso *string) string {
+	if iso == nil {
+		return ""
+	}
+	parts := isoDurationPattern.FindStringSubmatch(*iso)
+	if parts == nil {
+		return ""
+	}
+
+	seconds := 0.0
+	for i, unit := range []float64{24 * 60 * 60, 60 * 60, 60, 1} {
+		if len(parts[i+1]) > 0 {
+			value, _ := strconv.ParseFloat(parts[i+1], 64)
+			seconds += value * unit
+		}
+	}
+	if seconds*float64(time.Second) > float64(1<<62) {
+		return ""
+	}
+
+	return (time.Duration(seconds * float64(time.Second))).String()
+}
+
+// SameDuration tells whether the Go durations are equal, empty ones are only equal to empty ones
+func SameDuration(first string, second string) bool {
+	firstParsed, firstErr := time.ParseDuration(first)
+	secondParsed, secondErr := time.ParseDuration(second)
+	if firstErr != nil || secondErr != nil {
+		return first == second
+	}
+
+	return firstParsed == secondParsed
+}
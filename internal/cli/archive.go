@@ -0,0 +1,210 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/rafalpienkowski/busgopher/internal/archive"
+	"github.com/rafalpienkowski/busgopher/internal/asb"
+)
+
+func exportCommand() command {
+	return command{
+		name:        "export",
+		usage:       "--conn <connection> --dest <queue|topic> [--sub <subscription>] [--dlq] --out <file|dir|-> [flags]",
+		description: "Save messages with all their properties to a JSON Lines file or a directory",
+		define:      defineExport,
+	}
+}
+
+func importCommand() command {
+	return command{
+		name:        "import",
+		usage:       "--conn <connection>|--namespace <namespace> --dest <destination> --in <file|dir|-> [flags]",
+		description: "Send messages saved by export to the destination",
+		define:      defineImport,
+	}
+}
+
+type exportResult struct {
+	Source string `json:"source"`
+	Path   string `json:"path"`
+	Format string `json:"format"`
+	Count  int    `json:"count"`
+}
+
+func (result exportResult) printText(env *environment) {
+	// The messages themselves were written to stdout
+	if result.Path == "-" {
+		return
+	}
+	fmt.Fprintf(env.stdout, "Exported %v message(s) from %v to %v\n", result.Count, result.Source, result.Path)
+}
+
+type importResult struct {
+	Destination string   `json:"destination"`
+	Count       int      `json:"count"`
+	MessageIDs  []string `json:"messageIds"`
+}
+
+func (result importResult) printText(env *environment) {
+	fmt.Fprintf(env.stdout, "Imported %v message(s) to %v\n", result.Count, result.Destination)
+}
+
+func defineExport(env *environment, _ string) (*flag.FlagSet, execute) {
+	options := &options{}
+	flags := newFlagSet(env, exportCommand(), options)
+	options.addConnectionFlags(flags)
+	flags.StringVar(&options.subscription, "sub", "", "Subscription of the topic given in --dest")
+	flags.IntVar(&options.count, "count", 100, "Maximum number of messages")
+	deadLetter := flags.Bool("dlq", false, "Export the dead-letter queue")
+	receive := flags.Bool("receive", false, "Receive and remove the messages instead of peeking them")
+	fromSequenceNumber := flags.Int64("from-seq", 0, "Sequence number to start peeking from")
+	flags.DurationVar(&options.wait, "wait", 5*time.Second, "How long to wait for messages when receiving")
+	out := flags.String("out", "", "JSON Lines file or directory to write, - writes JSON Lines to stdout")
+	format := flags.String("format", archive.FormatJsonLines, "Archive format: jsonl or dir")
+	messageFilter := addFilterFlags(flags)
+
+	return flags, func(args []string) (result, error) {
+		err := parse(flags, args, "conn", "dest", "out")
+		if err != nil {
+			return nil, err
+		}
+		// Received messages are removed, the names must be exact
+		options.exact = *receive
+		err = validateFilter(messageFilter)
+		if err != nil {
+			return nil, err
+		}
+		if *format != archive.FormatJsonLines && *format != archive.FormatDirectory {
+			return nil, usageError{message: "invalid format '" + *format + "', use jsonl or dir"}
+		}
+		if *out == "-" && (*format != archive.FormatJsonLines || env.output == outputJson) {
+			return nil, usageError{message: "--out - writes JSON Lines and can't be combined with --format dir or --output json"}
+		}
+
+		controller, err := env.newConnectedController(options)
+		if err != nil {
+			return nil, err
+		}
+
+		// The output is opened before received messages are removed, so they aren't lost when it can't be written
+		var file *os.File
+		switch {
+		case *out == "-":
+		case *format == archive.FormatDirectory:
+			err = archive.PrepareDirectory(*out)
+		default:
+			file, err = os.Create(*out)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if file != nil {
+			defer file.Close()
+		}
+
+		source := asb.Source{
+			Destination:  options.destination,
+			Subscription: options.subscription,
+			DeadLetter:   *deadLetter,
+		}
+		var messages []asb.ReceivedMessage
+		if *receive {
+			messages, err = controller.ReceiveMatching(source, options.count, options.wait, *messageFilter)
+		} else {
+			messages, err = controller.PeekMatching(source, options.count, *fromSequenceNumber, *messageFilter)
+		}
+		// Received messages are already removed, they are exported before failing
+		receiveErr := err
+		if receiveErr != nil && len(messages) == 0 {
+			return nil, codedError{code: "receive_failed", err: receiveErr}
+		}
+
+		switch {
+		case *out == "-":
+			err = archive.WriteJsonLines(env.stdout, messages)
+		case *format == archive.FormatDirectory:
+			err = archive.WriteDirectory(*out, messages)
+		default:
+			err = archive.WriteJsonLines(file, messages)
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		if err != nil {
+			// Received messages are only left in the log
+			if *receive && len(messages) > 0 {
+				env.writeLog(fmt.Sprintf("Writing %v failed, the %v received message(s) follow as JSON Lines", *out, len(messages)))
+				archive.WriteJsonLines(env.stderr, messages)
+			}
+			return nil, err
+		}
+
+		exported := exportResult{Source: source.String(), Path: *out, Format: *format, Count: len(messages)}
+		if receiveErr != nil {
+			return exported, codedError{code: "receive_failed", err: receiveErr}
+		}
+		return exported, nil
+	}
+}
+
+func defineImport(env *environment, _ string) (*flag.FlagSet, execute) {
+	options := &options{}
+	flags := newFlagSet(env, importCommand(), options)
+	options.addConnectionFlags(flags)
+	namespace := flags.String("namespace", "", "Namespace to use instead of a saved connection")
+	in := flags.String("in", "", "JSON Lines file or directory written by export, - reads JSON Lines from stdin")
+	newIDs := flags.Bool("new-ids", false, "Generate new message IDs instead of keeping the exported ones")
+	messageFilter := addFilterFlags(flags)
+
+	return flags, func(args []string) (result, error) {
+		err := parse(flags, args, "dest", "in")
+		if err != nil {
+			return nil, err
+		}
+		if (len(options.connection) == 0) == (len(*namespace) == 0) {
+			return nil, usageError{message: "one of --conn or --namespace is required"}
+		}
+		err = validateFilter(messageFilter)
+		if err != nil {
+			return nil, err
+		}
+
+		var messages []asb.ReceivedMessage
+		if *in == "-" {
+			messages, err = archive.ReadJsonLines(env.stdin)
+		} else {
+			messages, err = archive.Read(*in)
+		}
+		if err != nil {
+			return nil, codedError{code: "invalid_archive", err: err}
+		}
+
+		ctrl, err := env.newController(options)
+		if err != nil {
+			return nil, err
+		}
+		connection, destination, err := selectTarget(ctrl, options.connection, *namespace, options.destination)
+		if err != nil {
+			return nil, err
+		}
+
+		imported := importResult{Destination: destination, MessageIDs: []string{}}
+		for _, message := range messageFilter.Apply(messages) {
+			if *newIDs {
+				message.MessageID = ""
+			}
+			sent, err := ctrl.SendTo(connection, destination, message.Message)
+			if err != nil {
+				return imported, codedError{code: "send_failed", err: err}
+			}
+			imported.Count++
+			imported.MessageIDs = append(imported.MessageIDs, sent.MessageID)
+		}
+
+		return imported, nil
+	}
+}
@@ -0,0 +1,449 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rafalpienkowski/busgopher/internal/asb"
+	"github.com/rafalpienkowski/busgopher/internal/config"
+	"github.com/rafalpienkowski/busgopher/internal/controller"
+	"github.com/rafalpienkowski/busgopher/internal/history"
+)
+
+// Exit codes returned by Run
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+// environment holds everything the commands need to talk to the outside world
+type environment struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+
+	newConfigStorage func(path string) config.ConfigStorage
+	// readConfig reads the config without creating or changing the file, for completion
+	readConfig func(path string) (config.Config, error)
+	// newHistoryStorage returns the storage of the sent messages kept next to the config
+	newHistoryStorage func(configPath string) history.Storage
+	messageSender     asb.MessageSender
+	messageReceiver   asb.MessageReceiver
+	administrator     asb.Administrator
+
+	// output is set by the --output flag of the running command
+	output string
+}
+
+const (
+	outputText = "text"
+	outputJson = "json"
+)
+
+type command struct {
+	name        string
+	usage       string
+	description string
+	// streaming commands print results while running, with --output json only failures get the envelope
+	streaming bool
+	// subcommands are the choices of the argument given before the flags, see subcommand
+	subcommands []string
+	// define registers the flags of the command (and its subcommand) without running it,
+	// completion reads the flags from it
+	define func(env *environment, subcommand string) (*flag.FlagSet, execute)
+}
+
+// execute parses the flags registered by define from the args and runs the command
+type execute func(args []string) (result, error)
+
+// result is returned by a command and printed as text or, with --output json, as JSON
+type result interface {
+	printText(env *environment)
+}
+
+// usageError is returned when the command was invoked with wrong arguments
+type usageError struct {
+	message string
+}
+
+func (err usageError) Error() string {
+	return err.message
+}
+
+// codedError gives a failure a more specific code in the JSON output
+type codedError struct {
+	code string
+	err  error
+}
+
+func (err codedError) Error() string {
+	return err.err.Error()
+}
+
+func (err codedError) Unwrap() error {
+	return err.err
+}
+
+func commands() []command {
+	return []command{
+		sendCommand(),
+		peekCommand(),
+		receiveCommand(),
+		dlqCommand(),
+		sessionCommand(),
+		settleCommand(),
+		tailCommand(),
+		exportCommand(),
+		importCommand(),
+		purgeCommand(),
+		statsCommand(),
+		createCommand(),
+		deleteCommand(),
+		ruleCommand(),
+		applyCommand(),
+		listCommand(),
+		renderCommand(),
+		runCommand(),
+		validateCommand(),
+		completionCommand(),
+		uiCommand(),
+	}
+}
+
+// Run executes the command given in args (without the program name) and returns the exit code
+func Run(args []string) int {
+	env := &environment{
+		stdin:  os.Stdin,
+		stdout: os.Stdout,
+		stderr: os.Stderr,
+		output: outputText,
+		newConfigStorage: func(path string) config.ConfigStorage {
+			return &config.FileConfigStorage{Path: path}
+		},
+		readConfig: config.Read,
+		newHistoryStorage: func(configPath string) history.Storage {
+			return history.NewFileStorage(configPath)
+		},
+		messageSender:   &asb.AsbMessageSender{},
+		messageReceiver: &asb.AsbMessageReceiver{},
+		administrator:   &asb.AsbAdministrator{},
+	}
+
+	return run(env, args)
+}
+
+func run(env *environment, args []string) int {
+	if len(args) == 0 {
+		args = []string{"ui"}
+	}
+
+	name := args[0]
+	switch {
+	case name == "help" || name == "-h" || name == "--help":
+		printUsage(env.stdout)
+		return exitOK
+	case name == completeCommand:
+		// Hidden command called by the completion scripts
+		for _, candidate := range complete(env, args[1:]) {
+			fmt.Fprintln(env.stdout, candidate)
+		}
+		return exitOK
+	case strings.HasPrefix(name, "-"):
+		// Before subcommands existed, sending was selected with flags only
+		fmt.Fprintln(env.stderr, "Running without a command is deprecated, use: busgopher send --conn --dest --msg")
+		name = "send"
+	default:
+		args = args[1:]
+	}
+
+	for _, command := range commands() {
+		if command.name != name {
+			continue
+		}
+
+		started := time.Now()
+		result, err := command.run(env, args)
+
+		return env.finish(command, result, err, time.Since(started))
+	}
+
+	fmt.Fprintf(env.stderr, "Error: unknown command '%v'\n\n", name)
+	printUsage(env.stderr)
+	return exitUsage
+}
+
+// finish prints the result and the error in the selected output and returns the exit code
+func (env *environment) finish(command command, result result, err error, duration time.Duration) int {
+	exitCode := exitOK
+	code := ""
+	var usage usageError
+	var coded codedError
+	var notFound controller.NotFoundError
+	switch {
+	case err == nil:
+	case errors.Is(err, flag.ErrHelp):
+		return exitOK
+	case errors.As(err, &usage):
+		exitCode = exitUsage
+		code = "invalid_usage"
+	case errors.As(err, &coded):
+		exitCode = exitError
+		code = coded.code
+	case errors.As(err, &notFound):
+		exitCode = exitError
+		code = "not_found"
+	default:
+		exitCode = exitError
+		code = "command_failed"
+	}
+
+	if env.output == outputJson {
+		if command.streaming && err == nil {
+			return exitCode
+		}
+		output := jsonOutput{
+			Command:    command.name,
+			Status:     "ok",
+			DurationMs: duration.Milliseconds(),
+			Result:     result,
+		}
+		if err != nil {
+			output.Status = "error"
+			output.Error = &jsonError{Code: code, ExitCode: exitCode, Message: err.Error()}
+			if errors.As(err, &notFound) {
+				output.Error.Suggestions = notFound.Suggestions
+			}
+		}
+		encoder := json.NewEncoder(env.stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(output)
+
+		return exitCode
+	}
+
+	if result != nil {
+		result.printText(env)
+	}
+	if err != nil {
+		fmt.Fprintf(env.stderr, "Error: %v\n", err)
+	}
+	if exitCode == exitUsage {
+		fmt.Fprintf(env.stderr, "Usage: busgopher %v %v\n", command.name, command.usage)
+	}
+
+	return exitCode
+}
+
+type jsonOutput struct {
+	Command    string     `json:"command"`
+	Status     string     `json:"status"`
+	DurationMs int64      `json:"durationMs"`
+	Result     result     `json:"result,omitempty"`
+	Error      *jsonError `json:"error,omitempty"`
+}
+
+type jsonError struct {
+	Code        string   `json:"code"`
+	ExitCode    int      `json:"exitCode"`
+	Message     string   `json:"message"`
+	Suggestions []string `json:"suggestions,omitempty"`
+}
+
+func printUsage(out io.Writer) {
+	fmt.Fprintln(out, "BusGopher - Azure Service Bus client made in the terminal for terminal")
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Usage: busgopher <command> [flags]")
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Commands:")
+	for _, command := range commands() {
+		fmt.Fprintf(out, "  %-10v %v\n", command.name, command.description)
+	}
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Run 'busgopher <command> -h' to see the flags of the command.")
+	fmt.Fprintln(out, "Without a command the GUI is started.")
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Exit codes: 0 - success, 1 - command failed, 2 - invalid usage.")
+}
+
+// options are flags shared by the commands, each command registers only the ones it uses
+type options struct {
+	configPath   string
+	connection   string
+	destination  string
+	subscription string
+	sessionID    string
+	nextSession  bool
+	message      string
+	count        int
+	wait         time.Duration
+	// exact is set by destructive commands, the connection and destination may only differ in case
+	exact bool
+}
+
+func newFlagSet(env *environment, command command, options *options) *flag.FlagSet {
+	flags := flag.NewFlagSet(command.name, flag.ContinueOnError)
+	flags.SetOutput(env.stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(env.stderr, "%v\n\nUsage: busgopher %v %v\n\nFlags:\n", command.description, command.name, command.usage)
+		flags.PrintDefaults()
+	}
+	flags.StringVar(&options.configPath, "config", "config.json", "Path to the config file")
+	flags.StringVar(&env.output, "output", outputText, "Output format: text or json")
+
+	return flags
+}
+
+func (options *options) addConnectionFlags(flags *flag.FlagSet) {
+	flags.StringVar(&options.connection, "conn", "", "Saved connection name")
+	flags.StringVar(&options.destination, "dest", "", "Destination (queue or topic)")
+}
+
+func (options *options) addSourceFlags(flags *flag.FlagSet) {
+	options.addConnectionFlags(flags)
+	flags.StringVar(&options.subscription, "sub", "", "Subscription of the topic given in --dest")
+	flags.IntVar(&options.count, "count", 10, "Maximum number of messages")
+}
+
+func (options *options) addSessionFlags(flags *flag.FlagSet) {
+	flags.StringVar(&options.sessionID, "session", "", "Session to accept on a session-enabled entity")
+	flags.BoolVar(&options.nextSession, "next-session", false, "Accept the next available session of a session-enabled entity")
+}
+
+func (options *options) validateSession() error {
+	if len(options.sessionID) > 0 && options.nextSession {
+		return usageError{message: "flags --session and --next-session can't be combined"}
+	}
+	return nil
+}
+
+// run reads the subcommand, registers the flags and runs the command
+func (command command) run(env *environment, args []string) (result, error) {
+	name := ""
+	if len(command.subcommands) > 0 {
+		var err error
+		name, err = subcommand(env, command, args)
+		if len(name) == 0 {
+			return nil, err
+		}
+		args = args[1:]
+	}
+	_, execute := command.define(env, name)
+
+	return execute(args)
+}
+
+// subcommand reads the first argument of commands taking one of their subcommands before the flags.
+// An empty subcommand means the usage was printed.
+func subcommand(env *environment, command command, args []string) (string, error) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		if len(args) > 0 && (args[0] == "-h" || args[0] == "--help") {
+			newFlagSet(env, command, &options{}).Usage()
+			return "", nil
+		}
+		return "", usageError{message: "what to " + command.name + " is required: " + strings.Join(command.subcommands, ", ")}
+	}
+	if !slices.Contains(command.subcommands, args[0]) {
+		return "", usageError{message: "can't " + command.name + " '" + args[0] + "'"}
+	}
+
+	return args[0], nil
+}
+
+// parse parses the flags and reports missing required ones as usage errors
+func parse(flags *flag.FlagSet, args []string, required ...string) error {
+	err := parseWithArgs(flags, args)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return usageError{message: "unexpected arguments: " + strings.Join(flags.Args(), " ")}
+	}
+
+	for _, name := range required {
+		if len(flags.Lookup(name).Value.String()) == 0 {
+			return usageError{message: "flag --" + name + " is required"}
+		}
+	}
+
+	return nil
+}
+
+// parseWithArgs parses the flags of commands that accept positional arguments
+func parseWithArgs(flags *flag.FlagSet, args []string) error {
+	err := flags.Parse(args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return usageError{message: err.Error()}
+	}
+
+	output := flags.Lookup("output").Value.String()
+	if output != outputText && output != outputJson {
+		return usageError{message: "invalid output '" + output + "', use text or json"}
+	}
+
+	return nil
+}
+
+func (env *environment) writeLog(log string) {
+	if env.output == outputJson {
+		line, _ := json.Marshal(map[string]string{
+			"time":    time.Now().Format(time.RFC3339),
+			"level":   "info",
+			"message": log,
+		})
+		fmt.Fprintln(env.stderr, string(line))
+		return
+	}
+
+	fmt.Fprintf(
+		env.stderr,
+		"[%v]: [Info] %v\n",
+		time.Now().Format("2006-01-02 15:04:05"),
+		log,
+	)
+}
+
+func (env *environment) newController(options *options) (*controller.Controller, error) {
+	return controller.NewController(
+		env.newConfigStorage(options.configPath),
+		env.messageSender,
+		env.messageReceiver,
+		env.administrator,
+		env.newHistoryStorage(options.configPath),
+		env.writeLog,
+	)
+}
+
+// newConnectedController creates the controller, selects the connection from the options and
+// resolves their destination
+func (env *environment) newConnectedController(options *options) (*controller.Controller, error) {
+	controller, err := env.newController(options)
+	if err != nil {
+		return nil, err
+	}
+
+	selectConnection := controller.SelectConnectionByName
+	if options.exact {
+		selectConnection = controller.SelectConnectionByExactName
+	}
+	err = selectConnection(options.connection)
+	if err != nil {
+		return nil, codedError{code: "not_found", err: err}
+	}
+	options.destination, err = controller.ResolveDestination(options.destination, options.exact)
+	if err != nil {
+		return nil, codedError{code: "not_found", err: err}
+	}
+
+	return controller, nil
+}
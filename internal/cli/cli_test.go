@@ -0,0 +1,1024 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rafalpienkowski/busgopher/internal/asb"
+	"github.com/rafalpienkowski/busgopher/internal/config"
+	"github.com/rafalpienkowski/busgopher/internal/history"
+	"github.com/stretchr/testify/assert"
+)
+
+func createTestEnvironment() (*environment, *bytes.Buffer, *bytes.Buffer, *asb.InMemoryMessageSender, *asb.InMemoryMessageReceiver) {
+	stdin := &bytes.Buffer{}
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	sender := &asb.InMemoryMessageSender{}
+	receiver := &asb.InMemoryMessageReceiver{}
+	storage := &config.InMemoryConfigStorage{Config: config.GetTestConfig()}
+	sent := &history.InMemoryStorage{}
+
+	env := &environment{
+		stdin:  stdin,
+		stdout: stdout,
+		stderr: stderr,
+		newConfigStorage: func(path string) config.ConfigStorage {
+			return storage
+		},
+		readConfig: func(path string) (config.Config, error) {
+			return storage.Load()
+		},
+		newHistoryStorage: func(configPath string) history.Storage {
+			return sent
+		},
+		messageSender:   sender,
+		messageReceiver: receiver,
+		administrator:   &asb.InMemoryAdministrator{},
+	}
+
+	return env, stdout, stderr, sender, receiver
+}
+
+func Test_Run_Should_Send_Message(t *testing.T) {
+	env, _, _, sender, _ := createTestEnvironment()
+
+	code := run(env, []string{"send", "--conn", "test-connection", "--dest", "queue", "--msg", "test-message"})
+
+	assert.Equal(t, exitOK, code)
+	assert.Equal(t, "queue", sender.Destination)
+	assert.Equal(t, "test.azure.com", sender.Namespace)
+	assert.Equal(t, "{ test msg body }", sender.Message.Body)
+}
+
+func Test_Run_Should_Send_Ad_Hoc_Message_From_Stdin(t *testing.T) {
+	env, _, _, sender, _ := createTestEnvironment()
+	env.stdin = strings.NewReader(`{"id": "{{ not a template }}"}`)
+
+	code := run(env, []string{
+		"send", "--namespace", "other.azure.com", "--dest", "undeclared-queue", "--body-file", "-",
+		"--subject", "piped", "--message-id", "42", "--property", "count=3", "--property", "source=cli",
+	})
+
+	assert.Equal(t, exitOK, code)
+	assert.Equal(t, "other.azure.com", sender.Namespace)
+	assert.Equal(t, "undeclared-queue", sender.Destination)
+	assert.Equal(t, asb.Message{
+		Body:             `{"id": "{{ not a template }}"}`,
+		Subject:          "piped",
+		MessageID:        "42",
+		CustomProperties: map[string]any{"count": 3.0, "source": "cli"},
+	}, sender.Message)
+}
+
+func Test_Run_Should_Send_Ad_Hoc_Message_From_File_With_Template(t *testing.T) {
+	env, _, _, sender, _ := createTestEnvironment()
+	file := filepath.Join(t.TempDir(), "body.txt")
+	assert.NoError(t, os.WriteFile(file, []byte(`{{ "rendered" }}`), 0644))
+
+	code := run(env, []string{"send", "--conn", "test-connection", "--dest", "queue", "--body-file", file, "--template"})
+
+	assert.Equal(t, exitOK, code)
+	assert.Equal(t, "test.azure.com", sender.Namespace)
+	assert.Equal(t, "rendered", sender.Message.Body)
+}
+
+func Test_Run_Should_Send_Secret_Reference_Of_Ad_Hoc_Message_Unchanged(t *testing.T) {
+	t.Setenv("BUSGOPHER_TEST_SECRET", "secret")
+	env, _, _, sender, _ := createTestEnvironment()
+	env.stdin = strings.NewReader("env:BUSGOPHER_TEST_SECRET")
+
+	code := run(env, []string{
+		"send", "--conn", "test-connection", "--dest", "queue", "--body-file", "-", "--subject", "env:BUSGOPHER_TEST_SECRET",
+	})
+
+	assert.Equal(t, exitOK, code)
+	assert.Equal(t, "env:BUSGOPHER_TEST_SECRET", sender.Message.Body)
+	assert.Equal(t, "env:BUSGOPHER_TEST_SECRET", sender.Message.Subject)
+}
+
+func Test_Run_Should_Reject_Destination_Not_Saved_In_Connection(t *testing.T) {
+	env, _, stderr, sender, _ := createTestEnvironment()
+
+	code := run(env, []string{"send", "--conn", "test-connection", "--dest", "undeclared-queue", "--msg", "test-message"})
+
+	assert.Equal(t, exitError, code)
+	assert.Empty(t, sender.Destination)
+	assert.Contains(t, stderr.String(), "undeclared-queue")
+}
+
+func Test_Run_Should_Override_Saved_Message_Properties(t *testing.T) {
+	env, _, _, sender, _ := createTestEnvironment()
+
+	code := run(env, []string{"send", "--conn", "test-connection", "--dest", "queue", "--msg", "test-message", "--subject", "override"})
+
+	assert.Equal(t, exitOK, code)
+	assert.Equal(t, "{ test msg body }", sender.Message.Body)
+	assert.Equal(t, "override", sender.Message.Subject)
+}
+
+func Test_Run_Should_Reject_Invalid_Property(t *testing.T) {
+	env, _, stderr, _, _ := createTestEnvironment()
+
+	code := run(env, []string{"send", "--namespace", "ns", "--dest", "queue", "--body-file", "-", "--property", "novalue"})
+
+	assert.Equal(t, exitUsage, code)
+	assert.Contains(t, stderr.String(), "invalid property 'novalue', expected key=value")
+}
+
+func Test_Run_Should_Send_Message_With_Legacy_Flags(t *testing.T) {
+	env, _, stderr, sender, _ := createTestEnvironment()
+
+	code := run(env, []string{"--conn", "test-connection", "--dest", "queue", "--msg", "test-message"})
+
+	assert.Equal(t, exitOK, code)
+	assert.Equal(t, "queue", sender.Destination)
+	assert.Contains(t, stderr.String(), "deprecated")
+}
+
+func Test_Run_Should_Return_Usage_Code_When_Flag_Is_Missing(t *testing.T) {
+	env, _, stderr, _, _ := createTestEnvironment()
+
+	code := run(env, []string{"send", "--conn", "test-connection", "--dest", "queue"})
+
+	assert.Equal(t, exitUsage, code)
+	assert.Contains(t, stderr.String(), "one of --msg or --body-file is required")
+}
+
+func Test_Run_Should_Return_Error_Code_When_Name_Is_Unknown(t *testing.T) {
+	env, _, stderr, _, _ := createTestEnvironment()
+
+	code := run(env, []string{"send", "--conn", "test-connection", "--dest", "queue", "--msg", "unknown"})
+
+	assert.Equal(t, exitError, code)
+	assert.Contains(t, stderr.String(), "Can't find message with name: unknown")
+}
+
+func Test_Run_Should_Return_Usage_Code_For_Unknown_Command(t *testing.T) {
+	env, _, _, _, _ := createTestEnvironment()
+
+	code := run(env, []string{"unknown"})
+
+	assert.Equal(t, exitUsage, code)
+}
+
+func Test_Run_Should_Peek_Messages(t *testing.T) {
+	env, stdout, _, _, receiver := createTestEnvironment()
+	source := asb.Source{Destination: "queue"}
+	receiver.Messages = map[asb.Source][]asb.ReceivedMessage{
+		source: {{Message: asb.Message{Body: "peeked", Subject: "subject"}, SequenceNumber: 7}},
+	}
+
+	code := run(env, []string{"peek", "--conn", "test-connection", "--dest", "queue"})
+
+	assert.Equal(t, exitOK, code)
+	assert.Contains(t, stdout.String(), "#7")
+	assert.Contains(t, stdout.String(), "Subject: subject")
+	assert.Contains(t, stdout.String(), "peeked")
+	assert.Len(t, receiver.Messages[source], 1)
+}
+
+func Test_Run_Should_Receive_Dead_Lettered_Messages(t *testing.T) {
+	env, stdout, _, _, receiver := createTestEnvironment()
+	source := asb.Source{Destination: "queue", DeadLetter: true}
+	receiver.Messages = map[asb.Source][]asb.ReceivedMessage{
+		source: {{Message: asb.Message{Body: "dead"}, DeadLetterReason: "expired"}},
+	}
+
+	code := run(env, []string{"dlq", "--conn", "test-connection", "--dest", "queue", "--receive"})
+
+	assert.Equal(t, exitOK, code)
+	assert.Contains(t, stdout.String(), "DeadLetterReason: expired")
+	assert.Empty(t, receiver.Messages[source])
+}
+
+func Test_Run_Should_List_Destinations(t *testing.T) {
+	env, stdout, _, _, _ := createTestEnvironment()
+
+	code := run(env, []string{"list", "destinations", "--conn", "test-connection"})
+
+	assert.Equal(t, exitOK, code)
+	assert.Equal(t, "queue\ntopic\n", stdout.String())
+}
+
+func Test_Run_Should_Render_Message(t *testing.T) {
+	env, stdout, _, _, _ := createTestEnvironment()
+
+	code := run(env, []string{"render", "--msg", "test-message"})
+
+	assert.Equal(t, exitOK, code)
+	assert.Contains(t, stdout.String(), "{ test msg body }")
+}
+
+func Test_Run_Should_Print_Send_Result_As_Json(t *testing.T) {
+	env, stdout, stderr, sender, _ := createTestEnvironment()
+
+	code := run(env, []string{"send", "--conn", "test-connection", "--dest", "queue", "--msg", "test-message", "--output", "json"})
+
+	assert.Equal(t, exitOK, code)
+	var output map[string]any
+	assert.NoError(t, json.Unmarshal(stdout.Bytes(), &output))
+	assert.Equal(t, "send", output["command"])
+	assert.Equal(t, "ok", output["status"])
+	assert.Equal(t, map[string]any{
+		"messageId":   sender.Message.MessageID,
+		"namespace":   "test.azure.com",
+		"destination": "queue",
+	}, output["result"])
+	for _, line := range strings.Split(strings.TrimSpace(stderr.String()), "\n") {
+		assert.True(t, json.Valid([]byte(line)), line)
+	}
+}
+
+func Test_Run_Should_Print_Error_As_Json(t *testing.T) {
+	env, stdout, _, _, _ := createTestEnvironment()
+
+	code := run(env, []string{"send", "--conn", "unknown", "--dest", "queue", "--msg", "test-message", "--output=json"})
+
+	assert.Equal(t, exitError, code)
+	var output map[string]any
+	assert.NoError(t, json.Unmarshal(stdout.Bytes(), &output))
+	assert.Equal(t, "error", output["status"])
+	assert.Equal(t, map[string]any{
+		"code":     "not_found",
+		"exitCode": 1.0,
+		"message":  "Can't find connection with name: unknown",
+	}, output["error"])
+}
+
+func Test_Run_Should_Reject_Unknown_Output(t *testing.T) {
+	env, _, stderr, _, _ := createTestEnvironment()
+
+	code := run(env, []string{"list", "connections", "--output", "yaml"})
+
+	assert.Equal(t, exitUsage, code)
+	assert.Contains(t, stderr.String(), "invalid output 'yaml'")
+}
+
+func Test_Run_Should_Print_Suggestions_As_Json(t *testing.T) {
+	env, stdout, _, _, _ := createTestEnvironment()
+
+	code := run(env, []string{"render", "--msg", "test-mesasge", "--output", "json"})
+
+	assert.Equal(t, exitError, code)
+	var output map[string]any
+	assert.NoError(t, json.Unmarshal(stdout.Bytes(), &output))
+	assert.Equal(t, map[string]any{
+		"code":        "not_found",
+		"exitCode":    1.0,
+		"message":     "Can't find message with name: test-mesasge, did you mean: test-message?",
+		"suggestions": []any{"test-message"},
+	}, output["error"])
+}
+
+func Test_Run_Should_Run_Scenario(t *testing.T) {
+	env, stdout, _, sender, _ := createTestEnvironment()
+	file := filepath.Join(t.TempDir(), "scenario.yaml")
+	assert.NoError(t, os.WriteFile(file, []byte(`
+connection: test-connection
+steps:
+  - send:
+      destination: queue
+      message: test-message
+      subject: ${subject}
+  - peek:
+      destination: queue
+`), 0644))
+
+	code := run(env, []string{"run", file, "--var", "subject=scenario"})
+
+	assert.Equal(t, exitError, code)
+	assert.Equal(t, "scenario", sender.Message.Subject)
+	assert.Contains(t, stdout.String(), "1. [PASSED] send to queue")
+	assert.Contains(t, stdout.String(), "2. [FAILED] peek queue")
+	assert.Contains(t, stdout.String(), "no messages in queue")
+	assert.Contains(t, stdout.String(), "1 of 2 step(s) passed")
+}
+
+func Test_Run_Should_Print_Reply_To_Request(t *testing.T) {
+	env, stdout, _, _, receiver := createTestEnvironment()
+	receiver.Messages = map[asb.Source][]asb.ReceivedMessage{
+		{Destination: "replies"}: {{Message: asb.Message{Body: "pong", CorrelationID: "request-1"}}},
+	}
+
+	code := run(env, []string{
+		"send", "--conn", "test-connection", "--dest", "queue", "--msg", "test-message",
+		"--message-id", "request-1", "--reply-to", "replies", "--await-reply", "--output", "json",
+	})
+
+	assert.Equal(t, exitOK, code)
+	var output struct {
+		Result struct {
+			MessageID string
+			Reply     asb.ReceivedMessage
+		}
+	}
+	assert.NoError(t, json.Unmarshal(stdout.Bytes(), &output))
+	assert.Equal(t, "request-1", output.Result.MessageID)
+	assert.Equal(t, "pong", output.Result.Reply.Body)
+}
+
+func Test_Run_Should_Fail_When_Reply_Not_Received(t *testing.T) {
+	env, _, stderr, sender, _ := createTestEnvironment()
+
+	code := run(env, []string{
+		"send", "--conn", "test-connection", "--dest", "queue", "--msg", "test-message",
+		"--reply-to", "replies", "--await-reply", "--reply-timeout", "1ms",
+	})
+
+	assert.Equal(t, exitError, code)
+	assert.Contains(t, stderr.String(), "No reply to message "+sender.Message.MessageID+" received within 1ms")
+}
+
+func Test_Run_Should_Tail_Messages_From_Sequence_Number(t *testing.T) {
+	env, stdout, _, _, receiver := createTestEnvironment()
+	source := asb.Source{Destination: "queue"}
+	receiver.Add(
+		source,
+		asb.ReceivedMessage{Message: asb.Message{Body: "first"}, SequenceNumber: 1},
+		asb.ReceivedMessage{Message: asb.Message{Body: "second"}, SequenceNumber: 2},
+		asb.ReceivedMessage{Message: asb.Message{Body: "third"}, SequenceNumber: 3},
+	)
+
+	code := run(env, []string{
+		"tail", "--conn", "test-connection", "--dest", "queue",
+		"--from-seq", "2", "--count", "1", "--interval", "10ms",
+	})
+
+	assert.Equal(t, exitOK, code)
+	assert.Contains(t, stdout.String(), "#2")
+	assert.Contains(t, stdout.String(), "second")
+	assert.NotContains(t, stdout.String(), "third")
+	assert.Len(t, receiver.Messages[source], 3)
+}
+
+func Test_Run_Should_Tail_Messages_As_Json_Lines(t *testing.T) {
+	env, stdout, _, _, receiver := createTestEnvironment()
+	source := asb.Source{Destination: "queue", DeadLetter: true}
+	receiver.Add(
+		source,
+		asb.ReceivedMessage{Message: asb.Message{Body: "dead"}, SequenceNumber: 1},
+		asb.ReceivedMessage{Message: asb.Message{Body: "deader"}, SequenceNumber: 2},
+	)
+
+	code := run(env, []string{
+		"tail", "--conn", "test-connection", "--dest", "queue", "--dlq",
+		"--from-seq", "1", "--contains", "deader", "--duration", "50ms", "--interval", "10ms", "--output", "json",
+	})
+
+	assert.Equal(t, exitOK, code)
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	assert.Len(t, lines, 1)
+	var message asb.ReceivedMessage
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &message))
+	assert.Equal(t, "deader", message.Body)
+}
+
+func Test_Run_Should_Colorize_Tailed_Json_Bodies(t *testing.T) {
+	env, stdout, _, _, receiver := createTestEnvironment()
+	receiver.Add(
+		asb.Source{Destination: "queue"},
+		asb.ReceivedMessage{Message: asb.Message{Body: `{"name":"a","count":1}`}, SequenceNumber: 1},
+	)
+
+	code := run(env, []string{
+		"tail", "--conn", "test-connection", "--dest", "queue",
+		"--from-seq", "1", "--count", "1", "--color", "always",
+	})
+
+	assert.Equal(t, exitOK, code)
+	assert.Contains(t, stdout.String(), colorKey+`"name"`+colorReset+": "+colorString+`"a"`+colorReset)
+	assert.Contains(t, stdout.String(), colorNumber+"1"+colorReset)
+}
+
+func Test_Run_Should_Peek_Filtered_Messages(t *testing.T) {
+	env, stdout, _, _, receiver := createTestEnvironment()
+	source := asb.Source{Destination: "queue", DeadLetter: true}
+	receiver.Add(
+		source,
+		asb.ReceivedMessage{Message: asb.Message{Body: `{"id": 1}`, Subject: "created"}, SequenceNumber: 1},
+		asb.ReceivedMessage{Message: asb.Message{Body: `{"id": 2}`, Subject: "created"}, SequenceNumber: 2},
+		asb.ReceivedMessage{Message: asb.Message{Body: `{"id": 2}`, Subject: "deleted"}, SequenceNumber: 3},
+	)
+
+	code := run(env, []string{
+		"dlq", "--conn", "test-connection", "--dest", "queue",
+		"--where", "subject=created", "--jsonpath", "$.id", "--jsonpath-equals", "2",
+	})
+
+	assert.Equal(t, exitOK, code)
+	assert.Contains(t, stdout.String(), "#2")
+	assert.NotContains(t, stdout.String(), "#1")
+	assert.NotContains(t, stdout.String(), "#3")
+}
+
+func Test_Run_Should_Reject_Invalid_Filter(t *testing.T) {
+	env, _, stderr, _, _ := createTestEnvironment()
+
+	code := run(env, []string{"peek", "--conn", "test-connection", "--dest", "queue", "--where", "label=x"})
+
+	assert.Equal(t, exitUsage, code)
+	assert.Contains(t, stderr.String(), "unknown broker property 'label'")
+}
+
+func Test_Run_Should_Export_And_Import_Messages(t *testing.T) {
+	env, stdout, _, sender, receiver := createTestEnvironment()
+	source := asb.Source{Destination: "queue", DeadLetter: true}
+	receiver.Add(
+		source,
+		asb.ReceivedMessage{
+			Message:          asb.Message{Body: "poison", MessageID: "message-1", ContentType: "text/plain", CustomProperties: map[string]any{"attempt": int64(3)}},
+			SequenceNumber:   1,
+			DeadLetterReason: "MaxDeliveryCountExceeded",
+		},
+		asb.ReceivedMessage{Message: asb.Message{Body: "other", MessageID: "message-2"}, SequenceNumber: 2},
+	)
+	path := filepath.Join(t.TempDir(), "poison.jsonl")
+
+	code := run(env, []string{"export", "--conn", "test-connection", "--dest", "queue", "--dlq", "--out", path})
+
+	assert.Equal(t, exitOK, code)
+	assert.Contains(t, stdout.String(), "Exported 2 message(s) from queue/$deadletterqueue to "+path)
+	assert.Len(t, receiver.Messages[source], 2)
+
+	code = run(env, []string{
+		"import", "--namespace", "test.servicebus.windows.net", "--dest", "reproduction",
+		"--in", path, "--contains", "poison", "--output", "json",
+	})
+
+	assert.Equal(t, exitOK, code)
+	assert.Contains(t, stdout.String(), `"messageIds": [`)
+	assert.Equal(t, "reproduction", sender.Destination)
+	assert.Equal(t, "poison", sender.Message.Body)
+	assert.Equal(t, "message-1", sender.Message.MessageID)
+	assert.Equal(t, "text/plain", sender.Message.ContentType)
+	assert.Equal(t, int64(3), sender.Message.CustomProperties["attempt"])
+}
+
+func Test_Run_Should_Not_Receive_Messages_When_Export_Output_Cant_Be_Created(t *testing.T) {
+	env, _, _, _, receiver := createTestEnvironment()
+	source := asb.Source{Destination: "queue"}
+	receiver.Add(source, asb.ReceivedMessage{Message: asb.Message{Body: "kept"}, SequenceNumber: 1})
+	path := filepath.Join(t.TempDir(), "missing", "kept.jsonl")
+
+	code := run(env, []string{"export", "--conn", "test-connection", "--dest", "queue", "--receive", "--out", path})
+
+	assert.Equal(t, exitError, code)
+	assert.Len(t, receiver.Messages[source], 1)
+}
+
+// failingWriter fails every write, like a full disk
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("no space left on device")
+}
+
+func Test_Run_Should_Log_Received_Messages_When_Export_Fails(t *testing.T) {
+	env, _, stderr, _, receiver := createTestEnvironment()
+	receiver.Add(asb.Source{Destination: "queue"}, asb.ReceivedMessage{Message: asb.Message{Body: "removed"}, SequenceNumber: 1})
+	env.stdout = failingWriter{}
+
+	code := run(env, []string{"export", "--conn", "test-connection", "--dest", "queue", "--receive", "--out", "-"})
+
+	assert.Equal(t, exitError, code)
+	assert.Contains(t, stderr.String(), "Writing - failed, the 1 received message(s) follow as JSON Lines")
+	assert.Contains(t, stderr.String(), `"body":"removed"`)
+}
+
+func Test_Run_Should_Export_Messages_To_Directory_With_New_Ids_On_Import(t *testing.T) {
+	env, _, _, sender, receiver := createTestEnvironment()
+	receiver.Add(
+		asb.Source{Destination: "queue"},
+		asb.ReceivedMessage{Message: asb.Message{Body: "first", MessageID: "message-1"}, SequenceNumber: 1},
+	)
+	dir := filepath.Join(t.TempDir(), "export")
+
+	code := run(env, []string{"export", "--conn", "test-connection", "--dest", "queue", "--receive", "--out", dir, "--format", "dir"})
+
+	assert.Equal(t, exitOK, code)
+	assert.FileExists(t, filepath.Join(dir, "message-000001.json"))
+	assert.Empty(t, receiver.Messages[asb.Source{Destination: "queue"}])
+
+	code = run(env, []string{"import", "--conn", "test-connection", "--dest", "queue", "--in", dir, "--new-ids"})
+
+	assert.Equal(t, exitOK, code)
+	assert.Equal(t, "first", sender.Message.Body)
+	assert.NotEqual(t, "message-1", sender.Message.MessageID)
+}
+
+func Test_Run_Should_Import_Secret_References_Unchanged(t *testing.T) {
+	t.Setenv("BUSGOPHER_TEST_SECRET", "secret")
+	env, _, _, sender, _ := createTestEnvironment()
+	env.stdin = strings.NewReader(`{"body": "env:BUSGOPHER_TEST_SECRET", "customProperties": {"token": "env:BUSGOPHER_TEST_SECRET"}}` + "\n")
+
+	code := run(env, []string{"import", "--conn", "test-connection", "--dest", "queue", "--in", "-"})
+
+	assert.Equal(t, exitOK, code)
+	assert.Equal(t, "env:BUSGOPHER_TEST_SECRET", sender.Message.Body)
+	assert.Equal(t, "env:BUSGOPHER_TEST_SECRET", sender.Message.CustomProperties["token"])
+}
+
+func Test_Run_Should_Not_Export_To_Stdout_As_Json(t *testing.T) {
+	env, _, _, _, _ := createTestEnvironment()
+
+	code := run(env, []string{"export", "--conn", "test-connection", "--dest", "queue", "--out", "-", "--output", "json"})
+
+	assert.Equal(t, exitUsage, code)
+}
+
+func Test_Run_Should_Save_Peeked_Message(t *testing.T) {
+	env, _, _, _, receiver := createTestEnvironment()
+	receiver.Add(
+		asb.Source{Destination: "queue", DeadLetter: true},
+		asb.ReceivedMessage{Message: asb.Message{Body: "{{broken", Subject: "created"}, SequenceNumber: 4},
+	)
+
+	code := run(env, []string{"dlq", "--conn", "test-connection", "--dest", "queue", "--save-as", "poison"})
+
+	assert.Equal(t, exitOK, code)
+	storage := env.newConfigStorage("").(*config.InMemoryConfigStorage)
+	assert.Equal(t, `{{"{{"}}broken`, storage.Config.Messages["poison"].Body)
+	assert.Equal(t, "created", storage.Config.Messages["poison"].Subject)
+}
+
+func Test_Run_Should_Purge_After_Confirmation(t *testing.T) {
+	env, stdout, stderr, _, receiver := createTestEnvironment()
+	source := asb.Source{Destination: "queue", Subscription: "audit"}
+	receiver.Add(source, asb.ReceivedMessage{SequenceNumber: 1}, asb.ReceivedMessage{SequenceNumber: 2})
+	env.stdin.(*bytes.Buffer).WriteString("yes\n")
+
+	code := run(env, []string{"purge", "--conn", "test-connection", "--dest", "queue", "--sub", "audit"})
+
+	assert.Equal(t, exitOK, code)
+	assert.Contains(t, stderr.String(), "Delete all messages from queue/subscriptions/audit of connection test-connection (test.azure.com)? Type 'yes' to confirm:")
+	assert.Contains(t, stdout.String(), "Purged 2 message(s) from queue/subscriptions/audit")
+	assert.Empty(t, receiver.Messages[source])
+}
+
+func Test_Run_Should_Not_Purge_Connection_Matched_By_Prefix(t *testing.T) {
+	env, _, stderr, _, receiver := createTestEnvironment()
+	source := asb.Source{Destination: "queue"}
+	receiver.Add(source, asb.ReceivedMessage{SequenceNumber: 1})
+
+	code := run(env, []string{"purge", "--conn", "test", "--dest", "queue", "--yes"})
+
+	assert.Equal(t, exitError, code)
+	assert.Contains(t, stderr.String(), "Can't find connection with name: test, did you mean: test-connection?")
+	assert.Len(t, receiver.Messages[source], 1)
+}
+
+func Test_Run_Should_Not_Receive_From_Connection_Matched_By_Prefix(t *testing.T) {
+	env, _, stderr, _, receiver := createTestEnvironment()
+	source := asb.Source{Destination: "queue"}
+	receiver.Add(source, asb.ReceivedMessage{SequenceNumber: 1})
+
+	code := run(env, []string{"receive", "--conn", "test", "--dest", "queue"})
+
+	assert.Equal(t, exitError, code)
+	assert.Contains(t, stderr.String(), "Can't find connection with name: test, did you mean: test-connection?")
+	assert.Len(t, receiver.Messages[source], 1)
+}
+
+func Test_Run_Should_Not_Send_To_Destination_Matched_By_Prefix(t *testing.T) {
+	env, _, stderr, sender, _ := createTestEnvironment()
+
+	code := run(env, []string{"send", "--conn", "test-connection", "--dest", "que", "--msg", "test-message"})
+
+	assert.Equal(t, exitError, code)
+	assert.Contains(t, stderr.String(), "Can't find destination with name: que, did you mean: queue?")
+	assert.Empty(t, sender.Destination)
+}
+
+func Test_Run_Should_Resolve_Destination(t *testing.T) {
+	env, stdout, _, _, receiver := createTestEnvironment()
+	receiver.Add(asb.Source{Destination: "queue"}, asb.ReceivedMessage{Message: asb.Message{Body: "resolved"}})
+
+	code := run(env, []string{"peek", "--conn", "test-connection", "--dest", "QUE"})
+
+	assert.Equal(t, exitOK, code)
+	assert.Contains(t, stdout.String(), "resolved")
+}
+
+func Test_Run_Should_Not_Purge_Without_Confirmation(t *testing.T) {
+	env, _, _, _, receiver := createTestEnvironment()
+	source := asb.Source{Destination: "queue"}
+	receiver.Add(source, asb.ReceivedMessage{SequenceNumber: 1})
+	env.stdin.(*bytes.Buffer).WriteString("no\n")
+
+	code := run(env, []string{"purge", "--conn", "test-connection", "--dest", "queue", "--output", "json"})
+
+	assert.Equal(t, exitError, code)
+	assert.Len(t, receiver.Messages[source], 1)
+}
+
+func Test_Run_Should_List_Discovered_Entities(t *testing.T) {
+	env, stdout, _, _, _ := createTestEnvironment()
+	env.administrator = &asb.InMemoryAdministrator{Entities: map[string][]asb.Entity{
+		"test.azure.com": {
+			{Kind: asb.EntityQueue, Name: "orders"},
+			{Kind: asb.EntityTopic, Name: "topic"},
+			{Kind: asb.EntitySubscription, Name: "audit", Topic: "topic"},
+		},
+	}}
+
+	code := run(env, []string{"list", "destinations", "--conn", "test-connection", "--discover", "--output", "json"})
+
+	assert.Equal(t, exitOK, code)
+	var output map[string]any
+	assert.NoError(t, json.Unmarshal(stdout.Bytes(), &output))
+	assert.Equal(t, []any{
+		map[string]any{"name": "orders", "kind": "queue", "saved": false},
+		map[string]any{"name": "topic", "kind": "topic", "saved": true},
+		map[string]any{"name": "topic/subscriptions/audit", "kind": "subscription"},
+	}, output["result"].(map[string]any)["items"])
+}
+
+func Test_Run_Should_Save_Discovered_Destinations(t *testing.T) {
+	env, stdout, _, _, _ := createTestEnvironment()
+	env.administrator = &asb.InMemoryAdministrator{Entities: map[string][]asb.Entity{
+		"test.azure.com": {{Kind: asb.EntityQueue, Name: "orders"}},
+	}}
+
+	code := run(env, []string{"list", "destinations", "--conn", "test-connection", "--discover", "--save"})
+
+	assert.Equal(t, exitOK, code)
+	assert.Contains(t, stdout.String(), "orders")
+	storage, _ := env.newConfigStorage("").Load()
+	assert.Equal(t, []string{"queue", "topic", "orders"}, storage.Connections["test-connection"].Destinations)
+}
+
+func Test_Run_Should_Require_Discover_For_Save(t *testing.T) {
+	env, _, _, _, _ := createTestEnvironment()
+
+	code := run(env, []string{"list", "destinations", "--conn", "test-connection", "--save"})
+
+	assert.Equal(t, exitUsage, code)
+}
+
+func Test_Run_Should_Print_Statistics(t *testing.T) {
+	env, stdout, _, _, _ := createTestEnvironment()
+	env.administrator = &asb.InMemoryAdministrator{Statistics: map[string][]asb.EntityStatistics{
+		"test.azure.com": {
+			{Entity: asb.Entity{Kind: asb.EntityQueue, Name: "queue"}, ActiveMessageCount: 3, SizeInBytes: 1024},
+			{Entity: asb.Entity{Kind: asb.EntitySubscription, Name: "audit", Topic: "topic"}, DeadLetterMessageCount: 2},
+		},
+	}}
+
+	code := run(env, []string{"stats", "--conn", "test-connection"})
+
+	assert.Equal(t, exitOK, code)
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	assert.Len(t, lines, 3)
+	assert.Equal(t, []string{"queue", "queue", "3", "0", "0", "0", "1024", "-"}, strings.Fields(lines[1]))
+	assert.Equal(t, []string{"topic/subscriptions/audit", "subscription", "0", "2", "0", "0", "0", "-"}, strings.Fields(lines[2]))
+}
+
+func Test_Run_Should_Print_Statistics_Of_Resolved_Destination(t *testing.T) {
+	env, stdout, _, _, _ := createTestEnvironment()
+	env.administrator = &asb.InMemoryAdministrator{Statistics: map[string][]asb.EntityStatistics{
+		"test.azure.com": {
+			{Entity: asb.Entity{Kind: asb.EntityQueue, Name: "queue"}, ActiveMessageCount: 3},
+			{Entity: asb.Entity{Kind: asb.EntitySubscription, Name: "audit", Topic: "topic"}},
+		},
+	}}
+
+	code := run(env, []string{"stats", "--conn", "test-connection", "--dest", "QUE"})
+
+	assert.Equal(t, exitOK, code)
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	assert.Len(t, lines, 2)
+	assert.Equal(t, "queue", strings.Fields(lines[1])[0])
+}
+
+func Test_Run_Should_Not_Watch_Statistics_With_Json_Output(t *testing.T) {
+	env, _, _, _, _ := createTestEnvironment()
+
+	code := run(env, []string{"stats", "--conn", "test-connection", "--watch", "5s", "--output", "json"})
+
+	assert.Equal(t, exitUsage, code)
+}
+
+func Test_Run_Should_Create_Entities_And_Rules(t *testing.T) {
+	env, stdout, _, _, _ := createTestEnvironment()
+	administrator := &asb.InMemoryAdministrator{}
+	env.administrator = administrator
+
+	assert.Equal(t, exitOK, run(env, []string{"create", "queue", "--conn", "test-connection", "--name", "orders", "--max-delivery-count", "5"}))
+	assert.Equal(t, exitOK, run(env, []string{"create", "topic", "--conn", "test-connection", "--name", "events"}))
+	assert.Equal(t, exitOK, run(env, []string{"create", "subscription", "--conn", "test-connection", "--topic", "events", "--name", "audit"}))
+	assert.Equal(t, exitOK, run(env, []string{
+		"rule", "add", "--conn", "test-connection", "--topic", "events", "--sub", "audit",
+		"--name", "created", "--correlation", "subject=created", "--correlation", "app.tenant=acme",
+	}))
+	stdout.Reset()
+	code := run(env, []string{"rule", "list", "--conn", "test-connection", "--topic", "events", "--sub", "audit"})
+
+	assert.Equal(t, exitOK, code)
+	topology := administrator.Topologies["test.azure.com"]
+	assert.Equal(t, []asb.QueueDefinition{{Name: "orders", MaxDeliveryCount: 5}}, topology.Queues)
+	assert.Equal(t, "audit", topology.Topics[0].Subscriptions[0].Name)
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	assert.Len(t, lines, 2)
+	assert.Equal(t, []string{"created", "correlation:", "subject=created,", "app.tenant=acme"}, strings.Fields(lines[1]))
+}
+
+func Test_Run_Should_Reject_Rule_With_Both_Filters(t *testing.T) {
+	env, _, _, _, _ := createTestEnvironment()
+
+	code := run(env, []string{
+		"rule", "add", "--conn", "test-connection", "--topic", "events", "--sub", "audit",
+		"--name", "both", "--sql", "1=1", "--correlation", "subject=created",
+	})
+
+	assert.Equal(t, exitUsage, code)
+}
+
+func Test_Run_Should_Delete_Entity_After_Confirmation(t *testing.T) {
+	env, _, stderr, _, _ := createTestEnvironment()
+	administrator := &asb.InMemoryAdministrator{Topologies: map[string]*asb.Topology{
+		"test.azure.com": {Queues: []asb.QueueDefinition{{Name: "orders"}}},
+	}}
+	env.administrator = administrator
+	env.stdin.(*bytes.Buffer).WriteString("yes\n")
+
+	code := run(env, []string{"delete", "queue", "--conn", "test-connection", "--name", "orders"})
+
+	assert.Equal(t, exitOK, code)
+	assert.Contains(t, stderr.String(), "Delete queue orders with all its messages?")
+	assert.Empty(t, administrator.Topologies["test.azure.com"].Queues)
+}
+
+func Test_Run_Should_Apply_Topology(t *testing.T) {
+	env, stdout, _, _, _ := createTestEnvironment()
+	administrator := &asb.InMemoryAdministrator{}
+	env.administrator = administrator
+	storage, _ := env.newConfigStorage("").Load()
+	storage.Topologies = map[string]asb.Topology{
+		"orders": {
+			Queues: []asb.QueueDefinition{{Name: "orders"}},
+			Topics: []asb.TopicDefinition{{Name: "events", Subscriptions: []asb.SubscriptionDefinition{{Name: "audit"}}}},
+		},
+	}
+	assert.NoError(t, env.newConfigStorage("").Save(storage))
+
+	code := run(env, []string{"apply", "--conn", "test-connection", "--topology", "orders", "--dry-run"})
+
+	assert.Equal(t, exitOK, code)
+	assert.Contains(t, stdout.String(), "Dry run, 3 change(s) would be made")
+	assert.Empty(t, administrator.Topologies["test.azure.com"].Queues)
+
+	stdout.Reset()
+	code = run(env, []string{"apply", "--conn", "test-connection", "--topology", "orders", "--output", "json"})
+
+	assert.Equal(t, exitOK, code)
+	var output map[string]any
+	assert.NoError(t, json.Unmarshal(stdout.Bytes(), &output))
+	assert.Len(t, output["result"].(map[string]any)["changes"], 3)
+	assert.Len(t, administrator.Topologies["test.azure.com"].Topics[0].Subscriptions, 1)
+}
+
+func Test_Run_Should_Report_Unknown_Topology(t *testing.T) {
+	env, _, _, _, _ := createTestEnvironment()
+
+	code := run(env, []string{"apply", "--conn", "test-connection", "--topology", "missing"})
+
+	assert.Equal(t, exitError, code)
+}
+
+func Test_Run_Should_Send_Message_With_Session(t *testing.T) {
+	env, _, _, sender, _ := createTestEnvironment()
+
+	code := run(env, []string{"send", "--conn", "test-connection", "--dest", "queue", "--msg", "test-message", "--session-id", "order-42"})
+
+	assert.Equal(t, exitOK, code)
+	assert.Equal(t, "order-42", sender.Message.SessionID)
+}
+
+func Test_Run_Should_Peek_Next_Available_Session(t *testing.T) {
+	env, stdout, _, _, receiver := createTestEnvironment()
+	receiver.Add(asb.Source{Destination: "queue", SessionID: "order-42"}, asb.ReceivedMessage{
+		SequenceNumber: 3,
+		Message:        asb.Message{SessionID: "order-42", Body: "stuck"},
+	})
+
+	code := run(env, []string{"peek", "--conn", "test-connection", "--dest", "queue", "--next-session"})
+
+	assert.Equal(t, exitOK, code)
+	assert.Contains(t, stdout.String(), "SessionID: order-42")
+	assert.Contains(t, stdout.String(), "stuck")
+}
+
+func Test_Run_Should_Not_Combine_Session_Flags(t *testing.T) {
+	env, _, _, _, _ := createTestEnvironment()
+
+	code := run(env, []string{"peek", "--conn", "test-connection", "--dest", "queue", "--session", "a", "--next-session"})
+
+	assert.Equal(t, exitUsage, code)
+}
+
+func Test_Run_Should_Set_Get_And_Clear_Session_State(t *testing.T) {
+	env, stdout, _, _, _ := createTestEnvironment()
+	session := []string{"--conn", "test-connection", "--dest", "queue", "--session", "order-42"}
+
+	code := run(env, append([]string{"session", "set", "--state", `{"step": 2}`}, session...))
+	assert.Equal(t, exitOK, code)
+	assert.Contains(t, stdout.String(), "Set session state of queue (session order-42)")
+
+	stdout.Reset()
+	code = run(env, append([]string{"session", "get", "--output", "json"}, session...))
+	assert.Equal(t, exitOK, code)
+	var output map[string]any
+	assert.NoError(t, json.Unmarshal(stdout.Bytes(), &output))
+	assert.Equal(t, `{"step": 2}`, output["result"].(map[string]any)["state"])
+
+	code = run(env, append([]string{"session", "clear"}, session...))
+	assert.Equal(t, exitOK, code)
+	stdout.Reset()
+	code = run(env, append([]string{"session", "get", "--output", "json"}, session...))
+	assert.Equal(t, exitOK, code)
+	assert.NoError(t, json.Unmarshal(stdout.Bytes(), &output))
+	assert.Nil(t, output["result"].(map[string]any)["state"])
+}
+
+func Test_Run_Should_Report_Session_Accepted_For_Next_Session(t *testing.T) {
+	env, stdout, stderr, _, receiver := createTestEnvironment()
+	receiver.Add(asb.Source{Destination: "queue", SessionID: "order-42"}, asb.ReceivedMessage{SequenceNumber: 1})
+	receiver.SessionStates = map[asb.Source][]byte{{Destination: "queue", SessionID: "order-42"}: []byte("paid")}
+
+	code := run(env, []string{"session", "get", "--conn", "test-connection", "--dest", "queue", "--next-session"})
+
+	assert.Equal(t, exitOK, code)
+	assert.Equal(t, "paid\n", stdout.String())
+	assert.Contains(t, stderr.String(), "State of queue (session order-42):")
+}
+
+func Test_Run_Should_Not_Change_State_Of_Next_Session(t *testing.T) {
+	env, _, _, _, _ := createTestEnvironment()
+
+	for _, action := range [][]string{{"set", "--state", "paid"}, {"clear"}} {
+		args := append([]string{"session"}, action...)
+		code := run(env, append(args, "--conn", "test-connection", "--dest", "queue", "--next-session"))
+
+		assert.Equal(t, exitUsage, code, action[0])
+	}
+}
+
+func Test_Run_Should_Require_Session_For_Session_State(t *testing.T) {
+	env, _, _, _, _ := createTestEnvironment()
+
+	code := run(env, []string{"session", "get", "--conn", "test-connection", "--dest", "queue"})
+
+	assert.Equal(t, exitUsage, code)
+}
+
+func Test_Run_Should_Settle_Messages_By_Sequence_Number(t *testing.T) {
+	env, stdout, _, _, receiver := createTestEnvironment()
+	source := asb.Source{Destination: "queue"}
+	receiver.Add(source, asb.ReceivedMessage{SequenceNumber: 1}, asb.ReceivedMessage{SequenceNumber: 2}, asb.ReceivedMessage{SequenceNumber: 3})
+
+	code := run(env, []string{
+		"settle", "dead-letter", "--conn", "test-connection", "--dest", "queue", "--seq", "2", "--scan",
+		"--reason", "invalid", "--description", "missing tenant", "--property", "checked=true",
+	})
+
+	assert.Equal(t, exitOK, code)
+	assert.Contains(t, stdout.String(), "Settled (dead-letter) message #2")
+	assert.Contains(t, stdout.String(), "Abandoned 1 other locked message(s)")
+	assert.Len(t, receiver.Messages[source], 2)
+	assert.Equal(t, uint32(1), receiver.Messages[source][0].DeliveryCount)
+	assert.Equal(t, uint32(0), receiver.Messages[source][1].DeliveryCount)
+	deadLettered := receiver.Messages[asb.Source{Destination: "queue", DeadLetter: true}]
+	assert.Len(t, deadLettered, 1)
+	assert.Equal(t, "invalid", deadLettered[0].DeadLetterReason)
+	assert.Equal(t, true, deadLettered[0].CustomProperties["checked"])
+}
+
+func Test_Run_Should_Defer_And_Complete_Deferred_Message(t *testing.T) {
+	env, _, _, _, receiver := createTestEnvironment()
+	source := asb.Source{Destination: "queue"}
+	receiver.Add(source, asb.ReceivedMessage{SequenceNumber: 7})
+	settle := []string{"--conn", "test-connection", "--dest", "queue", "--seq", "7"}
+
+	code := run(env, append([]string{"settle", "defer", "--scan"}, settle...))
+	assert.Equal(t, exitOK, code)
+	assert.Empty(t, receiver.Messages[source])
+	assert.Len(t, receiver.Deferred[source], 1)
+
+	code = run(env, append([]string{"settle", "complete", "--deferred"}, settle...))
+	assert.Equal(t, exitOK, code)
+	assert.Empty(t, receiver.Deferred[source])
+}
+
+func Test_Run_Should_Not_Settle_When_Sequence_Number_Is_Missing(t *testing.T) {
+	env, _, stderr, _, receiver := createTestEnvironment()
+	source := asb.Source{Destination: "queue"}
+	receiver.Add(source, asb.ReceivedMessage{SequenceNumber: 1})
+
+	code := run(env, []string{"settle", "complete", "--conn", "test-connection", "--dest", "queue", "--seq", "1,5", "--scan"})
+
+	assert.Equal(t, exitError, code)
+	assert.Contains(t, stderr.String(), "Can't find messages with sequence numbers: 5, 1 locked message(s) were abandoned")
+	assert.Len(t, receiver.Messages[source], 1)
+}
+
+func Test_Run_Should_Require_Scan_To_Settle_Active_Messages(t *testing.T) {
+	env, _, _, _, receiver := createTestEnvironment()
+	source := asb.Source{Destination: "queue"}
+	receiver.Add(source, asb.ReceivedMessage{SequenceNumber: 1})
+
+	code := run(env, []string{"settle", "complete", "--conn", "test-connection", "--dest", "queue", "--seq", "1"})
+
+	assert.Equal(t, exitUsage, code)
+	assert.Len(t, receiver.Messages[source], 1)
+}
+
+func Test_Run_Should_Require_Sequence_Number_To_Settle(t *testing.T) {
+	env, _, _, _, _ := createTestEnvironment()
+
+	code := run(env, []string{"settle", "complete", "--conn", "test-connection", "--dest", "queue"})
+
+	assert.Equal(t, exitUsage, code)
+}
+
+func Test_Run_Should_Record_Saved_Message_Name_In_History(t *testing.T) {
+	env, _, _, _, _ := createTestEnvironment()
+
+	code := run(env, []string{"send", "--conn", "test-connection", "--dest", "queue", "--msg", "test-mes"})
+
+	assert.Equal(t, exitOK, code)
+	entries, err := env.newHistoryStorage("").Load()
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "test-message", entries[0].MessageName)
+	assert.Equal(t, "test-connection", entries[0].Connection)
+}
+
+func Test_Run_Should_Send_Preset_With_Variables(t *testing.T) {
+	env, _, _, sender, _ := createTestEnvironment()
+	storage := env.newConfigStorage("")
+	saved, _ := storage.Load()
+	saved.Messages["order-created"] = asb.Message{Body: `{{ var "orderId" "1" }}-{{ var "tenant" "none" }}`}
+	saved.Presets = map[string]config.Preset{
+		"order-created-dev": {
+			Connection:  "test-connection",
+			Destination: "topic",
+			Message:     "order-created",
+			Variables:   map[string]string{"orderId": "42", "tenant": "acme"},
+		},
+	}
+	assert.NoError(t, storage.Save(saved))
+
+	code := run(env, []string{"send", "--preset", "order-created-dev", "--var", "tenant=contoso"})
+
+	assert.Equal(t, exitOK, code)
+	assert.Equal(t, "test.azure.com", sender.Namespace)
+	assert.Equal(t, "topic", sender.Destination)
+	assert.Equal(t, "42-contoso", sender.Message.Body)
+
+	code = run(env, []string{"send", "--preset", "order-created-dev", "--dest", "queue"})
+
+	assert.Equal(t, exitOK, code)
+	assert.Equal(t, "queue", sender.Destination)
+	assert.Equal(t, "42-acme", sender.Message.Body)
+}
+
+func Test_Run_Should_Return_Error_Code_When_Preset_Is_Unknown(t *testing.T) {
+	env, _, stderr, _, _ := createTestEnvironment()
+
+	code := run(env, []string{"send", "--preset", "unknown"})
+
+	assert.Equal(t, exitError, code)
+	assert.Contains(t, stderr.String(), "Can't find preset with name: unknown")
+}
+
+func Test_Run_Should_Print_Received_Messages_Before_Failing(t *testing.T) {
+	env, stdout, stderr, _, receiver := createTestEnvironment()
+	receiver.Add(asb.Source{Destination: "queue"}, asb.ReceivedMessage{Message: asb.Message{Body: "removed"}, SequenceNumber: 1})
+	receiver.ReceiveError = errors.New("connection lost")
+
+	code := run(env, []string{"receive", "--conn", "test-connection", "--dest", "queue"})
+
+	assert.Equal(t, exitError, code)
+	assert.Contains(t, stdout.String(), "removed")
+	assert.Contains(t, stderr.String(), "connection lost")
+}
+
+func Test_Run_Should_Export_Received_Messages_Before_Failing(t *testing.T) {
+	env, _, _, _, receiver := createTestEnvironment()
+	receiver.Add(asb.Source{Destination: "queue"}, asb.ReceivedMessage{Message: asb.Message{Body: "removed"}, SequenceNumber: 1})
+	receiver.ReceiveError = errors.New("connection lost")
+	path := filepath.Join(t.TempDir(), "removed.jsonl")
+
+	code := run(env, []string{"export", "--conn", "test-connection", "--dest", "queue", "--receive", "--out", path})
+
+	assert.Equal(t, exitError, code)
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "removed")
+}
@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// ANSI escape codes used to colorize JSON bodies
+const (
+	colorReset   = "\033[0m"
+	colorKey     = "\033[33m"
+	colorString  = "\033[32m"
+	colorNumber  = "\033[36m"
+	colorLiteral = "\033[35m"
+)
+
+// colorizeBody indents and colorizes JSON bodies, other bodies are returned unchanged
+func colorizeBody(body string) string {
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, []byte(body), "", "  "); err != nil {
+		return body
+	}
+	text := indented.String()
+
+	var colorized strings.Builder
+	for i := 0; i < len(text); {
+		switch c := text[i]; {
+		case c == '"':
+			end := stringEnd(text, i)
+			color := colorString
+			if strings.HasPrefix(strings.TrimLeft(text[end:], " "), ":") {
+				color = colorKey
+			}
+			colorized.WriteString(color + text[i:end] + colorReset)
+			i = end
+		case c == '-' || (c >= '0' && c <= '9'):
+			end := i + strings.IndexAny(text[i:]+",", ",\n]} ")
+			colorized.WriteString(colorNumber + text[i:end] + colorReset)
+			i = end
+		case c == 't' || c == 'f' || c == 'n':
+			end := i + strings.IndexAny(text[i:]+",", ",\n]} ")
+			colorized.WriteString(colorLiteral + text[i:end] + colorReset)
+			i = end
+		default:
+			colorized.WriteByte(c)
+			i++
+		}
+	}
+
+	return colorized.String()
+}
+
+// stringEnd returns the index after the closing quote of the string starting at start
+func stringEnd(text string, start int) int {
+	for i := start + 1; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+
+	return len(text)
+}
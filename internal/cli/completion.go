@@ -0,0 +1,276 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// completeCommand is called by the completion scripts with the words typed so far,
+// the last one being the word to complete
+const completeCommand = "__complete"
+
+var shells = []string{"bash", "zsh", "fish"}
+
+func completionCommand() command {
+	return command{
+		name:        "completion",
+		usage:       "bash|zsh|fish",
+		description: "Print the shell completion script",
+		define:      defineCompletion,
+	}
+}
+
+type completionResult struct {
+	Shell  string `json:"shell"`
+	Script string `json:"script"`
+}
+
+func (result completionResult) printText(env *environment) {
+	fmt.Fprint(env.stdout, result.Script)
+}
+
+func defineCompletion(env *environment, _ string) (*flag.FlagSet, execute) {
+	flags := newFlagSet(env, completionCommand(), &options{})
+
+	return flags, func(args []string) (result, error) {
+		err := parseWithArgs(flags, args)
+		if err != nil {
+			return nil, err
+		}
+		if flags.NArg() != 1 {
+			return nil, usageError{message: "shell is required"}
+		}
+
+		shell := flags.Arg(0)
+		switch shell {
+		case "bash":
+			return completionResult{Shell: shell, Script: bashCompletion}, nil
+		case "zsh":
+			return completionResult{Shell: shell, Script: zshCompletion}, nil
+		case "fish":
+			return completionResult{Shell: shell, Script: fishCompletion}, nil
+		}
+
+		return nil, usageError{message: "unsupported shell '" + shell + "', use bash, zsh or fish"}
+	}
+}
+
+// complete returns the candidates for the last of the words
+func complete(env *environment, words []string) []string {
+	current := ""
+	if len(words) > 0 {
+		current = words[len(words)-1]
+		words = words[:len(words)-1]
+	}
+
+	if len(words) == 0 {
+		if strings.HasPrefix(current, "-") {
+			return withPrefix(flagNames(env, sendCommand(), nil), current)
+		}
+		names := []string{"help"}
+		for _, command := range commands() {
+			names = append(names, command.name)
+		}
+		return withPrefix(names, current)
+	}
+
+	// Running with flags only sends the message
+	name := words[0]
+	if strings.HasPrefix(name, "-") {
+		name = sendCommand().name
+	} else {
+		words = words[1:]
+	}
+	index := slices.IndexFunc(commands(), func(command command) bool { return command.name == name })
+	if index < 0 {
+		return []string{}
+	}
+	command := commands()[index]
+
+	// --flag=value
+	if strings.HasPrefix(current, "-") && strings.Contains(current, "=") {
+		flagName, value, _ := strings.Cut(current, "=")
+		candidates := []string{}
+		for _, candidate := range withPrefix(flagValues(env, strings.TrimLeft(flagName, "-"), words), value) {
+			candidates = append(candidates, flagName+"="+candidate)
+		}
+		return candidates
+	}
+
+	flags := commandFlags(env, command, words)
+	if len(words) > 0 {
+		previous := words[len(words)-1]
+		if strings.HasPrefix(previous, "-") && !strings.Contains(previous, "=") {
+			if takesValue(flags, strings.TrimLeft(previous, "-")) {
+				return withPrefix(flagValues(env, strings.TrimLeft(previous, "-"), words), current)
+			}
+		}
+	}
+
+	if strings.HasPrefix(current, "-") {
+		return withPrefix(flagNames(env, command, words), current)
+	}
+
+	// Positional arguments
+	if len(words) == 0 {
+		if len(command.subcommands) > 0 {
+			return withPrefix(command.subcommands, current)
+		}
+		if command.name == "completion" {
+			return withPrefix(shells, current)
+		}
+	}
+
+	return []string{}
+}
+
+// commandFlags returns the flags the command registers, without running it
+func commandFlags(env *environment, command command, words []string) *flag.FlagSet {
+	probe := *env
+	probe.stdout = io.Discard
+	probe.stderr = io.Discard
+
+	// Subcommands are given before the flags, the flags depend on them
+	subcommand := ""
+	if len(words) > 0 && slices.Contains(command.subcommands, words[0]) {
+		subcommand = words[0]
+	}
+	flags, _ := command.define(&probe, subcommand)
+
+	return flags
+}
+
+func flagNames(env *environment, command command, words []string) []string {
+	names := []string{}
+	commandFlags(env, command, words).VisitAll(func(flag *flag.Flag) {
+		names = append(names, "--"+flag.Name)
+	})
+
+	return names
+}
+
+func takesValue(flags *flag.FlagSet, name string) bool {
+	found := flags.Lookup(name)
+	if found == nil {
+		return false
+	}
+	boolFlag, ok := found.Value.(interface{ IsBoolFlag() bool })
+
+	return !ok || !boolFlag.IsBoolFlag()
+}
+
+// flagValues returns the names from the config that fit the flag
+func flagValues(env *environment, name string, words []string) []string {
+	if name == "output" {
+		return []string{outputText, outputJson}
+	}
+	if name != "conn" && name != "dest" && name != "msg" && name != "topology" && name != "preset" {
+		return []string{}
+	}
+
+	// The config is only read, completing must not create it
+	config, err := env.readConfig(flagValue(words, "config", "config.json"))
+	if err != nil {
+		return []string{}
+	}
+
+	values := []string{}
+	switch name {
+	case "conn":
+		values = slices.Collect(maps.Keys(config.Connections))
+	case "msg":
+		values = slices.Collect(maps.Keys(config.Messages))
+	case "topology":
+		values = slices.Collect(maps.Keys(config.Topologies))
+	case "preset":
+		values = slices.Collect(maps.Keys(config.Presets))
+	case "dest":
+		// Destinations of the selected connection, or of all connections when none is selected
+		selected := flagValue(words, "conn", "")
+		for connectionName, connection := range config.Connections {
+			if len(selected) == 0 || connectionName == selected {
+				values = append(values, connection.Destinations...)
+			}
+		}
+	}
+	slices.Sort(values)
+
+	return slices.Compact(values)
+}
+
+// flagValue finds the value of the flag in the typed words
+func flagValue(words []string, name string, defaultValue string) string {
+	value := defaultValue
+	for i, word := range words {
+		flagName, flagValue, hasValue := strings.Cut(strings.TrimLeft(word, "-"), "=")
+		if !strings.HasPrefix(word, "-") || flagName != name {
+			continue
+		}
+		if hasValue {
+			value = flagValue
+		} else if i+1 < len(words) {
+			value = words[i+1]
+		}
+	}
+
+	return value
+}
+
+func withPrefix(candidates []string, prefix string) []string {
+	filtered := []string{}
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, prefix) {
+			filtered = append(filtered, candidate)
+		}
+	}
+
+	return filtered
+}
+
+const bashCompletion = `# bash completion for busgopher, load with: source <(busgopher completion bash)
+_busgopher() {
+    local line="${COMP_LINE:0:$COMP_POINT}"
+    local -a words
+    read -ra words <<< "$line"
+    [[ "$line" == *" " ]] && words+=("")
+    local cur="${words[${#words[@]}-1]}"
+
+    local IFS=$'\n'
+    COMPREPLY=($(busgopher __complete "${words[@]:1}" 2>/dev/null))
+    # bash splits --flag=value on '=', complete only the value
+    if [[ "$cur" == *=* && "$COMP_WORDBREAKS" == *=* ]]; then
+        COMPREPLY=("${COMPREPLY[@]#*=}")
+    fi
+}
+complete -o default -F _busgopher busgopher
+`
+
+const zshCompletion = `#compdef busgopher
+# zsh completion for busgopher, load with: source <(busgopher completion zsh)
+_busgopher() {
+    local -a candidates
+    candidates=(${(f)"$(busgopher __complete "${(@)words[2,CURRENT]}" 2>/dev/null)"})
+    if (( ${#candidates} )); then
+        compadd -Q -- "${candidates[@]}"
+    else
+        _files
+    fi
+}
+compdef _busgopher busgopher
+`
+
+const fishCompletion = `# fish completion for busgopher, load with: busgopher completion fish | source
+function __busgopher_complete
+    set -l tokens (commandline -opc)
+    set -e tokens[1]
+    set -l current (commandline -ct)
+    busgopher __complete $tokens "$current" 2>/dev/null
+end
+complete -c busgopher -f -a '(__busgopher_complete)'
+complete -c busgopher -l config -r -F
+complete -c busgopher -l body-file -r -F
+`
@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/rafalpienkowski/busgopher/internal/asb"
+	"github.com/rafalpienkowski/busgopher/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Complete_Should_Return_Commands(t *testing.T) {
+	env, _, _, _, _ := createTestEnvironment()
+
+	assert.Equal(t, []string{"receive", "render"}, complete(env, []string{"re"}))
+}
+
+func Test_Complete_Should_Return_Flags_Of_Command(t *testing.T) {
+	env, _, _, _, _ := createTestEnvironment()
+
+	assert.Equal(t, []string{"--subject"}, complete(env, []string{"send", "--sub"}))
+	assert.Equal(t, []string{"--sub"}, complete(env, []string{"peek", "--su"}))
+}
+
+func Test_Complete_Should_Return_Connections(t *testing.T) {
+	env, _, _, _, _ := createTestEnvironment()
+
+	assert.Equal(t, []string{"test-connection"}, complete(env, []string{"send", "--conn", ""}))
+	assert.Equal(t, []string{"--conn=test-connection"}, complete(env, []string{"send", "--conn=te"}))
+}
+
+func Test_Complete_Should_Return_Destinations_Of_Selected_Connection(t *testing.T) {
+	env, _, _, _, _ := createTestEnvironment()
+	storage, _ := env.newConfigStorage("").Load()
+	storage.Connections["other"] = asb.Connection{Namespace: "other", Destinations: []string{"other-queue"}}
+
+	assert.Equal(t, []string{"queue", "topic"}, complete(env, []string{"send", "--conn", "test-connection", "--dest", ""}))
+	assert.Equal(t, []string{"other-queue", "queue", "topic"}, complete(env, []string{"peek", "--dest", ""}))
+}
+
+func Test_Complete_Should_Return_Messages(t *testing.T) {
+	env, _, _, _, _ := createTestEnvironment()
+
+	assert.Equal(t, []string{"test-message"}, complete(env, []string{"render", "--msg", "test"}))
+}
+
+func Test_Complete_Should_Return_Positional_Arguments(t *testing.T) {
+	env, _, _, _, _ := createTestEnvironment()
+
+	assert.Equal(t, []string{"destinations"}, complete(env, []string{"list", "d"}))
+	assert.Equal(t, []string{"--config", "--conn"}, complete(env, []string{"list", "destinations", "--co"}))
+	assert.Equal(t, []string{"zsh"}, complete(env, []string{"completion", "z"}))
+}
+
+func Test_Complete_Should_Not_Create_Missing_Config(t *testing.T) {
+	env, _, _, _, _ := createTestEnvironment()
+	env.readConfig = config.Read
+	path := filepath.Join(t.TempDir(), "config.json")
+
+	assert.Empty(t, complete(env, []string{"send", "--config", path, "--conn", ""}))
+	assert.NoFileExists(t, path)
+}
+
+func Test_Run_Should_Print_Completion_Script(t *testing.T) {
+	env, stdout, _, _, _ := createTestEnvironment()
+
+	code := run(env, []string{"completion", "bash"})
+
+	assert.Equal(t, exitOK, code)
+	assert.Contains(t, stdout.String(), "complete -o default -F _busgopher busgopher")
+}
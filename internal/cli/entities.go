@@ -0,0 +1,308 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/rafalpienkowski/busgopher/internal/asb"
+	"github.com/rafalpienkowski/busgopher/internal/controller"
+)
+
+func createCommand() command {
+	return command{
+		name:        "create",
+		usage:       "queue|topic|subscription --conn <connection> --name <name> [--topic <topic>] [flags]",
+		description: "Create a queue, topic or subscription",
+		subcommands: []string{asb.EntityQueue, asb.EntityTopic, asb.EntitySubscription},
+		define:      defineCreate,
+	}
+}
+
+func deleteCommand() command {
+	return command{
+		name:        "delete",
+		usage:       "queue|topic|subscription --conn <connection> --name <name> [--topic <topic>] [--yes]",
+		description: "Delete a queue, topic (with its subscriptions) or subscription",
+		subcommands: []string{asb.EntityQueue, asb.EntityTopic, asb.EntitySubscription},
+		define:      defineDelete,
+	}
+}
+
+func ruleCommand() command {
+	return command{
+		name:        "rule",
+		usage:       "list|add|delete --conn <connection> --topic <topic> --sub <subscription> [--name <rule>] [flags]",
+		description: "List, add or delete the filter rules of a subscription",
+		subcommands: []string{"list", "add", "delete"},
+		define:      defineRule,
+	}
+}
+
+func applyCommand() command {
+	return command{
+		name:        "apply",
+		usage:       "--conn <connection> --topology <topology> [--dry-run]",
+		description: "Create or update the queues, topics, subscriptions and rules of a topology from the config",
+		define:      defineApply,
+	}
+}
+
+type changesResult struct {
+	DryRun  bool                      `json:"dryRun,omitempty"`
+	Changes []controller.EntityChange `json:"changes"`
+}
+
+func (result changesResult) printText(env *environment) {
+	writer := tabwriter.NewWriter(env.stdout, 0, 4, 2, ' ', 0)
+	defer writer.Flush()
+
+	changed := 0
+	for _, change := range result.Changes {
+		fmt.Fprintf(writer, "%v\t%v\t%v\n", change.Action, change.Kind, change.Path)
+		if change.Changed() {
+			changed++
+		}
+	}
+	if result.DryRun {
+		fmt.Fprintf(writer, "Dry run, %v change(s) would be made\n", changed)
+	}
+}
+
+type rulesResult struct {
+	Subscription string               `json:"subscription"`
+	Rules        []asb.RuleDefinition `json:"rules"`
+}
+
+func (result rulesResult) printText(env *environment) {
+	writer := tabwriter.NewWriter(env.stdout, 0, 4, 2, ' ', 0)
+	defer writer.Flush()
+
+	for _, rule := range result.Rules {
+		fmt.Fprintf(writer, "%v\t%v\t%v\n", rule.Name, rule.FilterText(), rule.Action)
+	}
+}
+
+// entityFlags registers the properties of queues and subscriptions
+type entityFlags struct {
+	lockDuration          string
+	maxDeliveryCount      int
+	requiresSession       bool
+	deadLetteringOnExpiry bool
+	forwardTo             string
+}
+
+func (entity *entityFlags) register(flags *flag.FlagSet) {
+	flags.StringVar(&entity.lockDuration, "lock-duration", "", "Lock duration, e.g. 30s")
+	flags.IntVar(&entity.maxDeliveryCount, "max-delivery-count", 0, "Deliveries before a message is dead-lettered")
+	flags.BoolVar(&entity.requiresSession, "requires-session", false, "Require sessions")
+	flags.BoolVar(&entity.deadLetteringOnExpiry, "dead-letter-on-expiration", false, "Dead-letter expired messages")
+	flags.StringVar(&entity.forwardTo, "forward-to", "", "Queue or topic to forward messages to")
+}
+
+func defineCreate(env *environment, kind string) (*flag.FlagSet, execute) {
+	options := &options{}
+	flags := newFlagSet(env, createCommand(), options)
+	flags.StringVar(&options.connection, "conn", "", "Saved connection name")
+	name := flags.String("name", "", "Name of the "+kind)
+	timeToLive := flags.String("ttl", "", "Default message time to live, e.g. 24h")
+	required := []string{"conn", "name"}
+	properties := entityFlags{}
+	topic := new(string)
+	if kind != asb.EntityTopic {
+		properties.register(flags)
+	}
+	if kind == asb.EntitySubscription {
+		flags.StringVar(topic, "topic", "", "Topic of the subscription")
+		required = append(required, "topic")
+	}
+
+	return flags, func(args []string) (result, error) {
+		err := parse(flags, args, required...)
+		if err != nil {
+			return nil, err
+		}
+		if properties.maxDeliveryCount < 0 {
+			return nil, usageError{message: "flag --max-delivery-count can't be negative"}
+		}
+
+		ctrl, err := env.newConnectedController(options)
+		if err != nil {
+			return nil, err
+		}
+
+		path := *name
+		switch kind {
+		case asb.EntityQueue:
+			err = ctrl.CreateQueue(asb.QueueDefinition{
+				Name:                             *name,
+				LockDuration:                     properties.lockDuration,
+				DefaultMessageTimeToLive:         *timeToLive,
+				MaxDeliveryCount:                 int32(properties.maxDeliveryCount),
+				RequiresSession:                  properties.requiresSession,
+				DeadLetteringOnMessageExpiration: properties.deadLetteringOnExpiry,
+				ForwardTo:                        properties.forwardTo,
+			})
+		case asb.EntityTopic:
+			err = ctrl.CreateTopic(asb.TopicDefinition{Name: *name, DefaultMessageTimeToLive: *timeToLive})
+		case asb.EntitySubscription:
+			path = asb.Entity{Kind: kind, Name: *name, Topic: *topic}.Path()
+			err = ctrl.CreateSubscription(*topic, asb.SubscriptionDefinition{
+				Name:                             *name,
+				LockDuration:                     properties.lockDuration,
+				DefaultMessageTimeToLive:         *timeToLive,
+				MaxDeliveryCount:                 int32(properties.maxDeliveryCount),
+				RequiresSession:                  properties.requiresSession,
+				DeadLetteringOnMessageExpiration: properties.deadLetteringOnExpiry,
+				ForwardTo:                        properties.forwardTo,
+			})
+		}
+		if err != nil {
+			return nil, codedError{code: "admin_failed", err: err}
+		}
+
+		return changesResult{Changes: []controller.EntityChange{
+			{Action: controller.ChangeCreated, Kind: kind, Path: path},
+		}}, nil
+	}
+}
+
+func defineDelete(env *environment, kind string) (*flag.FlagSet, execute) {
+	options := &options{exact: true}
+	flags := newFlagSet(env, deleteCommand(), options)
+	flags.StringVar(&options.connection, "conn", "", "Saved connection name")
+	entity := asb.Entity{Kind: kind}
+	flags.StringVar(&entity.Name, "name", "", "Name of the "+kind)
+	required := []string{"conn", "name"}
+	if kind == asb.EntitySubscription {
+		flags.StringVar(&entity.Topic, "topic", "", "Topic of the subscription")
+		required = append(required, "topic")
+	}
+	yes := flags.Bool("yes", false, "Don't ask for confirmation")
+
+	return flags, func(args []string) (result, error) {
+		err := parse(flags, args, required...)
+		if err != nil {
+			return nil, err
+		}
+
+		ctrl, err := env.newConnectedController(options)
+		if err != nil {
+			return nil, err
+		}
+		if !*yes {
+			question := fmt.Sprintf("Delete %v %v with all its messages? Type 'yes' to confirm: ", kind, entity.Path())
+			if !env.confirm(question) {
+				return nil, codedError{code: "aborted", err: errors.New("Deletion not confirmed")}
+			}
+		}
+
+		err = ctrl.DeleteEntity(entity)
+		if err != nil {
+			return nil, codedError{code: "admin_failed", err: err}
+		}
+
+		return changesResult{Changes: []controller.EntityChange{
+			{Action: controller.ChangeDeleted, Kind: kind, Path: entity.Path()},
+		}}, nil
+	}
+}
+
+func defineRule(env *environment, action string) (*flag.FlagSet, execute) {
+	options := &options{exact: true}
+	flags := newFlagSet(env, ruleCommand(), options)
+	flags.StringVar(&options.connection, "conn", "", "Saved connection name")
+	topic := flags.String("topic", "", "Topic of the subscription")
+	flags.StringVar(&options.subscription, "sub", "", "Subscription")
+	required := []string{"conn", "topic", "sub"}
+	rule := asb.RuleDefinition{}
+	if action != "list" {
+		flags.StringVar(&rule.Name, "name", "", "Name of the rule")
+		required = append(required, "name")
+	}
+	if action == "add" {
+		flags.StringVar(&rule.SQL, "sql", "", "SQL filter, e.g. \"subject = 'created' AND tenant = 'acme'\"")
+		flags.Func("correlation", "Correlation filter property as name=value, e.g. subject=created or app.tenant=acme (repeatable)", func(term string) error {
+			if rule.Correlation == nil {
+				rule.Correlation = &asb.CorrelationFilter{}
+			}
+			return rule.Correlation.Set(term)
+		})
+		flags.StringVar(&rule.Action, "action", "", "SQL action, e.g. \"SET priority = 'high'\"")
+	}
+
+	return flags, func(args []string) (result, error) {
+		err := parse(flags, args, required...)
+		if err != nil {
+			return nil, err
+		}
+		if action == "add" {
+			if err := rule.Validate(); err != nil {
+				return nil, usageError{message: err.Error()}
+			}
+		}
+
+		ctrl, err := env.newConnectedController(options)
+		if err != nil {
+			return nil, err
+		}
+
+		path := *topic + "/subscriptions/" + options.subscription
+		switch action {
+		case "list":
+			rules, err := ctrl.GetRules(*topic, options.subscription)
+			if err != nil {
+				return nil, codedError{code: "admin_failed", err: err}
+			}
+			return rulesResult{Subscription: path, Rules: rules}, nil
+		case "add":
+			err = ctrl.AddRule(*topic, options.subscription, rule)
+		case "delete":
+			err = ctrl.DeleteRule(*topic, options.subscription, rule.Name)
+		}
+		if err != nil {
+			return nil, codedError{code: "admin_failed", err: err}
+		}
+
+		change := controller.ChangeCreated
+		if action == "delete" {
+			change = controller.ChangeDeleted
+		}
+		return changesResult{Changes: []controller.EntityChange{
+			{Action: change, Kind: "rule", Path: path + "/rules/" + rule.Name},
+		}}, nil
+	}
+}
+
+func defineApply(env *environment, _ string) (*flag.FlagSet, execute) {
+	options := &options{exact: true}
+	flags := newFlagSet(env, applyCommand(), options)
+	flags.StringVar(&options.connection, "conn", "", "Saved connection name")
+	topology := flags.String("topology", "", "Name of the topology in the config")
+	dryRun := flags.Bool("dry-run", false, "Only print the changes that would be made")
+
+	return flags, func(args []string) (result, error) {
+		err := parse(flags, args, "conn", "topology")
+		if err != nil {
+			return nil, err
+		}
+
+		ctrl, err := env.newConnectedController(options)
+		if err != nil {
+			return nil, err
+		}
+
+		changes, err := ctrl.Apply(*topology, *dryRun)
+		result := changesResult{DryRun: *dryRun, Changes: changes}
+		var notFound controller.NotFoundError
+		if errors.As(err, &notFound) {
+			return nil, err
+		}
+		if err != nil {
+			return result, codedError{code: "admin_failed", err: err}
+		}
+
+		return result, nil
+	}
+}
@@ -0,0 +1,41 @@
+package cli
+
+import (
+	"flag"
+	"time"
+
+	"github.com/rafalpienkowski/busgopher/internal/filter"
+)
+
+// addFilterFlags registers the flags selecting messages, the filter is built while parsing
+func addFilterFlags(flags *flag.FlagSet) *filter.Filter {
+	messageFilter := &filter.Filter{}
+	flags.Func("where", "Broker property condition as name=value, e.g. subject=created (repeatable)", messageFilter.AddBrokerProperty)
+	flags.Func("property", "Application property condition as name=value (repeatable)", messageFilter.AddApplicationProperty)
+	flags.StringVar(&messageFilter.BodyContains, "contains", "", "Text the body has to contain")
+	flags.Func("matches", "Regular expression the body has to match", messageFilter.SetBodyMatches)
+	flags.Func("jsonpath", "JSONPath expression that has to exist in the JSON body, e.g. $.order.id", messageFilter.SetBodyPath)
+	flags.Func("jsonpath-equals", "Value the --jsonpath expression has to be equal to", func(value string) error {
+		messageFilter.BodyPathEquals = &value
+		return nil
+	})
+	flags.Func("after", "Show messages enqueued after the time (RFC 3339, '2006-01-02 15:04:05', or a duration ago like 1h)", func(text string) (err error) {
+		messageFilter.EnqueuedAfter, err = filter.ParseTime(text, time.Now())
+		return err
+	})
+	flags.Func("before", "Show messages enqueued before the time", func(text string) (err error) {
+		messageFilter.EnqueuedBefore, err = filter.ParseTime(text, time.Now())
+		return err
+	})
+
+	return messageFilter
+}
+
+// validateFilter reports flags that only make sense together
+func validateFilter(messageFilter *filter.Filter) error {
+	if messageFilter.BodyPathEquals != nil && messageFilter.BodyPath == nil {
+		return usageError{message: "flag --jsonpath-equals requires --jsonpath"}
+	}
+
+	return nil
+}
@@ -0,0 +1,142 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/rafalpienkowski/busgopher/internal/asb"
+	"github.com/rafalpienkowski/busgopher/internal/controller"
+)
+
+func listCommand() command {
+	return command{
+		name:        "list",
+		usage:       "connections|messages|destinations [--conn <connection>] [--discover [--save]] [flags]",
+		description: "List saved connections, messages or destinations of a connection",
+		subcommands: []string{"connections", "messages", "destinations"},
+		define:      defineList,
+	}
+}
+
+type listItem struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	Subject   string `json:"subject,omitempty"`
+	// Kind and Saved are set for discovered entities
+	Kind  string `json:"kind,omitempty"`
+	Saved *bool  `json:"saved,omitempty"`
+}
+
+type listResult struct {
+	Kind  string     `json:"kind"`
+	Items []listItem `json:"items"`
+}
+
+func (result listResult) printText(env *environment) {
+	writer := tabwriter.NewWriter(env.stdout, 0, 4, 2, ' ', 0)
+	defer writer.Flush()
+
+	for _, item := range result.Items {
+		switch result.Kind {
+		case "connections":
+			fmt.Fprintf(writer, "%v\t%v\n", item.Name, item.Namespace)
+		case "messages":
+			fmt.Fprintf(writer, "%v\t%v\n", item.Name, item.Subject)
+		case "entities":
+			state := ""
+			if item.Saved != nil && !*item.Saved {
+				state = "not saved"
+			}
+			fmt.Fprintf(writer, "%v\t%v\t%v\n", item.Name, item.Kind, state)
+		default:
+			fmt.Fprintln(writer, item.Name)
+		}
+	}
+}
+
+func defineList(env *environment, what string) (*flag.FlagSet, execute) {
+	options := &options{}
+	flags := newFlagSet(env, listCommand(), options)
+	required := []string{}
+	discover, save := new(bool), new(bool)
+	switch what {
+	case "connections", "messages":
+	case "destinations":
+		flags.StringVar(&options.connection, "conn", "", "Saved connection name")
+		flags.BoolVar(discover, "discover", false, "List the queues, topics and subscriptions of the namespace")
+		flags.BoolVar(save, "save", false, "Add the discovered queues and topics to the connection in the config")
+		required = append(required, "conn")
+	}
+
+	return flags, func(args []string) (result, error) {
+		err := parse(flags, args, required...)
+		if err != nil {
+			return nil, err
+		}
+		if *save && !*discover {
+			return nil, usageError{message: "flag --save requires --discover"}
+		}
+
+		ctrl, err := env.newController(options)
+		if err != nil {
+			return nil, err
+		}
+
+		result := listResult{Kind: what, Items: []listItem{}}
+		switch what {
+		case "connections":
+			for _, connection := range ctrl.GetConnections() {
+				result.Items = append(result.Items, listItem{Name: connection.Name, Namespace: connection.Namespace})
+			}
+		case "messages":
+			for _, message := range ctrl.GetMessages() {
+				result.Items = append(result.Items, listItem{Name: message.Name, Subject: message.Message.Subject})
+			}
+		case "destinations":
+			err = ctrl.SelectConnectionByName(options.connection)
+			if err != nil {
+				return nil, codedError{code: "not_found", err: err}
+			}
+			if *discover {
+				return discoverEntities(ctrl, *save)
+			}
+			for _, destination := range ctrl.GetDestiationNamesForSelectedConnection() {
+				result.Items = append(result.Items, listItem{Name: destination})
+			}
+		}
+		if what != "destinations" {
+			slices.SortFunc(result.Items, func(a, b listItem) int { return strings.Compare(a.Name, b.Name) })
+		}
+
+		return result, nil
+	}
+}
+
+func discoverEntities(ctrl *controller.Controller, save bool) (result, error) {
+	entities, err := ctrl.DiscoverEntities()
+	if err != nil {
+		return nil, codedError{code: "discovery_failed", err: err}
+	}
+	if save {
+		_, err = ctrl.SaveDiscoveredDestinations()
+		if err != nil {
+			return nil, codedError{code: "invalid_config", err: err}
+		}
+	}
+
+	unsaved := ctrl.GetUnsavedDestinations()
+	result := listResult{Kind: "entities", Items: []listItem{}}
+	for _, entity := range entities {
+		item := listItem{Name: entity.Path(), Kind: entity.Kind}
+		if entity.Kind != asb.EntitySubscription {
+			item.Saved = new(bool)
+			*item.Saved = !slices.Contains(unsaved, entity.Name)
+		}
+		result.Items = append(result.Items, item)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,124 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rafalpienkowski/busgopher/internal/asb"
+)
+
+func purgeCommand() command {
+	return command{
+		name:        "purge",
+		usage:       "--conn <connection> --dest <queue|topic> [--sub <subscription>] [--dlq] [filters] [--yes]",
+		description: "Delete all messages, or the ones matching the filters",
+		define:      definePurge,
+	}
+}
+
+type purgeResult struct {
+	Source string `json:"source"`
+	Purged int    `json:"purged"`
+	// Released is the number of kept messages locked by a filtered purge, their delivery count was raised
+	Released   int   `json:"released"`
+	DurationMs int64 `json:"durationMs"`
+}
+
+func (result purgeResult) printText(env *environment) {
+	fmt.Fprintf(
+		env.stdout,
+		"Purged %v message(s) from %v in %v\n",
+		result.Purged,
+		result.Source,
+		(time.Duration(result.DurationMs) * time.Millisecond).String(),
+	)
+	if result.Released > 0 {
+		fmt.Fprintf(env.stdout, "Released %v kept message(s) locked on the way, their delivery count was raised by one\n", result.Released)
+	}
+}
+
+func definePurge(env *environment, _ string) (*flag.FlagSet, execute) {
+	options := &options{exact: true}
+	flags := newFlagSet(env, purgeCommand(), options)
+	options.addConnectionFlags(flags)
+	flags.StringVar(&options.subscription, "sub", "", "Subscription of the topic given in --dest")
+	deadLetter := flags.Bool("dlq", false, "Purge the dead-letter queue")
+	parallel := flags.Int("parallel", 4, "Number of parallel receivers, filtered purges use one")
+	yes := flags.Bool("yes", false, "Don't ask for confirmation")
+	messageFilter := addFilterFlags(flags)
+
+	return flags, func(args []string) (result, error) {
+		err := parse(flags, args, "conn", "dest")
+		if err != nil {
+			return nil, err
+		}
+		err = validateFilter(messageFilter)
+		if err != nil {
+			return nil, err
+		}
+		if *parallel < 1 {
+			return nil, usageError{message: "flag --parallel has to be positive"}
+		}
+
+		controller, err := env.newConnectedController(options)
+		if err != nil {
+			return nil, err
+		}
+
+		source := asb.Source{
+			Destination:  options.destination,
+			Subscription: options.subscription,
+			DeadLetter:   *deadLetter,
+		}
+		if !*yes {
+			what := "all messages"
+			if !messageFilter.IsEmpty() {
+				what = "the messages matching the filters"
+			}
+			question := fmt.Sprintf(
+				"Delete %v from %v of connection %v (%v)? Type 'yes' to confirm: ",
+				what,
+				source,
+				controller.GetSelectedConnectionName(),
+				controller.GetSelectedConnection().Namespace,
+			)
+			if !env.confirm(question) {
+				return nil, codedError{code: "aborted", err: errors.New("Purge not confirmed")}
+			}
+		}
+
+		onProgress := func(purged int) {}
+		if env.output == outputText && isTerminal(env.stderr) {
+			onProgress = func(purged int) {
+				fmt.Fprintf(env.stderr, "\rPurged %v message(s)...", purged)
+			}
+			defer fmt.Fprintln(env.stderr)
+		}
+
+		started := time.Now()
+		purged, released, err := controller.Purge(source, *messageFilter, *parallel, onProgress)
+		summary := purgeResult{
+			Source:     source.String(),
+			Purged:     purged,
+			Released:   released,
+			DurationMs: time.Since(started).Milliseconds(),
+		}
+		if err != nil {
+			return summary, codedError{code: "receive_failed", err: err}
+		}
+
+		return summary, nil
+	}
+}
+
+// confirm asks the question on stderr and reads the answer from stdin
+func (env *environment) confirm(question string) bool {
+	fmt.Fprint(env.stderr, question)
+	answer, _ := bufio.NewReader(env.stdin).ReadString('\n')
+
+	return strings.TrimSpace(answer) == "yes"
+}
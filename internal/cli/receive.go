@@ -0,0 +1,163 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/rafalpienkowski/busgopher/internal/asb"
+)
+
+func peekCommand() command {
+	return command{
+		name:        "peek",
+		usage:       "--conn <connection> --dest <queue|topic> [--sub <subscription>] [flags]",
+		description: "Show messages without removing them",
+		define: func(env *environment, _ string) (*flag.FlagSet, execute) {
+			return defineReceive(env, peekCommand(), false)
+		},
+	}
+}
+
+func receiveCommand() command {
+	return command{
+		name:        "receive",
+		usage:       "--conn <connection> --dest <queue|topic> [--sub <subscription>] [flags]",
+		description: "Receive and remove messages",
+		define: func(env *environment, _ string) (*flag.FlagSet, execute) {
+			return defineReceive(env, receiveCommand(), false)
+		},
+	}
+}
+
+func dlqCommand() command {
+	return command{
+		name:        "dlq",
+		usage:       "--conn <connection> --dest <queue|topic> [--sub <subscription>] [--receive] [flags]",
+		description: "Show (or receive with --receive) dead-lettered messages",
+		define: func(env *environment, _ string) (*flag.FlagSet, execute) {
+			return defineReceive(env, dlqCommand(), true)
+		},
+	}
+}
+
+type messagesResult struct {
+	Source   string                `json:"source"`
+	Count    int                   `json:"count"`
+	Messages []asb.ReceivedMessage `json:"messages"`
+}
+
+func (result messagesResult) printText(env *environment) {
+	for _, message := range result.Messages {
+		printMessage(env.stdout, message)
+	}
+}
+
+func defineReceive(env *environment, command command, deadLetter bool) (*flag.FlagSet, execute) {
+	options := &options{}
+	flags := newFlagSet(env, command, options)
+	options.addSourceFlags(flags)
+
+	// peek is the default for the dead-letter queue, receive removes messages
+	receive := command.name == "receive"
+	if deadLetter {
+		flags.BoolVar(&receive, "receive", false, "Receive and remove the messages instead of peeking them")
+	} else {
+		options.addSessionFlags(flags)
+	}
+	fromSequenceNumber := flags.Int64("from-seq", 0, "Sequence number to start peeking from")
+	flags.DurationVar(&options.wait, "wait", 5*time.Second, "How long to wait for messages when receiving")
+	messageFilter := addFilterFlags(flags)
+	saveAs := flags.String("save-as", "", "Save the first message to the config as a message with this name")
+	templatize := flags.Bool("templatize", false, "Replace IDs and timestamps with template functions when saving with --save-as")
+
+	return flags, func(args []string) (result, error) {
+		err := parse(flags, args, "conn", "dest")
+		if err != nil {
+			return nil, err
+		}
+		// Received messages are removed, the names must be exact
+		options.exact = receive
+		err = validateFilter(messageFilter)
+		if err != nil {
+			return nil, err
+		}
+		err = options.validateSession()
+		if err != nil {
+			return nil, err
+		}
+
+		controller, err := env.newConnectedController(options)
+		if err != nil {
+			return nil, err
+		}
+
+		source := asb.Source{
+			Destination:  options.destination,
+			Subscription: options.subscription,
+			DeadLetter:   deadLetter,
+			SessionID:    options.sessionID,
+			NextSession:  options.nextSession,
+		}
+		var messages []asb.ReceivedMessage
+		if receive {
+			messages, err = controller.ReceiveMatching(source, options.count, options.wait, *messageFilter)
+		} else {
+			messages, err = controller.PeekMatching(source, options.count, *fromSequenceNumber, *messageFilter)
+		}
+		if err != nil {
+			// Received messages are already removed, they are printed before the error
+			if len(messages) > 0 {
+				return messagesResult{Source: source.String(), Count: len(messages), Messages: messages}, codedError{code: "receive_failed", err: err}
+			}
+			return nil, codedError{code: "receive_failed", err: err}
+		}
+		if messages == nil {
+			messages = []asb.ReceivedMessage{}
+		}
+		if len(*saveAs) > 0 {
+			if len(messages) == 0 {
+				return nil, codedError{code: "not_found", err: errors.New("No message to save as: " + *saveAs)}
+			}
+			err = controller.SaveReceivedMessage(*saveAs, messages[0], *templatize)
+			if err != nil {
+				return nil, codedError{code: "invalid_config", err: err}
+			}
+		}
+
+		return messagesResult{Source: source.String(), Count: len(messages), Messages: messages}, nil
+	}
+}
+
+func printMessage(out io.Writer, message asb.ReceivedMessage) {
+	fmt.Fprintf(
+		out,
+		"#%v enqueued at %v, delivery count: %v\n",
+		message.SequenceNumber,
+		message.EnqueuedTime.Format("2006-01-02 15:04:05"),
+		message.DeliveryCount,
+	)
+	printProperty(out, "MessageID", message.MessageID)
+	printProperty(out, "CorrelationID", message.CorrelationID)
+	printProperty(out, "Subject", message.Subject)
+	printProperty(out, "ReplyTo", message.ReplayTo)
+	printProperty(out, "SessionID", message.SessionID)
+	printProperty(out, "ReplyToSessionID", message.ReplyToSessionID)
+	printProperty(out, "ContentType", message.ContentType)
+	printProperty(out, "DeadLetterReason", message.DeadLetterReason)
+	printProperty(out, "DeadLetterDescription", message.DeadLetterDescription)
+	for _, key := range slices.Sorted(maps.Keys(message.CustomProperties)) {
+		fmt.Fprintf(out, "  %v: %v\n", key, message.CustomProperties[key])
+	}
+	fmt.Fprintf(out, "%v\n\n", message.Body)
+}
+
+func printProperty(out io.Writer, name string, value string) {
+	if len(value) > 0 {
+		fmt.Fprintf(out, "  %v: %v\n", name, value)
+	}
+}
@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/rafalpienkowski/busgopher/internal/asb"
+)
+
+func renderCommand() command {
+	return command{
+		name:        "render",
+		usage:       "--msg <message> [flags]",
+		description: "Print a saved message with its body template rendered",
+		define:      defineRender,
+	}
+}
+
+type renderResult struct {
+	asb.Message
+}
+
+func (result renderResult) printText(env *environment) {
+	fmt.Fprintln(env.stdout, result.Print())
+}
+
+func defineRender(env *environment, _ string) (*flag.FlagSet, execute) {
+	options := &options{}
+	flags := newFlagSet(env, renderCommand(), options)
+	flags.StringVar(&options.message, "msg", "", "Saved message name")
+
+	return flags, func(args []string) (result, error) {
+		err := parse(flags, args, "msg")
+		if err != nil {
+			return nil, err
+		}
+
+		controller, err := env.newController(options)
+		if err != nil {
+			return nil, err
+		}
+
+		message, err := controller.RenderMessage(options.message)
+		if err != nil {
+			return nil, err
+		}
+
+		return renderResult{message}, nil
+	}
+}
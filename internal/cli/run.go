@@ -0,0 +1,99 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/rafalpienkowski/busgopher/internal/scenario"
+)
+
+func runCommand() command {
+	return command{
+		name:        "run",
+		usage:       "<scenario file> [--var name=value...] [flags]",
+		description: "Run the steps of a scenario file and report pass/fail per step",
+		define:      defineScenario,
+	}
+}
+
+type runResult struct {
+	scenario.Report
+}
+
+func (result runResult) printText(env *environment) {
+	if len(result.Name) > 0 {
+		fmt.Fprintf(env.stdout, "Scenario: %v\n", result.Name)
+	}
+	passed := 0
+	for i, step := range result.Steps {
+		fmt.Fprintf(env.stdout, "%v. [%v] %v", i+1, strings.ToUpper(step.Status), step.Name)
+		if step.Status != scenario.StatusSkipped {
+			fmt.Fprintf(env.stdout, " (%vms)", step.DurationMs)
+		}
+		fmt.Fprintln(env.stdout)
+		if len(step.Error) > 0 {
+			fmt.Fprintf(env.stdout, "   %v\n", step.Error)
+		}
+		if step.Status == scenario.StatusPassed {
+			passed++
+		}
+	}
+	fmt.Fprintf(env.stdout, "%v of %v step(s) passed\n", passed, len(result.Steps))
+}
+
+func defineScenario(env *environment, _ string) (*flag.FlagSet, execute) {
+	options := &options{}
+	var variables repeatedFlag
+	flags := newFlagSet(env, runCommand(), options)
+	flags.Var(&variables, "var", "Variable as name=value overriding the scenario, can be repeated")
+
+	return flags, func(args []string) (result, error) {
+		// The file may be given before the flags
+		file := ""
+		if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+			file = args[0]
+			args = args[1:]
+		}
+		err := parseWithArgs(flags, args)
+		if err != nil {
+			return nil, err
+		}
+		if len(file) == 0 && flags.NArg() > 0 {
+			file = flags.Arg(0)
+		} else if flags.NArg() > 0 {
+			return nil, usageError{message: "unexpected arguments: " + strings.Join(flags.Args(), " ")}
+		}
+		if len(file) == 0 {
+			return nil, usageError{message: "scenario file is required"}
+		}
+
+		overrides, err := parseVariableFlags(variables)
+		if err != nil {
+			return nil, err
+		}
+
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := scenario.Parse(content)
+		if err != nil {
+			return nil, codedError{code: "invalid_scenario", err: err}
+		}
+
+		controller, err := env.newController(options)
+		if err != nil {
+			return nil, err
+		}
+
+		report := scenario.Run(controller, parsed, overrides)
+		if !report.Passed {
+			return runResult{report}, codedError{code: "scenario_failed", err: errors.New("scenario failed")}
+		}
+
+		return runResult{report}, nil
+	}
+}
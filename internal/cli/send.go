@@ -0,0 +1,302 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"maps"
+	"strings"
+	"time"
+
+	"github.com/rafalpienkowski/busgopher/internal/asb"
+	"github.com/rafalpienkowski/busgopher/internal/config"
+	"github.com/rafalpienkowski/busgopher/internal/controller"
+)
+
+func sendCommand() command {
+	return command{
+		name:        "send",
+		usage:       "--preset <preset>|--conn <connection>|--namespace <namespace> --dest <destination> --msg <message>|--body-file <file|-> [flags]",
+		description: "Send a saved or an ad-hoc message to the destination, or the message of a preset",
+		define:      defineSend,
+	}
+}
+
+type sendResult struct {
+	controller.SendResult
+	Reply *asb.ReceivedMessage `json:"reply,omitempty"`
+}
+
+func (result sendResult) printText(env *environment) {
+	fmt.Fprintf(env.stdout, "Message %v sent to %v\n", result.MessageID, result.Destination)
+	if result.Reply != nil {
+		fmt.Fprintln(env.stdout, "Reply:")
+		printMessage(env.stdout, *result.Reply)
+	}
+}
+
+// repeatedFlag collects the values of a flag given many times
+type repeatedFlag []string
+
+func (values *repeatedFlag) String() string {
+	return strings.Join(*values, ",")
+}
+
+func (values *repeatedFlag) Set(value string) error {
+	*values = append(*values, value)
+	return nil
+}
+
+// sendOptions override the fields of the sent message when they are set
+type sendOptions struct {
+	preset        string
+	variables     repeatedFlag
+	namespace     string
+	bodyFile      string
+	template      bool
+	subject       string
+	messageID     string
+	correlationID string
+	replyTo       string
+	sessionID     string
+	properties    repeatedFlag
+
+	awaitReply       bool
+	replyToSessionID string
+	replyTimeout     time.Duration
+}
+
+func defineSend(env *environment, _ string) (*flag.FlagSet, execute) {
+	options := &options{}
+	sendOptions := &sendOptions{}
+	flags := newFlagSet(env, sendCommand(), options)
+	options.addConnectionFlags(flags)
+	flags.StringVar(&options.message, "msg", "", "Saved message name")
+	flags.StringVar(&sendOptions.preset, "preset", "", "Preset with the connection, destination and message, the flags override it")
+	flags.Var(&sendOptions.variables, "var", "Variable of the body template as name=value, overrides the preset, can be repeated")
+	flags.StringVar(&sendOptions.namespace, "namespace", "", "Namespace to use instead of a saved connection")
+	flags.StringVar(&sendOptions.bodyFile, "body-file", "", "File with the body of an ad-hoc message, - reads stdin")
+	flags.BoolVar(&sendOptions.template, "template", false, "Render the body from --body-file as a template")
+	flags.StringVar(&sendOptions.subject, "subject", "", "Subject of the message")
+	flags.StringVar(&sendOptions.messageID, "message-id", "", "Message ID, generated when empty")
+	flags.StringVar(&sendOptions.correlationID, "correlation-id", "", "Correlation ID of the message")
+	flags.StringVar(&sendOptions.replyTo, "reply-to", "", "Reply to of the message")
+	flags.StringVar(&sendOptions.sessionID, "session-id", "", "Session ID, required by session-enabled entities")
+	flags.Var(&sendOptions.properties, "property", "Custom property as key=value, can be repeated")
+	flags.BoolVar(&sendOptions.awaitReply, "await-reply", false, "Wait for the reply correlated with the sent message on the reply to entity")
+	flags.StringVar(&sendOptions.replyToSessionID, "reply-to-session-id", "", "Session of the reply to entity the reply is expected in")
+	flags.DurationVar(&sendOptions.replyTimeout, "reply-timeout", 30*time.Second, "How long to wait for the reply")
+
+	return flags, func(args []string) (result, error) {
+		err := parse(flags, args)
+		if err != nil {
+			return nil, err
+		}
+		variables, err := parseVariableFlags(sendOptions.variables)
+		if err != nil {
+			return nil, err
+		}
+
+		var ctrl *controller.Controller
+		if len(sendOptions.preset) > 0 {
+			ctrl, err = env.newController(options)
+			if err != nil {
+				return nil, err
+			}
+			preset, err := ctrl.ApplyPreset(sendOptions.preset)
+			if err != nil {
+				return nil, codedError{code: "not_found", err: err}
+			}
+			variables = sendOptions.fromPreset(options, preset, variables)
+		}
+
+		if len(options.destination) == 0 {
+			return nil, usageError{message: "flag --dest is required"}
+		}
+		if (len(options.connection) == 0) == (len(sendOptions.namespace) == 0) {
+			return nil, usageError{message: "one of --conn or --namespace is required"}
+		}
+		if (len(options.message) == 0) == (len(sendOptions.bodyFile) == 0) {
+			return nil, usageError{message: "one of --msg or --body-file is required"}
+		}
+		properties, err := parsePropertyFlags(sendOptions.properties)
+		if err != nil {
+			return nil, err
+		}
+
+		if ctrl == nil {
+			ctrl, err = env.newController(options)
+			if err != nil {
+				return nil, err
+			}
+		}
+		connection, destination, err := selectTarget(ctrl, options.connection, sendOptions.namespace, options.destination)
+		if err != nil {
+			return nil, err
+		}
+
+		var message asb.Message
+		if len(options.message) > 0 {
+			message, err = ctrl.RenderMessageWith(options.message, variables)
+			if err != nil {
+				return nil, codedError{code: "not_found", err: err}
+			}
+		} else {
+			message, err = env.readMessage(sendOptions.bodyFile, sendOptions.template, variables)
+			if err != nil {
+				return nil, err
+			}
+		}
+		sendOptions.apply(&message, properties)
+
+		if !sendOptions.awaitReply {
+			var sent controller.SendResult
+			if len(options.message) > 0 {
+				sent, err = ctrl.SendSavedTo(connection, destination, options.message, message)
+			} else {
+				sent, err = ctrl.SendTo(connection, destination, message)
+			}
+			if err != nil {
+				return nil, codedError{code: "send_failed", err: err}
+			}
+			return sendResult{SendResult: sent}, nil
+		}
+
+		var sent controller.SendResult
+		var reply asb.ReceivedMessage
+		if len(options.message) > 0 {
+			sent, reply, err = ctrl.RequestSavedTo(connection, destination, options.message, message, sendOptions.replyTimeout)
+		} else {
+			sent, reply, err = ctrl.RequestTo(connection, destination, message, sendOptions.replyTimeout)
+		}
+		if err != nil {
+			if len(sent.MessageID) > 0 {
+				return sendResult{SendResult: sent}, codedError{code: "no_reply", err: err}
+			}
+			return nil, codedError{code: "send_failed", err: err}
+		}
+
+		return sendResult{SendResult: sent, Reply: &reply}, nil
+	}
+}
+
+// selectTarget returns the saved connection with the given name and its destination, or the namespace
+// and the destination as they are when no name is given. Only a namespace allows sending to a destination
+// that isn't saved in a connection. Sent messages can't be taken back, so the names must be exact.
+func selectTarget(
+	ctrl *controller.Controller,
+	connectionName string,
+	namespace string,
+	destination string,
+) (asb.Connection, string, error) {
+	if len(connectionName) == 0 {
+		return asb.Connection{Namespace: namespace}, destination, nil
+	}
+	err := ctrl.SelectConnectionByExactName(connectionName)
+	if err != nil {
+		return asb.Connection{}, "", codedError{code: "not_found", err: err}
+	}
+	err = ctrl.SelectDestinationByExactName(destination)
+	if err != nil {
+		return asb.Connection{}, "", codedError{code: "not_found", err: err}
+	}
+
+	return *ctrl.GetSelectedConnection(), ctrl.GetSelectedDestination(), nil
+}
+
+func (env *environment) readMessage(bodyFile string, template bool, variables map[string]string) (asb.Message, error) {
+	body, err := env.readFile(bodyFile)
+	if err != nil {
+		return asb.Message{}, err
+	}
+
+	message := asb.Message{Body: body}
+	if template {
+		message.Body, err = message.TransformBodyWith(variables)
+		if err != nil {
+			return asb.Message{}, err
+		}
+	}
+
+	return message, nil
+}
+
+// fromPreset fills the connection, destination and message not given by the flags from the preset
+// and returns its variables overridden by the given ones
+func (sendOptions *sendOptions) fromPreset(
+	options *options,
+	preset config.Preset,
+	variables map[string]string,
+) map[string]string {
+	if len(options.connection) == 0 && len(sendOptions.namespace) == 0 {
+		options.connection = preset.Connection
+	}
+	if len(options.destination) == 0 {
+		options.destination = preset.Destination
+	}
+	if len(options.message) == 0 && len(sendOptions.bodyFile) == 0 {
+		options.message = preset.Message
+	}
+
+	merged := maps.Clone(preset.Variables)
+	if merged == nil {
+		merged = make(map[string]string)
+	}
+	maps.Copy(merged, variables)
+
+	return merged
+}
+
+func (sendOptions *sendOptions) apply(message *asb.Message, properties map[string]any) {
+	for _, field := range []struct {
+		value  string
+		target *string
+	}{
+		{sendOptions.subject, &message.Subject},
+		{sendOptions.messageID, &message.MessageID},
+		{sendOptions.correlationID, &message.CorrelationID},
+		{sendOptions.replyTo, &message.ReplayTo},
+		{sendOptions.sessionID, &message.SessionID},
+		{sendOptions.replyToSessionID, &message.ReplyToSessionID},
+	} {
+		if len(field.value) > 0 {
+			*field.target = field.value
+		}
+	}
+
+	if len(properties) > 0 {
+		merged := maps.Clone(message.CustomProperties)
+		if merged == nil {
+			merged = make(map[string]any)
+		}
+		maps.Copy(merged, properties)
+		message.CustomProperties = merged
+	}
+}
+
+func parsePropertyFlags(rows []string) (map[string]any, error) {
+	properties := make(map[string]any)
+	for _, row := range rows {
+		key, text, found := strings.Cut(row, "=")
+		key = strings.TrimSpace(key)
+		if !found || len(key) == 0 {
+			return nil, usageError{message: "invalid property '" + row + "', expected key=value"}
+		}
+		properties[key] = asb.ParsePropertyValue(text)
+	}
+
+	return properties, nil
+}
+
+func parseVariableFlags(rows []string) (map[string]string, error) {
+	variables := make(map[string]string)
+	for _, row := range rows {
+		name, value, found := strings.Cut(row, "=")
+		name = strings.TrimSpace(name)
+		if !found || len(name) == 0 {
+			return nil, usageError{message: "invalid variable '" + row + "', expected name=value"}
+		}
+		variables[name] = value
+	}
+
+	return variables, nil
+}
@@ -0,0 +1,134 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/rafalpienkowski/busgopher/internal/asb"
+)
+
+func sessionCommand() command {
+	return command{
+		name:        "session",
+		usage:       "get|set|clear --conn <connection> --dest <queue|topic> [--sub <subscription>] --session <id>|--next-session (get) [--state <text>|--state-file <file>]",
+		description: "Read, set or clear the state of a session",
+		subcommands: []string{"get", "set", "clear"},
+		define:      defineSession,
+	}
+}
+
+type sessionResult struct {
+	Source    string  `json:"source"`
+	SessionID string  `json:"sessionId"`
+	Action    string  `json:"action"`
+	State     *string `json:"state"`
+}
+
+func (result sessionResult) printText(env *environment) {
+	switch {
+	case result.Action == "set":
+		fmt.Fprintf(env.stdout, "Set session state of %v\n", result.Source)
+	case result.Action == "clear":
+		fmt.Fprintf(env.stdout, "Cleared session state of %v\n", result.Source)
+	case result.State == nil:
+		fmt.Fprintf(env.stderr, "Session of %v has no state\n", result.Source)
+	default:
+		fmt.Fprintf(env.stderr, "State of %v:\n", result.Source)
+		fmt.Fprintln(env.stdout, *result.State)
+	}
+}
+
+func defineSession(env *environment, action string) (*flag.FlagSet, execute) {
+	options := &options{}
+	flags := newFlagSet(env, sessionCommand(), options)
+	options.addConnectionFlags(flags)
+	flags.StringVar(&options.subscription, "sub", "", "Subscription of the topic given in --dest")
+	options.addSessionFlags(flags)
+	state := new(string)
+	stateFile := new(string)
+	if action == "set" {
+		flags.StringVar(state, "state", "", "New state")
+		flags.StringVar(stateFile, "state-file", "", "File with the new state, - reads stdin")
+	}
+
+	return flags, func(args []string) (result, error) {
+		err := parse(flags, args, "conn", "dest")
+		if err != nil {
+			return nil, err
+		}
+		err = options.validateSession()
+		if err != nil {
+			return nil, err
+		}
+		if len(options.sessionID) == 0 && !options.nextSession {
+			return nil, usageError{message: "one of --session or --next-session is required"}
+		}
+		if action != "get" && options.nextSession {
+			// The state would be changed in whichever session is accepted
+			return nil, usageError{message: "flag --next-session can only be used with get, " + action + " requires --session"}
+		}
+		if action == "set" && (len(*state) == 0) == (len(*stateFile) == 0) {
+			return nil, usageError{message: "one of --state or --state-file is required"}
+		}
+
+		controller, err := env.newConnectedController(options)
+		if err != nil {
+			return nil, err
+		}
+
+		source := asb.Source{
+			Destination:  options.destination,
+			Subscription: options.subscription,
+			SessionID:    options.sessionID,
+			NextSession:  options.nextSession,
+		}
+		result := sessionResult{Source: source.String(), SessionID: source.SessionID, Action: action}
+		switch action {
+		case "get":
+			sessionID, current, err := controller.GetSessionState(source)
+			if err != nil {
+				return nil, codedError{code: "receive_failed", err: err}
+			}
+			source.SessionID = sessionID
+			source.NextSession = false
+			result.Source = source.String()
+			result.SessionID = sessionID
+			if current != nil {
+				text := string(current)
+				result.State = &text
+			}
+			return result, nil
+		case "set":
+			if len(*stateFile) > 0 {
+				*state, err = env.readFile(*stateFile)
+				if err != nil {
+					return nil, err
+				}
+			}
+			result.State = state
+			err = controller.SetSessionState(source, []byte(*state))
+		case "clear":
+			err = controller.SetSessionState(source, nil)
+		}
+		if err != nil {
+			return nil, codedError{code: "receive_failed", err: err}
+		}
+
+		return result, nil
+	}
+}
+
+// readFile reads the file, - reads stdin
+func (env *environment) readFile(path string) (string, error) {
+	var content []byte
+	var err error
+	if path == "-" {
+		content, err = io.ReadAll(env.stdin)
+	} else {
+		content, err = os.ReadFile(path)
+	}
+
+	return string(content), err
+}
@@ -0,0 +1,182 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rafalpienkowski/busgopher/internal/asb"
+	"github.com/rafalpienkowski/busgopher/internal/controller"
+)
+
+func settleCommand() command {
+	return command{
+		name:        "settle",
+		usage:       "complete|abandon|defer|dead-letter --conn <connection> --dest <queue|topic> [--sub <subscription>] --seq <n[,n]> --deferred|--scan [flags]",
+		description: "Complete, abandon, defer or dead-letter messages by sequence number",
+		subcommands: asb.SettleActions,
+		define:      defineSettle,
+	}
+}
+
+type settleResult struct {
+	Source   string                `json:"source"`
+	Action   string                `json:"action"`
+	Messages []asb.ReceivedMessage `json:"messages"`
+	// Abandoned is the number of other messages locked by --scan, their delivery count was raised
+	Abandoned int `json:"abandoned"`
+}
+
+func (result settleResult) printText(env *environment) {
+	for _, message := range result.Messages {
+		fmt.Fprintf(env.stdout, "Settled (%v) message #%v %v of %v\n", result.Action, message.SequenceNumber, message.MessageID, result.Source)
+	}
+	if result.Abandoned > 0 {
+		fmt.Fprintf(env.stdout, "Abandoned %v other locked message(s), their delivery count was raised by one\n", result.Abandoned)
+	}
+}
+
+// defineSettle settles deferred messages locked by their sequence numbers. With --scan it locks
+// messages from the head of the source until the ones with the sequence numbers are found instead,
+// and abandons the others, which increases their delivery count.
+func defineSettle(env *environment, action string) (*flag.FlagSet, execute) {
+	options := &options{exact: true}
+	flags := newFlagSet(env, settleCommand(), options)
+	options.addConnectionFlags(flags)
+	flags.StringVar(&options.subscription, "sub", "", "Subscription of the topic given in --dest")
+	options.addSessionFlags(flags)
+	flags.IntVar(&options.count, "count", 10, "Maximum number of messages locked by --scan while looking for the sequence numbers")
+	flags.DurationVar(&options.wait, "wait", 5*time.Second, "How long to wait for messages")
+	sequenceNumbers := []int64{}
+	flags.Func("seq", "Sequence numbers of the messages, comma separated or repeated", func(text string) error {
+		for _, part := range strings.Split(text, ",") {
+			sequenceNumber, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+			if err != nil || sequenceNumber <= 0 {
+				return errors.New("invalid sequence number '" + part + "'")
+			}
+			sequenceNumbers = append(sequenceNumbers, sequenceNumber)
+		}
+		return nil
+	})
+	deferred := flags.Bool("deferred", false, "Settle deferred messages")
+	scan := flags.Bool("scan", false, "Lock messages from the head of the source to find the sequence numbers, the others are abandoned")
+	settlement := asb.Settlement{Action: action}
+	properties := repeatedFlag{}
+	if action != asb.SettleComplete {
+		flags.Var(&properties, "property", "Custom property to modify as key=value, can be repeated")
+	}
+	if action == asb.SettleDeadLetter {
+		flags.StringVar(&settlement.Reason, "reason", "", "Dead-letter reason")
+		flags.StringVar(&settlement.Description, "description", "", "Dead-letter error description")
+	}
+
+	return flags, func(args []string) (result, error) {
+		err := parse(flags, args, "conn", "dest")
+		if err != nil {
+			return nil, err
+		}
+		if len(sequenceNumbers) == 0 {
+			return nil, usageError{message: "flag --seq is required"}
+		}
+		if *deferred == *scan {
+			return nil, usageError{message: "one of --deferred or --scan is required"}
+		}
+		if options.count < 1 {
+			return nil, usageError{message: "flag --count has to be positive"}
+		}
+		err = options.validateSession()
+		if err != nil {
+			return nil, err
+		}
+		settlement.Properties, err = parsePropertyFlags(properties)
+		if err != nil {
+			return nil, err
+		}
+
+		ctrl, err := env.newConnectedController(options)
+		if err != nil {
+			return nil, err
+		}
+		defer ctrl.ReleaseLocked()
+
+		source := asb.Source{
+			Destination:  options.destination,
+			Subscription: options.subscription,
+			SessionID:    options.sessionID,
+			NextSession:  options.nextSession,
+		}
+		var found []asb.ReceivedMessage
+		locked := 0
+		if *deferred {
+			found, err = ctrl.ReceiveDeferred(source, sequenceNumbers)
+			locked = len(found)
+		} else {
+			found, locked, err = lockMessages(ctrl, source, sequenceNumbers, options.count, options.wait)
+		}
+		if err != nil {
+			return nil, codedError{code: "receive_failed", err: err}
+		}
+		if len(found) < len(sequenceNumbers) {
+			missing := []string{}
+			for _, sequenceNumber := range sequenceNumbers {
+				if !slices.ContainsFunc(found, func(message asb.ReceivedMessage) bool {
+					return message.SequenceNumber == sequenceNumber
+				}) {
+					missing = append(missing, strconv.FormatInt(sequenceNumber, 10))
+				}
+			}
+			return nil, codedError{
+				code: "not_found",
+				err: fmt.Errorf(
+					"Can't find messages with sequence numbers: %v, %v locked message(s) were abandoned",
+					strings.Join(missing, ", "),
+					locked,
+				),
+			}
+		}
+
+		for _, message := range found {
+			err = ctrl.Settle(message.SequenceNumber, settlement)
+			if err != nil {
+				return nil, codedError{code: "receive_failed", err: err}
+			}
+		}
+
+		return settleResult{Source: source.String(), Action: action, Messages: found, Abandoned: locked - len(found)}, nil
+	}
+}
+
+// lockMessages locks up to count messages of the source until the ones with the sequence numbers are
+// found, and returns them with the number of locked messages
+func lockMessages(
+	ctrl *controller.Controller,
+	source asb.Source,
+	sequenceNumbers []int64,
+	count int,
+	wait time.Duration,
+) ([]asb.ReceivedMessage, int, error) {
+	found := []asb.ReceivedMessage{}
+	locked := 0
+	for locked < count && len(found) < len(sequenceNumbers) {
+		// Only as many messages as are still missing are locked, so few are locked past them
+		messages, err := ctrl.ReceiveLocked(source, min(len(sequenceNumbers)-len(found), count-locked), wait)
+		locked += len(messages)
+		if err != nil {
+			return nil, locked, err
+		}
+		if len(messages) == 0 {
+			break
+		}
+		for _, message := range messages {
+			if slices.Contains(sequenceNumbers, message.SequenceNumber) {
+				found = append(found, message)
+			}
+		}
+	}
+
+	return found, locked, nil
+}
@@ -0,0 +1,117 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rafalpienkowski/busgopher/internal/asb"
+	"github.com/rafalpienkowski/busgopher/internal/controller"
+)
+
+func statsCommand() command {
+	return command{
+		name:        "stats",
+		usage:       "--conn <connection> [--dest <queue|topic>] [--watch <interval>]",
+		description: "Show message counts, size and last access of queues, topics and subscriptions",
+		define:      defineStats,
+	}
+}
+
+type statsResult struct {
+	Entities []asb.EntityStatistics `json:"entities"`
+}
+
+func (result statsResult) printText(env *environment) {
+	writer := tabwriter.NewWriter(env.stdout, 0, 4, 2, ' ', 0)
+	defer writer.Flush()
+
+	fmt.Fprintln(writer, "ENTITY\tKIND\tACTIVE\tDEAD-LETTER\tSCHEDULED\tTRANSFER\tSIZE\tACCESSED")
+	for _, entity := range result.Entities {
+		accessed := "-"
+		if !entity.AccessedAt.IsZero() {
+			accessed = entity.AccessedAt.Local().Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(
+			writer,
+			"%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\n",
+			entity.Path(),
+			entity.Kind,
+			entity.ActiveMessageCount,
+			entity.DeadLetterMessageCount,
+			entity.ScheduledMessageCount,
+			entity.TransferMessageCount,
+			entity.SizeInBytes,
+			accessed,
+		)
+	}
+}
+
+func defineStats(env *environment, _ string) (*flag.FlagSet, execute) {
+	options := &options{}
+	flags := newFlagSet(env, statsCommand(), options)
+	options.addConnectionFlags(flags)
+	watch := flags.Duration("watch", 0, "Print the statistics again every interval until interrupted")
+
+	return flags, func(args []string) (result, error) {
+		err := parse(flags, args, "conn")
+		if err != nil {
+			return nil, err
+		}
+		if *watch < 0 {
+			return nil, usageError{message: "flag --watch can't be negative"}
+		}
+		if *watch > 0 && env.output == outputJson {
+			return nil, usageError{message: "flag --watch can't be used with --output json"}
+		}
+
+		ctrl, err := env.newConnectedController(options)
+		if err != nil {
+			return nil, err
+		}
+		if *watch == 0 {
+			return getStats(ctrl, options.destination)
+		}
+
+		return nil, env.watchStats(ctrl, options.destination, *watch)
+	}
+}
+
+func getStats(ctrl *controller.Controller, destination string) (statsResult, error) {
+	statistics, err := ctrl.GetStatistics(destination)
+	if err != nil {
+		return statsResult{}, codedError{code: "stats_failed", err: err}
+	}
+
+	return statsResult{Entities: statistics}, nil
+}
+
+// watchStats prints the statistics every interval until interrupted, failed refreshes are reported
+// and retried
+func (env *environment) watchStats(ctrl *controller.Controller, destination string, interval time.Duration) error {
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt)
+	defer signal.Stop(interrupted)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		result, err := getStats(ctrl, destination)
+		if err != nil {
+			env.writeLog("Getting statistics failed: " + err.Error())
+		} else {
+			fmt.Fprintf(env.stdout, "[%v]\n", time.Now().Format("15:04:05"))
+			result.printText(env)
+			fmt.Fprintln(env.stdout)
+		}
+
+		select {
+		case <-interrupted:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
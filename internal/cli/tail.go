@@ -0,0 +1,137 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+
+	"github.com/rafalpienkowski/busgopher/internal/asb"
+)
+
+func tailCommand() command {
+	return command{
+		name:        "tail",
+		usage:       "--conn <connection> --dest <queue|topic> [--sub <subscription>] [flags]",
+		description: "Follow new messages without removing them, like tail -f",
+		streaming:   true,
+		define:      defineTail,
+	}
+}
+
+func defineTail(env *environment, _ string) (*flag.FlagSet, execute) {
+	options := &options{}
+	flags := newFlagSet(env, tailCommand(), options)
+	options.addConnectionFlags(flags)
+	flags.StringVar(&options.subscription, "sub", "", "Subscription of the topic given in --dest")
+	flags.IntVar(&options.count, "count", 0, "Stop after this many messages, 0 follows until interrupted")
+	deadLetter := flags.Bool("dlq", false, "Follow the dead-letter queue")
+	fromSequenceNumber := flags.Int64("from-seq", 0, "Sequence number to start from, by default only new messages are shown")
+	interval := flags.Duration("interval", 2*time.Second, "How often to check for new messages")
+	duration := flags.Duration("duration", 0, "Stop after this time, 0 follows until interrupted")
+	color := flags.String("color", "auto", "Colorize bodies: auto, always or never")
+	messageFilter := addFilterFlags(flags)
+
+	return flags, func(args []string) (result, error) {
+		err := parse(flags, args, "conn", "dest")
+		if err != nil {
+			return nil, err
+		}
+		err = validateFilter(messageFilter)
+		if err != nil {
+			return nil, err
+		}
+		if *color != "auto" && *color != "always" && *color != "never" {
+			return nil, usageError{message: "invalid color '" + *color + "', use auto, always or never"}
+		}
+		if *interval <= 0 {
+			return nil, usageError{message: "flag --interval has to be positive"}
+		}
+
+		controller, err := env.newConnectedController(options)
+		if err != nil {
+			return nil, err
+		}
+
+		source := asb.Source{
+			Destination:  options.destination,
+			Subscription: options.subscription,
+			DeadLetter:   *deadLetter,
+		}
+		colorize := *color == "always" || (*color == "auto" && isTerminal(env.stdout))
+
+		// The limit may be reached in the middle of a batch, the rest of it is not printed
+		var mutex sync.Mutex
+		printed := 0
+		limitReached := make(chan struct{})
+		onMessages := func(messages []asb.ReceivedMessage) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			for _, message := range messages {
+				if options.count > 0 && printed == options.count {
+					return
+				}
+				env.printTailed(message, colorize)
+				printed++
+				if printed == options.count {
+					close(limitReached)
+				}
+			}
+		}
+		onError := func(err error) {
+			env.writeLog("Peeking failed: " + err.Error())
+		}
+
+		stop, err := controller.Tail(source, *fromSequenceNumber, *interval, *messageFilter, onMessages, onError)
+		if err != nil {
+			return nil, codedError{code: "receive_failed", err: err}
+		}
+		defer stop()
+
+		interrupted := make(chan os.Signal, 1)
+		signal.Notify(interrupted, os.Interrupt)
+		defer signal.Stop(interrupted)
+		var timeout <-chan time.Time
+		if *duration > 0 {
+			timeout = time.After(*duration)
+		}
+
+		select {
+		case <-interrupted:
+		case <-timeout:
+		case <-limitReached:
+		}
+
+		return nil, nil
+	}
+}
+
+// printTailed prints the message as soon as it arrives, as one JSON line with --output json
+func (env *environment) printTailed(message asb.ReceivedMessage, colorize bool) {
+	if env.output == outputJson {
+		line, _ := json.Marshal(message)
+		fmt.Fprintln(env.stdout, string(line))
+		return
+	}
+
+	fmt.Fprintf(env.stdout, "[%v] ", time.Now().Format("15:04:05"))
+	if colorize {
+		message.Body = colorizeBody(message.Body)
+	}
+	printMessage(env.stdout, message)
+}
+
+// isTerminal tells whether the output is a terminal that may get colors and progress updates
+func isTerminal(out io.Writer) bool {
+	file, ok := out.(*os.File)
+	if !ok || len(os.Getenv("NO_COLOR")) > 0 {
+		return false
+	}
+	info, err := file.Stat()
+
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
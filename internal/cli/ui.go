@@ -0,0 +1,44 @@
+package cli
+
+import (
+	"flag"
+	"github.com/rafalpienkowski/busgopher/internal/controller"
+	"github.com/rafalpienkowski/busgopher/internal/ui"
+)
+
+func uiCommand() command {
+	return command{
+		name:        "ui",
+		usage:       "[flags]",
+		description: "Start the GUI (default when no command is given)",
+		define:      defineUI,
+	}
+}
+
+func defineUI(env *environment, _ string) (*flag.FlagSet, execute) {
+	options := &options{}
+	flags := newFlagSet(env, uiCommand(), options)
+
+	return flags, func(args []string) (result, error) {
+		err := parse(flags, args)
+		if err != nil {
+			return nil, err
+		}
+
+		ui := ui.NewUI()
+		controller, err := controller.NewController(
+			env.newConfigStorage(options.configPath),
+			env.messageSender,
+			env.messageReceiver,
+			env.administrator,
+			env.newHistoryStorage(options.configPath),
+			ui.WriteLog,
+		)
+		if err != nil {
+			return nil, err
+		}
+		ui.LoadData(controller)
+
+		return nil, ui.Start()
+	}
+}
@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/rafalpienkowski/busgopher/internal/config"
+)
+
+func validateCommand() command {
+	return command{
+		name:        "validate",
+		usage:       "[file...]",
+		description: "Validate config files (config.json by default), for example in CI",
+		define:      defineValidate,
+	}
+}
+
+type validationProblem struct {
+	Path    string `json:"path,omitempty"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Message string `json:"message"`
+}
+
+type validatedFile struct {
+	File   string              `json:"file"`
+	Valid  bool                `json:"valid"`
+	Errors []validationProblem `json:"errors"`
+}
+
+type validateResult struct {
+	Files []validatedFile `json:"files"`
+}
+
+// printText prints every problem as file:line:column
+func (result validateResult) printText(env *environment) {
+	for _, file := range result.Files {
+		if file.Valid {
+			fmt.Fprintf(env.stdout, "%v: OK\n", file.File)
+			continue
+		}
+		for _, problem := range file.Errors {
+			message := problem.Message
+			if len(problem.Path) > 0 {
+				message = problem.Path + ": " + message
+			}
+			if problem.Line == 0 {
+				fmt.Fprintf(env.stderr, "%v: %v\n", file.File, message)
+				continue
+			}
+			fmt.Fprintf(env.stderr, "%v:%v:%v: %v\n", file.File, problem.Line, problem.Column, message)
+		}
+	}
+}
+
+// defineValidate fails when any file is invalid
+func defineValidate(env *environment, _ string) (*flag.FlagSet, execute) {
+	flags := newFlagSet(env, validateCommand(), &options{})
+
+	return flags, func(args []string) (result, error) {
+		err := parseWithArgs(flags, args)
+		if err != nil {
+			return nil, err
+		}
+
+		files := flags.Args()
+		if len(files) == 0 {
+			files = []string{flags.Lookup("config").Value.String()}
+		}
+
+		result := validateResult{Files: []validatedFile{}}
+		invalid := 0
+		for _, file := range files {
+			validated := validatedFile{File: file, Valid: true, Errors: []validationProblem{}}
+
+			content, err := os.ReadFile(file)
+			if err != nil {
+				validated.Errors = append(validated.Errors, validationProblem{Message: err.Error()})
+			} else {
+				for _, validationError := range config.Validate(content) {
+					validated.Errors = append(validated.Errors, validationProblem{
+						Path:    validationError.Path,
+						Line:    validationError.Line,
+						Column:  validationError.Column,
+						Message: validationError.Message,
+					})
+				}
+			}
+
+			if len(validated.Errors) > 0 {
+				validated.Valid = false
+				invalid++
+			}
+			result.Files = append(result.Files, validated)
+		}
+
+		if invalid > 0 {
+			return result, codedError{
+				code: "invalid_config",
+				err:  fmt.Errorf("%v of %v config file(s) invalid", invalid, len(files)),
+			}
+		}
+		return result, nil
+	}
+}
@@ -1,12 +1,35 @@
 package config
 
 import (
+	"time"
+
 	"github.com/rafalpienkowski/busgopher/internal/asb"
 )
 
 type Config struct {
 	Connections map[string]asb.Connection `json:"connections"`
 	Messages    map[string]asb.Message    `json:"messages"`
+	// Topologies are sets of entities that can be applied to the namespace of any connection
+	Topologies map[string]asb.Topology `json:"topologies,omitempty"`
+	// Presets bind a connection, destination and message under one name
+	Presets map[string]Preset `json:"presets,omitempty"`
+	// Favorites are pinned at the top of the lists of the sending page
+	Favorites *Favorites `json:"favorites,omitempty"`
+}
+
+// Preset is a connection, destination and message sent together. Variables override the
+// defaults of the var function in the body template.
+type Preset struct {
+	Connection  string            `json:"connection"`
+	Destination string            `json:"destination"`
+	Message     string            `json:"message"`
+	Variables   map[string]string `json:"variables,omitempty"`
+}
+
+type Favorites struct {
+	Connections  []string `json:"connections,omitempty"`
+	Destinations []string `json:"destinations,omitempty"`
+	Messages     []string `json:"messages,omitempty"`
 }
 
 func Default() *Config {
@@ -36,7 +59,21 @@ func GetTestConfig() Config {
 	}
 }
 
+type Backup struct {
+	ID      int
+	ModTime time.Time
+}
+
 type ConfigStorage interface {
 	Load() (Config, error)
 	Save(Config) error
+	// Backups returns available backups, the most recent first
+	Backups() ([]Backup, error)
+	LoadBackup(id int) (Config, error)
+}
+
+// ConfigWatcher is implemented by storages that notice changes made outside of busgopher.
+// Watch returns a function that stops watching.
+type ConfigWatcher interface {
+	Watch(onChange func()) func()
 }
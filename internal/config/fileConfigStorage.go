@@ -1,21 +1,63 @@
 package config
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/rafalpienkowski/busgopher/internal/asb"
 )
 
 const configName = "config.json"
+const defaultMaxBackups = 5
+const watchInterval = time.Second
 
-type FileConfigStorage struct{}
+var ErrConfigModified = errors.New(
+	"Config file was modified outside of busgopher. Reload it before saving",
+)
+
+type FileConfigStorage struct {
+	// Path to the config file, config.json in the working directory when empty
+	Path string
+	// MaxBackups is the number of rotated backups kept next to the config file
+	MaxBackups int
+
+	// checksum of the file content that was last loaded or saved
+	checksum []byte
+	mutex    sync.Mutex
+}
+
+func (storage *FileConfigStorage) path() string {
+	if len(storage.Path) == 0 {
+		return configName
+	}
+	return storage.Path
+}
+
+func (storage *FileConfigStorage) maxBackups() int {
+	if storage.MaxBackups <= 0 {
+		return defaultMaxBackups
+	}
+	return storage.MaxBackups
+}
+
+func (storage *FileConfigStorage) backupPath(id int) string {
+	return storage.path() + "." + strconv.Itoa(id)
+}
 
 func (storage *FileConfigStorage) Load() (Config, error) {
 	var config *Config
 
-	bytes, err := readFile(configName)
+	bytes, err := readFile(storage.path())
 	if err != nil {
 		return Config{}, err
 	}
@@ -26,7 +68,7 @@ func (storage *FileConfigStorage) Load() (Config, error) {
 			return Config{}, jerr
 		}
 
-		err = writeFile(configName, string(json))
+		err = writeFile(storage.path(), json)
 		if err != nil {
 			return Config{}, err
 		}
@@ -41,23 +83,180 @@ func (storage *FileConfigStorage) Load() (Config, error) {
 			Connections: make(map[string]asb.Connection),
 		}, err
 	}
+	// Only a parsed file counts as loaded, saving over a broken file must be refused
+	storage.setChecksum(bytes)
 
 	return *config, nil
 }
 
+// Read reads the config file without creating or changing it, a missing file is an empty config
+func Read(path string) (Config, error) {
+	config := Config{}
+	bytes, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return config, err
+	}
+
+	err = json.Unmarshal(bytes, &config)
+
+	return config, err
+}
+
 func (storage *FileConfigStorage) Save(config Config) error {
 	json, err := json.MarshalIndent(config, "", "   ")
 	if err != nil {
 		return err
 	}
 
-	err = writeFile(configName, string(json))
+	current, err := os.ReadFile(storage.path())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if len(current) > 0 {
+		if !storage.isKnown(current) {
+			return ErrConfigModified
+		}
+
+		err = storage.rotateBackups(current)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = writeFile(storage.path(), json)
 	if err != nil {
 		return err
 	}
+	storage.setChecksum(json)
+
 	return nil
 }
 
+// Watch polls the config file and calls onChange (from another goroutine)
+// whenever its content differs from what was last loaded or saved
+func (storage *FileConfigStorage) Watch(onChange func()) func() {
+	done := make(chan struct{})
+
+	go func() {
+		ticker := time.NewTicker(watchInterval)
+		defer ticker.Stop()
+
+		var lastModTime time.Time
+		var lastSize int64
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+
+			info, err := os.Stat(storage.path())
+			if err != nil {
+				continue
+			}
+			if info.ModTime().Equal(lastModTime) && info.Size() == lastSize {
+				continue
+			}
+			lastModTime = info.ModTime()
+			lastSize = info.Size()
+
+			content, err := os.ReadFile(storage.path())
+			// Editors may truncate the file before writing it, wait for the content
+			if err != nil || len(content) == 0 {
+				continue
+			}
+			if !storage.isKnown(content) {
+				onChange()
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+func (storage *FileConfigStorage) Backups() ([]Backup, error) {
+	backups := []Backup{}
+	for id := 1; id <= storage.maxBackups(); id++ {
+		info, err := os.Stat(storage.backupPath(id))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		backups = append(backups, Backup{ID: id, ModTime: info.ModTime()})
+	}
+
+	return backups, nil
+}
+
+func (storage *FileConfigStorage) LoadBackup(id int) (Config, error) {
+	if id < 1 || id > storage.maxBackups() {
+		return Config{}, fmt.Errorf("Can't find backup with id: %v", id)
+	}
+
+	bytes, err := os.ReadFile(storage.backupPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return Config{}, fmt.Errorf("Can't find backup with id: %v", id)
+	}
+	if err != nil {
+		return Config{}, err
+	}
+
+	config := Config{}
+	err = json.Unmarshal(bytes, &config)
+	if err != nil {
+		return Config{}, err
+	}
+
+	return config, nil
+}
+
+// rotateBackups shifts existing backups by one (dropping the oldest)
+// and stores the current content as the most recent backup
+func (storage *FileConfigStorage) rotateBackups(current []byte) error {
+	err := os.Remove(storage.backupPath(storage.maxBackups()))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	for id := storage.maxBackups() - 1; id >= 1; id-- {
+		err = os.Rename(storage.backupPath(id), storage.backupPath(id+1))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	// Backups get the mode of the config, a config protected with 0600 mustn't leak into them
+	return writeFileMode(storage.backupPath(1), current, fileMode(storage.path()))
+}
+
+func (storage *FileConfigStorage) setChecksum(content []byte) {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	storage.checksum = checksum(content)
+}
+
+func (storage *FileConfigStorage) isKnown(content []byte) bool {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	return storage.checksum == nil || bytes.Equal(storage.checksum, checksum(content))
+}
+
+func checksum(content []byte) []byte {
+	sum := sha256.Sum256(content)
+	return sum[:]
+}
+
 func readFile(filePath string) ([]byte, error) {
 	file, err := os.OpenFile(filePath, os.O_CREATE, 0644)
 	if err != nil {
@@ -73,16 +272,51 @@ func readFile(filePath string) ([]byte, error) {
 	return bytes, nil
 }
 
-func writeFile(filePath string, content string) error {
-	file, err := os.OpenFile(filePath, os.O_RDWR|os.O_TRUNC, 0644)
+// writeFile writes the content to a temporary file in the same directory
+// and renames it over the target, so the target is never left half written
+// writeFile replaces the file atomically and keeps its mode, a config protected with 0600 must stay protected
+func writeFile(filePath string, content []byte) error {
+	return writeFileMode(filePath, content, fileMode(filePath))
+}
+
+// fileMode returns the permissions of the file, 0644 when it doesn't exist
+func fileMode(filePath string) os.FileMode {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return 0644
+	}
+
+	return info.Mode().Perm()
+}
+
+func writeFileMode(filePath string, content []byte, mode os.FileMode) error {
+	dir, name := filepath.Split(filePath)
+	if len(dir) == 0 {
+		dir = "."
+	}
+
+	file, err := os.CreateTemp(dir, "."+strings.TrimPrefix(name, ".")+".tmp-*")
 	if err != nil {
 		return err
 	}
-	defer file.Close()
+	tmpPath := file.Name()
+	defer os.Remove(tmpPath)
 
-	_, err = file.WriteString(content)
+	_, err = file.Write(content)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
 	if err != nil {
 		return err
 	}
-	return nil
+
+	err = os.Chmod(tmpPath, mode)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, filePath)
 }
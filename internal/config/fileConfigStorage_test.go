@@ -0,0 +1,157 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func createTestStorage(t *testing.T) *FileConfigStorage {
+	return &FileConfigStorage{
+		Path:       filepath.Join(t.TempDir(), "config.json"),
+		MaxBackups: 2,
+	}
+}
+
+func Test_FileConfigStorage_Should_Create_Default_Config(t *testing.T) {
+	storage := createTestStorage(t)
+
+	config, err := storage.Load()
+
+	assert.NoError(t, err)
+	assert.Equal(t, *Default(), config)
+	assert.FileExists(t, storage.Path)
+}
+
+func Test_Read_Should_Not_Create_Missing_Config(t *testing.T) {
+	storage := createTestStorage(t)
+
+	config, err := Read(storage.Path)
+
+	assert.NoError(t, err)
+	assert.Equal(t, Config{}, config)
+	assert.NoFileExists(t, storage.Path)
+}
+
+func Test_FileConfigStorage_Should_Save_And_Load_Config(t *testing.T) {
+	storage := createTestStorage(t)
+	_, err := storage.Load()
+	assert.NoError(t, err)
+
+	err = storage.Save(GetTestConfig())
+	assert.NoError(t, err)
+
+	config, err := (&FileConfigStorage{Path: storage.Path}).Load()
+	assert.NoError(t, err)
+	assert.Equal(t, GetTestConfig(), config)
+}
+
+func Test_FileConfigStorage_Should_Not_Leave_Temporary_Files(t *testing.T) {
+	storage := createTestStorage(t)
+	_, err := storage.Load()
+	assert.NoError(t, err)
+
+	err = storage.Save(GetTestConfig())
+	assert.NoError(t, err)
+
+	entries, err := os.ReadDir(filepath.Dir(storage.Path))
+	assert.NoError(t, err)
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.ElementsMatch(t, []string{"config.json", "config.json.1"}, names)
+}
+
+func Test_FileConfigStorage_Should_Rotate_Backups(t *testing.T) {
+	storage := createTestStorage(t)
+	_, err := storage.Load()
+	assert.NoError(t, err)
+
+	first := GetTestConfig()
+	first.Messages["first"] = first.Messages["test-message"]
+	second := GetTestConfig()
+	second.Messages["second"] = second.Messages["test-message"]
+	assert.NoError(t, storage.Save(GetTestConfig()))
+	assert.NoError(t, storage.Save(first))
+	assert.NoError(t, storage.Save(second))
+
+	backups, err := storage.Backups()
+	assert.NoError(t, err)
+	assert.Len(t, backups, 2)
+
+	latest, err := storage.LoadBackup(1)
+	assert.NoError(t, err)
+	assert.Equal(t, first, latest)
+	oldest, err := storage.LoadBackup(2)
+	assert.NoError(t, err)
+	assert.Equal(t, GetTestConfig(), oldest)
+	_, err = storage.LoadBackup(3)
+	assert.Error(t, err)
+}
+
+func Test_FileConfigStorage_Should_Detect_External_Modification(t *testing.T) {
+	storage := createTestStorage(t)
+	_, err := storage.Load()
+	assert.NoError(t, err)
+
+	err = os.WriteFile(storage.Path, []byte(`{"connections": {}}`), 0644)
+	assert.NoError(t, err)
+
+	err = storage.Save(GetTestConfig())
+
+	assert.ErrorIs(t, err, ErrConfigModified)
+	content, err := os.ReadFile(storage.Path)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"connections": {}}`, string(content))
+}
+
+func Test_FileConfigStorage_Should_Notify_About_External_Changes_Only(t *testing.T) {
+	storage := createTestStorage(t)
+	_, err := storage.Load()
+	assert.NoError(t, err)
+	changed := make(chan struct{}, 10)
+	stop := storage.Watch(func() { changed <- struct{}{} })
+	defer stop()
+
+	err = storage.Save(GetTestConfig())
+	assert.NoError(t, err)
+	assert.Never(t, func() bool { return len(changed) > 0 }, 2*watchInterval, watchInterval/10)
+
+	err = os.WriteFile(storage.Path, []byte(`{"connections": {}}`), 0644)
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool { return len(changed) > 0 }, 3*watchInterval, watchInterval/10)
+}
+
+func Test_FileConfigStorage_Should_Keep_File_Mode(t *testing.T) {
+	storage := createTestStorage(t)
+	_, err := storage.Load()
+	assert.NoError(t, err)
+	assert.NoError(t, os.Chmod(storage.Path, 0600))
+
+	err = storage.Save(GetTestConfig())
+
+	assert.NoError(t, err)
+	info, err := os.Stat(storage.Path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	info, err = os.Stat(storage.backupPath(1))
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
+
+func Test_FileConfigStorage_Should_Not_Save_Over_File_That_Failed_To_Load(t *testing.T) {
+	storage := createTestStorage(t)
+	_, err := storage.Load()
+	assert.NoError(t, err)
+	err = os.WriteFile(storage.Path, []byte(`{"connections": `), 0644)
+	assert.NoError(t, err)
+
+	_, err = storage.Load()
+	assert.Error(t, err)
+	err = storage.Save(GetTestConfig())
+
+	assert.ErrorIs(t, err, ErrConfigModified)
+}
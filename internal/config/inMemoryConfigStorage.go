@@ -1,7 +1,14 @@
 package config
 
+import (
+	"fmt"
+	"time"
+)
+
 type InMemoryConfigStorage struct {
 	Config Config
+
+	backups []Config
 }
 
 func (storage *InMemoryConfigStorage) Load() (Config, error) {
@@ -10,7 +17,25 @@ func (storage *InMemoryConfigStorage) Load() (Config, error) {
 }
 
 func (storage *InMemoryConfigStorage) Save(config Config) error {
+	storage.backups = append([]Config{storage.Config}, storage.backups...)
 	storage.Config = config
 
 	return nil
 }
+
+func (storage *InMemoryConfigStorage) Backups() ([]Backup, error) {
+	backups := []Backup{}
+	for i := range storage.backups {
+		backups = append(backups, Backup{ID: i + 1, ModTime: time.Time{}})
+	}
+
+	return backups, nil
+}
+
+func (storage *InMemoryConfigStorage) LoadBackup(id int) (Config, error) {
+	if id < 1 || id > len(storage.backups) {
+		return Config{}, fmt.Errorf("Can't find backup with id: %v", id)
+	}
+
+	return storage.backups[id-1], nil
+}
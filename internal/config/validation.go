@@ -0,0 +1,518 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rafalpienkowski/busgopher/internal/asb"
+	"github.com/rafalpienkowski/busgopher/internal/secret"
+)
+
+// ValidationError describes a single problem found in the config JSON
+type ValidationError struct {
+	// Path is a JSON path of the invalid element, like $.connections["dev"].namespace
+	Path    string
+	Offset  int64
+	Line    int
+	Column  int
+	Message string
+}
+
+func (validationError ValidationError) Error() string {
+	if len(validationError.Path) == 0 {
+		return fmt.Sprintf(
+			"line %v, column %v: %v",
+			validationError.Line,
+			validationError.Column,
+			validationError.Message,
+		)
+	}
+	return fmt.Sprintf(
+		"line %v, column %v: %v: %v",
+		validationError.Line,
+		validationError.Column,
+		validationError.Path,
+		validationError.Message,
+	)
+}
+
+type ValidationErrors []ValidationError
+
+func (validationErrors ValidationErrors) Error() string {
+	messages := []string{}
+	for _, validationError := range validationErrors {
+		messages = append(messages, validationError.Error())
+	}
+	return strings.Join(messages, "\n")
+}
+
+// Parse validates the config JSON and decodes it. All problems are returned as ValidationErrors.
+func Parse(data []byte) (Config, error) {
+	validationErrors := Validate(data)
+	if len(validationErrors) > 0 {
+		return Config{}, validationErrors
+	}
+
+	config := Config{}
+	err := json.Unmarshal(data, &config)
+	if err != nil {
+		return Config{}, err
+	}
+
+	return config, nil
+}
+
+// Validate reports every problem found in the config JSON, ordered by their position
+func Validate(data []byte) ValidationErrors {
+	validator := validator{data: data, nodes: make(map[string]*jsonNode)}
+
+	root, err := parseJSON(data)
+	if err != nil {
+		var syntaxError *parseError
+		offset := int64(len(data))
+		if errors.As(err, &syntaxError) {
+			offset = syntaxError.offset
+		}
+		validator.addAt(offset, "", err.Error())
+		return validator.errors
+	}
+
+	validator.checkType(root, reflect.TypeOf(Config{}), "$")
+
+	// Type errors are already reported, other structural problems don't prevent decoding
+	config := Config{}
+	err = json.Unmarshal(data, &config)
+	if err == nil {
+		validator.checkConfig(config)
+	} else if len(validator.errors) == 0 {
+		validator.addAt(0, "$", err.Error())
+	}
+
+	return validator.sorted()
+}
+
+type validator struct {
+	data   []byte
+	nodes  map[string]*jsonNode
+	errors ValidationErrors
+}
+
+func (validator *validator) addAt(offset int64, path string, message string) {
+	line, column := lineColumn(validator.data, offset)
+	validator.errors = append(validator.errors, ValidationError{
+		Path:    path,
+		Offset:  offset,
+		Line:    line,
+		Column:  column,
+		Message: message,
+	})
+}
+
+// add reports a problem at the path, or at the closest existing parent when the path is missing
+func (validator *validator) add(path string, message string) {
+	location := path
+	for {
+		if node, ok := validator.nodes[location]; ok {
+			validator.addAt(node.offset, path, message)
+			return
+		}
+		index := max(strings.LastIndex(location, "."), strings.LastIndex(location, "["))
+		if index <= 0 {
+			validator.addAt(0, path, message)
+			return
+		}
+		location = location[:index]
+	}
+}
+
+func (validator *validator) sorted() ValidationErrors {
+	sort.SliceStable(validator.errors, func(i, j int) bool {
+		return validator.errors[i].Offset < validator.errors[j].Offset
+	})
+	return validator.errors
+}
+
+// checkType compares the JSON structure with the Go type it will be decoded into
+func (validator *validator) checkType(node *jsonNode, t reflect.Type, path string) {
+	validator.nodes[path] = node
+	if node.kind == jsonNull {
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.kind != jsonObject {
+			validator.add(path, "expected an object, got "+node.describe())
+			return
+		}
+		seen := make(map[string]bool)
+		for i, key := range node.keys {
+			name, field, ok := findField(t, key)
+			if !ok {
+				validator.addAt(node.keyOffsets[i], path+"."+key, "unknown field '"+key+"'")
+				continue
+			}
+			if seen[field.Name] {
+				validator.addAt(node.keyOffsets[i], path+"."+name, "duplicated field '"+key+"'")
+				continue
+			}
+			seen[field.Name] = true
+			validator.checkType(node.children[i], field.Type, path+"."+name)
+		}
+	case reflect.Map:
+		if node.kind != jsonObject {
+			validator.add(path, "expected an object, got "+node.describe())
+			return
+		}
+		seen := make(map[string]bool)
+		for i, key := range node.keys {
+			childPath := path + "[" + strconv.Quote(key) + "]"
+			if seen[key] {
+				validator.addAt(node.keyOffsets[i], childPath, "duplicated key '"+key+"'")
+				continue
+			}
+			seen[key] = true
+			validator.checkType(node.children[i], t.Elem(), childPath)
+		}
+	case reflect.Slice:
+		if node.kind != jsonArray {
+			validator.add(path, "expected an array, got "+node.describe())
+			return
+		}
+		for i, child := range node.children {
+			validator.checkType(child, t.Elem(), path+"["+strconv.Itoa(i)+"]")
+		}
+	case reflect.String:
+		if node.kind != jsonString {
+			validator.add(path, "expected a string, got "+node.describe())
+		}
+	case reflect.Bool:
+		if node.kind != jsonBool {
+			validator.add(path, "expected a boolean, got "+node.describe())
+		}
+	case reflect.Int, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		if _, err := strconv.ParseInt(node.number, 10, 64); node.kind != jsonNumber || err != nil {
+			validator.add(path, "expected an integer, got "+node.describe())
+		}
+	case reflect.Float32, reflect.Float64:
+		if node.kind != jsonNumber {
+			validator.add(path, "expected a number, got "+node.describe())
+		}
+	case reflect.Pointer:
+		validator.checkType(node, t.Elem(), path)
+	}
+}
+
+func (validator *validator) checkConfig(config Config) {
+	for name, connection := range config.Connections {
+		path := "$.connections[" + strconv.Quote(name) + "]"
+		if len(strings.TrimSpace(name)) == 0 {
+			validator.add(path, "connection name is required")
+		}
+		if len(strings.TrimSpace(connection.Namespace)) == 0 && len(connection.ConnectionString) == 0 {
+			validator.add(path+".namespace", "namespace or connection string is required")
+		}
+		if secret.HasPlaintextKey(connection.ConnectionString) {
+			validator.add(
+				path+".connectionString",
+				"connection string contains a plaintext key, use an env:, file: or keyring: reference instead",
+			)
+		}
+		for i, destination := range connection.Destinations {
+			destinationPath := path + ".destinations[" + strconv.Itoa(i) + "]"
+			if len(strings.TrimSpace(destination)) == 0 {
+				validator.add(destinationPath, "destination name is required")
+				continue
+			}
+			for _, previous := range connection.Destinations[:i] {
+				if strings.EqualFold(previous, destination) {
+					validator.add(destinationPath, "destination '"+destination+"' is duplicated")
+					break
+				}
+			}
+		}
+	}
+
+	for name, message := range config.Messages {
+		path := "$.messages[" + strconv.Quote(name) + "]"
+		if len(strings.TrimSpace(name)) == 0 {
+			validator.add(path, "message name is required")
+		}
+		if err := message.ValidateBody(); err != nil {
+			validator.add(path+".body", "invalid body template: "+err.Error())
+		}
+		for key, value := range message.CustomProperties {
+			propertyPath := path + ".customProperties[" + strconv.Quote(key) + "]"
+			if len(strings.TrimSpace(key)) == 0 {
+				validator.add(propertyPath, "custom property name is required")
+			}
+			switch value.(type) {
+			case string, bool, float64:
+			default:
+				validator.add(propertyPath, "custom property must be a string, number or boolean")
+			}
+		}
+	}
+
+	for name, topology := range config.Topologies {
+		validator.checkTopology("$.topologies["+strconv.Quote(name)+"]", topology)
+	}
+
+	for name, preset := range config.Presets {
+		path := "$.presets[" + strconv.Quote(name) + "]"
+		if len(strings.TrimSpace(name)) == 0 {
+			validator.add(path, "preset name is required")
+		}
+		if _, ok := config.Connections[preset.Connection]; !ok {
+			validator.add(path+".connection", "connection '"+preset.Connection+"' doesn't exist")
+		}
+		if len(strings.TrimSpace(preset.Destination)) == 0 {
+			validator.add(path+".destination", "destination is required")
+		}
+		if _, ok := config.Messages[preset.Message]; !ok {
+			validator.add(path+".message", "message '"+preset.Message+"' doesn't exist")
+		}
+	}
+}
+
+func (validator *validator) checkTopology(path string, topology asb.Topology) {
+	names := []string{}
+	for i, queue := range topology.Queues {
+		queuePath := path + ".queues[" + strconv.Itoa(i) + "]"
+		if err := queue.Validate(); err != nil {
+			validator.add(queuePath, err.Error())
+		}
+		validator.checkDuplicate(queuePath, "entity", queue.Name, &names)
+	}
+	for i, topic := range topology.Topics {
+		topicPath := path + ".topics[" + strconv.Itoa(i) + "]"
+		if err := topic.Validate(); err != nil {
+			validator.add(topicPath, err.Error())
+		}
+		validator.checkDuplicate(topicPath, "entity", topic.Name, &names)
+
+		subscriptions := []string{}
+		for j, subscription := range topic.Subscriptions {
+			subscriptionPath := topicPath + ".subscriptions[" + strconv.Itoa(j) + "]"
+			if err := subscription.Validate(); err != nil {
+				validator.add(subscriptionPath, err.Error())
+			}
+			validator.checkDuplicate(subscriptionPath, "subscription", subscription.Name, &subscriptions)
+
+			rules := []string{}
+			for k, rule := range subscription.Rules {
+				rulePath := subscriptionPath + ".rules[" + strconv.Itoa(k) + "]"
+				if err := rule.Validate(); err != nil {
+					validator.add(rulePath, err.Error())
+				}
+				validator.checkDuplicate(rulePath, "rule", rule.Name, &rules)
+			}
+		}
+	}
+}
+
+// checkDuplicate reports the name when it's already in names (ignoring case), and adds it otherwise
+func (validator *validator) checkDuplicate(path string, kind string, name string, names *[]string) {
+	if len(strings.TrimSpace(name)) == 0 {
+		return
+	}
+	for _, previous := range *names {
+		if strings.EqualFold(previous, name) {
+			validator.add(path+".name", kind+" '"+name+"' is duplicated")
+			return
+		}
+	}
+	*names = append(*names, name)
+}
+
+// findField matches a JSON key with a struct field the same way encoding/json does
+// and returns the field name used in JSON
+func findField(t reflect.Type, key string) (string, reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if len(name) == 0 {
+			name = field.Name
+		}
+		if strings.EqualFold(name, key) {
+			return name, field, true
+		}
+	}
+	return "", reflect.StructField{}, false
+}
+
+func lineColumn(data []byte, offset int64) (int, int) {
+	offset = min(max(offset, 0), int64(len(data)))
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := len(before) - bytes.LastIndexByte(before, '\n')
+
+	return line, column
+}
+
+type jsonKind int
+
+const (
+	jsonNull jsonKind = iota
+	jsonObject
+	jsonArray
+	jsonString
+	jsonNumber
+	jsonBool
+)
+
+// jsonNode is a parsed JSON value that remembers where it starts in the source
+type jsonNode struct {
+	kind   jsonKind
+	offset int64
+	number string
+
+	// object keys with their offsets, children hold object values or array items
+	keys       []string
+	keyOffsets []int64
+	children   []*jsonNode
+}
+
+func (node *jsonNode) describe() string {
+	switch node.kind {
+	case jsonObject:
+		return "an object"
+	case jsonArray:
+		return "an array"
+	case jsonString:
+		return "a string"
+	case jsonNumber:
+		return "a number"
+	case jsonBool:
+		return "a boolean"
+	}
+	return "null"
+}
+
+// parseError is a JSON syntax error with the offset of the invalid character
+type parseError struct {
+	offset  int64
+	message string
+}
+
+func (parseError *parseError) Error() string {
+	return parseError.message
+}
+
+func parseJSON(data []byte) (*jsonNode, error) {
+	parser := jsonParser{data: data, decoder: json.NewDecoder(bytes.NewReader(data))}
+	parser.decoder.UseNumber()
+
+	root, err := parser.parseValue()
+	if err != nil {
+		return nil, parser.wrapError(err)
+	}
+
+	offset := parser.start()
+	_, err = parser.decoder.Token()
+	if err == nil {
+		return nil, &parseError{offset: offset, message: "unexpected content after the end of the config"}
+	}
+	if err != io.EOF {
+		return nil, parser.wrapError(err)
+	}
+
+	return root, nil
+}
+
+func (parser *jsonParser) wrapError(err error) error {
+	var syntaxError *json.SyntaxError
+	if errors.As(err, &syntaxError) {
+		return &parseError{offset: max(syntaxError.Offset-1, 0), message: syntaxError.Error()}
+	}
+	if err == io.ErrUnexpectedEOF {
+		return &parseError{offset: int64(len(parser.data)), message: "unexpected end of JSON input"}
+	}
+	return err
+}
+
+type jsonParser struct {
+	data    []byte
+	decoder *json.Decoder
+}
+
+// start returns the offset of the next token, skipping whitespace and separators
+func (parser *jsonParser) start() int64 {
+	offset := parser.decoder.InputOffset()
+	for offset < int64(len(parser.data)) && strings.ContainsRune(" \t\r\n,:", rune(parser.data[offset])) {
+		offset++
+	}
+	return offset
+}
+
+func (parser *jsonParser) parseValue() (*jsonNode, error) {
+	node := &jsonNode{offset: parser.start()}
+
+	token, err := parser.decoder.Token()
+	if err == io.EOF {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch value := token.(type) {
+	case json.Delim:
+		switch value {
+		case '{':
+			node.kind = jsonObject
+			for parser.decoder.More() {
+				keyOffset := parser.start()
+				key, err := parser.decoder.Token()
+				if err != nil {
+					return nil, err
+				}
+				child, err := parser.parseValue()
+				if err != nil {
+					return nil, err
+				}
+				node.keys = append(node.keys, key.(string))
+				node.keyOffsets = append(node.keyOffsets, keyOffset)
+				node.children = append(node.children, child)
+			}
+		case '[':
+			node.kind = jsonArray
+			for parser.decoder.More() {
+				child, err := parser.parseValue()
+				if err != nil {
+					return nil, err
+				}
+				node.children = append(node.children, child)
+			}
+		}
+		// Closing delimiter
+		_, err = parser.decoder.Token()
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, err
+		}
+	case string:
+		node.kind = jsonString
+	case json.Number:
+		node.kind = jsonNumber
+		node.number = value.String()
+	case bool:
+		node.kind = jsonBool
+	case nil:
+		node.kind = jsonNull
+	}
+
+	return node, nil
+}
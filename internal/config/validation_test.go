@@ -0,0 +1,145 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func validationMessages(errors ValidationErrors) []string {
+	messages := []string{}
+	for _, validationError := range errors {
+		messages = append(messages, validationError.Error())
+	}
+	return messages
+}
+
+func Test_Validate_Should_Accept_Valid_Config(t *testing.T) {
+	errors := Validate([]byte(`{
+    "connections": {
+        "dev": { "namespace": "dev.servicebus.windows.net", "destinations": [ "queue", "topic" ] }
+    },
+    "messages": {
+        "msg": {
+            "body": "{{generateUUID}}",
+            "messageID": "1",
+            "customProperties": { "a": "b", "c": 1, "d": true }
+        }
+    }
+}`))
+
+	assert.Empty(t, errors)
+}
+
+func Test_Validate_Should_Report_Syntax_Error_Position(t *testing.T) {
+	errors := Validate([]byte("{\n  \"connections\": {\n    \"dev\": {,\n  }\n}"))
+
+	assert.Equal(t, []string{
+		"line 3, column 13: invalid character ',' looking for beginning of value",
+	}, validationMessages(errors))
+}
+
+func Test_Validate_Should_Report_Unexpected_End(t *testing.T) {
+	errors := Validate([]byte("{\n  \"connections\": {"))
+
+	assert.Equal(t, []string{"line 2, column 18: unexpected end of JSON input"}, validationMessages(errors))
+}
+
+func Test_Validate_Should_Report_Type_Errors(t *testing.T) {
+	errors := Validate([]byte(`{
+  "connections": {
+    "dev": { "namespace": 12, "destinations": "queue", "unknown": 1 }
+  },
+  "messages": []
+}`))
+
+	assert.Equal(t, []string{
+		`line 3, column 27: $.connections["dev"].namespace: expected a string, got a number`,
+		`line 3, column 47: $.connections["dev"].destinations: expected an array, got a string`,
+		`line 3, column 56: $.connections["dev"].unknown: unknown field 'unknown'`,
+		`line 5, column 15: $.messages: expected an object, got an array`,
+	}, validationMessages(errors))
+}
+
+func Test_Validate_Should_Report_Every_Semantic_Problem(t *testing.T) {
+	errors := Validate([]byte(`{
+  "connections": {
+    "dev": { "destinations": [ "queue", "QUEUE", "" ] },
+    "test": { "namespace": "test", "Namespace": "test" }
+  },
+  "messages": {
+    "msg": {
+      "body": "{{ unknownFunction }}",
+      "customProperties": { "nested": { "a": 1 }, "empty": null }
+    }
+  }
+}`))
+
+	assert.Equal(t, []string{
+		`line 3, column 12: $.connections["dev"].namespace: namespace or connection string is required`,
+		`line 3, column 41: $.connections["dev"].destinations[1]: destination 'QUEUE' is duplicated`,
+		`line 3, column 50: $.connections["dev"].destinations[2]: destination name is required`,
+		`line 4, column 36: $.connections["test"].namespace: duplicated field 'Namespace'`,
+	}, validationMessages(errors)[:4])
+	assert.Equal(t, []string{
+		`line 8, column 15: $.messages["msg"].body: invalid body template: template: example:1: function "unknownFunction" not defined`,
+		`line 9, column 39: $.messages["msg"].customProperties["nested"]: custom property must be a string, number or boolean`,
+		`line 9, column 60: $.messages["msg"].customProperties["empty"]: custom property must be a string, number or boolean`,
+	}, validationMessages(errors)[4:])
+}
+
+func Test_Validate_Should_Reject_Plaintext_Connection_String(t *testing.T) {
+	errors := Validate([]byte(`{
+  "connections": {
+    "plain": { "connectionString": "Endpoint=sb://x/;SharedAccessKeyName=a;SharedAccessKey=b" },
+    "reference": { "connectionString": "keyring:dev" }
+  }
+}`))
+
+	assert.Equal(t, []string{
+		`line 3, column 36: $.connections["plain"].connectionString: connection string contains a plaintext key, use an env:, file: or keyring: reference instead`,
+	}, validationMessages(errors))
+}
+
+func Test_Validate_Should_Report_Topology_Problems(t *testing.T) {
+	errors := Validate([]byte(`{
+  "topologies": {
+    "orders": {
+      "queues": [ { "name": "orders", "lockDuration": "1 minute" } ],
+      "topics": [ {
+        "name": "ORDERS",
+        "subscriptions": [
+          { "name": "audit", "rules": [ { "name": "all" }, { "name": "created", "sql": "a = 1", "correlation": { "subject": "created" } } ] },
+          { "name": "audit" }
+        ]
+      } ]
+    }
+  }
+}`))
+
+	assert.Equal(t, []string{
+		`line 4, column 19: $.topologies["orders"].queues[0]: invalid lock duration: time: unknown unit " minute" in duration "1 minute"`,
+		`line 6, column 17: $.topologies["orders"].topics[0].name: entity 'ORDERS' is duplicated`,
+		`line 8, column 41: $.topologies["orders"].topics[0].subscriptions[0].rules[0]: rule needs a SQL or a correlation filter`,
+		`line 8, column 60: $.topologies["orders"].topics[0].subscriptions[0].rules[1]: rule can't have both a SQL and a correlation filter`,
+		`line 9, column 21: $.topologies["orders"].topics[0].subscriptions[1].name: subscription 'audit' is duplicated`,
+	}, validationMessages(errors))
+}
+
+func Test_Validate_Should_Report_Preset_Problems(t *testing.T) {
+	errors := Validate([]byte(`{
+  "connections": { "dev": { "namespace": "dev.servicebus.windows.net", "destinations": [ "orders" ] } },
+  "messages": { "order-created": { "body": "{{ var \"orderId\" \"42\" }}" } },
+  "presets": {
+    "created-dev": { "connection": "dev", "destination": "orders", "message": "order-created", "variables": { "orderId": "43" } },
+    "broken": { "connection": "prod", "destination": "", "message": "missing" }
+  },
+  "favorites": { "connections": [ "dev" ] }
+}`))
+
+	assert.Equal(t, []string{
+		`line 6, column 31: $.presets["broken"].connection: connection 'prod' doesn't exist`,
+		`line 6, column 54: $.presets["broken"].destination: destination is required`,
+		`line 6, column 69: $.presets["broken"].message: message 'missing' doesn't exist`,
+	}, validationMessages(errors))
+}
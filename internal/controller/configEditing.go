@@ -0,0 +1,295 @@
+package controller
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/rafalpienkowski/busgopher/internal/asb"
+	"github.com/rafalpienkowski/busgopher/internal/config"
+	"github.com/rafalpienkowski/busgopher/internal/secret"
+)
+
+// FieldError describes a problem with a single field of an edited connection or message
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (fieldError FieldError) Error() string {
+	return fieldError.Field + ": " + fieldError.Message
+}
+
+type FieldErrors []FieldError
+
+func (fieldErrors FieldErrors) Error() string {
+	messages := []string{}
+	for _, fieldError := range fieldErrors {
+		messages = append(messages, fieldError.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (controller *Controller) AddConnection(name string, connection asb.Connection) error {
+	if _, ok := controller.Config.Connections[name]; ok {
+		return FieldErrors{{Field: "Name", Message: "connection '" + name + "' already exists"}}
+	}
+	if err := validateConnection(name, connection); err != nil {
+		return err
+	}
+
+	updated := cloneConfig(controller.Config)
+	updated.Connections[name] = connection
+
+	return controller.saveConfig(updated, "Connection '"+name+"' added")
+}
+
+func (controller *Controller) UpdateConnection(name string, newName string, connection asb.Connection) error {
+	if _, ok := controller.Config.Connections[name]; !ok {
+		return fmt.Errorf("Can't find connection with name: %v", name)
+	}
+	if _, ok := controller.Config.Connections[newName]; ok && name != newName {
+		return FieldErrors{{Field: "Name", Message: "connection '" + newName + "' already exists"}}
+	}
+	if err := validateConnection(newName, connection); err != nil {
+		return err
+	}
+
+	updated := cloneConfig(controller.Config)
+	delete(updated.Connections, name)
+	updated.Connections[newName] = connection
+	renameReferences(updated, FavoriteConnection, name, newName)
+
+	selected := controller.selectedConnectionName
+	if selected == name {
+		controller.selectedConnectionName = newName
+	}
+
+	err := controller.saveConfig(updated, "Connection '"+newName+"' updated")
+	if err != nil {
+		controller.selectedConnectionName = selected
+	}
+	return err
+}
+
+func (controller *Controller) DeleteConnection(name string) error {
+	if _, ok := controller.Config.Connections[name]; !ok {
+		return fmt.Errorf("Can't find connection with name: %v", name)
+	}
+
+	updated := cloneConfig(controller.Config)
+	delete(updated.Connections, name)
+	if err := removeReferences(updated, FavoriteConnection, name); err != nil {
+		return err
+	}
+
+	return controller.saveConfig(updated, "Connection '"+name+"' deleted")
+}
+
+// DuplicateConnection copies the connection under a free name and returns that name
+func (controller *Controller) DuplicateConnection(name string) (string, error) {
+	connection, ok := controller.Config.Connections[name]
+	if !ok {
+		return "", fmt.Errorf("Can't find connection with name: %v", name)
+	}
+
+	newName := copyName(name, controller.Config.Connections)
+	connection.Destinations = slices.Clone(connection.Destinations)
+
+	return newName, controller.AddConnection(newName, connection)
+}
+
+func (controller *Controller) AddMessage(name string, message asb.Message) error {
+	if _, ok := controller.Config.Messages[name]; ok {
+		return FieldErrors{{Field: "Name", Message: "message '" + name + "' already exists"}}
+	}
+	if err := validateMessage(name, message); err != nil {
+		return err
+	}
+
+	updated := cloneConfig(controller.Config)
+	updated.Messages[name] = message
+
+	return controller.saveConfig(updated, "Message '"+name+"' added")
+}
+
+func (controller *Controller) UpdateMessage(name string, newName string, message asb.Message) error {
+	if _, ok := controller.Config.Messages[name]; !ok {
+		return fmt.Errorf("Can't find message with name: %v", name)
+	}
+	if _, ok := controller.Config.Messages[newName]; ok && name != newName {
+		return FieldErrors{{Field: "Name", Message: "message '" + newName + "' already exists"}}
+	}
+	if err := validateMessage(newName, message); err != nil {
+		return err
+	}
+
+	updated := cloneConfig(controller.Config)
+	delete(updated.Messages, name)
+	updated.Messages[newName] = message
+	renameReferences(updated, FavoriteMessage, name, newName)
+
+	selected := controller.selectedMessageName
+	if selected == name {
+		controller.selectedMessageName = newName
+	}
+
+	err := controller.saveConfig(updated, "Message '"+newName+"' updated")
+	if err != nil {
+		controller.selectedMessageName = selected
+	}
+	return err
+}
+
+func (controller *Controller) DeleteMessage(name string) error {
+	if _, ok := controller.Config.Messages[name]; !ok {
+		return fmt.Errorf("Can't find message with name: %v", name)
+	}
+
+	updated := cloneConfig(controller.Config)
+	delete(updated.Messages, name)
+	if err := removeReferences(updated, FavoriteMessage, name); err != nil {
+		return err
+	}
+
+	return controller.saveConfig(updated, "Message '"+name+"' deleted")
+}
+
+// DuplicateMessage copies the message under a free name and returns that name
+func (controller *Controller) DuplicateMessage(name string) (string, error) {
+	message, ok := controller.Config.Messages[name]
+	if !ok {
+		return "", fmt.Errorf("Can't find message with name: %v", name)
+	}
+
+	newName := copyName(name, controller.Config.Messages)
+	message.CustomProperties = maps.Clone(message.CustomProperties)
+
+	return newName, controller.AddMessage(newName, message)
+}
+
+// SaveReceivedMessage adds the received message to the saved messages, see asb.NewTemplate
+func (controller *Controller) SaveReceivedMessage(name string, received asb.ReceivedMessage, templatize bool) error {
+	return controller.AddMessage(name, asb.NewTemplate(received, templatize))
+}
+
+// saveConfig persists the edited config and drops selections that no longer exist
+func (controller *Controller) saveConfig(updated config.Config, log string) error {
+	err := controller.configStorage.Save(updated)
+	if err != nil {
+		return err
+	}
+	controller.Config = updated
+
+	_, ok := updated.Connections[controller.selectedConnectionName]
+	if !ok {
+		controller.selectedConnectionName = ""
+		controller.selectedDestination = ""
+	} else if !slices.Contains(controller.destinationsOf(updated, controller.selectedConnectionName), controller.selectedDestination) {
+		controller.selectedDestination = ""
+	}
+	if _, ok := updated.Messages[controller.selectedMessageName]; !ok {
+		controller.selectedMessageName = ""
+		controller.variables = nil
+	}
+	controller.writeLog(log)
+
+	return nil
+}
+
+func validateConnection(name string, connection asb.Connection) error {
+	errs := FieldErrors{}
+	if len(strings.TrimSpace(name)) == 0 {
+		errs = append(errs, FieldError{Field: "Name", Message: "name is required"})
+	}
+	if len(strings.TrimSpace(connection.Namespace)) == 0 && len(connection.ConnectionString) == 0 {
+		errs = append(errs, FieldError{Field: "Namespace", Message: "namespace or connection string is required"})
+	}
+	if secret.HasPlaintextKey(connection.ConnectionString) {
+		errs = append(errs, FieldError{
+			Field:   "Connection string",
+			Message: "connection string contains a plaintext key, use an env:, file: or keyring: reference instead",
+		})
+	}
+
+	for i, destination := range connection.Destinations {
+		field := fmt.Sprintf("Destination %v", i+1)
+		if len(strings.TrimSpace(destination)) == 0 {
+			errs = append(errs, FieldError{Field: field, Message: "destination name is required"})
+			continue
+		}
+		for _, previous := range connection.Destinations[:i] {
+			if strings.EqualFold(previous, destination) {
+				errs = append(errs, FieldError{Field: field, Message: "destination '" + destination + "' is duplicated"})
+				break
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func validateMessage(name string, message asb.Message) error {
+	errs := FieldErrors{}
+	if len(strings.TrimSpace(name)) == 0 {
+		errs = append(errs, FieldError{Field: "Name", Message: "name is required"})
+	}
+	if err := message.ValidateBody(); err != nil {
+		errs = append(errs, FieldError{Field: "Body", Message: err.Error()})
+	}
+	for key := range message.CustomProperties {
+		if len(strings.TrimSpace(key)) == 0 {
+			errs = append(errs, FieldError{Field: "Properties", Message: "property name is required"})
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func copyName[T any](name string, existing map[string]T) string {
+	newName := name + "-copy"
+	for i := 2; ; i++ {
+		if _, ok := existing[newName]; !ok {
+			return newName
+		}
+		newName = fmt.Sprintf("%v-copy-%v", name, i)
+	}
+}
+
+func cloneConfig(source config.Config) config.Config {
+	cloned := config.Config{
+		Connections: make(map[string]asb.Connection),
+		Messages:    make(map[string]asb.Message),
+	}
+	for name, connection := range source.Connections {
+		connection.Destinations = slices.Clone(connection.Destinations)
+		cloned.Connections[name] = connection
+	}
+	for name, message := range source.Messages {
+		message.CustomProperties = maps.Clone(message.CustomProperties)
+		cloned.Messages[name] = message
+	}
+	// Topologies are not edited by the controller
+	cloned.Topologies = maps.Clone(source.Topologies)
+	cloned.Presets = make(map[string]config.Preset)
+	for name, preset := range source.Presets {
+		preset.Variables = maps.Clone(preset.Variables)
+		cloned.Presets[name] = preset
+	}
+	if source.Favorites != nil {
+		cloned.Favorites = &config.Favorites{
+			Connections:  slices.Clone(source.Favorites.Connections),
+			Destinations: slices.Clone(source.Favorites.Destinations),
+			Messages:     slices.Clone(source.Favorites.Messages),
+		}
+	}
+
+	return cloned
+}
@@ -2,12 +2,18 @@ package controller
 
 import (
 	"bytes"
+	"cmp"
 	"encoding/json"
 	"errors"
-	"strings"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
 
+	"github.com/google/uuid"
 	"github.com/rafalpienkowski/busgopher/internal/asb"
 	"github.com/rafalpienkowski/busgopher/internal/config"
+	"github.com/rafalpienkowski/busgopher/internal/history"
 )
 
 type Connection struct {
@@ -22,6 +28,13 @@ type Message struct {
 
 type WriteLog func(log string)
 
+// SendResult describes a sent message
+type SendResult struct {
+	MessageID   string `json:"messageId"`
+	Namespace   string `json:"namespace"`
+	Destination string `json:"destination"`
+}
+
 type Controller struct {
 	Config        config.Config
 	configStorage config.ConfigStorage
@@ -29,14 +42,31 @@ type Controller struct {
 	selectedConnectionName string
 	selectedMessageName    string
 	selectedDestination    string
-
-	messageSender asb.MessageSender
-	writeLog      WriteLog
+	// variables of the applied preset, used to render the selected message
+	variables map[string]string
+	// revision counts the reloads of the config, edits started before a reload may conflict with it
+	revision int
+
+	messageSender   asb.MessageSender
+	messageReceiver asb.MessageReceiver
+	administrator   asb.Administrator
+	history         history.Storage
+	writeLog        WriteLog
+
+	// discovered holds the queues and topics discovered per connection name
+	discovered map[string][]string
+
+	// locked keeps the messages received in peek-lock mode until they are settled
+	locked       asb.LockedReceiver
+	lockedSource asb.Source
 }
 
 func NewController(
 	configStorage config.ConfigStorage,
 	messageSender asb.MessageSender,
+	messageReceiver asb.MessageReceiver,
+	administrator asb.Administrator,
+	history history.Storage,
 	writeLog WriteLog,
 ) (*Controller, error) {
 
@@ -48,6 +78,9 @@ func NewController(
 	controller := Controller{}
 	controller.Config = config
 	controller.messageSender = messageSender
+	controller.messageReceiver = messageReceiver
+	controller.administrator = administrator
+	controller.history = history
 	controller.configStorage = configStorage
     controller.writeLog = writeLog
 
@@ -66,6 +99,28 @@ func (controller *Controller) GetSelectedConnection() *asb.Connection {
 	return nil
 }
 
+func (controller *Controller) GetSelectedConnectionName() string {
+	return controller.selectedConnectionName
+}
+
+func (controller *Controller) GetSelectedDestination() string {
+	return controller.selectedDestination
+}
+
+// Snapshot returns a copy of the controller with the current config and selection. It is used by work
+// running in the background, which mustn't read the controller while the UI changes it.
+func (controller *Controller) Snapshot() *Controller {
+	snapshot := *controller
+	snapshot.variables = maps.Clone(controller.variables)
+	snapshot.discovered = maps.Clone(controller.discovered)
+
+	return &snapshot
+}
+
+func (controller *Controller) GetSelectedMessageName() string {
+	return controller.selectedMessageName
+}
+
 func (controller *Controller) GetConnections() []Connection {
 	var connections = []Connection{}
 	for key := range controller.Config.Connections {
@@ -74,6 +129,11 @@ func (controller *Controller) GetConnections() []Connection {
 			Connection{Name: key, Namespace: controller.Config.Connections[key].Namespace},
 		)
 	}
+	// Favorites are pinned at the top
+	favoritesFirst := controller.favoritesFirst(FavoriteConnection)
+	slices.SortFunc(connections, func(a, b Connection) int {
+		return cmp.Or(favoritesFirst(a.Name, b.Name), cmp.Compare(a.Name, b.Name))
+	})
 	return connections
 }
 
@@ -82,48 +142,97 @@ func (controller *Controller) GetMessages() []Message {
 	for key := range controller.Config.Messages {
 		messages = append(messages, Message{Name: key, Message: controller.Config.Messages[key]})
 	}
+	favoritesFirst := controller.favoritesFirst(FavoriteMessage)
+	slices.SortFunc(messages, func(a, b Message) int {
+		return cmp.Or(favoritesFirst(a.Name, b.Name), cmp.Compare(a.Name, b.Name))
+	})
 
 	return messages
 }
 
+// SelectConnectionByName selects the connection, see resolveName for the accepted names
 func (controller *Controller) SelectConnectionByName(name string) error {
+	resolved, err := resolveName("connection", name, slices.Collect(maps.Keys(controller.Config.Connections)))
+	if err != nil {
+		return err
+	}
 
-	_, ok := controller.Config.Connections[name]
-	if ok {
-		controller.selectedConnectionName = name
-		controller.selectedDestination = ""
-        controller.writeLog("Connection '" + name + "' selected")
+	controller.selectedConnectionName = resolved
+	controller.selectedDestination = ""
+	controller.variables = nil
+	controller.writeLog("Connection '" + resolved + "' selected")
 
-		return nil
+	return nil
+}
+
+// SelectConnectionByExactName is SelectConnectionByName for destructive operations, see resolveExactName
+func (controller *Controller) SelectConnectionByExactName(name string) error {
+	resolved, err := resolveExactName("connection", name, slices.Collect(maps.Keys(controller.Config.Connections)))
+	if err != nil {
+		return err
+	}
+
+	return controller.SelectConnectionByName(resolved)
+}
+
+// ResolveDestination returns the destination of the selected connection the name points to, or the name
+// as it is when no destination is close to it, destinations don't have to be saved in the connection.
+// With exact the name may only differ in case, as for destructive operations.
+func (controller *Controller) ResolveDestination(name string, exact bool) (string, error) {
+	if len(name) == 0 {
+		return name, nil
+	}
+
+	destinations := controller.destinationsOf(controller.Config, controller.selectedConnectionName)
+	resolve := resolveName
+	if exact {
+		resolve = resolveExactName
+	}
+	resolved, err := resolve("destination", name, destinations)
+	var notFound NotFoundError
+	if errors.As(err, &notFound) && len(notFound.Suggestions) == 0 {
+		return name, nil
 	}
 
-	return errors.New("Can't find connection with name: " + name)
+	return resolved, err
 }
 
 func (controller *Controller) SelectDestinationByName(name string) error {
-	conn, ok := controller.Config.Connections[controller.selectedConnectionName]
-	if ok {
-		for _, dest := range conn.Destinations {
-			if strings.EqualFold(dest, name) {
-				controller.selectedDestination = dest
-                controller.writeLog("Destination '" + name + "' selected")
+	destinations := controller.destinationsOf(controller.Config, controller.selectedConnectionName)
+	resolved, err := resolveName("destination", name, destinations)
+	if err != nil {
+		return err
+	}
 
-				return nil
-			}
-		}
+	controller.selectedDestination = resolved
+	controller.writeLog("Destination '" + resolved + "' selected")
+
+	return nil
+}
+
+// SelectDestinationByExactName is SelectDestinationByName for operations the wrong destination would
+// be costly for, see resolveExactName
+func (controller *Controller) SelectDestinationByExactName(name string) error {
+	destinations := controller.destinationsOf(controller.Config, controller.selectedConnectionName)
+	resolved, err := resolveExactName("destination", name, destinations)
+	if err != nil {
+		return err
 	}
-	return errors.New("Can't find destination with name: " + name)
+
+	return controller.SelectDestinationByName(resolved)
 }
 
 func (controller *Controller) SelectMessageByName(name string) error {
-	_, ok := controller.Config.Messages[name]
-	if ok {
-		controller.selectedMessageName = name
-        controller.writeLog("Message '" + name + "' selected")
-
-		return nil
+	resolved, err := resolveName("message", name, slices.Collect(maps.Keys(controller.Config.Messages)))
+	if err != nil {
+		return err
 	}
-	return errors.New("Can't find message with name: " + name)
+
+	controller.selectedMessageName = resolved
+	controller.variables = nil
+	controller.writeLog("Message '" + resolved + "' selected")
+
+	return nil
 }
 
 func (controller *Controller) GetDestiationNamesForSelectedConnection() []string {
@@ -131,58 +240,322 @@ func (controller *Controller) GetDestiationNamesForSelectedConnection() []string
 		return []string{}
 	}
 
-	return controller.Config.Connections[controller.selectedConnectionName].Destinations
+	destinations := controller.destinationsOf(controller.Config, controller.selectedConnectionName)
+	// The config order is kept after favorites
+	slices.SortStableFunc(destinations, controller.favoritesFirst(FavoriteDestination))
+
+	return destinations
+}
+
+func (controller *Controller) Send() (SendResult, error) {
+	connection, message, err := controller.getSelectedForSending()
+	if err != nil {
+		return SendResult{}, err
+	}
+
+	return controller.send(connection, controller.selectedDestination, controller.selectedMessageName, message)
 }
 
-func (controller *Controller) Send() error {
+// getSelectedForSending returns the selected connection and the selected message with its body rendered
+func (controller *Controller) getSelectedForSending() (asb.Connection, asb.Message, error) {
 
 	if len(controller.selectedConnectionName) == 0 {
-		return errors.New("Connection not selected!")
+		return asb.Connection{}, asb.Message{}, errors.New("Connection not selected!")
 	}
 
 	if len(controller.selectedMessageName) == 0 {
-		return errors.New("Message not selected!")
+		return asb.Connection{}, asb.Message{}, errors.New("Message not selected!")
 	}
 
 	if len(controller.selectedDestination) == 0 {
-		return errors.New("Destination not selected!")
+		return asb.Connection{}, asb.Message{}, errors.New("Destination not selected!")
 	}
 
-	controller.writeLog(
-		"Sending message to: " + controller.Config.Connections[controller.selectedConnectionName].Namespace,
-	)
+	connection := controller.Config.Connections[controller.selectedConnectionName]
+	target := connection.Namespace
+	if len(target) == 0 {
+		target = controller.selectedConnectionName
+	}
+	controller.writeLog("Sending message to: " + target)
 
-	err := controller.messageSender.Send(
-		controller.Config.Connections[controller.selectedConnectionName].Namespace,
-		controller.selectedDestination,
-		controller.Config.Messages[controller.selectedMessageName],
-	)
+	message, err := controller.RenderMessageWith(controller.selectedMessageName, controller.variables)
+	if err != nil {
+		return asb.Connection{}, asb.Message{}, err
+	}
 
+	return connection, message, nil
+}
+
+// SendTo sends the message to any destination, the connection, destination and message
+// don't need to be saved in the config. The body is sent as it is, without rendering it or
+// resolving secret references.
+func (controller *Controller) SendTo(
+	connection asb.Connection,
+	destination string,
+	message asb.Message,
+) (SendResult, error) {
+	if len(connection.Namespace) == 0 && len(connection.ConnectionString) == 0 {
+		return SendResult{}, errors.New("Namespace or connection string is required!")
+	}
+	if len(destination) == 0 {
+		return SendResult{}, errors.New("Destination not selected!")
+	}
+
+	target := connection.Namespace
+	if len(target) == 0 {
+		target = "connection string"
+	}
+	controller.writeLog("Sending message to: " + target)
+
+	return controller.send(connection, destination, "", message)
+}
+
+// SendSavedTo is SendTo for a message rendered from the saved message with the name, the history
+// keeps the name
+func (controller *Controller) SendSavedTo(
+	connection asb.Connection,
+	destination string,
+	name string,
+	message asb.Message,
+) (SendResult, error) {
+	resolved, err := resolveName("message", name, slices.Collect(maps.Keys(controller.Config.Messages)))
 	if err != nil {
-		return err
+		return SendResult{}, err
+	}
+	if len(connection.Namespace) == 0 && len(connection.ConnectionString) == 0 {
+		return SendResult{}, errors.New("Namespace or connection string is required!")
+	}
+	if len(destination) == 0 {
+		return SendResult{}, errors.New("Destination not selected!")
+	}
+	controller.writeLog("Sending message '" + resolved + "' to: " + destination)
+
+	return controller.send(connection, destination, resolved, message)
+}
+
+func (controller *Controller) send(
+	connection asb.Connection,
+	destination string,
+	messageName string,
+	message asb.Message,
+) (SendResult, error) {
+	return controller.sendAs(controller.connectionName(connection), connection, destination, messageName, message)
+}
+
+// sendAs sends the message from the connection saved under the name, it doesn't read the state of
+// the controller so it can be called in the background
+func (controller *Controller) sendAs(
+	connectionName string,
+	connection asb.Connection,
+	destination string,
+	messageName string,
+	message asb.Message,
+) (SendResult, error) {
+	// Secrets are resolved only for sending, the config keeps the references
+	// Failed resolutions are recorded too, so the send can be found and retried from the history
+	resolvedConnection, err := resolveConnection(connection)
+	if err != nil {
+		controller.record(connectionName, connection, destination, messageName, message, message.MessageID, err)
+		return SendResult{}, err
+	}
+	// Only saved messages may refer to secrets, ad-hoc and imported ones are sent as they are
+	// so a reference in them can't leak a local secret
+	resolvedMessage := message
+	if len(messageName) > 0 {
+		resolvedMessage, err = resolveMessage(message)
+		if err != nil {
+			controller.record(connectionName, connection, destination, messageName, message, message.MessageID, err)
+			return SendResult{}, err
+		}
+	}
+
+	// The ID is generated here so it can be reported back
+	if len(resolvedMessage.MessageID) == 0 {
+		resolvedMessage.MessageID = uuid.New().String()
+	}
+
+	err = controller.messageSender.Send(resolvedConnection, destination, resolvedMessage)
+	controller.record(connectionName, connection, destination, messageName, message, resolvedMessage.MessageID, err)
+	if err != nil {
+		return SendResult{}, err
 	}
 	controller.writeLog("Message send")
-	return nil
+
+	return SendResult{
+		MessageID:   resolvedMessage.MessageID,
+		Namespace:   connection.Namespace,
+		Destination: destination,
+	}, nil
+}
+
+// RenderMessage returns the message with its body template rendered.
+// Secret references are left as they are.
+func (controller *Controller) RenderMessage(name string) (asb.Message, error) {
+	return controller.RenderMessageWith(name, nil)
+}
+
+// RenderMessageWith is RenderMessage with the variables given to the var function of the template
+func (controller *Controller) RenderMessageWith(name string, variables map[string]string) (asb.Message, error) {
+	resolved, err := resolveName("message", name, slices.Collect(maps.Keys(controller.Config.Messages)))
+	if err != nil {
+		return asb.Message{}, err
+	}
+	message := controller.Config.Messages[resolved]
+
+	body, err := message.TransformBodyWith(variables)
+	if err != nil {
+		return asb.Message{}, err
+	}
+	message.Body = body
+
+	return message, nil
+}
+
+func (controller *Controller) ValidateConfigJson(configJson string) config.ValidationErrors {
+	return config.Validate([]byte(configJson))
 }
 
 func (controller *Controller) SaveConfigJson(configJson string) error {
-	config := config.Config{}
-    err := json.Unmarshal([]byte(configJson), &config)
-    if (err != nil){
-        return err
-    }
+	config, err := config.Parse([]byte(configJson))
+	if err != nil {
+		return err
+	}
+	err = controller.configStorage.Save(config)
+	if err != nil {
+		return err
+	}
 	controller.Config = config
 
 	controller.selectedConnectionName = ""
 	controller.selectedDestination = ""
 	controller.selectedMessageName = ""
+	controller.variables = nil
     controller.writeLog("Config saved")
 
-	return controller.configStorage.Save(controller.Config)
+	return nil
 }
 
 func (controller *Controller) GetConfigString() (string, error) {
-	decoded, err := json.Marshal(controller.Config)
+	return configToString(controller.Config)
+}
+
+func (controller *Controller) GetDefaultConfigString() (string, error) {
+	return configToString(config.GetTestConfig())
+}
+
+// ReloadConfig loads the config from the storage again. Selections are kept
+// when the selected items still exist in the reloaded config.
+func (controller *Controller) ReloadConfig() error {
+	config, err := controller.configStorage.Load()
+	if err != nil {
+		return err
+	}
+	previous := controller.Config
+	controller.Config = config
+	controller.revision++
+
+	for _, change := range diffConfigs(previous, config) {
+		controller.writeLog(change)
+	}
+
+	if _, ok := config.Connections[controller.selectedConnectionName]; !ok {
+		if len(controller.selectedConnectionName) > 0 {
+			controller.writeLog("Connection '" + controller.selectedConnectionName + "' no longer exists, selection cleared")
+		}
+		controller.selectedConnectionName = ""
+		controller.selectedDestination = ""
+	} else if !slices.Contains(controller.destinationsOf(config, controller.selectedConnectionName), controller.selectedDestination) {
+		if len(controller.selectedDestination) > 0 {
+			controller.writeLog("Destination '" + controller.selectedDestination + "' no longer exists, selection cleared")
+		}
+		controller.selectedDestination = ""
+	}
+
+	if _, ok := config.Messages[controller.selectedMessageName]; !ok {
+		if len(controller.selectedMessageName) > 0 {
+			controller.writeLog("Message '" + controller.selectedMessageName + "' no longer exists, selection cleared")
+		}
+		controller.selectedMessageName = ""
+		controller.variables = nil
+	}
+	controller.writeLog("Config reloaded")
+
+	return nil
+}
+
+// GetConfigRevision changes every time the config is reloaded from the storage. Editors remember it
+// when they start editing, a different revision on save means the edit is based on an older config.
+func (controller *Controller) GetConfigRevision() int {
+	return controller.revision
+}
+
+// WatchConfig calls onChange when the config was changed outside of busgopher.
+// It returns a function that stops watching.
+func (controller *Controller) WatchConfig(onChange func()) func() {
+	watcher, ok := controller.configStorage.(config.ConfigWatcher)
+	if !ok {
+		return func() {}
+	}
+
+	return watcher.Watch(onChange)
+}
+
+func diffConfigs(previous config.Config, current config.Config) []string {
+	changes := []string{}
+	changes = append(changes, diffMaps("Connection", previous.Connections, current.Connections)...)
+	changes = append(changes, diffMaps("Message", previous.Messages, current.Messages)...)
+	changes = append(changes, diffMaps("Topology", previous.Topologies, current.Topologies)...)
+	changes = append(changes, diffMaps("Preset", previous.Presets, current.Presets)...)
+
+	return changes
+}
+
+func diffMaps[T any](kind string, previous map[string]T, current map[string]T) []string {
+	changes := []string{}
+	for _, name := range slices.Sorted(maps.Keys(current)) {
+		old, ok := previous[name]
+		if !ok {
+			changes = append(changes, kind+" '"+name+"' added")
+		} else if !reflect.DeepEqual(old, current[name]) {
+			changes = append(changes, kind+" '"+name+"' changed")
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(previous)) {
+		if _, ok := current[name]; !ok {
+			changes = append(changes, kind+" '"+name+"' removed")
+		}
+	}
+
+	return changes
+}
+
+func (controller *Controller) GetConfigBackups() ([]config.Backup, error) {
+	return controller.configStorage.Backups()
+}
+
+func (controller *Controller) RestoreConfigBackup(id int) error {
+	backup, err := controller.configStorage.LoadBackup(id)
+	if err != nil {
+		return err
+	}
+
+	err = controller.configStorage.Save(backup)
+	if err != nil {
+		return err
+	}
+	controller.Config = backup
+
+	controller.selectedConnectionName = ""
+	controller.selectedDestination = ""
+	controller.selectedMessageName = ""
+	controller.variables = nil
+	controller.writeLog(fmt.Sprintf("Config restored from backup %v", id))
+
+	return nil
+}
+
+func configToString(config config.Config) (string, error) {
+	decoded, err := json.Marshal(config)
 	if err != nil {
 		return "", err
 	}
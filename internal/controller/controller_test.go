@@ -2,14 +2,19 @@ package controller
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
 	"github.com/rafalpienkowski/busgopher/internal/asb"
 	"github.com/rafalpienkowski/busgopher/internal/config"
+	"github.com/rafalpienkowski/busgopher/internal/history"
+	"github.com/rafalpienkowski/busgopher/internal/filter"
+	"github.com/rafalpienkowski/busgopher/internal/routing"
 )
 
 func getInMemoryConfig() *config.InMemoryConfigStorage {
@@ -20,20 +25,36 @@ func getInMemoryConfig() *config.InMemoryConfigStorage {
 }
 
 func createTestController() (*Controller, *config.InMemoryConfigStorage, *asb.InMemoryMessageSender) {
+	controller, inMemoryConfig, inMemoryMessageSender, _ := createTestControllerWithReceiver()
+
+	return controller, inMemoryConfig, inMemoryMessageSender
+}
+
+func createTestControllerWithReceiver() (
+	*Controller,
+	*config.InMemoryConfigStorage,
+	*asb.InMemoryMessageSender,
+	*asb.InMemoryMessageReceiver,
+) {
 	inMemoryConfig := getInMemoryConfig()
 	var testConfig config.ConfigStorage = inMemoryConfig
 	inMemoryMessageSender := &asb.InMemoryMessageSender{}
 	var testMessageSender asb.MessageSender = inMemoryMessageSender
+	inMemoryMessageReceiver := &asb.InMemoryMessageReceiver{Messages: make(map[asb.Source][]asb.ReceivedMessage)}
+	var testMessageReceiver asb.MessageReceiver = inMemoryMessageReceiver
 	var buffer bytes.Buffer
 	var writer io.Writer = &buffer
 
 	controller, _ := NewController(
 		testConfig,
 		testMessageSender,
+		testMessageReceiver,
+		&asb.InMemoryAdministrator{},
+		&history.InMemoryStorage{},
 		func(s string) { fmt.Fprintf(writer, "%v", s) },
 	)
 
-	return controller, inMemoryConfig, inMemoryMessageSender
+	return controller, inMemoryConfig, inMemoryMessageSender, inMemoryMessageReceiver
 }
 
 func Test_Controller_Should_Load_Config(t *testing.T) {
@@ -107,10 +128,113 @@ func Test_Controller_Should_Write_Error_When_Selecting_NonExisting_Message(t *te
 	assert.Error(t, err, "Can't find message with name: non-existing")
 }
 
+func createControllerWithNames(t *testing.T) *Controller {
+	controller, _, _ := createTestController()
+	for _, name := range []string{"dev", "Demo", "prod-eu", "prod-us"} {
+		assert.NoError(t, controller.AddConnection(name, asb.Connection{Namespace: name + ".azure.com"}))
+	}
+	for _, name := range []string{"order-created", "order-cancelled", "payment-received"} {
+		assert.NoError(t, controller.AddMessage(name, asb.Message{Body: name}))
+	}
+	return controller
+}
+
+func Test_Controller_Should_Select_Connection_Ignoring_Case(t *testing.T) {
+	controller := createControllerWithNames(t)
+
+	err := controller.SelectConnectionByName("DEV")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "dev", controller.GetSelectedConnectionName())
+}
+
+func Test_Controller_Should_Select_Connection_By_Unique_Prefix(t *testing.T) {
+	controller := createControllerWithNames(t)
+
+	err := controller.SelectConnectionByName("dem")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Demo", controller.GetSelectedConnectionName())
+}
+
+func Test_Controller_Should_Suggest_Names_When_Prefix_Is_Ambiguous(t *testing.T) {
+	controller := createControllerWithNames(t)
+
+	err := controller.SelectConnectionByName("prod")
+
+	assert.EqualError(t, err, "Can't find connection with name: prod, did you mean: prod-eu, prod-us?")
+	assert.Equal(t, "", controller.GetSelectedConnectionName())
+}
+
+func Test_Controller_Should_Select_Message_By_Fuzzy_Match(t *testing.T) {
+	controller := createControllerWithNames(t)
+
+	err := controller.SelectMessageByName("ordcr")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "order-created", controller.GetSelectedMessageName())
+}
+
+func Test_Controller_Should_Suggest_Closest_Names_For_Typo(t *testing.T) {
+	controller := createControllerWithNames(t)
+
+	err := controller.SelectMessageByName("order-creatde")
+
+	var notFound NotFoundError
+	assert.ErrorAs(t, err, &notFound)
+	assert.Equal(t, []string{"order-created"}, notFound.Suggestions)
+	assert.EqualError(t, err, "Can't find message with name: order-creatde, did you mean: order-created?")
+}
+
+func Test_Controller_Should_Not_Suggest_Unrelated_Names(t *testing.T) {
+	controller := createControllerWithNames(t)
+
+	err := controller.SelectConnectionByName("staging")
+
+	assert.EqualError(t, err, "Can't find connection with name: staging")
+}
+
+func Test_Controller_Should_Only_Suggest_Prefix_When_Exact_Name_Is_Required(t *testing.T) {
+	controller := createControllerWithNames(t)
+
+	err := controller.SelectConnectionByExactName("dem")
+
+	assert.EqualError(t, err, "Can't find connection with name: dem, did you mean: Demo?")
+	assert.Equal(t, "", controller.GetSelectedConnectionName())
+	assert.NoError(t, controller.SelectConnectionByExactName("demo"))
+	assert.Equal(t, "Demo", controller.GetSelectedConnectionName())
+}
+
+func Test_Controller_Should_Resolve_Destination_Or_Keep_Unknown_Name(t *testing.T) {
+	controller, _, _ := createTestController()
+	assert.NoError(t, controller.SelectConnectionByName("test-connection"))
+
+	resolved, err := controller.ResolveDestination("que", false)
+	assert.NoError(t, err)
+	assert.Equal(t, "queue", resolved)
+
+	resolved, err = controller.ResolveDestination("undeclared-entity", false)
+	assert.NoError(t, err)
+	assert.Equal(t, "undeclared-entity", resolved)
+
+	_, err = controller.ResolveDestination("que", true)
+	assert.EqualError(t, err, "Can't find destination with name: que, did you mean: queue?")
+}
+
+func Test_Controller_Should_Select_Destination_By_Prefix(t *testing.T) {
+	controller, _, _ := createTestController()
+	assert.NoError(t, controller.SelectConnectionByName("test-connection"))
+
+	err := controller.SelectDestinationByName("TOP")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "topic", controller.GetSelectedDestination())
+}
+
 func Test_Controller_Should_Not_Send_When_Connection_Not_Selected(t *testing.T) {
 	controller, _, _ := createTestController()
 
-	err := controller.Send()
+	_, err := controller.Send()
 
 	assert.Error(t, err, "Connection not selected!")
 }
@@ -122,7 +246,7 @@ func Test_Controller_Should_Not_Send_When_Destination_Not_Selected(t *testing.T)
 	err = controller.SelectMessageByName("test-message")
 	assert.NoError(t, err)
 
-	err = controller.Send()
+	_, err = controller.Send()
 
 	assert.Error(t, err, "Destination not selected!")
 }
@@ -134,7 +258,7 @@ func Test_Controller_Should_Not_Send_When_Message_Not_Selected(t *testing.T) {
 	err = controller.SelectDestinationByName("queue")
 	assert.NoError(t, err)
 
-	err = controller.Send()
+	_, err = controller.Send()
 
 	assert.Error(t, err, "Message not selected!")
 }
@@ -148,12 +272,17 @@ func Test_Controller_Should_Send_Message(t *testing.T) {
 	err = controller.SelectMessageByName("test-message")
 	assert.NoError(t, err)
 
-	err = controller.Send()
+	result, err := controller.Send()
 	assert.NoError(t, err)
 
 	assert.Equal(t, "test.azure.com", messageSender.Namespace)
 	assert.Equal(t, "queue", messageSender.Destination)
-	assert.Equal(t, inMemoryConfig.Config.Messages["test-message"], messageSender.Message)
+	expected := inMemoryConfig.Config.Messages["test-message"]
+	expected.MessageID = result.MessageID
+	assert.Equal(t, expected, messageSender.Message)
+	assert.NotEmpty(t, result.MessageID)
+	assert.Equal(t, "test.azure.com", result.Namespace)
+	assert.Equal(t, "queue", result.Destination)
 }
 
 func Test_Controller_Should_Get_Selected_Connection(t *testing.T) {
@@ -310,5 +439,1208 @@ func Test_Controller_Save_Config_Json_Should_Return_Error_On_Invalid_Json(t *tes
 
 	err := controller.SaveConfigJson("invalid json")
     
-    assert.Equal(t, "invalid character 'i' looking for beginning of value", err.Error())
+    assert.Equal(t, "line 1, column 1: invalid character 'i' looking for beginning of value", err.Error())
+}
+
+func Test_Controller_Should_Clear_Preset_Variables_When_Restoring_Config_Backup(t *testing.T) {
+	controller, _, _ := createTestController()
+	assert.NoError(t, controller.SavePreset("tenant", config.Preset{
+		Connection:  "test-connection",
+		Destination: "queue",
+		Message:     "test-message",
+		Variables:   map[string]string{"tenant": "acme"},
+	}))
+	_, err := controller.ApplyPreset("tenant")
+	assert.NoError(t, err)
+
+	err = controller.RestoreConfigBackup(1)
+
+	assert.NoError(t, err)
+	assert.Empty(t, controller.GetSelectedMessageName())
+	assert.Nil(t, controller.variables)
+}
+
+func Test_Controller_Should_Restore_Config_Backup(t *testing.T) {
+	controller, inMemoryConfig, _ := createTestController()
+	err := controller.SaveConfigJson("{}")
+	assert.NoError(t, err)
+
+	err = controller.RestoreConfigBackup(1)
+
+	assert.NoError(t, err)
+	assert.Equal(t, config.GetTestConfig(), controller.Config)
+	assert.Equal(t, config.GetTestConfig(), inMemoryConfig.Config)
+}
+
+func Test_Controller_Should_Keep_Current_Config_As_Backup_When_Restoring(t *testing.T) {
+	controller, _, _ := createTestController()
+	err := controller.SaveConfigJson("{}")
+	assert.NoError(t, err)
+
+	err = controller.RestoreConfigBackup(1)
+	assert.NoError(t, err)
+
+	backups, err := controller.GetConfigBackups()
+	assert.NoError(t, err)
+	assert.Len(t, backups, 2)
+	err = controller.RestoreConfigBackup(1)
+	assert.NoError(t, err)
+	assert.Equal(t, config.Config{}, controller.Config)
+}
+
+func Test_Controller_Should_Return_Error_When_Restoring_NonExisting_Backup(t *testing.T) {
+	controller, _, _ := createTestController()
+
+	err := controller.RestoreConfigBackup(1)
+
+	assert.Error(t, err, "Can't find backup with id: 1")
+}
+
+func Test_Controller_Reload_Config_Should_Keep_Existing_Selections(t *testing.T) {
+	controller, inMemoryConfig, _ := createTestController()
+	err := controller.SelectConnectionByName("test-connection")
+	assert.NoError(t, err)
+	err = controller.SelectDestinationByName("queue")
+	assert.NoError(t, err)
+	err = controller.SelectMessageByName("test-message")
+	assert.NoError(t, err)
+	inMemoryConfig.Config = config.GetTestConfig()
+	inMemoryConfig.Config.Messages["new-message"] = asb.Message{Body: "new"}
+
+	err = controller.ReloadConfig()
+
+	assert.NoError(t, err)
+	assert.Equal(t, inMemoryConfig.Config, controller.Config)
+	assert.Equal(t, "test-connection", controller.GetSelectedConnectionName())
+	assert.Equal(t, "queue", controller.GetSelectedDestination())
+	assert.Equal(t, "test-message", controller.GetSelectedMessageName())
+}
+
+func Test_Controller_Reload_Config_Should_Clear_Removed_Selections(t *testing.T) {
+	controller, inMemoryConfig, _ := createTestController()
+	err := controller.SelectConnectionByName("test-connection")
+	assert.NoError(t, err)
+	err = controller.SelectDestinationByName("queue")
+	assert.NoError(t, err)
+	err = controller.SelectMessageByName("test-message")
+	assert.NoError(t, err)
+	inMemoryConfig.Config = config.GetTestConfig()
+	inMemoryConfig.Config.Connections["test-connection"] = asb.Connection{
+		Namespace:    "test.azure.com",
+		Destinations: []string{"topic"},
+	}
+	delete(inMemoryConfig.Config.Messages, "test-message")
+
+	err = controller.ReloadConfig()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "test-connection", controller.GetSelectedConnectionName())
+	assert.Equal(t, "", controller.GetSelectedDestination())
+	assert.Equal(t, "", controller.GetSelectedMessageName())
+}
+
+func Test_Controller_Should_Describe_Config_Changes(t *testing.T) {
+	previous := config.GetTestConfig()
+	current := config.GetTestConfig()
+	current.Messages["test-message"] = asb.Message{Body: "changed"}
+	current.Messages["added-message"] = asb.Message{}
+	delete(current.Connections, "test-connection")
+
+	changes := diffConfigs(previous, current)
+
+	assert.Equal(t, []string{
+		"Connection 'test-connection' removed",
+		"Message 'added-message' added",
+		"Message 'test-message' changed",
+	}, changes)
+}
+
+func Test_Controller_Should_Add_Connection(t *testing.T) {
+	controller, inMemoryConfig, _ := createTestController()
+	connection := asb.Connection{Namespace: "new.azure.com", Destinations: []string{"new-queue"}}
+
+	err := controller.AddConnection("new-connection", connection)
+
+	assert.NoError(t, err)
+	assert.Equal(t, connection, controller.Config.Connections["new-connection"])
+	assert.Equal(t, connection, inMemoryConfig.Config.Connections["new-connection"])
+}
+
+func Test_Controller_Should_Not_Add_Invalid_Connection(t *testing.T) {
+	controller, inMemoryConfig, _ := createTestController()
+
+	err := controller.AddConnection("test-connection", asb.Connection{
+		Destinations: []string{"queue", "QUEUE", ""},
+	})
+
+	assert.Equal(t, FieldErrors{{Field: "Name", Message: "connection 'test-connection' already exists"}}, err)
+	err = controller.AddConnection("new-connection", asb.Connection{
+		Destinations: []string{"queue", "QUEUE", ""},
+	})
+	assert.Equal(t, FieldErrors{
+		{Field: "Namespace", Message: "namespace or connection string is required"},
+		{Field: "Destination 2", Message: "destination 'QUEUE' is duplicated"},
+		{Field: "Destination 3", Message: "destination name is required"},
+	}, err)
+	assert.Equal(t, config.GetTestConfig(), inMemoryConfig.Config)
+}
+
+func Test_Controller_Should_Rename_Selected_Connection(t *testing.T) {
+	controller, _, _ := createTestController()
+	err := controller.SelectConnectionByName("test-connection")
+	assert.NoError(t, err)
+	err = controller.SelectDestinationByName("queue")
+	assert.NoError(t, err)
+
+	err = controller.UpdateConnection("test-connection", "renamed", asb.Connection{
+		Namespace:    "test.azure.com",
+		Destinations: []string{"queue"},
+	})
+
+	assert.NoError(t, err)
+	assert.NotContains(t, controller.Config.Connections, "test-connection")
+	assert.Equal(t, "renamed", controller.GetSelectedConnectionName())
+	assert.Equal(t, "queue", controller.GetSelectedDestination())
+}
+
+func Test_Controller_Should_Delete_Selected_Connection(t *testing.T) {
+	controller, _, _ := createTestController()
+	err := controller.SelectConnectionByName("test-connection")
+	assert.NoError(t, err)
+
+	err = controller.DeleteConnection("test-connection")
+
+	assert.NoError(t, err)
+	assert.Empty(t, controller.Config.Connections)
+	assert.Equal(t, "", controller.GetSelectedConnectionName())
+}
+
+func Test_Controller_Should_Duplicate_Connection(t *testing.T) {
+	controller, _, _ := createTestController()
+
+	first, err := controller.DuplicateConnection("test-connection")
+	assert.NoError(t, err)
+	second, err := controller.DuplicateConnection("test-connection")
+	assert.NoError(t, err)
+
+	assert.Equal(t, "test-connection-copy", first)
+	assert.Equal(t, "test-connection-copy-2", second)
+	assert.Equal(t, controller.Config.Connections["test-connection"], controller.Config.Connections[first])
+}
+
+func Test_Controller_Should_Add_Update_And_Delete_Message(t *testing.T) {
+	controller, inMemoryConfig, _ := createTestController()
+	message := asb.Message{Body: "{{generateUUID}}", CustomProperties: map[string]any{"a": true}}
+
+	err := controller.AddMessage("new-message", message)
+	assert.NoError(t, err)
+	assert.Equal(t, message, inMemoryConfig.Config.Messages["new-message"])
+
+	message.Subject = "updated"
+	err = controller.UpdateMessage("new-message", "updated-message", message)
+	assert.NoError(t, err)
+	assert.Equal(t, message, inMemoryConfig.Config.Messages["updated-message"])
+	assert.NotContains(t, inMemoryConfig.Config.Messages, "new-message")
+
+	err = controller.DeleteMessage("updated-message")
+	assert.NoError(t, err)
+	assert.Equal(t, config.GetTestConfig().Messages, inMemoryConfig.Config.Messages)
+}
+
+func Test_Controller_Should_Not_Add_Message_With_Broken_Template(t *testing.T) {
+	controller, _, _ := createTestController()
+
+	err := controller.AddMessage("broken", asb.Message{Body: "{{unknownFunction}}"})
+
+	var fieldErrors FieldErrors
+	assert.ErrorAs(t, err, &fieldErrors)
+	assert.Equal(t, "Body", fieldErrors[0].Field)
+	assert.NotContains(t, controller.Config.Messages, "broken")
+}
+
+func Test_Controller_Should_Duplicate_Message_Without_Sharing_Properties(t *testing.T) {
+	controller, _, _ := createTestController()
+	err := controller.AddMessage("with-properties", asb.Message{CustomProperties: map[string]any{"a": "b"}})
+	assert.NoError(t, err)
+
+	name, err := controller.DuplicateMessage("with-properties")
+	assert.NoError(t, err)
+	controller.Config.Messages[name].CustomProperties["a"] = "changed"
+
+	assert.Equal(t, "b", controller.Config.Messages["with-properties"].CustomProperties["a"])
+}
+
+func Test_Controller_Save_Config_Json_Should_Not_Save_Invalid_Config(t *testing.T) {
+	controller, inMemoryConfig, _ := createTestController()
+
+	err := controller.SaveConfigJson(`{
+    "connections": {
+        "dev": { "namespace": "", "destinations": [ "queue" ] }
+    }
+}`)
+
+	assert.Equal(t, config.ValidationErrors{{
+		Path:    `$.connections["dev"].namespace`,
+		Offset:  53,
+		Line:    3,
+		Column:  31,
+		Message: "namespace or connection string is required",
+	}}, err)
+	assert.Equal(t, config.GetTestConfig(), inMemoryConfig.Config)
+	assert.Equal(t, config.GetTestConfig(), controller.Config)
+}
+
+func Test_Controller_Should_Resolve_Secrets_Only_When_Sending(t *testing.T) {
+	t.Setenv("BUSGOPHER_TEST_CONNECTION", "Endpoint=sb://test/;SharedAccessKey=secret")
+	t.Setenv("BUSGOPHER_TEST_TOKEN", "token")
+	controller, inMemoryConfig, messageSender := createTestController()
+	err := controller.AddConnection("secret-connection", asb.Connection{
+		ConnectionString: "env:BUSGOPHER_TEST_CONNECTION",
+		Destinations:     []string{"queue"},
+	})
+	assert.NoError(t, err)
+	err = controller.AddMessage("secret-message", asb.Message{
+		Body:             "body",
+		CustomProperties: map[string]any{"token": "env:BUSGOPHER_TEST_TOKEN", "count": 1.0},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, controller.SelectConnectionByName("secret-connection"))
+	assert.NoError(t, controller.SelectDestinationByName("queue"))
+	assert.NoError(t, controller.SelectMessageByName("secret-message"))
+
+	_, err = controller.Send()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Endpoint=sb://test/;SharedAccessKey=secret", messageSender.Connection.ConnectionString)
+	assert.Equal(t, map[string]any{"token": "token", "count": 1.0}, messageSender.Message.CustomProperties)
+	assert.Equal(t, "env:BUSGOPHER_TEST_CONNECTION", inMemoryConfig.Config.Connections["secret-connection"].ConnectionString)
+	assert.Equal(t, "env:BUSGOPHER_TEST_TOKEN", inMemoryConfig.Config.Messages["secret-message"].CustomProperties["token"])
+	configString, err := controller.GetConfigString()
+	assert.NoError(t, err)
+	assert.NotContains(t, configString, "SharedAccessKey=secret")
+}
+
+func Test_Controller_Should_Not_Send_When_Secret_Is_Missing(t *testing.T) {
+	controller, _, _ := createTestController()
+	err := controller.AddMessage("secret-message", asb.Message{Subject: "env:BUSGOPHER_TEST_MISSING"})
+	assert.NoError(t, err)
+	assert.NoError(t, controller.SelectConnectionByName("test-connection"))
+	assert.NoError(t, controller.SelectDestinationByName("queue"))
+	assert.NoError(t, controller.SelectMessageByName("secret-message"))
+
+	_, err = controller.Send()
+
+	assert.EqualError(t, err, "Can't resolve secret 'env:BUSGOPHER_TEST_MISSING': environment variable is not set")
+	entries, err := controller.GetHistory("")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, history.ResultFailed, entries[0].Result)
+	assert.Equal(t, "env:BUSGOPHER_TEST_MISSING", entries[0].Message.Subject)
+}
+
+func Test_Controller_Should_Send_Rendered_Body(t *testing.T) {
+	controller, _, messageSender := createTestController()
+	err := controller.AddMessage("template-message", asb.Message{Body: `{{ "rendered" }}`})
+	assert.NoError(t, err)
+	assert.NoError(t, controller.SelectConnectionByName("test-connection"))
+	assert.NoError(t, controller.SelectDestinationByName("queue"))
+	assert.NoError(t, controller.SelectMessageByName("template-message"))
+
+	_, err = controller.Send()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "rendered", messageSender.Message.Body)
+}
+
+func Test_Controller_Should_Send_To_Undeclared_Destination(t *testing.T) {
+	controller, _, messageSender := createTestController()
+
+	result, err := controller.SendTo(
+		asb.Connection{Namespace: "other.azure.com"},
+		"undeclared",
+		asb.Message{Body: "{{ raw }}", MessageID: "1"},
+	)
+
+	assert.NoError(t, err)
+	assert.Equal(t, SendResult{MessageID: "1", Namespace: "other.azure.com", Destination: "undeclared"}, result)
+	assert.Equal(t, "other.azure.com", messageSender.Namespace)
+	assert.Equal(t, "undeclared", messageSender.Destination)
+	assert.Equal(t, "{{ raw }}", messageSender.Message.Body)
+}
+
+func Test_Controller_Should_Not_Send_To_Without_Namespace(t *testing.T) {
+	controller, _, _ := createTestController()
+
+	_, err := controller.SendTo(asb.Connection{}, "queue", asb.Message{})
+
+	assert.EqualError(t, err, "Namespace or connection string is required!")
+}
+
+func Test_Controller_Should_Receive_Reply_To_Request(t *testing.T) {
+	controller, _, messageSender, messageReceiver := createTestControllerWithReceiver()
+	err := controller.AddMessage("request", asb.Message{Body: "ping", MessageID: "request-1"})
+	assert.NoError(t, err)
+	replies := asb.Source{Destination: "replies", SessionID: "client-1"}
+	messageReceiver.Messages[replies] = []asb.ReceivedMessage{
+		{Message: asb.Message{Body: "other", CorrelationID: "request-0"}},
+		{Message: asb.Message{Body: "pong", CorrelationID: "request-1"}},
+	}
+	assert.NoError(t, controller.SelectConnectionByName("test-connection"))
+	assert.NoError(t, controller.SelectDestinationByName("queue"))
+	assert.NoError(t, controller.SelectMessageByName("request"))
+
+	sent, reply, err := controller.Request("replies", "client-1", time.Second)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "request-1", sent.MessageID)
+	assert.Equal(t, "replies", messageSender.Message.ReplayTo)
+	assert.Equal(t, "client-1", messageSender.Message.ReplyToSessionID)
+	assert.Equal(t, "pong", reply.Body)
+	assert.Len(t, messageReceiver.Messages[replies], 1)
+}
+
+func Test_Controller_Should_Peek_Reply_Without_Session(t *testing.T) {
+	controller, _, _, messageReceiver := createTestControllerWithReceiver()
+	replies := asb.Source{Destination: "replies"}
+	messageReceiver.Messages[replies] = []asb.ReceivedMessage{
+		{Message: asb.Message{Body: "other", CorrelationID: "request-0"}, SequenceNumber: 1},
+		{Message: asb.Message{Body: "pong", CorrelationID: "request-1"}, SequenceNumber: 2},
+	}
+
+	_, reply, err := controller.RequestTo(
+		asb.Connection{Namespace: "test.azure.com"},
+		"queue",
+		asb.Message{Body: "ping", MessageID: "request-1", ReplayTo: "replies"},
+		time.Second,
+	)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "pong", reply.Body)
+	assert.Len(t, messageReceiver.Messages[replies], 2)
+	assert.Zero(t, messageReceiver.Messages[replies][0].DeliveryCount)
+}
+
+func Test_Controller_Should_Fail_When_No_Reply_Received(t *testing.T) {
+	controller, _, _, _ := createTestControllerWithReceiver()
+
+	sent, _, err := controller.RequestTo(
+		asb.Connection{Namespace: "test.azure.com"},
+		"queue",
+		asb.Message{Body: "ping", MessageID: "request-1", ReplayTo: "replies"},
+		time.Millisecond,
+	)
+
+	assert.EqualError(t, err, "No reply to message request-1 received within 1ms")
+	assert.Equal(t, "request-1", sent.MessageID)
+}
+
+func Test_Controller_Should_Not_Request_Without_Reply_To(t *testing.T) {
+	controller, _, messageSender, _ := createTestControllerWithReceiver()
+
+	_, _, err := controller.RequestTo(asb.Connection{Namespace: "test.azure.com"}, "queue", asb.Message{}, time.Second)
+
+	assert.EqualError(t, err, "Reply to is required!")
+	assert.Empty(t, messageSender.Destination)
+}
+
+func Test_Controller_Should_Tail_Only_New_Messages(t *testing.T) {
+	controller, _, _, messageReceiver := createTestControllerWithReceiver()
+	source := asb.Source{Destination: "queue"}
+	messageReceiver.Add(source, asb.ReceivedMessage{Message: asb.Message{Body: "old"}, SequenceNumber: 1})
+	assert.NoError(t, controller.SelectConnectionByName("test-connection"))
+	tailed := make(chan []asb.ReceivedMessage, 1)
+
+	stop, err := controller.Tail(
+		source,
+		0,
+		time.Millisecond,
+		filter.Filter{BodyContains: "new"},
+		func(messages []asb.ReceivedMessage) { tailed <- messages },
+		func(err error) { t.Error(err) },
+	)
+	assert.NoError(t, err)
+	messageReceiver.Add(
+		source,
+		asb.ReceivedMessage{Message: asb.Message{Body: "skipped"}, SequenceNumber: 2},
+		asb.ReceivedMessage{Message: asb.Message{Body: "new"}, SequenceNumber: 3},
+	)
+
+	messages := <-tailed
+	stop()
+
+	assert.Len(t, messages, 1)
+	assert.Equal(t, "new", messages[0].Body)
+	assert.Len(t, messageReceiver.Messages[source], 3)
+}
+
+func Test_Controller_Should_Find_Sequence_Number_After_Last_Message(t *testing.T) {
+	for _, last := range []int64{1, 99, 100, 101, 250, 7001, 123456} {
+		controller, _, _, messageReceiver := createTestControllerWithReceiver()
+		source := asb.Source{Destination: "queue"}
+		// Sequence numbers have gaps
+		for sequenceNumber := int64(1); sequenceNumber < last; sequenceNumber += 7 {
+			messageReceiver.Add(source, asb.ReceivedMessage{SequenceNumber: sequenceNumber})
+		}
+		messageReceiver.Add(source, asb.ReceivedMessage{SequenceNumber: last})
+
+		next, err := controller.nextSequenceNumber(asb.Connection{}, source)
+
+		assert.NoError(t, err)
+		assert.Equal(t, last+1, next, "last %v", last)
+	}
+}
+
+func Test_Controller_Should_Not_Tail_Without_Connection(t *testing.T) {
+	controller, _, _, _ := createTestControllerWithReceiver()
+
+	_, err := controller.Tail(asb.Source{Destination: "queue"}, 0, time.Second, filter.Filter{}, nil, nil)
+
+	assert.EqualError(t, err, "Connection not selected!")
+}
+
+func Test_Controller_Should_Peek_Matching_Messages_Across_Batches(t *testing.T) {
+	controller, _, _, messageReceiver := createTestControllerWithReceiver()
+	source := asb.Source{Destination: "queue", DeadLetter: true}
+	for i := 1; i <= 600; i++ {
+		messageReceiver.Add(source, asb.ReceivedMessage{
+			Message:        asb.Message{Body: fmt.Sprintf("message %v", i)},
+			SequenceNumber: int64(i),
+		})
+	}
+	assert.NoError(t, controller.SelectConnectionByName("test-connection"))
+
+	messages, err := controller.PeekMatching(source, 2, 0, filter.Filter{BodyContains: "message 5"})
+
+	assert.NoError(t, err)
+	assert.Len(t, messages, 2)
+	assert.Equal(t, "message 5", messages[0].Body)
+	assert.Equal(t, "message 50", messages[1].Body)
+
+	messages, err = controller.PeekMatching(source, 20, 0, filter.Filter{BodyContains: "message 59"})
+
+	assert.NoError(t, err)
+	assert.Len(t, messages, 11)
+	assert.Equal(t, int64(599), messages[10].SequenceNumber)
+}
+
+func Test_Controller_Should_Receive_Only_Matching_Messages(t *testing.T) {
+	controller, _, _, messageReceiver := createTestControllerWithReceiver()
+	source := asb.Source{Destination: "queue"}
+	messageReceiver.Add(
+		source,
+		asb.ReceivedMessage{Message: asb.Message{Body: "keep", Subject: "a"}},
+		asb.ReceivedMessage{Message: asb.Message{Body: "take", Subject: "b"}},
+	)
+	assert.NoError(t, controller.SelectConnectionByName("test-connection"))
+
+	messages, err := controller.ReceiveMatching(
+		source,
+		10,
+		time.Millisecond,
+		filter.Filter{BrokerProperties: map[string]string{"subject": "b"}},
+	)
+
+	assert.NoError(t, err)
+	assert.Len(t, messages, 1)
+	assert.Equal(t, "take", messages[0].Body)
+	assert.Len(t, messageReceiver.Messages[source], 1)
+	assert.Equal(t, "keep", messageReceiver.Messages[source][0].Body)
+}
+
+func Test_Controller_Should_Save_Received_Message(t *testing.T) {
+	controller, inMemoryConfig, _ := createTestController()
+	received := asb.ReceivedMessage{
+		Message:        asb.Message{Body: `{"id": "69a17b86-68d7-4e59-bb2f-09b3590135c8"}`, MessageID: "id-1", Subject: "created"},
+		SequenceNumber: 3,
+	}
+
+	err := controller.SaveReceivedMessage("captured", received, true)
+
+	assert.NoError(t, err)
+	saved := inMemoryConfig.Config.Messages["captured"]
+	assert.Equal(t, `{"id": "{{generateUUID}}"}`, saved.Body)
+	assert.Equal(t, "created", saved.Subject)
+	assert.Empty(t, saved.MessageID)
+	assert.Error(t, controller.SaveReceivedMessage("captured", received, false))
+}
+
+func Test_Controller_Should_Purge_All_Messages_In_Parallel(t *testing.T) {
+	controller, _, _, messageReceiver := createTestControllerWithReceiver()
+	source := asb.Source{Destination: "queue", DeadLetter: true}
+	for i := 1; i <= 1050; i++ {
+		messageReceiver.Add(source, asb.ReceivedMessage{SequenceNumber: int64(i)})
+	}
+	assert.NoError(t, controller.SelectConnectionByName("test-connection"))
+	progress := 0
+
+	purged, _, err := controller.Purge(source, filter.Filter{}, 4, func(purged int) { progress = purged })
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1050, purged)
+	assert.Equal(t, 1050, progress)
+	assert.Empty(t, messageReceiver.Messages[source])
+}
+
+func Test_Controller_Should_Purge_Only_Matching_Messages(t *testing.T) {
+	controller, _, _, messageReceiver := createTestControllerWithReceiver()
+	source := asb.Source{Destination: "queue"}
+	messageReceiver.Add(
+		source,
+		asb.ReceivedMessage{SequenceNumber: 1, Message: asb.Message{Body: "test run"}},
+		asb.ReceivedMessage{SequenceNumber: 2, Message: asb.Message{Body: "keep"}},
+		asb.ReceivedMessage{SequenceNumber: 3, Message: asb.Message{Body: "test run"}},
+		asb.ReceivedMessage{SequenceNumber: 4, Message: asb.Message{Body: "keep"}},
+	)
+	assert.NoError(t, controller.SelectConnectionByName("test-connection"))
+
+	purged, released, err := controller.Purge(source, filter.Filter{BodyContains: "test"}, 4, func(int) {})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, purged)
+	assert.Equal(t, 1, released)
+	kept := messageReceiver.Messages[source]
+	assert.Len(t, kept, 2)
+	assert.Equal(t, uint32(1), kept[0].DeliveryCount)
+	assert.Equal(t, uint32(0), kept[1].DeliveryCount)
+}
+
+func Test_Controller_Should_Not_Lock_Messages_When_None_Match_Purge_Filter(t *testing.T) {
+	controller, _, _, messageReceiver := createTestControllerWithReceiver()
+	source := asb.Source{Destination: "queue"}
+	messageReceiver.Add(source, asb.ReceivedMessage{SequenceNumber: 1, Message: asb.Message{Body: "keep"}})
+	assert.NoError(t, controller.SelectConnectionByName("test-connection"))
+
+	purged, released, err := controller.Purge(source, filter.Filter{BodyContains: "test"}, 4, func(int) {})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, purged)
+	assert.Equal(t, 0, released)
+	assert.Equal(t, uint32(0), messageReceiver.Messages[source][0].DeliveryCount)
+}
+
+func Test_Controller_Should_Not_Purge_When_Locking_Would_Dead_Letter_Kept_Message(t *testing.T) {
+	controller, _, _, messageReceiver := createTestControllerWithReceiver()
+	controller.administrator = &asb.InMemoryAdministrator{Topologies: map[string]*asb.Topology{
+		"test.azure.com": {Queues: []asb.QueueDefinition{{Name: "queue", MaxDeliveryCount: 3}}},
+	}}
+	source := asb.Source{Destination: "queue"}
+	messageReceiver.Add(
+		source,
+		asb.ReceivedMessage{SequenceNumber: 1, Message: asb.Message{Body: "keep"}, DeliveryCount: 2},
+		asb.ReceivedMessage{SequenceNumber: 2, Message: asb.Message{Body: "test run"}},
+	)
+	assert.NoError(t, controller.SelectConnectionByName("test-connection"))
+
+	purged, released, err := controller.Purge(source, filter.Filter{BodyContains: "test"}, 4, func(int) {})
+
+	assert.EqualError(t, err, "Kept message #1 was delivered 2 time(s), locking it to reach the matches after it would dead-letter it (max delivery count 3)!")
+	assert.Equal(t, 0, purged)
+	assert.Equal(t, 0, released)
+	assert.Len(t, messageReceiver.Messages[source], 2)
+	assert.Equal(t, uint32(2), messageReceiver.Messages[source][0].DeliveryCount)
+}
+
+func Test_Controller_Should_Purge_Before_Kept_Message_Near_Max_Delivery_Count(t *testing.T) {
+	controller, _, _, messageReceiver := createTestControllerWithReceiver()
+	source := asb.Source{Destination: "queue"}
+	messageReceiver.Add(
+		source,
+		asb.ReceivedMessage{SequenceNumber: 1, Message: asb.Message{Body: "test run"}},
+		asb.ReceivedMessage{SequenceNumber: 2, Message: asb.Message{Body: "keep"}, DeliveryCount: defaultMaxDeliveryCount - 1},
+	)
+	assert.NoError(t, controller.SelectConnectionByName("test-connection"))
+
+	purged, _, err := controller.Purge(source, filter.Filter{BodyContains: "test"}, 4, func(int) {})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, purged)
+	assert.Equal(t, uint32(defaultMaxDeliveryCount-1), messageReceiver.Messages[source][0].DeliveryCount)
+}
+
+func createControllerWithEntities(t *testing.T) (*Controller, *config.InMemoryConfigStorage) {
+	controller, inMemoryConfig, _ := createTestController()
+	controller.administrator = &asb.InMemoryAdministrator{Entities: map[string][]asb.Entity{
+		"test.azure.com": {
+			{Kind: asb.EntityQueue, Name: "queue"},
+			{Kind: asb.EntityQueue, Name: "orders"},
+			{Kind: asb.EntityTopic, Name: "topic"},
+			{Kind: asb.EntitySubscription, Name: "audit", Topic: "topic"},
+		},
+	}}
+	assert.NoError(t, controller.SelectConnectionByName("test-connection"))
+	return controller, inMemoryConfig
+}
+
+func Test_Controller_Should_Add_Discovered_Destinations_Without_Saving(t *testing.T) {
+	controller, inMemoryConfig := createControllerWithEntities(t)
+
+	entities, err := controller.DiscoverEntities()
+
+	assert.NoError(t, err)
+	assert.Len(t, entities, 4)
+	assert.Equal(t, []string{"queue", "topic", "orders"}, controller.GetDestiationNamesForSelectedConnection())
+	assert.Equal(t, []string{"orders"}, controller.GetUnsavedDestinations())
+	assert.NoError(t, controller.SelectDestinationByName("orders"))
+	assert.Equal(t, []string{"queue", "topic"}, inMemoryConfig.Config.Connections["test-connection"].Destinations)
+}
+
+func Test_Controller_Should_Add_Discovered_Destinations_To_Prepared_Connection(t *testing.T) {
+	controller, _ := createControllerWithEntities(t)
+	controller.Config.Connections["other"] = asb.Connection{Namespace: "other.azure.com"}
+	prepared, err := controller.PrepareDiscovery()
+	assert.NoError(t, err)
+	assert.NoError(t, controller.SelectConnectionByName("other"))
+
+	entities, err := controller.ListEntities(prepared)
+	controller.AddDiscovered(prepared, entities)
+
+	assert.NoError(t, err)
+	assert.Empty(t, controller.GetUnsavedDestinations())
+	assert.NoError(t, controller.SelectConnectionByName("test-connection"))
+	assert.Equal(t, []string{"orders"}, controller.GetUnsavedDestinations())
+}
+
+func Test_Controller_Should_Save_Discovered_Destinations(t *testing.T) {
+	controller, inMemoryConfig := createControllerWithEntities(t)
+	_, err := controller.DiscoverEntities()
+	assert.NoError(t, err)
+
+	added, err := controller.SaveDiscoveredDestinations()
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"orders"}, added)
+	assert.Equal(t, []string{"queue", "topic", "orders"}, inMemoryConfig.Config.Connections["test-connection"].Destinations)
+	assert.Empty(t, controller.GetUnsavedDestinations())
+}
+
+func Test_Controller_Should_Keep_Selection_Of_Snapshot(t *testing.T) {
+	controller, _, _ := createTestController()
+	assert.NoError(t, controller.SelectConnectionByName("test-connection"))
+	assert.NoError(t, controller.SelectDestinationByName("queue"))
+
+	controller.Config.Connections["other"] = asb.Connection{Namespace: "other.azure.com"}
+
+	snapshot := controller.Snapshot()
+	assert.NoError(t, controller.SelectConnectionByName("other"))
+
+	assert.Equal(t, "test-connection", snapshot.GetSelectedConnectionName())
+	assert.Equal(t, "queue", snapshot.GetSelectedDestination())
+	assert.Equal(t, "other", controller.GetSelectedConnectionName())
+}
+
+func Test_Controller_Should_Return_Statistics_Of_Destination_And_Its_Subscriptions(t *testing.T) {
+	controller, _, _ := createTestController()
+	controller.administrator = &asb.InMemoryAdministrator{Statistics: map[string][]asb.EntityStatistics{
+		"test.azure.com": {
+			{Entity: asb.Entity{Kind: asb.EntityQueue, Name: "queue"}, ActiveMessageCount: 3},
+			{Entity: asb.Entity{Kind: asb.EntityTopic, Name: "topic"}},
+			{Entity: asb.Entity{Kind: asb.EntitySubscription, Name: "audit", Topic: "topic"}, DeadLetterMessageCount: 1},
+		},
+	}}
+	assert.NoError(t, controller.SelectConnectionByName("test-connection"))
+
+	all, err := controller.GetStatistics("")
+	assert.NoError(t, err)
+	topic, err := controller.GetStatistics("topic")
+	assert.NoError(t, err)
+
+	assert.Len(t, all, 3)
+	assert.Len(t, topic, 2)
+	assert.Equal(t, "topic/subscriptions/audit", topic[1].Path())
+	assert.Equal(t, int32(1), topic[1].DeadLetterMessageCount)
+}
+
+func Test_Controller_Should_Not_Return_Statistics_Without_Connection(t *testing.T) {
+	controller, _, _ := createTestController()
+
+	_, err := controller.GetStatistics("")
+
+	assert.Error(t, err)
+}
+
+func createControllerWithTopology(t *testing.T) (*Controller, *asb.InMemoryAdministrator) {
+	controller, _, _ := createTestController()
+	administrator := &asb.InMemoryAdministrator{}
+	controller.administrator = administrator
+	controller.Config.Topologies = map[string]asb.Topology{
+		"orders": {
+			Queues: []asb.QueueDefinition{{Name: "orders", MaxDeliveryCount: 5, LockDuration: "1m"}},
+			Topics: []asb.TopicDefinition{{
+				Name: "events",
+				Subscriptions: []asb.SubscriptionDefinition{{
+					Name: "created",
+					Rules: []asb.RuleDefinition{
+						{Name: "created", Correlation: &asb.CorrelationFilter{Subject: "created", Properties: map[string]any{"version": 2.0}}},
+					},
+				}},
+			}},
+		},
+	}
+	assert.NoError(t, controller.SelectConnectionByName("test-connection"))
+	return controller, administrator
+}
+
+func Test_Controller_Should_Apply_Topology(t *testing.T) {
+	controller, administrator := createControllerWithTopology(t)
+
+	changes, err := controller.Apply("orders", false)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []EntityChange{
+		{Action: ChangeCreated, Kind: asb.EntityQueue, Path: "orders"},
+		{Action: ChangeCreated, Kind: asb.EntityTopic, Path: "events"},
+		{Action: ChangeCreated, Kind: asb.EntitySubscription, Path: "events/subscriptions/created"},
+		{Action: ChangeCreated, Kind: "rule", Path: "events/subscriptions/created/rules/created"},
+		{Action: ChangeDeleted, Kind: "rule", Path: "events/subscriptions/created/rules/$Default"},
+	}, changes)
+	rules, err := administrator.ListRules(asb.Connection{Namespace: "test.azure.com"}, "events", "created")
+	assert.NoError(t, err)
+	assert.Len(t, rules, 1)
+	assert.Equal(t, "created", rules[0].Name)
+}
+
+func Test_Controller_Should_Apply_Topology_Idempotently(t *testing.T) {
+	controller, _ := createControllerWithTopology(t)
+	_, err := controller.Apply("orders", false)
+	assert.NoError(t, err)
+
+	changes, err := controller.Apply("orders", false)
+
+	assert.NoError(t, err)
+	assert.Len(t, changes, 4)
+	for _, change := range changes {
+		assert.Equal(t, ChangeUnchanged, change.Action, change.Path)
+	}
+}
+
+func Test_Controller_Should_Update_Changed_Entities(t *testing.T) {
+	controller, administrator := createControllerWithTopology(t)
+	_, err := controller.Apply("orders", false)
+	assert.NoError(t, err)
+	topology := controller.Config.Topologies["orders"]
+	topology.Queues[0].LockDuration = "60s"
+	topology.Queues[0].MaxDeliveryCount = 10
+
+	changes, err := controller.Apply("orders", false)
+
+	assert.NoError(t, err)
+	assert.Equal(t, EntityChange{Action: ChangeUpdated, Kind: asb.EntityQueue, Path: "orders"}, changes[0])
+	queue, _ := administrator.GetQueue(asb.Connection{Namespace: "test.azure.com"}, "orders")
+	assert.Equal(t, int32(10), queue.MaxDeliveryCount)
+}
+
+func Test_Controller_Should_Only_Report_Entities_Requiring_Recreation(t *testing.T) {
+	controller, administrator := createControllerWithTopology(t)
+	_, err := controller.Apply("orders", false)
+	assert.NoError(t, err)
+	topology := controller.Config.Topologies["orders"]
+	topology.Queues[0].RequiresSession = true
+	topology.Queues[0].MaxDeliveryCount = 10
+
+	changes, err := controller.Apply("orders", false)
+
+	assert.NoError(t, err)
+	assert.Equal(t, EntityChange{Action: ChangeRecreationRequired, Kind: asb.EntityQueue, Path: "orders"}, changes[0])
+	assert.False(t, changes[0].Changed())
+	queue, _ := administrator.GetQueue(asb.Connection{Namespace: "test.azure.com"}, "orders")
+	assert.False(t, queue.RequiresSession)
+	assert.NotEqual(t, int32(10), queue.MaxDeliveryCount)
+}
+
+func Test_Controller_Should_Not_Change_Anything_In_Dry_Run(t *testing.T) {
+	controller, administrator := createControllerWithTopology(t)
+
+	changes, err := controller.Apply("orders", true)
+
+	assert.NoError(t, err)
+	assert.Len(t, changes, 5)
+	assert.Empty(t, administrator.Topologies["test.azure.com"].Queues)
+	assert.Empty(t, administrator.Topologies["test.azure.com"].Topics)
+}
+
+func Test_Controller_Should_Create_And_Delete_Entities(t *testing.T) {
+	controller, administrator := createControllerWithTopology(t)
+	connection := asb.Connection{Namespace: "test.azure.com"}
+
+	assert.NoError(t, controller.CreateTopic(asb.TopicDefinition{Name: "events"}))
+	assert.NoError(t, controller.CreateSubscription("events", asb.SubscriptionDefinition{Name: "audit"}))
+	assert.NoError(t, controller.AddRule("events", "audit", asb.RuleDefinition{Name: "acme", SQL: "tenant = 'acme'"}))
+	assert.Error(t, controller.AddRule("events", "audit", asb.RuleDefinition{Name: "empty"}))
+	assert.NoError(t, controller.DeleteRule("events", "audit", asb.DefaultRuleName))
+	rules, err := controller.GetRules("events", "audit")
+	assert.NoError(t, err)
+	assert.Equal(t, []asb.RuleDefinition{{Name: "acme", SQL: "tenant = 'acme'"}}, rules)
+
+	assert.NoError(t, controller.DeleteEntity(asb.Entity{Kind: asb.EntitySubscription, Name: "audit", Topic: "events"}))
+	subscription, err := administrator.GetSubscription(connection, "events", "audit")
+	assert.NoError(t, err)
+	assert.Nil(t, subscription)
+}
+
+func Test_Controller_Should_Test_Routing_Of_Selected_Message(t *testing.T) {
+	controller, _, _ := createTestController()
+	controller.administrator = &asb.InMemoryAdministrator{Topologies: map[string]*asb.Topology{
+		"test.azure.com": {Topics: []asb.TopicDefinition{{
+			Name: "topic",
+			Subscriptions: []asb.SubscriptionDefinition{
+				{Name: "all", Rules: []asb.RuleDefinition{{Name: asb.DefaultRuleName, SQL: "1=1"}}},
+				{Name: "created", Rules: []asb.RuleDefinition{
+					{Name: "created", Correlation: &asb.CorrelationFilter{Subject: "created"}},
+				}},
+			},
+		}}},
+	}}
+	message := controller.Config.Messages["test-message"]
+	message.Subject = "created"
+	controller.Config.Messages["test-message"] = message
+	assert.NoError(t, controller.SelectConnectionByName("test-connection"))
+	assert.NoError(t, controller.SelectDestinationByName("topic"))
+	assert.NoError(t, controller.SelectMessageByName("test-message"))
+
+	routes, err := controller.TestRouting()
+
+	assert.NoError(t, err)
+	assert.Equal(t, []routing.Route{
+		{Subscription: "all", Matched: true, Rules: []string{asb.DefaultRuleName}},
+		{Subscription: "created", Matched: true, Rules: []string{"created"}},
+	}, routes)
+}
+
+func Test_Controller_Should_Test_Routing_With_Variables_Of_Applied_Preset(t *testing.T) {
+	controller, _, _ := createTestController()
+	controller.administrator = &asb.InMemoryAdministrator{Topologies: map[string]*asb.Topology{
+		"test.azure.com": {Topics: []asb.TopicDefinition{{
+			Name:          "topic",
+			Subscriptions: []asb.SubscriptionDefinition{{Name: "all", Rules: []asb.RuleDefinition{{Name: asb.DefaultRuleName, SQL: "1=1"}}}},
+		}}},
+	}}
+	assert.NoError(t, controller.AddMessage("tenant-message", asb.Message{Body: `{"tenant": "{{ var "tenant" }}"}`}))
+	assert.NoError(t, controller.SavePreset("tenant", config.Preset{
+		Connection:  "test-connection",
+		Destination: "topic",
+		Message:     "tenant-message",
+		Variables:   map[string]string{"tenant": "acme"},
+	}))
+	_, err := controller.ApplyPreset("tenant")
+	assert.NoError(t, err)
+
+	routes, err := controller.TestRouting()
+
+	assert.NoError(t, err)
+	assert.Equal(t, []routing.Route{{Subscription: "all", Matched: true, Rules: []string{asb.DefaultRuleName}}}, routes)
+}
+
+func Test_Controller_Should_Not_Test_Routing_Without_Subscriptions(t *testing.T) {
+	controller, _, _ := createTestController()
+	assert.NoError(t, controller.SelectConnectionByName("test-connection"))
+	assert.NoError(t, controller.SelectDestinationByName("queue"))
+	assert.NoError(t, controller.SelectMessageByName("test-message"))
+
+	_, err := controller.TestRouting()
+
+	assert.EqualError(t, err, "Can't find subscriptions of topic: queue")
+}
+
+func Test_Controller_Should_Peek_Next_Available_Session(t *testing.T) {
+	controller, _, _, receiver := createTestControllerWithReceiver()
+	receiver.Add(asb.Source{Destination: "queue", SessionID: "b"}, asb.ReceivedMessage{SequenceNumber: 2, Message: asb.Message{SessionID: "b"}})
+	receiver.Add(asb.Source{Destination: "queue", SessionID: "a"}, asb.ReceivedMessage{SequenceNumber: 1, Message: asb.Message{SessionID: "a"}})
+	assert.NoError(t, controller.SelectConnectionByName("test-connection"))
+
+	messages, err := controller.Peek(asb.Source{Destination: "queue", NextSession: true}, 10, 0)
+
+	assert.NoError(t, err)
+	assert.Len(t, messages, 1)
+	assert.Equal(t, "a", messages[0].SessionID)
+}
+
+func Test_Controller_Should_Set_Get_And_Clear_Session_State(t *testing.T) {
+	controller, _, _, _ := createTestControllerWithReceiver()
+	assert.NoError(t, controller.SelectConnectionByName("test-connection"))
+	source := asb.Source{Destination: "queue", SessionID: "order-42"}
+
+	assert.NoError(t, controller.SetSessionState(source, []byte(`{"step": 2}`)))
+	sessionID, state, err := controller.GetSessionState(source)
+	assert.NoError(t, err)
+	assert.Equal(t, "order-42", sessionID)
+	assert.Equal(t, `{"step": 2}`, string(state))
+
+	assert.NoError(t, controller.SetSessionState(source, []byte{}))
+	_, state, err = controller.GetSessionState(source)
+	assert.NoError(t, err)
+	assert.Nil(t, state)
+}
+
+func Test_Controller_Should_Require_Session_For_Session_State(t *testing.T) {
+	controller, _, _, _ := createTestControllerWithReceiver()
+	assert.NoError(t, controller.SelectConnectionByName("test-connection"))
+
+	_, _, err := controller.GetSessionState(asb.Source{Destination: "queue"})
+
+	assert.EqualError(t, err, "Session not selected!")
+}
+
+func Test_Controller_Should_Settle_Locked_Messages(t *testing.T) {
+	controller, _, _, receiver := createTestControllerWithReceiver()
+	source := asb.Source{Destination: "queue"}
+	receiver.Add(source,
+		asb.ReceivedMessage{SequenceNumber: 1},
+		asb.ReceivedMessage{SequenceNumber: 2},
+		asb.ReceivedMessage{SequenceNumber: 3},
+		asb.ReceivedMessage{SequenceNumber: 4},
+	)
+	assert.NoError(t, controller.SelectConnectionByName("test-connection"))
+
+	messages, err := controller.ReceiveLocked(source, 4, time.Second)
+	assert.NoError(t, err)
+	assert.Len(t, messages, 4)
+	assert.NoError(t, controller.Settle(1, asb.Settlement{Action: asb.SettleComplete}))
+	assert.NoError(t, controller.Settle(2, asb.Settlement{Action: asb.SettleAbandon, Properties: map[string]any{"retry": true}}))
+	assert.NoError(t, controller.Settle(3, asb.Settlement{Action: asb.SettleDefer}))
+	assert.NoError(t, controller.Settle(4, asb.Settlement{Action: asb.SettleDeadLetter, Reason: "invalid", Description: "missing tenant"}))
+	assert.NoError(t, controller.ReleaseLocked())
+
+	assert.Len(t, receiver.Messages[source], 1)
+	assert.Equal(t, int64(2), receiver.Messages[source][0].SequenceNumber)
+	assert.Equal(t, uint32(1), receiver.Messages[source][0].DeliveryCount)
+	assert.Equal(t, true, receiver.Messages[source][0].CustomProperties["retry"])
+	deadLettered := receiver.Messages[asb.Source{Destination: "queue", DeadLetter: true}]
+	assert.Len(t, deadLettered, 1)
+	assert.Equal(t, "invalid", deadLettered[0].DeadLetterReason)
+	assert.Equal(t, "missing tenant", deadLettered[0].DeadLetterDescription)
+
+	deferred, err := controller.ReceiveDeferred(source, []int64{3})
+	assert.NoError(t, err)
+	assert.Len(t, deferred, 1)
+	assert.NoError(t, controller.Settle(3, asb.Settlement{Action: asb.SettleComplete}))
+	assert.Empty(t, receiver.Deferred[source])
+}
+
+func Test_Controller_Should_Abandon_Unsettled_Messages_When_Released(t *testing.T) {
+	controller, _, _, receiver := createTestControllerWithReceiver()
+	source := asb.Source{Destination: "queue"}
+	receiver.Add(source, asb.ReceivedMessage{SequenceNumber: 1}, asb.ReceivedMessage{SequenceNumber: 2})
+	assert.NoError(t, controller.SelectConnectionByName("test-connection"))
+
+	_, err := controller.ReceiveLocked(source, 2, time.Second)
+	assert.NoError(t, err)
+	assert.NoError(t, controller.ReleaseLocked())
+
+	assert.Len(t, receiver.Messages[source], 2)
+	assert.EqualError(t, controller.Settle(1, asb.Settlement{Action: asb.SettleComplete}), "No locked messages!")
+}
+
+func Test_Controller_Should_Record_Sent_Message_In_History(t *testing.T) {
+	controller, _, _ := createTestController()
+	assert.NoError(t, controller.SelectConnectionByName("test-connection"))
+	assert.NoError(t, controller.SelectDestinationByName("queue"))
+	assert.NoError(t, controller.SelectMessageByName("test-message"))
+
+	result, err := controller.Send()
+	assert.NoError(t, err)
+	entries, err := controller.GetHistory("")
+
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "test-connection", entries[0].Connection)
+	assert.Equal(t, "queue", entries[0].Destination)
+	assert.Equal(t, "test-message", entries[0].MessageName)
+	assert.Equal(t, result.MessageID, entries[0].MessageID)
+	assert.Equal(t, history.ResultSent, entries[0].Result)
+}
+
+func Test_Controller_Should_Search_History_Most_Recent_First(t *testing.T) {
+	controller, _, _ := createTestController()
+	connection := asb.Connection{Namespace: "adhoc.servicebus.windows.net"}
+	_, err := controller.SendTo(connection, "orders", asb.Message{Body: "first order"})
+	assert.NoError(t, err)
+	_, err = controller.SendTo(connection, "invoices", asb.Message{Body: "invoice"})
+	assert.NoError(t, err)
+	_, err = controller.SendTo(connection, "orders", asb.Message{Body: "second order"})
+	assert.NoError(t, err)
+
+	entries, err := controller.GetHistory("order")
+
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "second order", entries[0].Message.Body)
+	assert.Empty(t, entries[0].Connection)
+}
+
+func Test_Controller_Should_Resend_Same_Payload_From_History(t *testing.T) {
+	controller, _, messageSender := createTestController()
+	message := asb.Message{Body: `{"id": 42}`, Subject: "created", CustomProperties: map[string]any{"tenant": "acme"}}
+	sent, err := controller.SendTo(asb.Connection{Namespace: "test.azure.com"}, "queue", message)
+	assert.NoError(t, err)
+	entries, err := controller.GetHistory("")
+	assert.NoError(t, err)
+	assert.Equal(t, "test-connection", entries[0].Connection)
+
+	resent, err := controller.Resend(entries[0])
+
+	assert.NoError(t, err)
+	assert.NotEqual(t, sent.MessageID, resent.MessageID)
+	assert.Equal(t, "queue", messageSender.Destination)
+	assert.Equal(t, message.Body, messageSender.Message.Body)
+	assert.Equal(t, message.CustomProperties, messageSender.Message.CustomProperties)
+	entries, err = controller.GetHistory("")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+}
+
+func Test_Controller_Should_Not_Resend_When_Connection_Points_To_Another_Namespace(t *testing.T) {
+	controller, _, messageSender := createTestController()
+	entry := history.Entry{Connection: "test-connection", Namespace: "old.azure.com", Destination: "queue"}
+
+	_, err := controller.Resend(entry)
+
+	assert.EqualError(t, err, "Connection 'test-connection' points to test.azure.com now, the message was sent to old.azure.com!")
+	assert.Empty(t, messageSender.Destination)
+}
+
+func Test_Controller_Should_Not_Resend_Without_Saved_Connection(t *testing.T) {
+	controller, _, _ := createTestController()
+
+	_, err := controller.Resend(history.Entry{Destination: "queue"})
+
+	assert.EqualError(t, err, "Connection of the sent message isn't saved!")
+}
+
+func Test_Controller_Should_Send_Preset_With_Its_Variables(t *testing.T) {
+	controller, inMemoryConfig, messageSender := createTestController()
+	assert.NoError(t, controller.AddMessage("order-created", asb.Message{Body: `{"id": "{{ var "orderId" "1" }}"}`}))
+	assert.NoError(t, controller.SavePreset("created-queue", config.Preset{
+		Connection:  "test-connection",
+		Destination: "queue",
+		Message:     "order-created",
+		Variables:   map[string]string{"orderId": "42"},
+	}))
+
+	_, err := controller.ApplyPreset("created")
+	assert.NoError(t, err)
+	_, err = controller.Send()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "queue", messageSender.Destination)
+	assert.Equal(t, `{"id": "42"}`, messageSender.Message.Body)
+	assert.Equal(t, "42", inMemoryConfig.Config.Presets["created-queue"].Variables["orderId"])
+
+	assert.NoError(t, controller.SelectMessageByName("order-created"))
+	_, err = controller.Send()
+	assert.NoError(t, err)
+	assert.Equal(t, `{"id": "1"}`, messageSender.Message.Body)
+}
+
+func Test_Controller_Should_Rename_References_Of_Presets_And_Favorites(t *testing.T) {
+	controller, inMemoryConfig, _ := createTestController()
+	assert.NoError(t, controller.SavePreset("test", config.Preset{Connection: "test-connection", Destination: "queue", Message: "test-message"}))
+	_, err := controller.ToggleFavorite(FavoriteConnection, "test-connection")
+	assert.NoError(t, err)
+	_, err = controller.ToggleFavorite(FavoriteMessage, "test-message")
+	assert.NoError(t, err)
+
+	assert.NoError(t, controller.UpdateConnection("test-connection", "renamed-connection", controller.Config.Connections["test-connection"]))
+	assert.NoError(t, controller.UpdateMessage("test-message", "renamed-message", controller.Config.Messages["test-message"]))
+
+	preset := inMemoryConfig.Config.Presets["test"]
+	assert.Equal(t, "renamed-connection", preset.Connection)
+	assert.Equal(t, "renamed-message", preset.Message)
+	assert.True(t, controller.IsFavorite(FavoriteConnection, "renamed-connection"))
+	assert.True(t, controller.IsFavorite(FavoriteMessage, "renamed-message"))
+}
+
+func Test_Controller_Should_Not_Delete_Connection_Or_Message_Used_By_Preset(t *testing.T) {
+	controller, inMemoryConfig, _ := createTestController()
+	assert.NoError(t, controller.SavePreset("test", config.Preset{Connection: "test-connection", Destination: "queue", Message: "test-message"}))
+
+	err := controller.DeleteConnection("test-connection")
+	assert.EqualError(t, err, "Can't delete connection 'test-connection', it is used by presets: test")
+	err = controller.DeleteMessage("test-message")
+	assert.EqualError(t, err, "Can't delete message 'test-message', it is used by presets: test")
+
+	assert.NoError(t, controller.DeletePreset("test"))
+	_, err = controller.ToggleFavorite(FavoriteMessage, "test-message")
+	assert.NoError(t, err)
+	assert.NoError(t, controller.DeleteMessage("test-message"))
+	assert.Empty(t, inMemoryConfig.Config.Favorites.Messages)
+}
+
+func Test_Controller_Should_Not_Save_Preset_Of_Missing_Message(t *testing.T) {
+	controller, _, _ := createTestController()
+
+	err := controller.SavePreset("broken", config.Preset{Connection: "test-connection", Destination: "queue", Message: "missing"})
+
+	assert.EqualError(t, err, "Message: message 'missing' doesn't exist")
+}
+
+func Test_Controller_Should_List_Favorites_First(t *testing.T) {
+	controller, inMemoryConfig, _ := createTestController()
+	assert.NoError(t, controller.AddMessage("a-message", asb.Message{Body: "a"}))
+	assert.NoError(t, controller.SelectConnectionByName("test-connection"))
+
+	favorite, err := controller.ToggleFavorite(FavoriteMessage, "test-message")
+	assert.NoError(t, err)
+	assert.True(t, favorite)
+	_, err = controller.ToggleFavorite(FavoriteDestination, "topic")
+	assert.NoError(t, err)
+
+	messages := controller.GetMessages()
+	assert.Equal(t, "test-message", messages[0].Name)
+	assert.Equal(t, "a-message", messages[1].Name)
+	assert.Equal(t, []string{"topic", "queue"}, controller.GetDestiationNamesForSelectedConnection())
+	assert.Equal(t, []string{"test-message"}, inMemoryConfig.Config.Favorites.Messages)
+
+	favorite, err = controller.ToggleFavorite(FavoriteMessage, "test-message")
+	assert.NoError(t, err)
+	assert.False(t, favorite)
+	assert.Equal(t, "a-message", controller.GetMessages()[0].Name)
+}
+
+func Test_Controller_Should_Return_Recent_Combinations(t *testing.T) {
+	controller, _, _ := createTestController()
+	assert.NoError(t, controller.SelectConnectionByName("test-connection"))
+	assert.NoError(t, controller.SelectMessageByName("test-message"))
+	for _, destination := range []string{"queue", "topic", "queue"} {
+		assert.NoError(t, controller.SelectDestinationByName(destination))
+		_, err := controller.Send()
+		assert.NoError(t, err)
+	}
+	_, err := controller.SendTo(asb.Connection{Namespace: "adhoc.servicebus.windows.net"}, "orders", asb.Message{Body: "ad-hoc"})
+	assert.NoError(t, err)
+
+	combinations, err := controller.GetRecentCombinations(5)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []Combination{
+		{Connection: "test-connection", Destination: "queue", Message: "test-message"},
+		{Connection: "test-connection", Destination: "topic", Message: "test-message"},
+	}, combinations)
+
+	assert.NoError(t, controller.SelectCombination(combinations[1]))
+	assert.Equal(t, "topic", controller.GetSelectedDestination())
+}
+
+func Test_Controller_Should_Change_Config_Revision_On_Reload(t *testing.T) {
+	controller, _, _ := createTestController()
+	revision := controller.GetConfigRevision()
+	assert.NoError(t, controller.AddMessage("edited", asb.Message{Body: "edited"}))
+	assert.Equal(t, revision, controller.GetConfigRevision())
+
+	err := controller.ReloadConfig()
+
+	assert.NoError(t, err)
+	assert.NotEqual(t, revision, controller.GetConfigRevision())
+}
+
+func Test_Controller_Should_Return_Received_Messages_With_Error(t *testing.T) {
+	controller, _, _, receiver := createTestControllerWithReceiver()
+	assert.NoError(t, controller.SelectConnectionByName("test-connection"))
+	receiver.Add(asb.Source{Destination: "queue"}, asb.ReceivedMessage{Message: asb.Message{Body: "removed"}, SequenceNumber: 1})
+	receiver.ReceiveError = errors.New("connection lost")
+
+	messages, err := controller.Receive(asb.Source{Destination: "queue"}, 10, time.Second)
+
+	assert.EqualError(t, err, "connection lost")
+	assert.Len(t, messages, 1)
+	assert.Equal(t, "removed", messages[0].Body)
 }
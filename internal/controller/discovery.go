@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/rafalpienkowski/busgopher/internal/asb"
+	"github.com/rafalpienkowski/busgopher/internal/config"
+)
+
+// PreparedDiscovery is the connection whose entities are listed by ListEntities
+type PreparedDiscovery struct {
+	connectionName string
+	connection     asb.Connection
+}
+
+// DiscoverEntities lists the entities of the selected connection's namespace. The discovered queues
+// and topics can be selected as destinations of the connection, without being saved to the config.
+func (controller *Controller) DiscoverEntities() ([]asb.Entity, error) {
+	prepared, err := controller.PrepareDiscovery()
+	if err != nil {
+		return nil, err
+	}
+	entities, err := controller.ListEntities(prepared)
+	if err != nil {
+		return nil, err
+	}
+	controller.AddDiscovered(prepared, entities)
+
+	return entities, nil
+}
+
+// PrepareDiscovery captures the selected connection for ListEntities, see DiscoverEntities
+func (controller *Controller) PrepareDiscovery() (PreparedDiscovery, error) {
+	connection, err := controller.getResolvedConnection()
+	if err != nil {
+		return PreparedDiscovery{}, err
+	}
+
+	return PreparedDiscovery{connectionName: controller.selectedConnectionName, connection: connection}, nil
+}
+
+// ListEntities lists the entities of the prepared connection's namespace. It doesn't read the state
+// of the controller, so it can run in the background while the selection or the config changes.
+func (controller *Controller) ListEntities(prepared PreparedDiscovery) ([]asb.Entity, error) {
+	entities, err := controller.administrator.ListEntities(prepared.connection)
+	if err != nil {
+		return nil, err
+	}
+	controller.writeLog(fmt.Sprintf("Discovered %v entities in: %v", len(entities), prepared.connection.Namespace))
+
+	return entities, nil
+}
+
+// AddDiscovered makes the listed queues and topics destinations of the prepared connection, even
+// when another connection was selected meanwhile
+func (controller *Controller) AddDiscovered(prepared PreparedDiscovery, entities []asb.Entity) {
+	destinations := []string{}
+	for _, entity := range entities {
+		if entity.Kind != asb.EntitySubscription {
+			destinations = append(destinations, entity.Name)
+		}
+	}
+	if controller.discovered == nil {
+		controller.discovered = make(map[string][]string)
+	}
+	controller.discovered[prepared.connectionName] = destinations
+}
+
+// SaveDiscoveredDestinations adds the discovered queues and topics missing in the selected connection
+// to the config and returns their names
+func (controller *Controller) SaveDiscoveredDestinations() ([]string, error) {
+	name := controller.selectedConnectionName
+	if len(name) == 0 {
+		return nil, fmt.Errorf("Connection not selected!")
+	}
+
+	added := controller.GetUnsavedDestinations()
+	if len(added) == 0 {
+		return added, nil
+	}
+
+	updated := cloneConfig(controller.Config)
+	connection := controller.Config.Connections[name]
+	connection.Destinations = append(slices.Clone(connection.Destinations), added...)
+	updated.Connections[name] = connection
+
+	return added, controller.saveConfig(updated, fmt.Sprintf("Added %v discovered destination(s) to connection '%v'", len(added), name))
+}
+
+// GetUnsavedDestinations returns the discovered queues and topics missing in the selected connection
+func (controller *Controller) GetUnsavedDestinations() []string {
+	name := controller.selectedConnectionName
+	unsaved := []string{}
+	for _, destination := range controller.discovered[name] {
+		if !slices.Contains(controller.Config.Connections[name].Destinations, destination) {
+			unsaved = append(unsaved, destination)
+		}
+	}
+
+	return unsaved
+}
+
+// destinationsOf returns the saved destinations of the connection followed by the discovered ones
+func (controller *Controller) destinationsOf(config config.Config, name string) []string {
+	destinations := slices.Clone(config.Connections[name].Destinations)
+	for _, destination := range controller.discovered[name] {
+		if !slices.Contains(destinations, destination) {
+			destinations = append(destinations, destination)
+		}
+	}
+
+	return destinations
+}
@@ -0,0 +1,452 @@
+package controller
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/rafalpienkowski/busgopher/internal/asb"
+)
+
+// Entity changes made by Apply
+const (
+	ChangeCreated   = "created"
+	ChangeUpdated   = "updated"
+	ChangeDeleted   = "deleted"
+	ChangeUnchanged = "unchanged"
+	// ChangeRecreationRequired marks entities differing in requiresSession, which the service can only
+	// set when an entity is created. Apply leaves them unchanged.
+	ChangeRecreationRequired = "recreation-required"
+)
+
+// EntityChange is a change of a queue, topic, subscription or rule made by Apply, or to be made
+// in a dry run
+type EntityChange struct {
+	Action string `json:"action"`
+	Kind   string `json:"kind"`
+	Path   string `json:"path"`
+}
+
+// Changed tells whether Apply changes the entity
+func (change EntityChange) Changed() bool {
+	return change.Action != ChangeUnchanged && change.Action != ChangeRecreationRequired
+}
+
+func (controller *Controller) GetTopologyNames() []string {
+	return slices.Sorted(maps.Keys(controller.Config.Topologies))
+}
+
+func (controller *Controller) CreateQueue(queue asb.QueueDefinition) error {
+	connection, err := controller.getResolvedConnection()
+	if err != nil {
+		return err
+	}
+	if err := queue.Validate(); err != nil {
+		return err
+	}
+
+	err = controller.administrator.CreateQueue(connection, queue)
+	if err != nil {
+		return err
+	}
+	controller.writeLog("Created queue: " + queue.Name)
+
+	return nil
+}
+
+func (controller *Controller) CreateTopic(topic asb.TopicDefinition) error {
+	connection, err := controller.getResolvedConnection()
+	if err != nil {
+		return err
+	}
+	if err := topic.Validate(); err != nil {
+		return err
+	}
+
+	err = controller.administrator.CreateTopic(connection, topic)
+	if err != nil {
+		return err
+	}
+	controller.writeLog("Created topic: " + topic.Name)
+
+	return nil
+}
+
+func (controller *Controller) CreateSubscription(topic string, subscription asb.SubscriptionDefinition) error {
+	connection, err := controller.getResolvedConnection()
+	if err != nil {
+		return err
+	}
+	if err := subscription.Validate(); err != nil {
+		return err
+	}
+
+	err = controller.administrator.CreateSubscription(connection, topic, subscription)
+	if err != nil {
+		return err
+	}
+	controller.writeLog("Created subscription: " + topic + "/subscriptions/" + subscription.Name)
+
+	return nil
+}
+
+// DeleteEntity deletes the queue, the topic with its subscriptions or the subscription
+func (controller *Controller) DeleteEntity(entity asb.Entity) error {
+	connection, err := controller.getResolvedConnection()
+	if err != nil {
+		return err
+	}
+
+	err = controller.administrator.DeleteEntity(connection, entity)
+	if err != nil {
+		return err
+	}
+	controller.writeLog(fmt.Sprintf("Deleted %v: %v", entity.Kind, entity.Path()))
+
+	return nil
+}
+
+func (controller *Controller) GetRules(topic string, subscription string) ([]asb.RuleDefinition, error) {
+	connection, err := controller.getResolvedConnection()
+	if err != nil {
+		return nil, err
+	}
+
+	return controller.administrator.ListRules(connection, topic, subscription)
+}
+
+func (controller *Controller) AddRule(topic string, subscription string, rule asb.RuleDefinition) error {
+	connection, err := controller.getResolvedConnection()
+	if err != nil {
+		return err
+	}
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+
+	err = controller.administrator.CreateRule(connection, topic, subscription, rule)
+	if err != nil {
+		return err
+	}
+	controller.writeLog(fmt.Sprintf("Added rule '%v' to: %v/subscriptions/%v", rule.Name, topic, subscription))
+
+	return nil
+}
+
+func (controller *Controller) DeleteRule(topic string, subscription string, name string) error {
+	connection, err := controller.getResolvedConnection()
+	if err != nil {
+		return err
+	}
+
+	err = controller.administrator.DeleteRule(connection, topic, subscription, name)
+	if err != nil {
+		return err
+	}
+	controller.writeLog(fmt.Sprintf("Deleted rule '%v' from: %v/subscriptions/%v", name, topic, subscription))
+
+	return nil
+}
+
+// Apply creates the entities of the topology missing in the selected connection's namespace and
+// updates the ones with different properties. Entities missing in the topology are kept, but rules
+// of subscriptions with rules in the topology are replaced. Entities with a different requiresSession
+// are only reported, they have to be deleted and created again. With dryRun nothing is changed.
+// The changes made before a failure are returned with the error.
+func (controller *Controller) Apply(name string, dryRun bool) ([]EntityChange, error) {
+	// Applying replaces rules, the topology isn't guessed
+	resolved, err := resolveExactName("topology", name, controller.GetTopologyNames())
+	if err != nil {
+		return nil, err
+	}
+	connection, err := controller.getResolvedConnection()
+	if err != nil {
+		return nil, err
+	}
+
+	applier := topologyApplier{
+		administrator: controller.administrator,
+		connection:    connection,
+		dryRun:        dryRun,
+		changes:       []EntityChange{},
+	}
+	err = applier.apply(controller.Config.Topologies[resolved])
+
+	changed := 0
+	for _, change := range applier.changes {
+		if change.Changed() {
+			changed++
+		}
+	}
+	if !dryRun {
+		controller.writeLog(fmt.Sprintf(
+			"Applied topology '%v' to %v: %v change(s)",
+			resolved,
+			connection.Namespace,
+			changed,
+		))
+	}
+
+	return applier.changes, err
+}
+
+type topologyApplier struct {
+	administrator asb.Administrator
+	connection    asb.Connection
+	dryRun        bool
+	changes       []EntityChange
+}
+
+func (applier *topologyApplier) record(action string, kind string, path string) {
+	applier.changes = append(applier.changes, EntityChange{Action: action, Kind: kind, Path: path})
+}
+
+func (applier *topologyApplier) apply(topology asb.Topology) error {
+	for _, queue := range topology.Queues {
+		err := applier.applyQueue(queue)
+		if err != nil {
+			return err
+		}
+	}
+	for _, topic := range topology.Topics {
+		err := applier.applyTopic(topic)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (applier *topologyApplier) applyQueue(queue asb.QueueDefinition) error {
+	existing, err := applier.administrator.GetQueue(applier.connection, queue.Name)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case existing == nil:
+		if !applier.dryRun {
+			err = applier.administrator.CreateQueue(applier.connection, queue)
+		}
+		applier.record(ChangeCreated, asb.EntityQueue, queue.Name)
+	case queue.RequiresSession != existing.RequiresSession:
+		applier.record(ChangeRecreationRequired, asb.EntityQueue, queue.Name)
+	case !sameProperties(queueProperties(queue), queueProperties(*existing)):
+		if !applier.dryRun {
+			err = applier.administrator.UpdateQueue(applier.connection, queue)
+		}
+		applier.record(ChangeUpdated, asb.EntityQueue, queue.Name)
+	default:
+		applier.record(ChangeUnchanged, asb.EntityQueue, queue.Name)
+	}
+
+	return err
+}
+
+func (applier *topologyApplier) applyTopic(topic asb.TopicDefinition) error {
+	existing, err := applier.administrator.GetTopic(applier.connection, topic.Name)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case existing == nil:
+		if !applier.dryRun {
+			err = applier.administrator.CreateTopic(applier.connection, topic)
+		}
+		applier.record(ChangeCreated, asb.EntityTopic, topic.Name)
+	case len(topic.DefaultMessageTimeToLive) > 0 &&
+		!asb.SameDuration(topic.DefaultMessageTimeToLive, existing.DefaultMessageTimeToLive):
+		if !applier.dryRun {
+			err = applier.administrator.UpdateTopic(applier.connection, topic)
+		}
+		applier.record(ChangeUpdated, asb.EntityTopic, topic.Name)
+	default:
+		applier.record(ChangeUnchanged, asb.EntityTopic, topic.Name)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, subscription := range topic.Subscriptions {
+		// Subscriptions of a topic not created in a dry run can't be read
+		err := applier.applySubscription(topic.Name, subscription, existing == nil && applier.dryRun)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (applier *topologyApplier) applySubscription(
+	topic string,
+	subscription asb.SubscriptionDefinition,
+	topicMissing bool,
+) error {
+	path := topic + "/subscriptions/" + subscription.Name
+	var existing *asb.SubscriptionDefinition
+	var err error
+	if !topicMissing {
+		existing, err = applier.administrator.GetSubscription(applier.connection, topic, subscription.Name)
+		if err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case existing == nil:
+		if !applier.dryRun {
+			err = applier.administrator.CreateSubscription(applier.connection, topic, subscription)
+		}
+		applier.record(ChangeCreated, asb.EntitySubscription, path)
+	case subscription.RequiresSession != existing.RequiresSession:
+		applier.record(ChangeRecreationRequired, asb.EntitySubscription, path)
+	case !sameProperties(subscriptionProperties(subscription), subscriptionProperties(*existing)):
+		if !applier.dryRun {
+			err = applier.administrator.UpdateSubscription(applier.connection, topic, subscription)
+		}
+		applier.record(ChangeUpdated, asb.EntitySubscription, path)
+	default:
+		applier.record(ChangeUnchanged, asb.EntitySubscription, path)
+	}
+	if err != nil || len(subscription.Rules) == 0 {
+		return err
+	}
+
+	// New subscriptions have only the default rule
+	rules := []asb.RuleDefinition{{Name: asb.DefaultRuleName, SQL: "1=1"}}
+	if existing != nil || !applier.dryRun {
+		rules, err = applier.administrator.ListRules(applier.connection, topic, subscription.Name)
+		if err != nil {
+			return err
+		}
+	}
+
+	return applier.applyRules(topic, subscription, rules)
+}
+
+func (applier *topologyApplier) applyRules(
+	topic string,
+	subscription asb.SubscriptionDefinition,
+	existing []asb.RuleDefinition,
+) error {
+	path := topic + "/subscriptions/" + subscription.Name + "/rules/"
+	var err error
+	for _, rule := range subscription.Rules {
+		index := slices.IndexFunc(existing, func(existingRule asb.RuleDefinition) bool {
+			return existingRule.Name == rule.Name
+		})
+		switch {
+		case index < 0:
+			if !applier.dryRun {
+				err = applier.administrator.CreateRule(applier.connection, topic, subscription.Name, rule)
+			}
+			applier.record(ChangeCreated, "rule", path+rule.Name)
+		case !sameRule(rule, existing[index]):
+			if !applier.dryRun {
+				err = applier.administrator.UpdateRule(applier.connection, topic, subscription.Name, rule)
+			}
+			applier.record(ChangeUpdated, "rule", path+rule.Name)
+		default:
+			applier.record(ChangeUnchanged, "rule", path+rule.Name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, rule := range existing {
+		defined := slices.ContainsFunc(subscription.Rules, func(definedRule asb.RuleDefinition) bool {
+			return definedRule.Name == rule.Name
+		})
+		if defined {
+			continue
+		}
+		if !applier.dryRun {
+			err = applier.administrator.DeleteRule(applier.connection, topic, subscription.Name, rule.Name)
+			if err != nil {
+				return err
+			}
+		}
+		applier.record(ChangeDeleted, "rule", path+rule.Name)
+	}
+
+	return nil
+}
+
+// entityProperties are the properties of queues and subscriptions compared by Apply
+type entityProperties struct {
+	lockDuration                     string
+	defaultMessageTimeToLive         string
+	maxDeliveryCount                 int32
+	deadLetteringOnMessageExpiration bool
+	forwardTo                        string
+}
+
+func queueProperties(queue asb.QueueDefinition) entityProperties {
+	return entityProperties{
+		lockDuration:                     queue.LockDuration,
+		defaultMessageTimeToLive:         queue.DefaultMessageTimeToLive,
+		maxDeliveryCount:                 queue.MaxDeliveryCount,
+		deadLetteringOnMessageExpiration: queue.DeadLetteringOnMessageExpiration,
+		forwardTo:                        queue.ForwardTo,
+	}
+}
+
+func subscriptionProperties(subscription asb.SubscriptionDefinition) entityProperties {
+	return entityProperties{
+		lockDuration:                     subscription.LockDuration,
+		defaultMessageTimeToLive:         subscription.DefaultMessageTimeToLive,
+		maxDeliveryCount:                 subscription.MaxDeliveryCount,
+		deadLetteringOnMessageExpiration: subscription.DeadLetteringOnMessageExpiration,
+		forwardTo:                        subscription.ForwardTo,
+	}
+}
+
+// sameProperties tells whether the existing entity has the defined properties, empty durations and
+// counts are not compared
+func sameProperties(defined entityProperties, existing entityProperties) bool {
+	if len(defined.lockDuration) > 0 && !asb.SameDuration(defined.lockDuration, existing.lockDuration) {
+		return false
+	}
+	if len(defined.defaultMessageTimeToLive) > 0 &&
+		!asb.SameDuration(defined.defaultMessageTimeToLive, existing.defaultMessageTimeToLive) {
+		return false
+	}
+	if defined.maxDeliveryCount > 0 && defined.maxDeliveryCount != existing.maxDeliveryCount {
+		return false
+	}
+
+	return defined.deadLetteringOnMessageExpiration == existing.deadLetteringOnMessageExpiration &&
+		strings.EqualFold(defined.forwardTo, existing.forwardTo)
+}
+
+func sameRule(defined asb.RuleDefinition, existing asb.RuleDefinition) bool {
+	if strings.TrimSpace(defined.SQL) != strings.TrimSpace(existing.SQL) ||
+		strings.TrimSpace(defined.Action) != strings.TrimSpace(existing.Action) {
+		return false
+	}
+	if defined.Correlation == nil || existing.Correlation == nil {
+		return defined.Correlation == existing.Correlation
+	}
+
+	// Property values are compared as text, numbers may be decoded as different types
+	definedFilter, existingFilter := *defined.Correlation, *existing.Correlation
+	definedProperties, existingProperties := definedFilter.Properties, existingFilter.Properties
+	definedFilter.Properties, existingFilter.Properties = nil, nil
+	if !reflect.DeepEqual(definedFilter, existingFilter) || len(definedProperties) != len(existingProperties) {
+		return false
+	}
+	for key, value := range definedProperties {
+		existingValue, ok := existingProperties[key]
+		if !ok || fmt.Sprint(value) != fmt.Sprint(existingValue) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,112 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/rafalpienkowski/busgopher/internal/asb"
+	"github.com/rafalpienkowski/busgopher/internal/history"
+)
+
+// record appends the send to the history, failing to do so doesn't fail the send
+func (controller *Controller) record(
+	connectionName string,
+	connection asb.Connection,
+	destination string,
+	messageName string,
+	message asb.Message,
+	messageID string,
+	sendErr error,
+) {
+	if controller.history == nil {
+		return
+	}
+
+	entry := history.Entry{
+		Time:        time.Now(),
+		Connection:  connectionName,
+		Namespace:   connection.Namespace,
+		Destination: destination,
+		MessageName: messageName,
+		Message:     message,
+		MessageID:   messageID,
+		Result:      history.ResultSent,
+	}
+	if sendErr != nil {
+		entry.Result = history.ResultFailed
+		entry.Error = sendErr.Error()
+	}
+	err := controller.history.Append(entry)
+	if err != nil {
+		controller.writeLog("Can't save the send to the history: " + err.Error())
+	}
+}
+
+// connectionName returns the name of the saved connection to the same namespace, the selected one
+// is preferred
+func (controller *Controller) connectionName(connection asb.Connection) string {
+	sameAs := func(name string) bool {
+		saved, ok := controller.Config.Connections[name]
+		return ok && saved.Namespace == connection.Namespace && saved.ConnectionString == connection.ConnectionString
+	}
+	if sameAs(controller.selectedConnectionName) {
+		return controller.selectedConnectionName
+	}
+	for _, name := range slices.Sorted(maps.Keys(controller.Config.Connections)) {
+		if sameAs(name) {
+			return name
+		}
+	}
+
+	return ""
+}
+
+// GetHistory returns the sends containing the search text, the most recent first
+func (controller *Controller) GetHistory(search string) ([]history.Entry, error) {
+	if controller.history == nil {
+		return []history.Entry{}, nil
+	}
+	entries, err := controller.history.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	matching := []history.Entry{}
+	for _, entry := range slices.Backward(entries) {
+		if entry.Matches(search) {
+			matching = append(matching, entry)
+		}
+	}
+
+	return matching, nil
+}
+
+// Resend sends the message of the history entry to the same namespace and destination as it was
+// sent then. The saved connection with the name is used for its credentials, but not when it points
+// to another namespace now. Without it a connection to the namespace is used.
+func (controller *Controller) Resend(entry history.Entry) (SendResult, error) {
+	connection, ok := controller.Config.Connections[entry.Connection]
+	switch {
+	case ok && len(entry.Namespace) > 0 && connection.Namespace != entry.Namespace:
+		return SendResult{}, fmt.Errorf(
+			"Connection '%v' points to %v now, the message was sent to %v!",
+			entry.Connection,
+			connection.Namespace,
+			entry.Namespace,
+		)
+	case ok:
+	case len(entry.Namespace) > 0:
+		connection = asb.Connection{Namespace: entry.Namespace}
+	case len(entry.Connection) > 0:
+		return SendResult{}, errors.New("Can't find connection with name: " + entry.Connection)
+	default:
+		// Connection strings of ad-hoc connections aren't kept
+		return SendResult{}, errors.New("Connection of the sent message isn't saved!")
+	}
+	controller.writeLog("Re-sending message sent at " + entry.Time.Local().Format("2006-01-02 15:04:05") + " to: " + entry.Destination)
+
+	return controller.send(connection, entry.Destination, entry.MessageName, entry.Message)
+}
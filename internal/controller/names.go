@@ -0,0 +1,132 @@
+package controller
+
+import (
+	"slices"
+	"strings"
+)
+
+// maxSuggestions limits how many names are listed in "did you mean"
+const maxSuggestions = 3
+
+// NotFoundError is returned when a name can't be resolved, Suggestions hold the closest names
+type NotFoundError struct {
+	Kind        string
+	Name        string
+	Suggestions []string
+}
+
+func (err NotFoundError) Error() string {
+	message := "Can't find " + err.Kind + " with name: " + err.Name
+	if len(err.Suggestions) > 0 {
+		message += ", did you mean: " + strings.Join(err.Suggestions, ", ") + "?"
+	}
+	return message
+}
+
+// resolveName finds the name the query points to. The query may differ in case, be a unique
+// prefix of the name, or contain its characters in order (like "ordcr" for "order-created").
+func resolveName(kind string, query string, names []string) (string, error) {
+	if slices.Contains(names, query) {
+		return query, nil
+	}
+
+	lowerQuery := strings.ToLower(query)
+	matchers := []func(name string) bool{
+		func(name string) bool { return name == lowerQuery },
+		func(name string) bool { return strings.HasPrefix(name, lowerQuery) },
+		func(name string) bool { return isSubsequence(lowerQuery, name) },
+	}
+	for _, matches := range matchers {
+		found := []string{}
+		for _, name := range names {
+			if len(lowerQuery) > 0 && matches(strings.ToLower(name)) {
+				found = append(found, name)
+			}
+		}
+		slices.Sort(found)
+
+		if len(found) == 1 {
+			return found[0], nil
+		}
+		if len(found) > 1 {
+			return "", NotFoundError{Kind: kind, Name: query, Suggestions: found[:min(len(found), maxSuggestions)]}
+		}
+	}
+
+	return "", NotFoundError{Kind: kind, Name: query, Suggestions: suggest(lowerQuery, names)}
+}
+
+// resolveExactName is resolveName for destructive operations, the query may only differ in case.
+// The name resolveName would find is only suggested.
+func resolveExactName(kind string, query string, names []string) (string, error) {
+	resolved, err := resolveName(kind, query, names)
+	if err != nil {
+		return "", err
+	}
+	if !strings.EqualFold(resolved, query) {
+		return "", NotFoundError{Kind: kind, Name: query, Suggestions: []string{resolved}}
+	}
+
+	return resolved, nil
+}
+
+// suggest returns names close to the query by edit distance
+func suggest(query string, names []string) []string {
+	limit := max(2, len(query)/3)
+	distances := make(map[string]int)
+	suggestions := []string{}
+	for _, name := range names {
+		distance := editDistance(query, strings.ToLower(name))
+		if distance <= limit {
+			distances[name] = distance
+			suggestions = append(suggestions, name)
+		}
+	}
+
+	slices.SortFunc(suggestions, func(a, b string) int {
+		if distances[a] != distances[b] {
+			return distances[a] - distances[b]
+		}
+		return strings.Compare(a, b)
+	})
+
+	return suggestions[:min(len(suggestions), maxSuggestions)]
+}
+
+func isSubsequence(query string, name string) bool {
+	remaining := query
+	for _, r := range name {
+		if len(remaining) == 0 {
+			break
+		}
+		if strings.HasPrefix(remaining, string(r)) {
+			remaining = remaining[len(string(r)):]
+		}
+	}
+
+	return len(remaining) == 0
+}
+
+// editDistance is the Levenshtein distance between a and b
+func editDistance(a string, b string) int {
+	first, second := []rune(a), []rune(b)
+	previous := make([]int, len(second)+1)
+	current := make([]int, len(second)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(first); i++ {
+		current[0] = i
+		for j := 1; j <= len(second); j++ {
+			cost := 1
+			if first[i-1] == second[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(second)]
+}
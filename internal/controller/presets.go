@@ -0,0 +1,298 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/rafalpienkowski/busgopher/internal/config"
+)
+
+const (
+	FavoriteConnection  = "connection"
+	FavoriteDestination = "destination"
+	FavoriteMessage     = "message"
+)
+
+type Preset struct {
+	Name   string
+	Preset config.Preset
+}
+
+// Combination is a connection, destination and message that were sent together
+type Combination struct {
+	Connection  string `json:"connection"`
+	Destination string `json:"destination"`
+	Message     string `json:"message"`
+}
+
+func (combination Combination) String() string {
+	return combination.Message + " → " + combination.Destination + " via " + combination.Connection
+}
+
+func (controller *Controller) GetPresets() []Preset {
+	presets := []Preset{}
+	for _, name := range slices.Sorted(maps.Keys(controller.Config.Presets)) {
+		presets = append(presets, Preset{Name: name, Preset: controller.Config.Presets[name]})
+	}
+
+	return presets
+}
+
+// SavePreset adds the preset or replaces the one with the same name
+func (controller *Controller) SavePreset(name string, preset config.Preset) error {
+	errs := FieldErrors{}
+	if len(strings.TrimSpace(name)) == 0 {
+		errs = append(errs, FieldError{Field: "Name", Message: "name is required"})
+	}
+	if _, ok := controller.Config.Connections[preset.Connection]; !ok {
+		errs = append(errs, FieldError{Field: "Connection", Message: "connection '" + preset.Connection + "' doesn't exist"})
+	}
+	if len(strings.TrimSpace(preset.Destination)) == 0 {
+		errs = append(errs, FieldError{Field: "Destination", Message: "destination is required"})
+	}
+	if _, ok := controller.Config.Messages[preset.Message]; !ok {
+		errs = append(errs, FieldError{Field: "Message", Message: "message '" + preset.Message + "' doesn't exist"})
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+
+	updated := cloneConfig(controller.Config)
+	updated.Presets[name] = preset
+
+	return controller.saveConfig(updated, "Preset '"+name+"' saved")
+}
+
+// SaveSelectedAsPreset saves the selected connection, destination and message, with the variables
+// of the applied preset, as a preset
+func (controller *Controller) SaveSelectedAsPreset(name string) error {
+	if len(controller.selectedConnectionName) == 0 {
+		return errors.New("Connection not selected!")
+	}
+	if len(controller.selectedDestination) == 0 {
+		return errors.New("Destination not selected!")
+	}
+	if len(controller.selectedMessageName) == 0 {
+		return errors.New("Message not selected!")
+	}
+
+	return controller.SavePreset(name, config.Preset{
+		Connection:  controller.selectedConnectionName,
+		Destination: controller.selectedDestination,
+		Message:     controller.selectedMessageName,
+		Variables:   maps.Clone(controller.variables),
+	})
+}
+
+func (controller *Controller) DeletePreset(name string) error {
+	if _, ok := controller.Config.Presets[name]; !ok {
+		return fmt.Errorf("Can't find preset with name: %v", name)
+	}
+
+	updated := cloneConfig(controller.Config)
+	delete(updated.Presets, name)
+
+	return controller.saveConfig(updated, "Preset '"+name+"' deleted")
+}
+
+// ApplyPreset selects the connection, destination and message of the preset. Its variables are
+// used to render the message until another connection or message is selected.
+func (controller *Controller) ApplyPreset(name string) (config.Preset, error) {
+	resolved, err := resolveName("preset", name, slices.Collect(maps.Keys(controller.Config.Presets)))
+	if err != nil {
+		return config.Preset{}, err
+	}
+	preset := controller.Config.Presets[resolved]
+
+	err = controller.selectCombination(preset.Connection, preset.Destination, preset.Message)
+	if err != nil {
+		return config.Preset{}, err
+	}
+	controller.variables = maps.Clone(preset.Variables)
+	controller.writeLog("Preset '" + resolved + "' applied")
+
+	return preset, nil
+}
+
+// SelectCombination selects the connection, destination and message used together before
+func (controller *Controller) SelectCombination(combination Combination) error {
+	err := controller.selectCombination(combination.Connection, combination.Destination, combination.Message)
+	if err != nil {
+		return err
+	}
+	controller.variables = nil
+	controller.writeLog("Selected " + combination.String())
+
+	return nil
+}
+
+// selectCombination checks that the connection and message exist before selecting anything.
+// The destination doesn't have to be saved in the connection.
+func (controller *Controller) selectCombination(connection string, destination string, message string) error {
+	if _, ok := controller.Config.Connections[connection]; !ok {
+		return fmt.Errorf("Can't find connection with name: %v", connection)
+	}
+	if _, ok := controller.Config.Messages[message]; !ok {
+		return fmt.Errorf("Can't find message with name: %v", message)
+	}
+
+	controller.selectedConnectionName = connection
+	controller.selectedDestination = destination
+	controller.selectedMessageName = message
+
+	return nil
+}
+
+// GetVariables returns the variables of the applied preset
+func (controller *Controller) GetVariables() map[string]string {
+	return maps.Clone(controller.variables)
+}
+
+// GetRecentCombinations returns up to count combinations sent from saved connections and messages
+// that still exist, the most recent first
+func (controller *Controller) GetRecentCombinations(count int) ([]Combination, error) {
+	entries, err := controller.GetHistory("")
+	if err != nil {
+		return nil, err
+	}
+
+	combinations := []Combination{}
+	for _, entry := range entries {
+		if len(combinations) == count {
+			break
+		}
+		combination := Combination{
+			Connection:  entry.Connection,
+			Destination: entry.Destination,
+			Message:     entry.MessageName,
+		}
+		_, connectionExists := controller.Config.Connections[combination.Connection]
+		_, messageExists := controller.Config.Messages[combination.Message]
+		if !connectionExists || !messageExists || slices.Contains(combinations, combination) {
+			continue
+		}
+		combinations = append(combinations, combination)
+	}
+
+	return combinations, nil
+}
+
+// ToggleFavorite pins or unpins the connection, destination or message and returns whether it is
+// a favorite now
+func (controller *Controller) ToggleFavorite(kind string, name string) (bool, error) {
+	updated := cloneConfig(controller.Config)
+	if updated.Favorites == nil {
+		updated.Favorites = &config.Favorites{}
+	}
+
+	names := favoriteNames(updated.Favorites, kind)
+	if names == nil {
+		return false, errors.New("Unknown favorite kind: " + kind)
+	}
+
+	favorite := !slices.Contains(*names, name)
+	log := "Favorite " + kind + " '" + name + "' removed"
+	if favorite {
+		*names = append(*names, name)
+		log = "Favorite " + kind + " '" + name + "' added"
+	} else {
+		*names = slices.DeleteFunc(*names, func(favorite string) bool { return favorite == name })
+	}
+
+	return favorite, controller.saveConfig(updated, log)
+}
+
+// favoriteNames returns the favorite names of the kind, nil for unknown kinds
+func favoriteNames(favorites *config.Favorites, kind string) *[]string {
+	switch kind {
+	case FavoriteConnection:
+		return &favorites.Connections
+	case FavoriteDestination:
+		return &favorites.Destinations
+	case FavoriteMessage:
+		return &favorites.Messages
+	}
+
+	return nil
+}
+
+// renameReferences makes the presets and favorites of the config refer to the connection or
+// message under its new name
+func renameReferences(updated config.Config, kind string, name string, newName string) {
+	for presetName, preset := range updated.Presets {
+		switch {
+		case kind == FavoriteConnection && preset.Connection == name:
+			preset.Connection = newName
+		case kind == FavoriteMessage && preset.Message == name:
+			preset.Message = newName
+		default:
+			continue
+		}
+		updated.Presets[presetName] = preset
+	}
+	if updated.Favorites != nil {
+		names := favoriteNames(updated.Favorites, kind)
+		for i := range *names {
+			if (*names)[i] == name {
+				(*names)[i] = newName
+			}
+		}
+	}
+}
+
+// removeReferences removes the connection or message from the favorites of the config, it fails
+// when presets use it
+func removeReferences(updated config.Config, kind string, name string) error {
+	presets := []string{}
+	for _, preset := range slices.Sorted(maps.Keys(updated.Presets)) {
+		if kind == FavoriteConnection && updated.Presets[preset].Connection == name ||
+			kind == FavoriteMessage && updated.Presets[preset].Message == name {
+			presets = append(presets, preset)
+		}
+	}
+	if len(presets) > 0 {
+		return fmt.Errorf("Can't delete %v '%v', it is used by presets: %v", kind, name, strings.Join(presets, ", "))
+	}
+	if updated.Favorites != nil {
+		names := favoriteNames(updated.Favorites, kind)
+		*names = slices.DeleteFunc(*names, func(favorite string) bool { return favorite == name })
+	}
+
+	return nil
+}
+
+func (controller *Controller) IsFavorite(kind string, name string) bool {
+	favorites := controller.Config.Favorites
+	if favorites == nil {
+		return false
+	}
+
+	switch kind {
+	case FavoriteConnection:
+		return slices.Contains(favorites.Connections, name)
+	case FavoriteDestination:
+		return slices.Contains(favorites.Destinations, name)
+	case FavoriteMessage:
+		return slices.Contains(favorites.Messages, name)
+	}
+
+	return false
+}
+
+// favoritesFirst compares names so favorites come first
+func (controller *Controller) favoritesFirst(kind string) func(a string, b string) int {
+	return func(a string, b string) int {
+		aFavorite, bFavorite := controller.IsFavorite(kind, a), controller.IsFavorite(kind, b)
+		switch {
+		case aFavorite == bFavorite:
+			return 0
+		case aFavorite:
+			return -1
+		default:
+			return 1
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package controller
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rafalpienkowski/busgopher/internal/asb"
+	"github.com/rafalpienkowski/busgopher/internal/filter"
+)
+
+const (
+	// purgeBatchSize is the number of messages one receive call deletes
+	purgeBatchSize = 100
+	// purgeWait is how long a receive call waits for messages, the entity is considered empty after it
+	purgeWait = 2 * time.Second
+	// defaultMaxDeliveryCount is the max delivery count Service Bus gives entities
+	defaultMaxDeliveryCount = 10
+)
+
+// Purge deletes the messages of the source of the selected connection until none are left and
+// returns how many were deleted. Without a filter the messages are received and deleted by parallel
+// receivers. With a filter only matching messages are deleted, see purgeMatching, and the number of
+// kept messages that had to be locked on the way is returned too. onProgress gets the number of
+// messages deleted so far.
+func (controller *Controller) Purge(
+	source asb.Source,
+	filter filter.Filter,
+	parallel int,
+	onProgress func(purged int),
+) (purged int, released int, err error) {
+	if err := validateSource(source); err != nil {
+		return 0, 0, err
+	}
+	connection, err := controller.getResolvedConnection()
+	if err != nil {
+		return 0, 0, err
+	}
+	controller.writeLog("Purging: " + source.String())
+
+	if filter.IsEmpty() {
+		purged, err = controller.purgeAll(connection, source, parallel, onProgress)
+	} else {
+		purged, released, err = controller.purgeMatching(connection, source, filter, onProgress)
+	}
+
+	controller.writeLog(fmt.Sprintf("Purged %v message(s) from: %v", purged, source))
+	if released > 0 {
+		controller.writeLog(fmt.Sprintf("Released %v kept message(s) locked while purging: %v", released, source))
+	}
+
+	return purged, released, err
+}
+
+func (controller *Controller) purgeAll(
+	connection asb.Connection,
+	source asb.Source,
+	parallel int,
+	onProgress func(purged int),
+) (int, error) {
+	var mutex sync.Mutex
+	var wait sync.WaitGroup
+	purged := 0
+	var purgeErr error
+	for range max(parallel, 1) {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			for {
+				messages, err := controller.messageReceiver.Receive(connection, source, purgeBatchSize, purgeWait)
+
+				mutex.Lock()
+				purged += len(messages)
+				if len(messages) > 0 {
+					onProgress(purged)
+				}
+				if err != nil && purgeErr == nil {
+					purgeErr = err
+				}
+				stop := len(messages) == 0 || purgeErr != nil
+				mutex.Unlock()
+
+				if stop {
+					return
+				}
+			}
+		}()
+	}
+	wait.Wait()
+
+	return purged, purgeErr
+}
+
+// purgeMatching peeks the source to find the matching messages first, nothing is deleted when none
+// match. Then it locks the messages in one pass up to the last matching one and completes the matching
+// ones. Messages after the last match are never locked, the kept ones before it are locked once and
+// released when the pass ends, which raises their delivery count by one. Their number is returned
+// as released. A kept message one delivery away from being dead-lettered fails the purge before
+// anything is locked.
+func (controller *Controller) purgeMatching(
+	connection asb.Connection,
+	source asb.Source,
+	filter filter.Filter,
+	onProgress func(purged int),
+) (purged int, released int, err error) {
+	maxDeliveryCount := controller.maxDeliveryCount(connection, source)
+	matching := make(map[int64]bool)
+	toLock := 0
+	peeked := 0
+	// The first kept message locking would dead-letter, it matters only when a match follows it
+	var endangered asb.ReceivedMessage
+	endangeredAt := 0
+	fromSequenceNumber := int64(0)
+	for {
+		messages, err := controller.messageReceiver.Peek(connection, source, purgeBatchSize, fromSequenceNumber)
+		if err != nil {
+			return 0, 0, err
+		}
+		for _, message := range messages {
+			peeked++
+			if filter.Matches(message) {
+				matching[message.SequenceNumber] = true
+				toLock = peeked
+			} else if endangeredAt == 0 && maxDeliveryCount > 0 && int64(message.DeliveryCount) >= int64(maxDeliveryCount)-1 {
+				endangered = message
+				endangeredAt = peeked
+			}
+		}
+		if len(messages) < purgeBatchSize {
+			break
+		}
+		fromSequenceNumber = messages[len(messages)-1].SequenceNumber + 1
+	}
+	if len(matching) == 0 {
+		return 0, 0, nil
+	}
+	if endangeredAt > 0 && endangeredAt < toLock {
+		return 0, 0, fmt.Errorf(
+			"Kept message #%v was delivered %v time(s), locking it to reach the matches after it would dead-letter it (max delivery count %v)!",
+			endangered.SequenceNumber,
+			endangered.DeliveryCount,
+			maxDeliveryCount,
+		)
+	}
+
+	receiver, err := controller.messageReceiver.NewLockedReceiver(connection, source)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer func() {
+		closeErr := receiver.Close()
+		if err == nil {
+			err = closeErr
+		}
+	}()
+
+	for locked := 0; locked < toLock && len(matching) > 0; {
+		messages, err := receiver.Receive(min(purgeBatchSize, toLock-locked), purgeWait)
+		locked += len(messages)
+		for _, message := range messages {
+			if !matching[message.SequenceNumber] || !filter.Matches(message) {
+				released++
+				continue
+			}
+			err := receiver.Settle(message.SequenceNumber, asb.Settlement{Action: asb.SettleComplete})
+			if err != nil {
+				return purged, released, err
+			}
+			delete(matching, message.SequenceNumber)
+			purged++
+			onProgress(purged)
+		}
+		if err != nil {
+			return purged, released, err
+		}
+		if len(messages) == 0 {
+			break
+		}
+	}
+
+	return purged, released, nil
+}
+
+// maxDeliveryCount returns how many deliveries of a message of the source dead-letter it, 0 when
+// they never do. Service Bus' default is assumed when the entity can't be read.
+func (controller *Controller) maxDeliveryCount(connection asb.Connection, source asb.Source) int32 {
+	if source.DeadLetter {
+		return 0
+	}
+
+	var maxDeliveryCount int32
+	if len(source.Subscription) > 0 {
+		subscription, err := controller.administrator.GetSubscription(connection, source.Destination, source.Subscription)
+		if err == nil && subscription != nil {
+			maxDeliveryCount = subscription.MaxDeliveryCount
+		}
+	} else {
+		queue, err := controller.administrator.GetQueue(connection, source.Destination)
+		if err == nil && queue != nil {
+			maxDeliveryCount = queue.MaxDeliveryCount
+		}
+	}
+	if maxDeliveryCount <= 0 {
+		return defaultMaxDeliveryCount
+	}
+
+	return maxDeliveryCount
+}
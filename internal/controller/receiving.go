@@ -0,0 +1,141 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rafalpienkowski/busgopher/internal/asb"
+	"github.com/rafalpienkowski/busgopher/internal/filter"
+)
+
+// searchBatchSize is the number of messages peeked at once while searching for matching ones
+const searchBatchSize = 250
+
+func (controller *Controller) getResolvedConnection() (asb.Connection, error) {
+	if len(controller.selectedConnectionName) == 0 {
+		return asb.Connection{}, errors.New("Connection not selected!")
+	}
+
+	return resolveConnection(controller.Config.Connections[controller.selectedConnectionName])
+}
+
+func validateSource(source asb.Source) error {
+	if len(source.Destination) == 0 {
+		return errors.New("Destination not selected!")
+	}
+	return nil
+}
+
+// Peek returns up to count messages from the source of the selected connection without removing them
+func (controller *Controller) Peek(source asb.Source, count int, fromSequenceNumber int64) ([]asb.ReceivedMessage, error) {
+	if err := validateSource(source); err != nil {
+		return nil, err
+	}
+	connection, err := controller.getResolvedConnection()
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := controller.messageReceiver.Peek(connection, source, count, fromSequenceNumber)
+	if err != nil {
+		return nil, err
+	}
+	controller.writeLog(fmt.Sprintf("Peeked %v message(s) from: %v", len(messages), source))
+
+	return messages, nil
+}
+
+// Receive removes up to count messages from the source of the selected connection. Messages
+// received before a failure are returned with the error, they are already removed from the source.
+func (controller *Controller) Receive(source asb.Source, count int, wait time.Duration) ([]asb.ReceivedMessage, error) {
+	if err := validateSource(source); err != nil {
+		return nil, err
+	}
+	connection, err := controller.getResolvedConnection()
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := controller.messageReceiver.Receive(connection, source, count, wait)
+	controller.logReceived(messages, "", source, err)
+
+	return messages, err
+}
+
+// PeekMatching returns up to count messages matching the filter from the source of the selected
+// connection without removing them. The source is browsed until enough messages match or it ends.
+func (controller *Controller) PeekMatching(
+	source asb.Source,
+	count int,
+	fromSequenceNumber int64,
+	filter filter.Filter,
+) ([]asb.ReceivedMessage, error) {
+	if filter.IsEmpty() {
+		return controller.Peek(source, count, fromSequenceNumber)
+	}
+	if err := validateSource(source); err != nil {
+		return nil, err
+	}
+	connection, err := controller.getResolvedConnection()
+	if err != nil {
+		return nil, err
+	}
+
+	matching := []asb.ReceivedMessage{}
+	browsed := 0
+	next := fromSequenceNumber
+	for len(matching) < count {
+		messages, err := controller.messageReceiver.Peek(connection, source, searchBatchSize, next)
+		if err != nil {
+			return nil, err
+		}
+		if len(messages) == 0 {
+			break
+		}
+		browsed += len(messages)
+		next = messages[len(messages)-1].SequenceNumber + 1
+		for _, message := range filter.Apply(messages) {
+			if len(matching) < count {
+				matching = append(matching, message)
+			}
+		}
+	}
+	controller.writeLog(fmt.Sprintf("Peeked %v matching message(s) of %v browsed from: %v", len(matching), browsed, source))
+
+	return matching, nil
+}
+
+// ReceiveMatching removes up to count messages matching the filter from the source of the selected
+// connection. Other messages are locked while waiting and abandoned afterwards. As with Receive,
+// messages received before a failure are returned with the error.
+func (controller *Controller) ReceiveMatching(
+	source asb.Source,
+	count int,
+	wait time.Duration,
+	filter filter.Filter,
+) ([]asb.ReceivedMessage, error) {
+	if filter.IsEmpty() {
+		return controller.Receive(source, count, wait)
+	}
+	if err := validateSource(source); err != nil {
+		return nil, err
+	}
+	connection, err := controller.getResolvedConnection()
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := controller.messageReceiver.ReceiveMatching(connection, source, filter.Matches, count, wait)
+	controller.logReceived(messages, "matching ", source, err)
+
+	return messages, err
+}
+
+func (controller *Controller) logReceived(messages []asb.ReceivedMessage, kind string, source asb.Source, err error) {
+	if err == nil {
+		controller.writeLog(fmt.Sprintf("Received %v %vmessage(s) from: %v", len(messages), kind, source))
+	} else if len(messages) > 0 {
+		controller.writeLog(fmt.Sprintf("Received %v %vmessage(s) from: %v before failing", len(messages), kind, source))
+	}
+}
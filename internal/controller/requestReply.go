@@ -0,0 +1,195 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rafalpienkowski/busgopher/internal/asb"
+)
+
+const (
+	// replyPeekCount is the number of messages peeked at once while waiting for a reply
+	replyPeekCount = 100
+	// replyPollInterval is how often the reply entity is peeked again
+	replyPollInterval = time.Second
+)
+
+// PreparedRequest is a request with everything needed to send it, see SendRequest
+type PreparedRequest struct {
+	connectionName string
+	connection     asb.Connection
+	destination    string
+	messageName    string
+	message        asb.Message
+}
+
+// Request sends the selected message with ReplyTo set and waits for the reply, see RequestTo.
+// An empty replyTo (or replyToSessionID) keeps the value of the message.
+func (controller *Controller) Request(
+	replyTo string,
+	replyToSessionID string,
+	timeout time.Duration,
+) (SendResult, asb.ReceivedMessage, error) {
+	prepared, err := controller.PrepareRequest(replyTo, replyToSessionID)
+	if err != nil {
+		return SendResult{}, asb.ReceivedMessage{}, err
+	}
+
+	return controller.SendRequest(prepared, timeout)
+}
+
+// PrepareRequest renders the selected message with ReplyTo set for SendRequest, see Request
+func (controller *Controller) PrepareRequest(replyTo string, replyToSessionID string) (PreparedRequest, error) {
+	connection, message, err := controller.getSelectedForSending()
+	if err != nil {
+		return PreparedRequest{}, err
+	}
+	if len(replyTo) > 0 {
+		message.ReplayTo = replyTo
+	}
+	if len(replyToSessionID) > 0 {
+		message.ReplyToSessionID = replyToSessionID
+	}
+
+	return controller.prepareRequest(connection, controller.selectedDestination, controller.selectedMessageName, message), nil
+}
+
+// SendRequest sends the prepared request and waits for the reply. It doesn't read the state of the
+// controller, so it can run in the background while the selection or the config changes.
+func (controller *Controller) SendRequest(prepared PreparedRequest, timeout time.Duration) (SendResult, asb.ReceivedMessage, error) {
+	return controller.request(prepared, timeout)
+}
+
+// RequestTo sends the message and waits at most timeout for the reply on the message's ReplyTo
+// entity (and ReplyToSessionID session). The reply is the message whose CorrelationID is
+// the MessageID of the sent message. Like SendTo, secret references in the message aren't resolved.
+func (controller *Controller) RequestTo(
+	connection asb.Connection,
+	destination string,
+	message asb.Message,
+	timeout time.Duration,
+) (SendResult, asb.ReceivedMessage, error) {
+	if len(connection.Namespace) == 0 && len(connection.ConnectionString) == 0 {
+		return SendResult{}, asb.ReceivedMessage{}, errors.New("Namespace or connection string is required!")
+	}
+	if len(destination) == 0 {
+		return SendResult{}, asb.ReceivedMessage{}, errors.New("Destination not selected!")
+	}
+
+	return controller.request(controller.prepareRequest(connection, destination, "", message), timeout)
+}
+
+// RequestSavedTo is RequestTo for a message rendered from the saved message with the name
+func (controller *Controller) RequestSavedTo(
+	connection asb.Connection,
+	destination string,
+	name string,
+	message asb.Message,
+	timeout time.Duration,
+) (SendResult, asb.ReceivedMessage, error) {
+	resolved, err := resolveName("message", name, slices.Collect(maps.Keys(controller.Config.Messages)))
+	if err != nil {
+		return SendResult{}, asb.ReceivedMessage{}, err
+	}
+	if len(connection.Namespace) == 0 && len(connection.ConnectionString) == 0 {
+		return SendResult{}, asb.ReceivedMessage{}, errors.New("Namespace or connection string is required!")
+	}
+	if len(destination) == 0 {
+		return SendResult{}, asb.ReceivedMessage{}, errors.New("Destination not selected!")
+	}
+
+	return controller.request(controller.prepareRequest(connection, destination, resolved, message), timeout)
+}
+
+func (controller *Controller) prepareRequest(
+	connection asb.Connection,
+	destination string,
+	messageName string,
+	message asb.Message,
+) PreparedRequest {
+	return PreparedRequest{
+		connectionName: controller.connectionName(connection),
+		connection:     connection,
+		destination:    destination,
+		messageName:    messageName,
+		message:        message,
+	}
+}
+
+func (controller *Controller) request(prepared PreparedRequest, timeout time.Duration) (SendResult, asb.ReceivedMessage, error) {
+	message := prepared.message
+	if len(message.ReplayTo) == 0 {
+		return SendResult{}, asb.ReceivedMessage{}, errors.New("Reply to is required!")
+	}
+	if len(message.MessageID) == 0 {
+		message.MessageID = uuid.New().String()
+	}
+
+	sent, err := controller.sendAs(prepared.connectionName, prepared.connection, prepared.destination, prepared.messageName, message)
+	if err != nil {
+		return SendResult{}, asb.ReceivedMessage{}, err
+	}
+
+	resolvedConnection, err := resolveConnection(prepared.connection)
+	if err != nil {
+		return sent, asb.ReceivedMessage{}, err
+	}
+	source := asb.Source{Destination: message.ReplayTo, SessionID: message.ReplyToSessionID}
+	controller.writeLog(fmt.Sprintf("Waiting %v for reply on: %v", timeout, source))
+
+	reply, found, err := controller.waitForReply(resolvedConnection, source, sent.MessageID, timeout)
+	if err != nil {
+		return sent, asb.ReceivedMessage{}, err
+	}
+	if !found {
+		return sent, asb.ReceivedMessage{}, fmt.Errorf("No reply to message %v received within %v", sent.MessageID, timeout)
+	}
+	controller.writeLog("Reply received from: " + source.String())
+
+	return sent, reply, nil
+}
+
+// waitForReply receives the reply from the session of the source, which only holds replies to this
+// client. Without a session the reply entity may be shared, so it is peeked until the reply arrives
+// and the reply stays in the entity, messages of other consumers are never locked.
+func (controller *Controller) waitForReply(
+	connection asb.Connection,
+	source asb.Source,
+	correlationID string,
+	timeout time.Duration,
+) (asb.ReceivedMessage, bool, error) {
+	matches := func(received asb.ReceivedMessage) bool { return received.CorrelationID == correlationID }
+	if len(source.SessionID) > 0 {
+		replies, err := controller.messageReceiver.ReceiveMatching(connection, source, matches, 1, timeout)
+		if err != nil || len(replies) == 0 {
+			return asb.ReceivedMessage{}, false, err
+		}
+		return replies[0], true, nil
+	}
+
+	deadline := time.Now().Add(timeout)
+	fromSequenceNumber := int64(0)
+	for {
+		peeked, err := controller.messageReceiver.Peek(connection, source, replyPeekCount, fromSequenceNumber)
+		if err != nil {
+			return asb.ReceivedMessage{}, false, err
+		}
+		for _, message := range peeked {
+			if matches(message) {
+				return message, true, nil
+			}
+			fromSequenceNumber = message.SequenceNumber + 1
+		}
+		if len(peeked) == replyPeekCount {
+			continue
+		}
+		if !time.Now().Before(deadline) {
+			return asb.ReceivedMessage{}, false, nil
+		}
+		time.Sleep(min(replyPollInterval, time.Until(deadline)))
+	}
+}
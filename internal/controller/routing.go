@@ -0,0 +1,74 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/rafalpienkowski/busgopher/internal/asb"
+	"github.com/rafalpienkowski/busgopher/internal/routing"
+)
+
+// TestRouting evaluates the rules of the selected topic's subscriptions locally, to tell which
+// subscriptions the selected message (with its body rendered as it is sent, with the variables of the
+// applied preset) would be delivered to
+func (controller *Controller) TestRouting() ([]routing.Route, error) {
+	if len(controller.selectedMessageName) == 0 {
+		return nil, errors.New("Message not selected!")
+	}
+	if len(controller.selectedDestination) == 0 {
+		return nil, errors.New("Destination not selected!")
+	}
+	message, err := controller.RenderMessageWith(controller.selectedMessageName, controller.variables)
+	if err != nil {
+		return nil, err
+	}
+
+	subscriptions, err := controller.GetSubscriptionRules(controller.selectedDestination)
+	if err != nil {
+		return nil, err
+	}
+	routes := routing.Evaluate(subscriptions, message)
+
+	matched := 0
+	for _, route := range routes {
+		if route.Matched {
+			matched++
+		}
+	}
+	controller.writeLog(fmt.Sprintf(
+		"Message '%v' would be delivered to %v of %v subscription(s) of: %v",
+		controller.selectedMessageName,
+		matched,
+		len(routes),
+		controller.selectedDestination,
+	))
+
+	return routes, nil
+}
+
+// GetSubscriptionRules returns the subscriptions of the topic in the selected connection's
+// namespace with their rules
+func (controller *Controller) GetSubscriptionRules(topic string) ([]asb.SubscriptionDefinition, error) {
+	connection, err := controller.getResolvedConnection()
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := controller.administrator.ListSubscriptions(connection, topic)
+	if err != nil {
+		return nil, err
+	}
+	subscriptions := []asb.SubscriptionDefinition{}
+	for _, name := range names {
+		rules, err := controller.administrator.ListRules(connection, topic, name)
+		if err != nil {
+			return nil, err
+		}
+		subscriptions = append(subscriptions, asb.SubscriptionDefinition{Name: name, Rules: rules})
+	}
+	if len(subscriptions) == 0 {
+		return nil, fmt.Errorf("Can't find subscriptions of topic: %v", topic)
+	}
+
+	return subscriptions, nil
+}
@@ -0,0 +1,54 @@
+package controller
+
+import (
+	"maps"
+
+	"github.com/rafalpienkowski/busgopher/internal/asb"
+	"github.com/rafalpienkowski/busgopher/internal/secret"
+)
+
+func resolveConnection(connection asb.Connection) (asb.Connection, error) {
+	var err error
+	resolved := connection
+	for _, value := range []*string{&resolved.Namespace, &resolved.ConnectionString} {
+		*value, err = secret.Resolve(*value)
+		if err != nil {
+			return asb.Connection{}, err
+		}
+	}
+
+	return resolved, nil
+}
+
+func resolveMessage(message asb.Message) (asb.Message, error) {
+	var err error
+	resolved := message
+	for _, value := range []*string{
+		&resolved.Body,
+		&resolved.CorrelationID,
+		&resolved.MessageID,
+		&resolved.ReplayTo,
+		&resolved.ReplyToSessionID,
+		&resolved.SessionID,
+		&resolved.Subject,
+	} {
+		*value, err = secret.Resolve(*value)
+		if err != nil {
+			return asb.Message{}, err
+		}
+	}
+
+	resolved.CustomProperties = maps.Clone(message.CustomProperties)
+	for key, value := range resolved.CustomProperties {
+		text, ok := value.(string)
+		if !ok {
+			continue
+		}
+		resolved.CustomProperties[key], err = secret.Resolve(text)
+		if err != nil {
+			return asb.Message{}, err
+		}
+	}
+
+	return resolved, nil
+}
@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/rafalpienkowski/busgopher/internal/asb"
+)
+
+func validateSession(source asb.Source) error {
+	if err := validateSource(source); err != nil {
+		return err
+	}
+	if len(source.SessionID) == 0 && !source.NextSession {
+		return errors.New("Session not selected!")
+	}
+	return nil
+}
+
+// GetSessionState returns the ID of the source's session in the selected connection, which tells
+// the session accepted for NextSession, and its state, nil when the session has none
+func (controller *Controller) GetSessionState(source asb.Source) (string, []byte, error) {
+	if err := validateSession(source); err != nil {
+		return "", nil, err
+	}
+	connection, err := controller.getResolvedConnection()
+	if err != nil {
+		return "", nil, err
+	}
+
+	sessionID, state, err := controller.messageReceiver.GetSessionState(connection, source)
+	if err != nil {
+		return "", nil, err
+	}
+	source.SessionID = sessionID
+	source.NextSession = false
+	controller.writeLog(fmt.Sprintf("Got session state (%v bytes) of: %v", len(state), source))
+
+	return sessionID, state, nil
+}
+
+// SetSessionState replaces the state of the source's session in the selected connection, an empty
+// state clears it. The session ID is required, the next available session isn't guessed.
+func (controller *Controller) SetSessionState(source asb.Source, state []byte) error {
+	if err := validateSession(source); err != nil {
+		return err
+	}
+	if source.NextSession {
+		return errors.New("Session ID is required to change the session state!")
+	}
+	connection, err := controller.getResolvedConnection()
+	if err != nil {
+		return err
+	}
+
+	if len(state) == 0 {
+		state = nil
+	}
+	err = controller.messageReceiver.SetSessionState(connection, source, state)
+	if err != nil {
+		return err
+	}
+	if state == nil {
+		controller.writeLog("Cleared session state of: " + source.String())
+	} else {
+		controller.writeLog(fmt.Sprintf("Set session state (%v bytes) of: %v", len(state), source))
+	}
+
+	return nil
+}
@@ -0,0 +1,115 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rafalpienkowski/busgopher/internal/asb"
+)
+
+// settledLogs are the past tense of the settle actions used in logs
+var settledLogs = map[string]string{
+	asb.SettleComplete:   "Completed",
+	asb.SettleAbandon:    "Abandoned",
+	asb.SettleDefer:      "Deferred",
+	asb.SettleDeadLetter: "Dead-lettered",
+}
+
+// lockedReceiver opens a locked receiver of the source, the receiver of another source is released
+func (controller *Controller) lockedReceiver(source asb.Source) (asb.LockedReceiver, error) {
+	if err := validateSource(source); err != nil {
+		return nil, err
+	}
+	if controller.locked != nil && controller.lockedSource == source {
+		return controller.locked, nil
+	}
+	connection, err := controller.getResolvedConnection()
+	if err != nil {
+		return nil, err
+	}
+	if err := controller.ReleaseLocked(); err != nil {
+		return nil, err
+	}
+
+	locked, err := controller.messageReceiver.NewLockedReceiver(connection, source)
+	if err != nil {
+		return nil, err
+	}
+	controller.locked = locked
+	controller.lockedSource = source
+
+	return locked, nil
+}
+
+// ReceiveLocked receives up to count messages from the source of the selected connection and keeps
+// them locked until they are settled or released
+func (controller *Controller) ReceiveLocked(source asb.Source, count int, wait time.Duration) ([]asb.ReceivedMessage, error) {
+	locked, err := controller.lockedReceiver(source)
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := locked.Receive(count, wait)
+	if err != nil {
+		return nil, err
+	}
+	controller.writeLog(fmt.Sprintf("Locked %v message(s) from: %v", len(messages), source))
+
+	return messages, nil
+}
+
+// ReceiveDeferred locks the deferred messages with the sequence numbers from the source of the
+// selected connection
+func (controller *Controller) ReceiveDeferred(source asb.Source, sequenceNumbers []int64) ([]asb.ReceivedMessage, error) {
+	if len(sequenceNumbers) == 0 {
+		return nil, errors.New("Sequence numbers not entered!")
+	}
+	locked, err := controller.lockedReceiver(source)
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := locked.ReceiveDeferred(sequenceNumbers)
+	if err != nil {
+		return nil, err
+	}
+	controller.writeLog(fmt.Sprintf("Locked %v deferred message(s) from: %v", len(messages), source))
+
+	return messages, nil
+}
+
+// Settle completes, abandons, defers or dead-letters a message locked by ReceiveLocked or
+// ReceiveDeferred
+func (controller *Controller) Settle(sequenceNumber int64, settlement asb.Settlement) error {
+	if controller.locked == nil {
+		return errors.New("No locked messages!")
+	}
+	if _, ok := settledLogs[settlement.Action]; !ok {
+		return fmt.Errorf("Unknown settlement action: %v", settlement.Action)
+	}
+
+	err := controller.locked.Settle(sequenceNumber, settlement)
+	if err != nil {
+		return err
+	}
+	controller.writeLog(fmt.Sprintf("%v message #%v of: %v", settledLogs[settlement.Action], sequenceNumber, controller.lockedSource))
+
+	return nil
+}
+
+// ReleaseLocked abandons the messages that weren't settled and closes the locked receiver
+func (controller *Controller) ReleaseLocked() error {
+	if controller.locked == nil {
+		return nil
+	}
+
+	err := controller.locked.Close()
+	controller.locked = nil
+	if err != nil {
+		return err
+	}
+	controller.writeLog("Released locked messages of: " + controller.lockedSource.String())
+
+	return nil
+}
@@ -0,0 +1,27 @@
+package controller
+
+import "github.com/rafalpienkowski/busgopher/internal/asb"
+
+// GetStatistics returns the runtime properties of the entities of the selected connection's
+// namespace, or only of the destination and its subscriptions. It doesn't log, so it can be
+// called periodically.
+func (controller *Controller) GetStatistics(destination string) ([]asb.EntityStatistics, error) {
+	connection, err := controller.getResolvedConnection()
+	if err != nil {
+		return nil, err
+	}
+
+	statistics, err := controller.administrator.GetStatistics(connection)
+	if err != nil || len(destination) == 0 {
+		return statistics, err
+	}
+
+	filtered := []asb.EntityStatistics{}
+	for _, entity := range statistics {
+		if entity.Topic == destination || entity.Name == destination && entity.Kind != asb.EntitySubscription {
+			filtered = append(filtered, entity)
+		}
+	}
+
+	return filtered, nil
+}
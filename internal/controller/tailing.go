@@ -0,0 +1,138 @@
+package controller
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/rafalpienkowski/busgopher/internal/asb"
+	"github.com/rafalpienkowski/busgopher/internal/filter"
+)
+
+// tailBatchSize is the maximum number of messages peeked at once while tailing
+const tailBatchSize = 100
+
+// Tail peeks messages of the source of the selected connection every interval and passes the ones
+// matching the filter to onMessages. Messages are never locked or removed, so messages received by
+// other consumers between two peeks are not shown. With fromSequenceNumber 0 only messages
+// arriving after the start are shown. It returns a function that stops tailing.
+func (controller *Controller) Tail(
+	source asb.Source,
+	fromSequenceNumber int64,
+	interval time.Duration,
+	filter filter.Filter,
+	onMessages func([]asb.ReceivedMessage),
+	onError func(error),
+) (func(), error) {
+	if err := validateSource(source); err != nil {
+		return nil, err
+	}
+	connection, err := controller.getResolvedConnection()
+	if err != nil {
+		return nil, err
+	}
+
+	next := fromSequenceNumber
+	if next <= 0 {
+		next, err = controller.nextSequenceNumber(connection, source)
+		if err != nil {
+			return nil, err
+		}
+	}
+	controller.writeLog(fmt.Sprintf("Tailing: %v from sequence number %v", source, next))
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			messages, err := controller.messageReceiver.Peek(connection, source, tailBatchSize, next)
+			if err != nil {
+				onError(err)
+			} else if len(messages) > 0 {
+				next = messages[len(messages)-1].SequenceNumber + 1
+				if matching := filter.Apply(messages); len(matching) > 0 {
+					onMessages(matching)
+				}
+			}
+
+			// More messages may be waiting, peek again without waiting. Stopping is checked first, so
+			// a long backlog doesn't delay it.
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if err == nil && len(messages) == tailBatchSize {
+				continue
+			}
+			select {
+			case <-stop:
+				return
+			case <-time.After(interval):
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-done
+		controller.writeLog("Tailing stopped: " + source.String())
+	}, nil
+}
+
+// nextSequenceNumber returns the sequence number after the last message in the source. Peeking the
+// whole source would take long for a big one, so single messages are peeked at doubling distances
+// until none is found, the range of the last message is halved down to tailBatchSize sequence
+// numbers and only that range is peeked.
+func (controller *Controller) nextSequenceNumber(connection asb.Connection, source asb.Source) (int64, error) {
+	next := int64(1)
+	// There are no messages from end on
+	end := int64(math.MaxInt64)
+	for step := int64(tailBatchSize); step <= math.MaxInt64-next; step = min(step*2, math.MaxInt64/2) {
+		after, err := controller.peekAfter(connection, source, next+step)
+		if err != nil {
+			return 0, err
+		}
+		if after == 0 {
+			end = next + step
+			break
+		}
+		next = after
+	}
+
+	for end-next > tailBatchSize {
+		middle := next + (end-next)/2
+		after, err := controller.peekAfter(connection, source, middle)
+		if err != nil {
+			return 0, err
+		}
+		if after == 0 {
+			end = middle
+		} else {
+			next = after
+		}
+	}
+
+	for {
+		messages, err := controller.messageReceiver.Peek(connection, source, tailBatchSize, next)
+		if err != nil {
+			return 0, err
+		}
+		if len(messages) == 0 {
+			return next, nil
+		}
+		next = messages[len(messages)-1].SequenceNumber + 1
+	}
+}
+
+// peekAfter returns the sequence number after the first message from fromSequenceNumber on, 0 when
+// there is no such message
+func (controller *Controller) peekAfter(connection asb.Connection, source asb.Source, fromSequenceNumber int64) (int64, error) {
+	messages, err := controller.messageReceiver.Peek(connection, source, 1, fromSequenceNumber)
+	if err != nil || len(messages) == 0 {
+		return 0, err
+	}
+
+	return messages[0].SequenceNumber + 1, nil
+}
@@ -0,0 +1,235 @@
+package filter
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rafalpienkowski/busgopher/internal/asb"
+	"github.com/rafalpienkowski/busgopher/internal/jsonpath"
+)
+
+// Filter selects messages, an empty filter matches every message. All conditions have to match.
+type Filter struct {
+	// BrokerProperties maps broker property names, as in the JSON output (subject, messageId, ...), to values
+	BrokerProperties map[string]string
+	// ApplicationProperties maps custom property names to values
+	ApplicationProperties map[string]string
+	// BodyContains is a substring the body has to contain
+	BodyContains string
+	// BodyMatches is a regular expression the body has to match
+	BodyMatches *regexp.Regexp
+	// BodyPath has to exist in the JSON body, and to be equal to BodyPathEquals when it is set
+	BodyPath       *jsonpath.Path
+	BodyPathEquals *string
+	// EnqueuedAfter and EnqueuedBefore limit the enqueued time, zero values are not checked
+	EnqueuedAfter  time.Time
+	EnqueuedBefore time.Time
+}
+
+// BrokerPropertyNames are the broker properties messages can be filtered by
+var BrokerPropertyNames = []string{
+	"messageId",
+	"correlationId",
+	"subject",
+	"replyTo",
+	"replyToSessionId",
+	"sessionId",
+	"contentType",
+	"deadLetterReason",
+	"deadLetterDescription",
+	"deadLetterSource",
+	"sequenceNumber",
+	"deliveryCount",
+}
+
+func (filter Filter) IsEmpty() bool {
+	return len(filter.BrokerProperties) == 0 &&
+		len(filter.ApplicationProperties) == 0 &&
+		len(filter.BodyContains) == 0 &&
+		filter.BodyMatches == nil &&
+		filter.BodyPath == nil &&
+		filter.EnqueuedAfter.IsZero() &&
+		filter.EnqueuedBefore.IsZero()
+}
+
+func (filter Filter) Matches(message asb.ReceivedMessage) bool {
+	for name, expected := range filter.BrokerProperties {
+		if value, _ := brokerProperty(message, name); value != expected {
+			return false
+		}
+	}
+	for name, expected := range filter.ApplicationProperties {
+		value, found := message.CustomProperties[name]
+		if !found || fmt.Sprint(value) != expected {
+			return false
+		}
+	}
+	if len(filter.BodyContains) > 0 && !strings.Contains(message.Body, filter.BodyContains) {
+		return false
+	}
+	if filter.BodyMatches != nil && !filter.BodyMatches.MatchString(message.Body) {
+		return false
+	}
+	if filter.BodyPath != nil && !filter.matchesPath(message.Body) {
+		return false
+	}
+	if !filter.EnqueuedAfter.IsZero() && message.EnqueuedTime.Before(filter.EnqueuedAfter) {
+		return false
+	}
+	if !filter.EnqueuedBefore.IsZero() && message.EnqueuedTime.After(filter.EnqueuedBefore) {
+		return false
+	}
+
+	return true
+}
+
+func (filter Filter) matchesPath(body string) bool {
+	var document any
+	if json.Unmarshal([]byte(body), &document) != nil {
+		return false
+	}
+	value, found := filter.BodyPath.Get(document)
+	if !found {
+		return false
+	}
+	if filter.BodyPathEquals == nil {
+		return true
+	}
+	if text, ok := value.(string); ok {
+		return text == *filter.BodyPathEquals
+	}
+	encoded, _ := json.Marshal(value)
+
+	return string(encoded) == *filter.BodyPathEquals
+}
+
+// Apply returns the messages matching the filter
+func (filter Filter) Apply(messages []asb.ReceivedMessage) []asb.ReceivedMessage {
+	if filter.IsEmpty() {
+		return messages
+	}
+
+	matching := []asb.ReceivedMessage{}
+	for _, message := range messages {
+		if filter.Matches(message) {
+			matching = append(matching, message)
+		}
+	}
+
+	return matching
+}
+
+func brokerProperty(message asb.ReceivedMessage, name string) (string, bool) {
+	switch strings.ToLower(name) {
+	case "messageid":
+		return message.MessageID, true
+	case "correlationid":
+		return message.CorrelationID, true
+	case "subject":
+		return message.Subject, true
+	case "replyto":
+		return message.ReplayTo, true
+	case "replytosessionid":
+		return message.ReplyToSessionID, true
+	case "sessionid":
+		return message.SessionID, true
+	case "contenttype":
+		return message.ContentType, true
+	case "deadletterreason":
+		return message.DeadLetterReason, true
+	case "deadletterdescription":
+		return message.DeadLetterDescription, true
+	case "deadlettersource":
+		return message.DeadLetterSource, true
+	case "sequencenumber":
+		return strconv.FormatInt(message.SequenceNumber, 10), true
+	case "deliverycount":
+		return strconv.FormatUint(uint64(message.DeliveryCount), 10), true
+	}
+
+	return "", false
+}
+
+// AddBrokerProperty adds a condition on the broker property given as name=value
+func (filter *Filter) AddBrokerProperty(condition string) error {
+	name, value, err := splitCondition(condition)
+	if err != nil {
+		return err
+	}
+	if _, known := brokerProperty(asb.ReceivedMessage{}, name); !known {
+		return fmt.Errorf("unknown broker property '%v', use one of: %v", name, strings.Join(BrokerPropertyNames, ", "))
+	}
+	if filter.BrokerProperties == nil {
+		filter.BrokerProperties = make(map[string]string)
+	}
+	filter.BrokerProperties[name] = value
+
+	return nil
+}
+
+// AddApplicationProperty adds a condition on the application property given as name=value
+func (filter *Filter) AddApplicationProperty(condition string) error {
+	name, value, err := splitCondition(condition)
+	if err != nil {
+		return err
+	}
+	if filter.ApplicationProperties == nil {
+		filter.ApplicationProperties = make(map[string]string)
+	}
+	filter.ApplicationProperties[name] = value
+
+	return nil
+}
+
+func splitCondition(condition string) (string, string, error) {
+	name, value, found := strings.Cut(condition, "=")
+	if !found || len(name) == 0 {
+		return "", "", fmt.Errorf("invalid condition '%v', use name=value", condition)
+	}
+
+	return name, value, nil
+}
+
+// SetBodyPath sets the JSONPath expression that has to exist in the body
+func (filter *Filter) SetBodyPath(expression string) error {
+	path, err := jsonpath.Parse(expression)
+	if err != nil {
+		return err
+	}
+	filter.BodyPath = &path
+
+	return nil
+}
+
+// SetBodyMatches sets the regular expression the body has to match
+func (filter *Filter) SetBodyMatches(expression string) error {
+	matches, err := regexp.Compile(expression)
+	if err != nil {
+		return fmt.Errorf("invalid regular expression '%v': %w", expression, err)
+	}
+	filter.BodyMatches = matches
+
+	return nil
+}
+
+// ParseTime reads a time as RFC 3339, "2006-01-02 15:04:05" or "2006-01-02" in local time,
+// or as a duration before now, e.g. 15m
+func ParseTime(text string, now time.Time) (time.Time, error) {
+	if parsed, err := time.Parse(time.RFC3339, text); err == nil {
+		return parsed, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02"} {
+		if parsed, err := time.ParseInLocation(layout, text, time.Local); err == nil {
+			return parsed, nil
+		}
+	}
+	if ago, err := time.ParseDuration(text); err == nil {
+		return now.Add(-ago), nil
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time '%v', use RFC 3339, '2006-01-02 15:04:05', '2006-01-02' or a duration like 15m", text)
+}
@@ -0,0 +1,90 @@
+package filter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/rafalpienkowski/busgopher/internal/asb"
+)
+
+var enqueued = time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)
+
+func createTestMessage() asb.ReceivedMessage {
+	return asb.ReceivedMessage{
+		Message: asb.Message{
+			Body:             `{"order": {"id": 42, "customer": "acme"}}`,
+			Subject:          "order-created",
+			MessageID:        "message-1",
+			CustomProperties: map[string]any{"tenant": "acme", "priority": int64(5)},
+		},
+		SequenceNumber:   7,
+		EnqueuedTime:     enqueued,
+		DeadLetterReason: "MaxDeliveryCountExceeded",
+	}
+}
+
+func Test_Filter_Should_Match_Every_Message_When_Empty(t *testing.T) {
+	assert.True(t, Filter{}.IsEmpty())
+	assert.True(t, Filter{}.Matches(createTestMessage()))
+}
+
+func Test_Filter_Should_Match_Conditions(t *testing.T) {
+	for query, expected := range map[string]bool{
+		"subject=order-created":                     true,
+		"SUBJECT=order-created":                     true,
+		"subject=order-deleted":                     false,
+		"sequenceNumber=7":                          true,
+		"deadLetterReason=MaxDeliveryCountExceeded": true,
+		"app.tenant=acme":                           true,
+		"app.priority=5":                            true,
+		"app.missing=":                              false,
+		"acme":                                      true,
+		"contoso":                                   false,
+		"/\"id\":\\s*42/":                           true,
+		"/^plain/":                                  false,
+		"$.order.id":                                true,
+		"$.order.id=42":                             true,
+		"$.order.customer=acme":                     true,
+		"$.order.id=43":                             false,
+		"$.order.missing":                           false,
+		"after:2024-10-01":                          true,
+		"before:2024-10-01":                         false,
+		"after:2024-09-30T00:00:00Z before:2024-10-02": true,
+		"subject=order-created contoso":                false,
+	} {
+		filter, err := ParseQuery(query, enqueued)
+
+		assert.NoError(t, err, query)
+		assert.Equal(t, expected, filter.Matches(createTestMessage()), query)
+	}
+}
+
+func Test_Filter_Should_Parse_Relative_Time(t *testing.T) {
+	parsed, err := ParseTime("90m", enqueued)
+
+	assert.NoError(t, err)
+	assert.Equal(t, enqueued.Add(-90*time.Minute), parsed)
+}
+
+func Test_Filter_Should_Reject_Invalid_Conditions(t *testing.T) {
+	filter := Filter{}
+
+	assert.EqualError(t, filter.AddApplicationProperty("tenant"), "invalid condition 'tenant', use name=value")
+	assert.ErrorContains(t, filter.AddBrokerProperty("label=x"), "unknown broker property 'label'")
+	assert.Error(t, filter.SetBodyMatches("("))
+	assert.Error(t, filter.SetBodyPath("order.id"))
+	_, err := ParseQuery("after:yesterday", enqueued)
+	assert.ErrorContains(t, err, "invalid time 'yesterday'")
+}
+
+func Test_Filter_Should_Apply_To_Messages(t *testing.T) {
+	other := createTestMessage()
+	other.Subject = "order-deleted"
+	filter := Filter{BrokerProperties: map[string]string{"subject": "order-deleted"}}
+
+	matching := filter.Apply([]asb.ReceivedMessage{createTestMessage(), other})
+
+	assert.Equal(t, []asb.ReceivedMessage{other}, matching)
+}
@@ -0,0 +1,62 @@
+package filter
+
+import (
+	"strings"
+	"time"
+)
+
+// ParseQuery reads a filter from space separated terms, as typed in a search box:
+//
+//	subject=created         broker property
+//	app.tenant=acme         application property
+//	/timeout|refused/       body regular expression
+//	$.order.id=42           JSONPath in the body, without =value it only has to exist
+//	after:1h before:2024-10-01
+//	other words             text the body has to contain
+func ParseQuery(query string, now time.Time) (Filter, error) {
+	filter := Filter{}
+	words := []string{}
+	for _, term := range strings.Fields(query) {
+		var err error
+		switch {
+		case strings.HasPrefix(term, "after:"):
+			filter.EnqueuedAfter, err = ParseTime(strings.TrimPrefix(term, "after:"), now)
+		case strings.HasPrefix(term, "before:"):
+			filter.EnqueuedBefore, err = ParseTime(strings.TrimPrefix(term, "before:"), now)
+		case strings.HasPrefix(term, "app."):
+			err = filter.AddApplicationProperty(strings.TrimPrefix(term, "app."))
+		case strings.HasPrefix(term, "$"):
+			expression, value, found := strings.Cut(term, "=")
+			err = filter.SetBodyPath(expression)
+			if found {
+				filter.BodyPathEquals = &value
+			}
+		case len(term) > 2 && strings.HasPrefix(term, "/") && strings.HasSuffix(term, "/"):
+			err = filter.SetBodyMatches(term[1 : len(term)-1])
+		case isBrokerCondition(term):
+			err = filter.AddBrokerProperty(term)
+		default:
+			words = append(words, term)
+		}
+		if err != nil {
+			return Filter{}, err
+		}
+	}
+	filter.BodyContains = strings.Join(words, " ")
+
+	return filter, nil
+}
+
+func isBrokerCondition(term string) bool {
+	name, _, found := strings.Cut(term, "=")
+	if !found {
+		return false
+	}
+	for _, known := range BrokerPropertyNames {
+		if strings.EqualFold(name, known) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,148 @@
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const historyName = "history.jsonl"
+const defaultMaxEntries = 1000
+
+// FileStorage appends entries to a JSON Lines file. When the file holds a tenth more entries than
+// MaxEntries, it is rewritten with the newest MaxEntries.
+type FileStorage struct {
+	// Path to the history file, history.jsonl in the working directory when empty
+	Path string
+	// MaxEntries is the number of entries kept, 1000 when not set
+	MaxEntries int
+
+	// count of the entries in the file, known once counted
+	count   int
+	counted bool
+	mutex   sync.Mutex
+}
+
+// NewFileStorage returns the storage of the history file next to the config file
+func NewFileStorage(configPath string) *FileStorage {
+	return &FileStorage{Path: filepath.Join(filepath.Dir(configPath), historyName)}
+}
+
+func (storage *FileStorage) path() string {
+	if len(storage.Path) == 0 {
+		return historyName
+	}
+	return storage.Path
+}
+
+func (storage *FileStorage) maxEntries() int {
+	if storage.MaxEntries <= 0 {
+		return defaultMaxEntries
+	}
+	return storage.MaxEntries
+}
+
+func (storage *FileStorage) Append(entry Entry) error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(storage.path(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(append(line, '\n'))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if !storage.counted {
+		entries, err := storage.load()
+		if err != nil {
+			return err
+		}
+		storage.count = len(entries)
+		storage.counted = true
+	} else {
+		storage.count++
+	}
+	if storage.count > storage.maxEntries()+storage.maxEntries()/10 {
+		return storage.trim()
+	}
+
+	return nil
+}
+
+func (storage *FileStorage) Load() ([]Entry, error) {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	return storage.load()
+}
+
+func (storage *FileStorage) load() ([]Entry, error) {
+	content, err := os.ReadFile(storage.path())
+	if errors.Is(err, os.ErrNotExist) {
+		return []Entry{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []Entry{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(nil, len(content)+1)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		entry := Entry{}
+		// A line cut by a crash must not hide the rest of the history
+		if json.Unmarshal(scanner.Bytes(), &entry) == nil {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, scanner.Err()
+}
+
+// trim rewrites the file with the newest entries, the file is replaced only when fully written
+func (storage *FileStorage) trim() error {
+	entries, err := storage.load()
+	if err != nil {
+		return err
+	}
+	entries = entries[max(0, len(entries)-storage.maxEntries()):]
+
+	content := bytes.Buffer{}
+	encoder := json.NewEncoder(&content)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			return err
+		}
+	}
+
+	temp := storage.path() + ".tmp"
+	err = os.WriteFile(temp, content.Bytes(), 0600)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(temp, storage.path())
+	if err != nil {
+		return err
+	}
+	storage.count = len(entries)
+
+	return nil
+}
@@ -0,0 +1,56 @@
+package history
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/rafalpienkowski/busgopher/internal/asb"
+)
+
+func createTestStorage(t *testing.T) *FileStorage {
+	return &FileStorage{Path: filepath.Join(t.TempDir(), "history.jsonl"), MaxEntries: 10}
+}
+
+func Test_FileStorage_Should_Append_And_Load_Entries(t *testing.T) {
+	storage := createTestStorage(t)
+	entry := Entry{
+		Time:        time.Date(2024, 10, 8, 20, 4, 37, 0, time.UTC),
+		Connection:  "dev",
+		Namespace:   "dev.servicebus.windows.net",
+		Destination: "orders",
+		MessageName: "order-created",
+		Message:     asb.Message{Body: `{"id": 42}`, CustomProperties: map[string]any{"tenant": "acme"}},
+		MessageID:   "id-1",
+		Result:      ResultSent,
+	}
+
+	assert.NoError(t, storage.Append(entry))
+	entries, err := (&FileStorage{Path: storage.Path}).Load()
+
+	assert.NoError(t, err)
+	assert.Equal(t, []Entry{entry}, entries)
+}
+
+func Test_FileStorage_Should_Load_Empty_History_When_File_Is_Missing(t *testing.T) {
+	entries, err := createTestStorage(t).Load()
+
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func Test_FileStorage_Should_Keep_Newest_Entries(t *testing.T) {
+	storage := createTestStorage(t)
+	for i := range 12 {
+		assert.NoError(t, storage.Append(Entry{MessageID: string(rune('a' + i))}))
+	}
+
+	entries, err := storage.Load()
+
+	assert.NoError(t, err)
+	assert.Len(t, entries, 10)
+	assert.Equal(t, "c", entries[0].MessageID)
+	assert.Equal(t, "l", entries[9].MessageID)
+}
@@ -0,0 +1,162 @@
+// Package history keeps a record of the sent messages, so they can be looked up and sent again
+package history
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rafalpienkowski/busgopher/internal/asb"
+)
+
+const (
+	ResultSent   = "sent"
+	ResultFailed = "failed"
+)
+
+// Entry is one send. The message is stored with its body rendered and secret references kept,
+// its MessageID is empty when the ID was generated for the send.
+type Entry struct {
+	Time time.Time `json:"time"`
+	// Connection is the name of the saved connection, empty for ad-hoc namespaces
+	Connection  string      `json:"connection,omitempty"`
+	Namespace   string      `json:"namespace,omitempty"`
+	Destination string      `json:"destination"`
+	MessageName string      `json:"messageName,omitempty"`
+	Message     asb.Message `json:"message"`
+	MessageID   string      `json:"messageId"`
+	Result      string      `json:"result"`
+	Error       string      `json:"error,omitempty"`
+}
+
+type Storage interface {
+	Append(entry Entry) error
+	// Load returns the entries, the oldest first
+	Load() ([]Entry, error)
+}
+
+// Matches tells whether the entry contains the text, ignoring case, in its names, IDs, subject,
+// body, properties or error
+func (entry Entry) Matches(text string) bool {
+	text = strings.ToLower(strings.TrimSpace(text))
+	if len(text) == 0 {
+		return true
+	}
+
+	fields := []string{
+		entry.Connection,
+		entry.Namespace,
+		entry.Destination,
+		entry.MessageName,
+		entry.MessageID,
+		entry.Message.Subject,
+		entry.Message.CorrelationID,
+		entry.Message.SessionID,
+		entry.Message.Body,
+		entry.Error,
+	}
+	for key, value := range entry.Message.CustomProperties {
+		fields = append(fields, fmt.Sprintf("%v=%v", key, value))
+	}
+	for _, field := range fields {
+		if strings.Contains(strings.ToLower(field), text) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Diff lists the differences between two entries, the body is compared line by line with
+// removed lines prefixed with "- " and added ones with "+ "
+func Diff(first Entry, second Entry) []string {
+	lines := []string{}
+	for _, field := range []struct {
+		name   string
+		first  string
+		second string
+	}{
+		{"Connection", first.Connection, second.Connection},
+		{"Namespace", first.Namespace, second.Namespace},
+		{"Destination", first.Destination, second.Destination},
+		{"MessageName", first.MessageName, second.MessageName},
+		{"MessageID", first.MessageID, second.MessageID},
+		{"CorrelationID", first.Message.CorrelationID, second.Message.CorrelationID},
+		{"Subject", first.Message.Subject, second.Message.Subject},
+		{"ReplyTo", first.Message.ReplayTo, second.Message.ReplayTo},
+		{"SessionID", first.Message.SessionID, second.Message.SessionID},
+		{"ReplyToSessionID", first.Message.ReplyToSessionID, second.Message.ReplyToSessionID},
+		{"Result", first.Result, second.Result},
+	} {
+		if field.first != field.second {
+			lines = append(lines, fmt.Sprintf("%v: %q -> %q", field.name, field.first, field.second))
+		}
+	}
+
+	keys := slices.Sorted(maps.Keys(first.Message.CustomProperties))
+	for key := range second.Message.CustomProperties {
+		if _, ok := first.Message.CustomProperties[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		before, inFirst := first.Message.CustomProperties[key]
+		after, inSecond := second.Message.CustomProperties[key]
+		switch {
+		case !inSecond:
+			lines = append(lines, fmt.Sprintf("Property %v: removed (was %v)", key, before))
+		case !inFirst:
+			lines = append(lines, fmt.Sprintf("Property %v: added %v", key, after))
+		case fmt.Sprint(before) != fmt.Sprint(after):
+			lines = append(lines, fmt.Sprintf("Property %v: %v -> %v", key, before, after))
+		}
+	}
+
+	if first.Message.Body != second.Message.Body {
+		lines = append(lines, "Body:")
+		lines = append(lines, diffLines(strings.Split(first.Message.Body, "\n"), strings.Split(second.Message.Body, "\n"))...)
+	}
+
+	return lines
+}
+
+// diffLines compares the lines using their longest common subsequence, unchanged lines are
+// prefixed with two spaces
+func diffLines(first []string, second []string) []string {
+	// common[i][j] is the length of the longest common subsequence of first[i:] and second[j:]
+	common := make([][]int, len(first)+1)
+	for i := range common {
+		common[i] = make([]int, len(second)+1)
+	}
+	for i := len(first) - 1; i >= 0; i-- {
+		for j := len(second) - 1; j >= 0; j-- {
+			if first[i] == second[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+
+	lines := []string{}
+	i, j := 0, 0
+	for i < len(first) || j < len(second) {
+		switch {
+		case i < len(first) && j < len(second) && first[i] == second[j]:
+			lines = append(lines, "  "+first[i])
+			i++
+			j++
+		case j == len(second) || (i < len(first) && common[i+1][j] >= common[i][j+1]):
+			lines = append(lines, "- "+first[i])
+			i++
+		default:
+			lines = append(lines, "+ "+second[j])
+			j++
+		}
+	}
+
+	return lines
+}
@@ -0,0 +1,57 @@
+package history
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/rafalpienkowski/busgopher/internal/asb"
+)
+
+func Test_Entry_Should_Match_Text_Ignoring_Case(t *testing.T) {
+	entry := Entry{
+		Destination: "orders",
+		Message:     asb.Message{Body: `{"orderId": 42}`, CustomProperties: map[string]any{"tenant": "acme"}},
+	}
+
+	assert.True(t, entry.Matches("ORDERID"))
+	assert.True(t, entry.Matches("tenant=acme"))
+	assert.True(t, entry.Matches(""))
+	assert.False(t, entry.Matches("invoices"))
+}
+
+func Test_Diff_Should_List_Changed_Fields_Properties_And_Body_Lines(t *testing.T) {
+	first := Entry{
+		Destination: "orders",
+		Message: asb.Message{
+			Body:             "{\n  \"id\": 41,\n  \"tenant\": \"acme\"\n}",
+			CustomProperties: map[string]any{"tenant": "acme", "retry": 1.0},
+		},
+	}
+	second := Entry{
+		Destination: "orders-v2",
+		Message: asb.Message{
+			Body:             "{\n  \"id\": 42,\n  \"tenant\": \"acme\"\n}",
+			CustomProperties: map[string]any{"tenant": "globex", "priority": "high"},
+		},
+	}
+
+	assert.Equal(t, []string{
+		`Destination: "orders" -> "orders-v2"`,
+		"Property priority: added high",
+		"Property retry: removed (was 1)",
+		"Property tenant: acme -> globex",
+		"Body:",
+		"  {",
+		`-   "id": 41,`,
+		`+   "id": 42,`,
+		`    "tenant": "acme"`,
+		"  }",
+	}, Diff(first, second))
+}
+
+func Test_Diff_Should_Be_Empty_For_Same_Entries(t *testing.T) {
+	entry := Entry{Destination: "orders", Message: asb.Message{Body: "body"}}
+
+	assert.Empty(t, Diff(entry, entry))
+}
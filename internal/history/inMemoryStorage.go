@@ -0,0 +1,17 @@
+package history
+
+import "slices"
+
+type InMemoryStorage struct {
+	Entries []Entry
+}
+
+func (storage *InMemoryStorage) Append(entry Entry) error {
+	storage.Entries = append(storage.Entries, entry)
+
+	return nil
+}
+
+func (storage *InMemoryStorage) Load() ([]Entry, error) {
+	return slices.Clone(storage.Entries), nil
+}
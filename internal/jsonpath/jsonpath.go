@@ -0,0 +1,121 @@
+package jsonpath
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Path is a parsed JSON path like $.body.items[0]["first name"]
+type Path struct {
+	text     string
+	segments []segment
+}
+
+// segment is either an object key or an array index
+type segment struct {
+	key   string
+	index int
+	isKey bool
+}
+
+// Parse reads a path made of the root $, .key, ["key"] and [index] segments
+func Parse(text string) (Path, error) {
+	if !strings.HasPrefix(text, "$") {
+		return Path{}, fmt.Errorf("invalid path '%v': must start with $", text)
+	}
+
+	path := Path{text: text}
+	rest := text[1:]
+	for len(rest) > 0 {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			key := rest[1 : end+1]
+			if len(key) == 0 {
+				return Path{}, fmt.Errorf("invalid path '%v': empty key", text)
+			}
+			path.segments = append(path.segments, segment{key: key, isKey: true})
+			rest = rest[end+1:]
+		case '[':
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return Path{}, fmt.Errorf("invalid path '%v': missing ]", text)
+			}
+			inside := rest[1:end]
+			if strings.HasPrefix(inside, "\"") || strings.HasPrefix(inside, "'") {
+				key, err := unquote(inside)
+				if err != nil {
+					return Path{}, fmt.Errorf("invalid path '%v': %w", text, err)
+				}
+				path.segments = append(path.segments, segment{key: key, isKey: true})
+			} else {
+				index, err := strconv.Atoi(inside)
+				if err != nil || index < 0 {
+					return Path{}, fmt.Errorf("invalid path '%v': invalid index '%v'", text, inside)
+				}
+				path.segments = append(path.segments, segment{index: index})
+			}
+			rest = rest[end+1:]
+		default:
+			return Path{}, fmt.Errorf("invalid path '%v': unexpected '%c'", text, rest[0])
+		}
+	}
+
+	return path, nil
+}
+
+func unquote(quoted string) (string, error) {
+	if len(quoted) < 2 || quoted[0] != quoted[len(quoted)-1] {
+		return "", errors.New("unterminated key")
+	}
+	if quoted[0] == '\'' {
+		return quoted[1 : len(quoted)-1], nil
+	}
+	return strconv.Unquote(quoted)
+}
+
+func (path Path) String() string {
+	return path.text
+}
+
+// Get returns the value at the path in a document decoded by encoding/json
+func (path Path) Get(document any) (any, bool) {
+	current := document
+	for _, segment := range path.segments {
+		if segment.isKey {
+			object, ok := current.(map[string]any)
+			if !ok {
+				return nil, false
+			}
+			current, ok = object[segment.key]
+			if !ok {
+				return nil, false
+			}
+			continue
+		}
+
+		array, ok := current.([]any)
+		if !ok || segment.index >= len(array) {
+			return nil, false
+		}
+		current = array[segment.index]
+	}
+
+	return current, true
+}
+
+// Get parses the path and returns the value at it
+func Get(document any, text string) (any, bool, error) {
+	path, err := Parse(text)
+	if err != nil {
+		return nil, false, err
+	}
+	value, found := path.Get(document)
+
+	return value, found, nil
+}
@@ -0,0 +1,71 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func createTestDocument(t *testing.T) any {
+	var document any
+	err := json.Unmarshal([]byte(`{
+		"body": {"items": [{"id": 1}, {"id": 2, "first name": "Ann"}]},
+		"subject": "created"
+	}`), &document)
+	assert.NoError(t, err)
+	return document
+}
+
+func Test_Get_Should_Return_Values(t *testing.T) {
+	document := createTestDocument(t)
+
+	for path, expected := range map[string]any{
+		"$.subject":                     "created",
+		"$.body.items[1].id":            2.0,
+		`$.body.items[1]["first name"]`: "Ann",
+		`$['body']['items'][0]['id']`:   1.0,
+		"$.body.items[0]":               map[string]any{"id": 1.0},
+	} {
+		value, found, err := Get(document, path)
+
+		assert.NoError(t, err, path)
+		assert.True(t, found, path)
+		assert.Equal(t, expected, value, path)
+	}
+}
+
+func Test_Get_Should_Return_Root(t *testing.T) {
+	document := createTestDocument(t)
+
+	value, found, err := Get(document, "$")
+
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, document, value)
+}
+
+func Test_Get_Should_Not_Find_Missing_Values(t *testing.T) {
+	document := createTestDocument(t)
+
+	for _, path := range []string{"$.missing", "$.body.items[5]", "$.subject.length", "$.body[0]"} {
+		_, found, err := Get(document, path)
+
+		assert.NoError(t, err, path)
+		assert.False(t, found, path)
+	}
+}
+
+func Test_Parse_Should_Reject_Invalid_Paths(t *testing.T) {
+	for path, message := range map[string]string{
+		"body":        "invalid path 'body': must start with $",
+		"$.":          "invalid path '$.': empty key",
+		"$.items[0":   "invalid path '$.items[0': missing ]",
+		"$.items[-1]": "invalid path '$.items[-1]': invalid index '-1'",
+		"$x":          "invalid path '$x': unexpected 'x'",
+	} {
+		_, err := Parse(path)
+
+		assert.EqualError(t, err, message, path)
+	}
+}
@@ -0,0 +1,98 @@
+// Package routing evaluates subscription rules locally, to tell which subscriptions of a topic
+// a message would be delivered to without sending it
+package routing
+
+import (
+	"fmt"
+	"maps"
+
+	"github.com/rafalpienkowski/busgopher/internal/asb"
+)
+
+// Route tells whether a subscription receives a message and which of its rules matched it
+type Route struct {
+	Subscription string   `json:"subscription"`
+	Matched      bool     `json:"matched"`
+	Rules        []string `json:"rules,omitempty"`
+	// Errors are rules that couldn't be evaluated, e.g. because of an unsupported function
+	Errors []string `json:"errors,omitempty"`
+}
+
+// MessageProperties returns the properties of the message filters can use. System properties
+// the message doesn't set are unknown.
+func MessageProperties(message asb.Message) Properties {
+	return Properties{
+		System: map[string]any{
+			"messageid":        message.MessageID,
+			"correlationid":    message.CorrelationID,
+			"label":            message.Subject,
+			"subject":          message.Subject,
+			"replyto":          message.ReplayTo,
+			"replytosessionid": message.ReplyToSessionID,
+			"sessionid":        message.SessionID,
+		},
+		User: maps.Clone(message.CustomProperties),
+	}
+}
+
+// Matches tells whether the rule's SQL or correlation filter matches the message. Actions aren't
+// applied.
+func Matches(rule asb.RuleDefinition, properties Properties) (bool, error) {
+	if rule.Correlation != nil {
+		return matchesCorrelation(*rule.Correlation, properties), nil
+	}
+
+	expression, err := ParseSQL(rule.SQL)
+	if err != nil {
+		return false, fmt.Errorf("rule '%v': %w", rule.Name, err)
+	}
+
+	return MatchesSQL(expression, properties), nil
+}
+
+// matchesCorrelation tells whether all properties of the filter are equal to the message's
+func matchesCorrelation(filter asb.CorrelationFilter, properties Properties) bool {
+	for name, expected := range map[string]string{
+		"correlationid":    filter.CorrelationID,
+		"messageid":        filter.MessageID,
+		"to":               filter.To,
+		"replyto":          filter.ReplyTo,
+		"label":            filter.Subject,
+		"sessionid":        filter.SessionID,
+		"replytosessionid": filter.ReplyToSessionID,
+		"contenttype":      filter.ContentType,
+	} {
+		if len(expected) > 0 && properties.System[name] != expected {
+			return false
+		}
+	}
+	for name, expected := range filter.Properties {
+		if compare("=", normalize(properties.User[name]), normalize(expected)) != true {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Evaluate tells, for every subscription, whether any of its rules matches the message
+func Evaluate(subscriptions []asb.SubscriptionDefinition, message asb.Message) []Route {
+	properties := MessageProperties(message)
+	routes := []Route{}
+	for _, subscription := range subscriptions {
+		route := Route{Subscription: subscription.Name}
+		for _, rule := range subscription.Rules {
+			matched, err := Matches(rule, properties)
+			if err != nil {
+				route.Errors = append(route.Errors, err.Error())
+			}
+			if matched {
+				route.Matched = true
+				route.Rules = append(route.Rules, rule.Name)
+			}
+		}
+		routes = append(routes, route)
+	}
+
+	return routes
+}
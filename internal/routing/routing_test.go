@@ -0,0 +1,135 @@
+package routing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/rafalpienkowski/busgopher/internal/asb"
+)
+
+func createTestMessage() asb.Message {
+	return asb.Message{
+		Body:          `{"order": 42}`,
+		Subject:       "order-created",
+		CorrelationID: "correlation-1",
+		CustomProperties: map[string]any{
+			"tenant":   "acme",
+			"priority": 5.0,
+			"amount":   12.5,
+			"urgent":   true,
+			"region":   "eu-west",
+		},
+	}
+}
+
+func Test_MatchesSQL_Should_Evaluate_Filters(t *testing.T) {
+	properties := MessageProperties(createTestMessage())
+
+	for filter, expected := range map[string]bool{
+		"1=1":                                      true,
+		"1=0":                                      false,
+		"sys.Label = 'order-created'":              true,
+		"sys.label = 'order-created'":              true,
+		"sys.Subject <> 'order-created'":           false,
+		"tenant = 'acme'":                          true,
+		"user.tenant = 'acme'":                     true,
+		"[tenant] = 'acme'":                        true,
+		"tenant = 'ACME'":                          false,
+		"priority > 3 AND amount <= 12.5":          true,
+		"priority = 5.0":                           true,
+		"priority * 2 + 1 = 11":                    true,
+		"priority % 2 = 1":                         true,
+		"-priority < 0":                            true,
+		"urgent = TRUE":                            true,
+		"urgent":                                   true,
+		"NOT urgent OR tenant = 'other'":           false,
+		"region LIKE 'eu-%'":                       true,
+		"region LIKE 'eu_west'":                    true,
+		"region NOT LIKE 'us%'":                    true,
+		"region LIKE 'eu!-%' ESCAPE '!'":           true,
+		"tenant IN ('acme', 'contoso')":            true,
+		"tenant NOT IN ('acme', 'contoso')":        false,
+		"priority IN (1, 5)":                       true,
+		"missing = 'x'":                            false,
+		"NOT missing = 'x'":                        false,
+		"missing IS NULL":                          true,
+		"tenant IS NOT NULL":                       true,
+		"EXISTS(tenant) AND NOT EXISTS(missing)":   true,
+		"missing = 'x' OR tenant = 'acme'":         true,
+		"sys.MessageId IS NULL":                    true,
+		"sys.CorrelationId = 'correlation-1'":      true,
+		"tenant = 'it''s'":                         false,
+		"(tenant = 'acme' OR 1=0) AND priority<10": true,
+		"tenant + '-' + region = 'acme-eu-west'":   true,
+		"tenant > 5":                               false,
+	} {
+		expression, err := ParseSQL(filter)
+		assert.NoError(t, err, filter)
+		if err == nil {
+			assert.Equal(t, expected, MatchesSQL(expression, properties), filter)
+		}
+	}
+}
+
+func Test_ParseSQL_Should_Report_Invalid_Filters(t *testing.T) {
+	for filter, message := range map[string]string{
+		"tenant = 'acme":        "unterminated string at position 10",
+		"tenant = ":             "expected a value at the end of the filter",
+		"tenant == 'acme'":      "expected a value instead of '=' at position 9",
+		"tenant 'acme'":         "unexpected 'acme' at position 8",
+		"tenant NOT = 'acme'":   "expected LIKE or IN after NOT instead of '=' at position 12",
+		"newid() = 'x'":         "function 'newid' at position 1 isn't supported",
+		"tenant = 'acme' AND ?": "unexpected '?' at position 21",
+	} {
+		_, err := ParseSQL(filter)
+		assert.EqualError(t, err, message, filter)
+	}
+}
+
+func Test_Matches_Should_Evaluate_Correlation_Filters(t *testing.T) {
+	properties := MessageProperties(createTestMessage())
+
+	for _, test := range []struct {
+		filter   asb.CorrelationFilter
+		expected bool
+	}{
+		{asb.CorrelationFilter{Subject: "order-created"}, true},
+		{asb.CorrelationFilter{Subject: "order-deleted"}, false},
+		{asb.CorrelationFilter{CorrelationID: "correlation-1", Properties: map[string]any{"tenant": "acme"}}, true},
+		{asb.CorrelationFilter{Properties: map[string]any{"priority": int64(5)}}, true},
+		{asb.CorrelationFilter{Properties: map[string]any{"priority": "5"}}, false},
+		{asb.CorrelationFilter{Properties: map[string]any{"missing": "x"}}, false},
+		{asb.CorrelationFilter{SessionID: "session-1"}, false},
+	} {
+		matched, err := Matches(asb.RuleDefinition{Name: "rule", Correlation: &test.filter}, properties)
+		assert.NoError(t, err)
+		assert.Equal(t, test.expected, matched, asb.RuleDefinition{Correlation: &test.filter}.FilterText())
+	}
+}
+
+func Test_Evaluate_Should_Route_Message_To_Subscriptions_With_Matching_Rules(t *testing.T) {
+	subscriptions := []asb.SubscriptionDefinition{
+		{Name: "all", Rules: []asb.RuleDefinition{{Name: asb.DefaultRuleName, SQL: "1=1"}}},
+		{Name: "deleted", Rules: []asb.RuleDefinition{
+			{Name: "deleted", Correlation: &asb.CorrelationFilter{Subject: "order-deleted"}},
+		}},
+		{Name: "acme", Rules: []asb.RuleDefinition{
+			{Name: "us", SQL: "region LIKE 'us%'"},
+			{Name: "acme", SQL: "tenant = 'acme'"},
+			{Name: "broken", SQL: "tenant ="},
+		}},
+		{Name: "empty"},
+	}
+
+	routes := Evaluate(subscriptions, createTestMessage())
+
+	assert.Equal(t, []Route{
+		{Subscription: "all", Matched: true, Rules: []string{asb.DefaultRuleName}},
+		{Subscription: "deleted"},
+		{Subscription: "acme", Matched: true, Rules: []string{"acme"}, Errors: []string{
+			"rule 'broken': expected a value at the end of the filter",
+		}},
+		{Subscription: "empty"},
+	}, routes)
+}
@@ -0,0 +1,679 @@
+package routing
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Expression is a parsed SQL filter. Evaluating it returns true, false, a number, a string or nil,
+// which stands for an unknown value, e.g. of a missing property.
+type Expression interface {
+	evaluate(properties Properties) any
+}
+
+// Properties are the properties of a message as seen by filters
+type Properties struct {
+	// System properties by lower case name, e.g. messageid or label
+	System map[string]any
+	User   map[string]any
+}
+
+// ParseSQL parses a SQL filter expression, e.g. sys.Label = 'created' AND tenant LIKE 'acme%'
+func ParseSQL(text string) (Expression, error) {
+	tokens, err := tokenize(text)
+	if err != nil {
+		return nil, err
+	}
+
+	parser := parser{tokens: tokens}
+	expression, err := parser.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !parser.done() {
+		return nil, fmt.Errorf("unexpected '%v' at position %v", parser.peek().text, parser.peek().position)
+	}
+
+	return expression, nil
+}
+
+// MatchesSQL tells whether the expression is true for the properties, unknown values don't match
+func MatchesSQL(expression Expression, properties Properties) bool {
+	return expression.evaluate(properties) == true
+}
+
+type tokenKind int
+
+const (
+	tokenWord tokenKind = iota
+	tokenIdentifier
+	tokenString
+	tokenNumber
+	tokenSymbol
+)
+
+type token struct {
+	kind     tokenKind
+	text     string
+	position int
+}
+
+func tokenize(text string) ([]token, error) {
+	tokens := []token{}
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		start := i
+		switch current := runes[i]; {
+		case unicode.IsSpace(current):
+			i++
+			continue
+		case current == '\'':
+			value := strings.Builder{}
+			for i++; ; i++ {
+				if i >= len(runes) {
+					return nil, fmt.Errorf("unterminated string at position %v", start+1)
+				}
+				if runes[i] == '\'' {
+					if i+1 < len(runes) && runes[i+1] == '\'' {
+						i++
+					} else {
+						break
+					}
+				}
+				value.WriteRune(runes[i])
+			}
+			i++
+			tokens = append(tokens, token{kind: tokenString, text: value.String(), position: start + 1})
+		case current == '[':
+			end := slices.Index(runes[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated identifier at position %v", start+1)
+			}
+			i += end + 1
+			tokens = append(tokens, token{kind: tokenIdentifier, text: string(runes[start+1 : i-1]), position: start + 1})
+		case unicode.IsDigit(current) || current == '.' && i+1 < len(runes) && unicode.IsDigit(runes[i+1]):
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.' || unicode.ToLower(runes[i]) == 'e' ||
+				(runes[i] == '-' || runes[i] == '+') && unicode.ToLower(runes[i-1]) == 'e') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: string(runes[start:i]), position: start + 1})
+		case unicode.IsLetter(current) || current == '_' || current == '$':
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_' ||
+				runes[i] == '$' || runes[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenWord, text: string(runes[start:i]), position: start + 1})
+		default:
+			symbol := string(current)
+			if i+1 < len(runes) && slices.Contains([]string{"<>", "!=", "<=", ">="}, string(runes[i:i+2])) {
+				symbol = string(runes[i : i+2])
+			}
+			if len(symbol) == 1 && !strings.Contains("=<>+-*/%(),", symbol) {
+				return nil, fmt.Errorf("unexpected '%v' at position %v", symbol, start+1)
+			}
+			i += len(symbol)
+			tokens = append(tokens, token{kind: tokenSymbol, text: symbol, position: start + 1})
+		}
+	}
+
+	return tokens, nil
+}
+
+type parser struct {
+	tokens  []token
+	current int
+}
+
+func (parser *parser) done() bool {
+	return parser.current >= len(parser.tokens)
+}
+
+func (parser *parser) peek() token {
+	if parser.done() {
+		return token{kind: tokenSymbol, text: "end of filter", position: -1}
+	}
+	return parser.tokens[parser.current]
+}
+
+// accept consumes the next token when it is the keyword or symbol
+func (parser *parser) accept(text string) bool {
+	next := parser.peek()
+	if parser.done() || next.kind != tokenWord && next.kind != tokenSymbol || !strings.EqualFold(next.text, text) {
+		return false
+	}
+	parser.current++
+	return true
+}
+
+func (parser *parser) expect(text string) error {
+	if !parser.accept(text) {
+		return parser.unexpected(text)
+	}
+	return nil
+}
+
+func (parser *parser) unexpected(expected string) error {
+	next := parser.peek()
+	if parser.done() {
+		return fmt.Errorf("expected %v at the end of the filter", expected)
+	}
+	return fmt.Errorf("expected %v instead of '%v' at position %v", expected, next.text, next.position)
+}
+
+func (parser *parser) parseOr() (Expression, error) {
+	left, err := parser.parseAnd()
+	for err == nil && parser.accept("OR") {
+		var right Expression
+		right, err = parser.parseAnd()
+		left = or{left, right}
+	}
+	return left, err
+}
+
+func (parser *parser) parseAnd() (Expression, error) {
+	left, err := parser.parseNot()
+	for err == nil && parser.accept("AND") {
+		var right Expression
+		right, err = parser.parseNot()
+		left = and{left, right}
+	}
+	return left, err
+}
+
+func (parser *parser) parseNot() (Expression, error) {
+	if parser.accept("NOT") {
+		operand, err := parser.parseNot()
+		return not{operand}, err
+	}
+	return parser.parsePredicate()
+}
+
+func (parser *parser) parsePredicate() (Expression, error) {
+	if parser.accept("EXISTS") {
+		if err := parser.expect("("); err != nil {
+			return nil, err
+		}
+		operand, err := parser.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		property, ok := operand.(property)
+		if !ok {
+			return nil, fmt.Errorf("EXISTS needs a property name")
+		}
+		return exists{property}, parser.expect(")")
+	}
+
+	left, err := parser.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, operator := range []string{"=", "<>", "!=", "<=", ">=", "<", ">"} {
+		if parser.accept(operator) {
+			right, err := parser.parseAdditive()
+			return comparison{operator, left, right}, err
+		}
+	}
+	if parser.accept("IS") {
+		negated := parser.accept("NOT")
+		return isNull{left, negated}, parser.expect("NULL")
+	}
+	negated := parser.accept("NOT")
+	switch {
+	case parser.accept("LIKE"):
+		return parser.parseLike(left, negated)
+	case parser.accept("IN"):
+		return parser.parseIn(left, negated)
+	case negated:
+		return nil, parser.unexpected("LIKE or IN after NOT")
+	}
+
+	return left, nil
+}
+
+func (parser *parser) parseLike(operand Expression, negated bool) (Expression, error) {
+	pattern := parser.peek()
+	if pattern.kind != tokenString {
+		return nil, parser.unexpected("a pattern string")
+	}
+	parser.current++
+	escape := ""
+	if parser.accept("ESCAPE") {
+		next := parser.peek()
+		if next.kind != tokenString || len([]rune(next.text)) != 1 {
+			return nil, parser.unexpected("a single character escape string")
+		}
+		parser.current++
+		escape = next.text
+	}
+
+	expression := strings.Builder{}
+	expression.WriteString("(?s)^")
+	escaped := false
+	for _, character := range pattern.text {
+		switch {
+		case escaped:
+			expression.WriteString(regexp.QuoteMeta(string(character)))
+			escaped = false
+		case string(character) == escape:
+			escaped = true
+		case character == '%':
+			expression.WriteString(".*")
+		case character == '_':
+			expression.WriteString(".")
+		default:
+			expression.WriteString(regexp.QuoteMeta(string(character)))
+		}
+	}
+	expression.WriteString("$")
+
+	return like{operand, regexp.MustCompile(expression.String()), negated}, nil
+}
+
+func (parser *parser) parseIn(operand Expression, negated bool) (Expression, error) {
+	if err := parser.expect("("); err != nil {
+		return nil, err
+	}
+	values := []Expression{}
+	for {
+		value, err := parser.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		if !parser.accept(",") {
+			break
+		}
+	}
+
+	return in{operand, values, negated}, parser.expect(")")
+}
+
+func (parser *parser) parseAdditive() (Expression, error) {
+	left, err := parser.parseMultiplicative()
+	for err == nil {
+		operator := ""
+		for _, candidate := range []string{"+", "-"} {
+			if operator == "" && parser.accept(candidate) {
+				operator = candidate
+			}
+		}
+		if operator == "" {
+			break
+		}
+		var right Expression
+		right, err = parser.parseMultiplicative()
+		left = arithmetic{operator, left, right}
+	}
+	return left, err
+}
+
+func (parser *parser) parseMultiplicative() (Expression, error) {
+	left, err := parser.parseUnary()
+	for err == nil {
+		operator := ""
+		for _, candidate := range []string{"*", "/", "%"} {
+			if operator == "" && parser.accept(candidate) {
+				operator = candidate
+			}
+		}
+		if operator == "" {
+			break
+		}
+		var right Expression
+		right, err = parser.parseUnary()
+		left = arithmetic{operator, left, right}
+	}
+	return left, err
+}
+
+func (parser *parser) parseUnary() (Expression, error) {
+	if parser.accept("-") {
+		operand, err := parser.parseUnary()
+		return arithmetic{"-", literal{int64(0)}, operand}, err
+	}
+	if parser.accept("+") {
+		return parser.parseUnary()
+	}
+	return parser.parsePrimary()
+}
+
+func (parser *parser) parsePrimary() (Expression, error) {
+	if parser.accept("(") {
+		expression, err := parser.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return expression, parser.expect(")")
+	}
+
+	next := parser.peek()
+	if parser.done() {
+		return nil, parser.unexpected("a value")
+	}
+	switch next.kind {
+	case tokenString:
+		parser.current++
+		return literal{next.text}, nil
+	case tokenNumber:
+		parser.current++
+		if value, err := strconv.ParseInt(next.text, 10, 64); err == nil {
+			return literal{value}, nil
+		}
+		value, err := strconv.ParseFloat(next.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number '%v' at position %v", next.text, next.position)
+		}
+		return literal{value}, nil
+	case tokenIdentifier:
+		parser.current++
+		return property{name: next.text}, nil
+	case tokenWord:
+		switch strings.ToUpper(next.text) {
+		case "TRUE":
+			parser.current++
+			return literal{true}, nil
+		case "FALSE":
+			parser.current++
+			return literal{false}, nil
+		case "NULL":
+			parser.current++
+			return literal{nil}, nil
+		case "AND", "OR", "NOT", "IS", "LIKE", "IN", "ESCAPE", "EXISTS":
+			return nil, parser.unexpected("a value")
+		}
+		parser.current++
+		if parser.peek().text == "(" {
+			return nil, fmt.Errorf("function '%v' at position %v isn't supported", next.text, next.position)
+		}
+		return newProperty(next.text), nil
+	}
+
+	return nil, parser.unexpected("a value")
+}
+
+// property is a system property (sys.name) or a user property (user.name or just name)
+type property struct {
+	system bool
+	name   string
+}
+
+func newProperty(text string) property {
+	scope, name, found := strings.Cut(text, ".")
+	switch {
+	case found && strings.EqualFold(scope, "sys"):
+		return property{system: true, name: name}
+	case found && strings.EqualFold(scope, "user"):
+		return property{name: name}
+	}
+	return property{name: text}
+}
+
+func (property property) evaluate(properties Properties) any {
+	if property.system {
+		// Unset system properties are null
+		if value := properties.System[strings.ToLower(property.name)]; value != "" {
+			return normalize(value)
+		}
+		return nil
+	}
+	return normalize(properties.User[property.name])
+}
+
+// normalize converts numbers to int64 when they are whole, or to float64
+func normalize(value any) any {
+	switch number := value.(type) {
+	case int:
+		return int64(number)
+	case int32:
+		return int64(number)
+	case float32:
+		return normalize(float64(number))
+	case float64:
+		if number == math.Trunc(number) && math.Abs(number) < 1<<53 {
+			return int64(number)
+		}
+	}
+	return value
+}
+
+type literal struct {
+	value any
+}
+
+func (literal literal) evaluate(Properties) any {
+	return literal.value
+}
+
+type exists struct {
+	property property
+}
+
+func (exists exists) evaluate(properties Properties) any {
+	return exists.property.evaluate(properties) != nil
+}
+
+type not struct {
+	operand Expression
+}
+
+func (not not) evaluate(properties Properties) any {
+	value, ok := not.operand.evaluate(properties).(bool)
+	if !ok {
+		return nil
+	}
+	return !value
+}
+
+type and struct {
+	left, right Expression
+}
+
+func (and and) evaluate(properties Properties) any {
+	left := and.left.evaluate(properties)
+	right := and.right.evaluate(properties)
+	if left == false || right == false {
+		return false
+	}
+	if left == true && right == true {
+		return true
+	}
+	return nil
+}
+
+type or struct {
+	left, right Expression
+}
+
+func (or or) evaluate(properties Properties) any {
+	left := or.left.evaluate(properties)
+	right := or.right.evaluate(properties)
+	if left == true || right == true {
+		return true
+	}
+	if left == false && right == false {
+		return false
+	}
+	return nil
+}
+
+type isNull struct {
+	operand Expression
+	negated bool
+}
+
+func (isNull isNull) evaluate(properties Properties) any {
+	return (isNull.operand.evaluate(properties) == nil) != isNull.negated
+}
+
+type like struct {
+	operand Expression
+	pattern *regexp.Regexp
+	negated bool
+}
+
+func (like like) evaluate(properties Properties) any {
+	value, ok := like.operand.evaluate(properties).(string)
+	if !ok {
+		return nil
+	}
+	return like.pattern.MatchString(value) != like.negated
+}
+
+type in struct {
+	operand Expression
+	values  []Expression
+	negated bool
+}
+
+func (in in) evaluate(properties Properties) any {
+	value := in.operand.evaluate(properties)
+	if value == nil {
+		return nil
+	}
+	for _, candidate := range in.values {
+		if compare("=", value, candidate.evaluate(properties)) == true {
+			return !in.negated
+		}
+	}
+	return in.negated
+}
+
+type comparison struct {
+	operator    string
+	left, right Expression
+}
+
+func (comparison comparison) evaluate(properties Properties) any {
+	return compare(comparison.operator, comparison.left.evaluate(properties), comparison.right.evaluate(properties))
+}
+
+// compare compares numbers, strings and booleans, other combinations are unknown
+func compare(operator string, left any, right any) any {
+	order := 0
+	switch {
+	case isNumber(left) && isNumber(right):
+		leftNumber, rightNumber := toFloat(left), toFloat(right)
+		if leftNumber < rightNumber {
+			order = -1
+		} else if leftNumber > rightNumber {
+			order = 1
+		}
+	case isString(left) && isString(right):
+		order = strings.Compare(left.(string), right.(string))
+	case isBool(left) && isBool(right):
+		if operator != "=" && operator != "<>" && operator != "!=" {
+			return nil
+		}
+		if left != right {
+			order = 1
+		}
+	default:
+		return nil
+	}
+
+	switch operator {
+	case "=":
+		return order == 0
+	case "<>", "!=":
+		return order != 0
+	case "<":
+		return order < 0
+	case "<=":
+		return order <= 0
+	case ">":
+		return order > 0
+	default:
+		return order >= 0
+	}
+}
+
+type arithmetic struct {
+	operator    string
+	left, right Expression
+}
+
+func (arithmetic arithmetic) evaluate(properties Properties) any {
+	left := arithmetic.left.evaluate(properties)
+	right := arithmetic.right.evaluate(properties)
+	if arithmetic.operator == "+" && isString(left) && isString(right) {
+		return left.(string) + right.(string)
+	}
+	if !isNumber(left) || !isNumber(right) {
+		return nil
+	}
+
+	leftInteger, leftIsInteger := left.(int64)
+	rightInteger, rightIsInteger := right.(int64)
+	if leftIsInteger && rightIsInteger {
+		switch arithmetic.operator {
+		case "+":
+			return leftInteger + rightInteger
+		case "-":
+			return leftInteger - rightInteger
+		case "*":
+			return leftInteger * rightInteger
+		case "/":
+			if rightInteger == 0 {
+				return nil
+			}
+			return leftInteger / rightInteger
+		case "%":
+			if rightInteger == 0 {
+				return nil
+			}
+			return leftInteger % rightInteger
+		}
+	}
+
+	leftNumber, rightNumber := toFloat(left), toFloat(right)
+	switch arithmetic.operator {
+	case "+":
+		return leftNumber + rightNumber
+	case "-":
+		return leftNumber - rightNumber
+	case "*":
+		return leftNumber * rightNumber
+	case "/":
+		if rightNumber == 0 {
+			return nil
+		}
+		return leftNumber / rightNumber
+	default:
+		if rightNumber == 0 {
+			return nil
+		}
+		return math.Mod(leftNumber, rightNumber)
+	}
+}
+
+func isNumber(value any) bool {
+	switch value.(type) {
+	case int64, float64:
+		return true
+	}
+	return false
+}
+
+func isString(value any) bool {
+	_, ok := value.(string)
+	return ok
+}
+
+func isBool(value any) bool {
+	_, ok := value.(bool)
+	return ok
+}
+
+func toFloat(value any) float64 {
+	if integer, ok := value.(int64); ok {
+		return float64(integer)
+	}
+	return value.(float64)
+}
@@ -0,0 +1,435 @@
+package scenario
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rafalpienkowski/busgopher/internal/asb"
+	"github.com/rafalpienkowski/busgopher/internal/controller"
+	"github.com/rafalpienkowski/busgopher/internal/jsonpath"
+)
+
+const (
+	StatusPassed  = "passed"
+	StatusFailed  = "failed"
+	StatusSkipped = "skipped"
+)
+
+const (
+	defaultPeekCount    = 10
+	defaultReceiveCount = 1
+	defaultReceiveWait  = 10 * time.Second
+)
+
+type StepResult struct {
+	Name       string            `json:"name"`
+	Kind       string            `json:"kind"`
+	Status     string            `json:"status"`
+	Error      string            `json:"error,omitempty"`
+	DurationMs int64             `json:"durationMs"`
+	Captured   map[string]string `json:"captured,omitempty"`
+}
+
+type Report struct {
+	Name   string       `json:"name"`
+	Passed bool         `json:"passed"`
+	Steps  []StepResult `json:"steps"`
+}
+
+var variablePattern = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+type runner struct {
+	controller *controller.Controller
+	scenario   Scenario
+	variables  map[string]string
+}
+
+// Run executes the steps in order and stops at the first failed step.
+// The variables override the ones defined in the scenario.
+func Run(controller *controller.Controller, scenario Scenario, variables map[string]string) Report {
+	runner := runner{controller: controller, scenario: scenario, variables: make(map[string]string)}
+	maps.Copy(runner.variables, scenario.Variables)
+	maps.Copy(runner.variables, variables)
+
+	report := Report{Name: scenario.Name, Passed: true, Steps: []StepResult{}}
+	for _, step := range scenario.Steps {
+		result := StepResult{Name: step.Title(), Kind: step.Kind(), Status: StatusSkipped}
+		if !report.Passed {
+			report.Steps = append(report.Steps, result)
+			continue
+		}
+
+		started := time.Now()
+		captured, err := runner.runStep(step)
+		result.DurationMs = time.Since(started).Milliseconds()
+		result.Captured = captured
+		result.Status = StatusPassed
+		if err != nil {
+			result.Status = StatusFailed
+			result.Error = err.Error()
+			report.Passed = false
+		}
+		report.Steps = append(report.Steps, result)
+	}
+
+	return report
+}
+
+func (runner *runner) runStep(step Step) (map[string]string, error) {
+	var documents []any
+	var err error
+	switch step.Kind() {
+	case "wait":
+		duration, err := parseDuration(step.Wait, 0)
+		if err != nil {
+			return nil, err
+		}
+		time.Sleep(duration)
+		return nil, nil
+	case "send":
+		var document any
+		document, err = runner.send(step.Send)
+		documents = []any{document}
+	case "peek":
+		documents, err = runner.receive(step.Peek, true)
+	case "receive":
+		documents, err = runner.receive(step.Receive, false)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	document, err := runner.findMatching(step.Assert, documents)
+	if err != nil {
+		return nil, err
+	}
+
+	return runner.capture(step.Capture, document)
+}
+
+func (runner *runner) selectConnection(name string) error {
+	if len(name) == 0 {
+		name = runner.scenario.Connection
+	}
+	if len(name) == 0 {
+		return errors.New("connection is required")
+	}
+	name, err := runner.expand(name)
+	if err != nil {
+		return err
+	}
+
+	return runner.controller.SelectConnectionByName(name)
+}
+
+func (runner *runner) send(step *SendStep) (any, error) {
+	connection := asb.Connection{Namespace: step.Namespace}
+	if len(step.Namespace) == 0 {
+		err := runner.selectConnection(step.Connection)
+		if err != nil {
+			return nil, err
+		}
+		connection = *runner.controller.GetSelectedConnection()
+	}
+
+	var message asb.Message
+	var err error
+	if len(step.Message) > 0 {
+		message, err = runner.controller.RenderMessageWith(step.Message, runner.variables)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, field := range []struct {
+		value  string
+		target *string
+	}{
+		{step.Body, &message.Body},
+		{step.Subject, &message.Subject},
+		{step.MessageID, &message.MessageID},
+		{step.CorrelationID, &message.CorrelationID},
+		{step.ReplyTo, &message.ReplayTo},
+	} {
+		if len(field.value) > 0 {
+			*field.target = field.value
+		}
+	}
+	if len(step.Body) > 0 {
+		// Inline bodies are templates, the same as bodies of saved messages. Variables are given to
+		// the var function, so their values are inserted as they are and never rendered.
+		message.Body, err = message.TransformBodyWith(runner.variables)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(step.Properties) > 0 {
+		properties := maps.Clone(message.CustomProperties)
+		if properties == nil {
+			properties = make(map[string]any)
+		}
+		maps.Copy(properties, step.Properties)
+		message.CustomProperties = properties
+	}
+	message, err = runner.expandMessage(message)
+	if err != nil {
+		return nil, err
+	}
+
+	destination, err := runner.expand(step.Destination)
+	if err != nil {
+		return nil, err
+	}
+	result, err := runner.controller.SendTo(connection, destination, message)
+	if err != nil {
+		return nil, err
+	}
+
+	return toDocument(result)
+}
+
+func (runner *runner) receive(step *ReceiveStep, peek bool) ([]any, error) {
+	err := runner.selectConnection(step.Connection)
+	if err != nil {
+		return nil, err
+	}
+	source := asb.Source{Subscription: step.Subscription, DeadLetter: step.DeadLetter}
+	source.Destination, err = runner.expand(step.Destination)
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []asb.ReceivedMessage
+	if peek {
+		messages, err = runner.controller.Peek(source, valueOr(step.Count, defaultPeekCount), step.FromSequenceNumber)
+	} else {
+		wait, _ := parseDuration(step.Wait, defaultReceiveWait)
+		messages, err = runner.controller.Receive(source, valueOr(step.Count, defaultReceiveCount), wait)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return nil, fmt.Errorf("no messages in %v", source)
+	}
+
+	documents := []any{}
+	for _, message := range messages {
+		document, err := MessageDocument(message)
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, document)
+	}
+
+	return documents, nil
+}
+
+// findMatching returns the first document passing all assertions
+func (runner *runner) findMatching(assertions []Assertion, documents []any) (any, error) {
+	var firstErr error
+	for _, document := range documents {
+		err := runner.checkAll(assertions, document)
+		if err == nil {
+			return document, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	if len(documents) > 1 {
+		return nil, fmt.Errorf("%w (none of %v messages matched)", firstErr, len(documents))
+	}
+	return nil, firstErr
+}
+
+func (runner *runner) checkAll(assertions []Assertion, document any) error {
+	for _, assertion := range assertions {
+		err := runner.check(assertion, document)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (runner *runner) check(assertion Assertion, document any) error {
+	value, found, err := jsonpath.Get(document, assertion.Path)
+	if err != nil {
+		return err
+	}
+
+	if assertion.Exists != nil {
+		if *assertion.Exists && !found {
+			return fmt.Errorf("%v: expected to exist", assertion.Path)
+		}
+		if !*assertion.Exists && found {
+			return fmt.Errorf("%v: expected not to exist, got %v", assertion.Path, toText(value))
+		}
+	}
+	if !found {
+		if assertion.Equals != nil || assertion.Contains != nil || assertion.Matches != nil {
+			return fmt.Errorf("%v: not found", assertion.Path)
+		}
+		return nil
+	}
+
+	if assertion.Equals != nil {
+		expected := assertion.Equals
+		if text, ok := expected.(string); ok {
+			expected, err = runner.expand(text)
+			if err != nil {
+				return err
+			}
+		}
+		if !equalValues(value, expected) {
+			return fmt.Errorf("%v: expected %v, got %v", assertion.Path, toText(expected), toText(value))
+		}
+	}
+	if assertion.Contains != nil {
+		expected, err := runner.expand(*assertion.Contains)
+		if err != nil {
+			return err
+		}
+		if !strings.Contains(toText(value), expected) {
+			return fmt.Errorf("%v: expected to contain %v, got %v", assertion.Path, expected, toText(value))
+		}
+	}
+	if assertion.Matches != nil {
+		pattern, err := runner.expand(*assertion.Matches)
+		if err != nil {
+			return err
+		}
+		matched, err := regexp.MatchString(pattern, toText(value))
+		if err != nil {
+			return fmt.Errorf("invalid pattern: %w", err)
+		}
+		if !matched {
+			return fmt.Errorf("%v: expected to match %v, got %v", assertion.Path, pattern, toText(value))
+		}
+	}
+
+	return nil
+}
+
+func (runner *runner) capture(captures map[string]string, document any) (map[string]string, error) {
+	if len(captures) == 0 {
+		return nil, nil
+	}
+
+	captured := make(map[string]string)
+	for _, name := range slices.Sorted(maps.Keys(captures)) {
+		value, found, err := jsonpath.Get(document, captures[name])
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			return nil, fmt.Errorf("capture '%v': %v not found", name, captures[name])
+		}
+		captured[name] = toText(value)
+		runner.variables[name] = captured[name]
+	}
+
+	return captured, nil
+}
+
+// expand replaces ${name} with the value of the variable
+func (runner *runner) expand(text string) (string, error) {
+	var err error
+	expanded := variablePattern.ReplaceAllStringFunc(text, func(match string) string {
+		name := match[2 : len(match)-1]
+		value, ok := runner.variables[name]
+		if !ok {
+			err = fmt.Errorf("variable '%v' is not defined", name)
+		}
+		return value
+	})
+
+	return expanded, err
+}
+
+// expandMessage expands the fields of the message except the rendered body
+func (runner *runner) expandMessage(message asb.Message) (asb.Message, error) {
+	var err error
+	for _, value := range []*string{
+		&message.CorrelationID,
+		&message.MessageID,
+		&message.ReplayTo,
+		&message.Subject,
+	} {
+		*value, err = runner.expand(*value)
+		if err != nil {
+			return asb.Message{}, err
+		}
+	}
+
+	message.CustomProperties = maps.Clone(message.CustomProperties)
+	for key, value := range message.CustomProperties {
+		if text, ok := value.(string); ok {
+			message.CustomProperties[key], err = runner.expand(text)
+			if err != nil {
+				return asb.Message{}, err
+			}
+		}
+	}
+
+	return message, nil
+}
+
+// MessageDocument converts the message to the document JSON paths are evaluated on.
+// A JSON body is decoded, so paths like $.body.id can be used.
+func MessageDocument(message asb.ReceivedMessage) (map[string]any, error) {
+	document, err := toDocument(message)
+	if err != nil {
+		return nil, err
+	}
+
+	var body any
+	if json.Unmarshal([]byte(message.Body), &body) == nil {
+		document["body"] = body
+	}
+
+	return document, nil
+}
+
+func toDocument(value any) (map[string]any, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	document := make(map[string]any)
+	err = json.Unmarshal(encoded, &document)
+
+	return document, err
+}
+
+func toText(value any) string {
+	if text, ok := value.(string); ok {
+		return text
+	}
+	encoded, _ := json.Marshal(value)
+	return string(encoded)
+}
+
+func equalValues(actual any, expected any) bool {
+	var normalized any
+	encoded, err := json.Marshal(expected)
+	if err == nil && json.Unmarshal(encoded, &normalized) == nil && reflect.DeepEqual(actual, normalized) {
+		return true
+	}
+	return toText(actual) == toText(expected)
+}
+
+func valueOr(value int, defaultValue int) int {
+	if value > 0 {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,201 @@
+package scenario
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/rafalpienkowski/busgopher/internal/jsonpath"
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario is an ordered list of steps run against the service bus, written in YAML or JSON
+type Scenario struct {
+	Name string `yaml:"name"`
+	// Connection is the saved connection used by steps that don't set their own
+	Connection string            `yaml:"connection"`
+	Variables  map[string]string `yaml:"variables"`
+	Steps      []Step            `yaml:"steps"`
+}
+
+// Step does exactly one of send, wait, peek or receive
+type Step struct {
+	Name    string       `yaml:"name"`
+	Send    *SendStep    `yaml:"send"`
+	Wait    string       `yaml:"wait"`
+	Peek    *ReceiveStep `yaml:"peek"`
+	Receive *ReceiveStep `yaml:"receive"`
+
+	// Assert is checked on the sent result or on the received messages
+	Assert []Assertion `yaml:"assert"`
+	// Capture stores values found by JSON paths into variables used by later steps, as ${name} or,
+	// in bodies, as {{ var "name" }}
+	Capture map[string]string `yaml:"capture"`
+}
+
+type SendStep struct {
+	Connection string `yaml:"connection"`
+	// Namespace is used instead of a saved connection
+	Namespace   string `yaml:"namespace"`
+	Destination string `yaml:"destination"`
+	// Message is a saved message, the fields below override it or define an inline message
+	Message       string         `yaml:"message"`
+	Body          string         `yaml:"body"`
+	Subject       string         `yaml:"subject"`
+	MessageID     string         `yaml:"messageId"`
+	CorrelationID string         `yaml:"correlationId"`
+	ReplyTo       string         `yaml:"replyTo"`
+	Properties    map[string]any `yaml:"properties"`
+}
+
+type ReceiveStep struct {
+	Connection         string `yaml:"connection"`
+	Destination        string `yaml:"destination"`
+	Subscription       string `yaml:"subscription"`
+	DeadLetter         bool   `yaml:"deadLetter"`
+	Count              int    `yaml:"count"`
+	Wait               string `yaml:"wait"`
+	FromSequenceNumber int64  `yaml:"fromSequenceNumber"`
+}
+
+// Assertion checks the value at Path, every set condition must hold
+type Assertion struct {
+	Path     string  `yaml:"path"`
+	Equals   any     `yaml:"equals"`
+	Contains *string `yaml:"contains"`
+	Matches  *string `yaml:"matches"`
+	Exists   *bool   `yaml:"exists"`
+}
+
+// Kind returns the action of the step
+func (step Step) Kind() string {
+	switch {
+	case step.Send != nil:
+		return "send"
+	case step.Peek != nil:
+		return "peek"
+	case step.Receive != nil:
+		return "receive"
+	case len(step.Wait) > 0:
+		return "wait"
+	}
+	return ""
+}
+
+// Title returns the name of the step or describes it when it has no name
+func (step Step) Title() string {
+	if len(step.Name) > 0 {
+		return step.Name
+	}
+	switch step.Kind() {
+	case "send":
+		return "send to " + step.Send.Destination
+	case "peek":
+		return "peek " + step.Peek.Destination
+	case "receive":
+		return "receive " + step.Receive.Destination
+	}
+	return "wait " + step.Wait
+}
+
+// Parse reads and validates a scenario
+func Parse(data []byte) (Scenario, error) {
+	scenario := Scenario{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	err := decoder.Decode(&scenario)
+	if err != nil {
+		return Scenario{}, fmt.Errorf("invalid scenario: %w", err)
+	}
+
+	problems := []string{}
+	if len(scenario.Steps) == 0 {
+		problems = append(problems, "at least one step is required")
+	}
+	for i, step := range scenario.Steps {
+		for _, problem := range validateStep(step) {
+			problems = append(problems, fmt.Sprintf("step %v: %v", i+1, problem))
+		}
+	}
+	if len(problems) > 0 {
+		return Scenario{}, errors.New("invalid scenario: " + strings.Join(problems, "; "))
+	}
+
+	return scenario, nil
+}
+
+func validateStep(step Step) []string {
+	problems := []string{}
+	actions := 0
+	for _, set := range []bool{step.Send != nil, step.Peek != nil, step.Receive != nil, len(step.Wait) > 0} {
+		if set {
+			actions++
+		}
+	}
+	if actions != 1 {
+		return []string{"exactly one of send, wait, peek or receive is required"}
+	}
+
+	switch step.Kind() {
+	case "send":
+		if len(step.Send.Destination) == 0 {
+			problems = append(problems, "send destination is required")
+		}
+		if len(step.Send.Message) == 0 && len(step.Send.Body) == 0 {
+			problems = append(problems, "send message or body is required")
+		}
+		if strings.Contains(step.Send.Body, "${") {
+			problems = append(problems, `use {{ var "name" }} for variables in the body`)
+		}
+	case "peek", "receive":
+		receive := step.Peek
+		if receive == nil {
+			receive = step.Receive
+		}
+		if len(receive.Destination) == 0 {
+			problems = append(problems, step.Kind()+" destination is required")
+		}
+		if _, err := parseDuration(receive.Wait, 0); err != nil {
+			problems = append(problems, err.Error())
+		}
+	case "wait":
+		if len(step.Assert) > 0 || len(step.Capture) > 0 {
+			problems = append(problems, "wait can't have assertions or captures")
+		}
+		if _, err := parseDuration(step.Wait, 0); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	for _, assertion := range step.Assert {
+		if _, err := jsonpath.Parse(assertion.Path); err != nil {
+			problems = append(problems, err.Error())
+		}
+		if assertion.Matches != nil && !strings.Contains(*assertion.Matches, "${") {
+			if _, err := regexp.Compile(*assertion.Matches); err != nil {
+				problems = append(problems, "invalid pattern: "+err.Error())
+			}
+		}
+	}
+	for _, path := range step.Capture {
+		if _, err := jsonpath.Parse(path); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	return problems
+}
+
+func parseDuration(text string, defaultValue time.Duration) (time.Duration, error) {
+	if len(text) == 0 {
+		return defaultValue, nil
+	}
+	duration, err := time.ParseDuration(text)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration '%v'", text)
+	}
+	return duration, nil
+}
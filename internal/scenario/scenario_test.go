@@ -0,0 +1,214 @@
+package scenario
+
+import (
+	"testing"
+
+	"github.com/rafalpienkowski/busgopher/internal/asb"
+	"github.com/rafalpienkowski/busgopher/internal/config"
+	"github.com/rafalpienkowski/busgopher/internal/controller"
+	"github.com/stretchr/testify/assert"
+)
+
+func createTestController(t *testing.T) (*controller.Controller, *asb.InMemoryMessageSender, *asb.InMemoryMessageReceiver) {
+	sender := &asb.InMemoryMessageSender{}
+	receiver := &asb.InMemoryMessageReceiver{Messages: map[asb.Source][]asb.ReceivedMessage{
+		{Destination: "processed"}: {
+			{Message: asb.Message{Body: `{"orderId": "41", "status": "ignored"}`}, SequenceNumber: 1},
+			{Message: asb.Message{Body: `{"orderId": "42", "status": "done"}`, Subject: "processed"}, SequenceNumber: 2},
+		},
+	}}
+	controller, err := controller.NewController(
+		&config.InMemoryConfigStorage{Config: config.GetTestConfig()},
+		sender,
+		receiver,
+		&asb.InMemoryAdministrator{},
+		nil,
+		func(string) {},
+	)
+	assert.NoError(t, err)
+
+	return controller, sender, receiver
+}
+
+const testScenario = `
+name: order flow
+connection: test-connection
+variables:
+  orderId: "42"
+steps:
+  - name: send order
+    send:
+      destination: queue
+      body: '{"orderId": "{{ var "orderId" }}", "at": "{{ "now" }}"}'
+      properties:
+        tenant: ${tenant}
+    capture:
+      sentId: $.messageId
+  - wait: 1ms
+  - name: order processed
+    peek:
+      destination: processed
+    assert:
+      - path: $.body.orderId
+        equals: ${orderId}
+      - path: $.subject
+        exists: true
+    capture:
+      status: $.body.status
+      sequence: $.sequenceNumber
+  - name: status is done
+    peek:
+      destination: processed
+      fromSequenceNumber: 2
+    assert:
+      - path: $.body.status
+        matches: ^${status}$
+`
+
+func Test_Parse_Should_Read_Yaml_Scenario(t *testing.T) {
+	scenario, err := Parse([]byte(testScenario))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "order flow", scenario.Name)
+	assert.Len(t, scenario.Steps, 4)
+	assert.Equal(t, "send", scenario.Steps[0].Kind())
+	assert.Equal(t, "wait", scenario.Steps[1].Kind())
+	assert.Equal(t, "wait 1ms", scenario.Steps[1].Title())
+}
+
+func Test_Parse_Should_Read_Json_Scenario(t *testing.T) {
+	scenario, err := Parse([]byte(`{"steps": [{"receive": {"destination": "queue", "wait": "1s"}}]}`))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "receive queue", scenario.Steps[0].Title())
+}
+
+func Test_Parse_Should_Report_Invalid_Steps(t *testing.T) {
+	_, err := Parse([]byte(`
+steps:
+  - name: nothing
+  - send:
+      destination: queue
+  - send:
+      destination: queue
+      body: ${orderId}
+  - peek:
+      destination: queue
+    assert:
+      - path: body
+  - wait: soon
+`))
+
+	assert.EqualError(t, err, "invalid scenario: "+
+		"step 1: exactly one of send, wait, peek or receive is required; "+
+		"step 2: send message or body is required; "+
+		"step 3: use {{ var \"name\" }} for variables in the body; "+
+		"step 4: invalid path 'body': must start with $; "+
+		"step 5: invalid duration 'soon'")
+}
+
+func Test_Parse_Should_Reject_Unknown_Fields(t *testing.T) {
+	_, err := Parse([]byte(`steps: [{sned: {destination: queue}}]`))
+
+	assert.ErrorContains(t, err, "field sned not found")
+}
+
+func Test_Run_Should_Pass_Scenario(t *testing.T) {
+	controller, sender, _ := createTestController(t)
+	scenario, err := Parse([]byte(testScenario))
+	assert.NoError(t, err)
+
+	report := Run(controller, scenario, map[string]string{"tenant": "acme"})
+
+	assert.True(t, report.Passed, report)
+	assert.Equal(t, `{"orderId": "42", "at": "now"}`, sender.Message.Body)
+	assert.Equal(t, map[string]any{"tenant": "acme"}, sender.Message.CustomProperties)
+	assert.Equal(t, map[string]string{"sentId": sender.Message.MessageID}, report.Steps[0].Captured)
+	assert.Equal(t, map[string]string{"status": "done", "sequence": "2"}, report.Steps[2].Captured)
+	for _, step := range report.Steps {
+		assert.Equal(t, StatusPassed, step.Status, step.Name)
+	}
+}
+
+func Test_Run_Should_Stop_At_Failed_Step(t *testing.T) {
+	controller, _, _ := createTestController(t)
+	scenario, err := Parse([]byte(testScenario))
+	assert.NoError(t, err)
+
+	report := Run(controller, scenario, map[string]string{"tenant": "acme", "orderId": "43"})
+
+	assert.False(t, report.Passed)
+	assert.Equal(t, StatusPassed, report.Steps[0].Status)
+	assert.Equal(t, StatusPassed, report.Steps[1].Status)
+	assert.Equal(t, StatusFailed, report.Steps[2].Status)
+	assert.Equal(t, "$.body.orderId: expected 43, got 41 (none of 2 messages matched)", report.Steps[2].Error)
+	assert.Equal(t, StatusSkipped, report.Steps[3].Status)
+}
+
+func Test_Run_Should_Fail_On_Undefined_Variable(t *testing.T) {
+	controller, _, _ := createTestController(t)
+	scenario, err := Parse([]byte(testScenario))
+	assert.NoError(t, err)
+
+	report := Run(controller, scenario, nil)
+
+	assert.False(t, report.Passed)
+	assert.Equal(t, "variable 'tenant' is not defined", report.Steps[0].Error)
+}
+
+func Test_Run_Should_Receive_And_Remove_Messages(t *testing.T) {
+	controller, _, receiver := createTestController(t)
+	scenario, err := Parse([]byte(`
+connection: test-connection
+steps:
+  - receive:
+      destination: processed
+      wait: 1ms
+    assert:
+      - path: $.body.status
+        contains: ignore
+`))
+	assert.NoError(t, err)
+
+	report := Run(controller, scenario, nil)
+
+	assert.True(t, report.Passed, report)
+	assert.Len(t, receiver.Messages[asb.Source{Destination: "processed"}], 1)
+}
+
+func Test_Run_Should_Fail_When_No_Messages(t *testing.T) {
+	controller, _, _ := createTestController(t)
+	scenario, err := Parse([]byte(`{"connection": "test-connection", "steps": [{"peek": {"destination": "empty"}}]}`))
+	assert.NoError(t, err)
+
+	report := Run(controller, scenario, nil)
+
+	assert.Equal(t, "no messages in empty", report.Steps[0].Error)
+}
+
+func Test_Run_Should_Not_Render_Captured_Values(t *testing.T) {
+	controller, sender, receiver := createTestController(t)
+	receiver.Add(asb.Source{Destination: "incoming"}, asb.ReceivedMessage{
+		Message: asb.Message{Body: `{"id": "{{ uuid }}", "subject": "${other}"}`},
+	})
+	scenario, err := Parse([]byte(`
+connection: test-connection
+steps:
+  - peek:
+      destination: incoming
+    capture:
+      id: $.body.id
+      subject: $.body.subject
+  - send:
+      destination: queue
+      body: '{{ var "id" }}'
+      subject: ${subject}
+`))
+	assert.NoError(t, err)
+
+	report := Run(controller, scenario, nil)
+
+	assert.True(t, report.Passed, report)
+	assert.Equal(t, "{{ uuid }}", sender.Message.Body)
+	assert.Equal(t, "${other}", sender.Message.Subject)
+}
@@ -0,0 +1,91 @@
+package secret
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// keyringService is the service name under which secrets are looked up in the OS keyring
+const keyringService = "busgopher"
+
+type provider func(name string) (string, error)
+
+var providers = map[string]provider{
+	"env":     fromEnv,
+	"file":    fromFile,
+	"keyring": fromKeyring,
+}
+
+// IsReference reports whether the value points to a secret (env:NAME, file:/path or keyring:name)
+func IsReference(value string) bool {
+	scheme, name, found := strings.Cut(value, ":")
+	_, known := providers[scheme]
+
+	return found && known && len(name) > 0
+}
+
+// HasPlaintextKey reports whether the connection string holds a shared access key or signature instead
+// of referencing it. Connection strings without a key, like ones using a managed identity, are fine.
+func HasPlaintextKey(connectionString string) bool {
+	lower := strings.ToLower(connectionString)
+
+	return !IsReference(connectionString) &&
+		(strings.Contains(lower, "sharedaccesskey=") || strings.Contains(lower, "sharedaccesssignature="))
+}
+
+// Resolve returns the secret the value points to, other values are returned unchanged
+func Resolve(value string) (string, error) {
+	if !IsReference(value) {
+		return value, nil
+	}
+
+	scheme, name, _ := strings.Cut(value, ":")
+	resolved, err := providers[scheme](name)
+	if err != nil {
+		return "", fmt.Errorf("Can't resolve secret '%v': %w", value, err)
+	}
+
+	return resolved, nil
+}
+
+func fromEnv(name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", errors.New("environment variable is not set")
+	}
+	return value, nil
+}
+
+func fromFile(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
+
+func fromKeyring(name string) (string, error) {
+	var command *exec.Cmd
+	switch runtime.GOOS {
+	case "linux":
+		command = exec.Command("secret-tool", "lookup", "service", keyringService, "account", name)
+	case "darwin":
+		command = exec.Command("security", "find-generic-password", "-s", keyringService, "-a", name, "-w")
+	default:
+		return "", fmt.Errorf("keyring is not supported on %v", runtime.GOOS)
+	}
+
+	output, err := command.Output()
+	if err != nil {
+		return "", fmt.Errorf("keyring lookup failed: %w", err)
+	}
+	if len(output) == 0 {
+		return "", errors.New("secret not found in keyring")
+	}
+
+	return strings.TrimRight(string(output), "\r\n"), nil
+}
@@ -0,0 +1,52 @@
+package secret
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Resolve_Should_Return_Plain_Values_Unchanged(t *testing.T) {
+	for _, value := range []string{"", "plain", "unknown:value", "env:", "https://example.com"} {
+		resolved, err := Resolve(value)
+
+		assert.NoError(t, err)
+		assert.Equal(t, value, resolved)
+	}
+}
+
+func Test_Resolve_Should_Read_Environment_Variable(t *testing.T) {
+	t.Setenv("BUSGOPHER_TEST_SECRET", "from-env")
+
+	resolved, err := Resolve("env:BUSGOPHER_TEST_SECRET")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "from-env", resolved)
+}
+
+func Test_Resolve_Should_Fail_On_Missing_Environment_Variable(t *testing.T) {
+	_, err := Resolve("env:BUSGOPHER_TEST_MISSING")
+
+	assert.EqualError(t, err, "Can't resolve secret 'env:BUSGOPHER_TEST_MISSING': environment variable is not set")
+}
+
+func Test_Resolve_Should_Read_File_Without_Trailing_Newline(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret")
+	err := os.WriteFile(path, []byte("from-file\n"), 0600)
+	assert.NoError(t, err)
+
+	resolved, err := Resolve("file:" + path)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "from-file", resolved)
+}
+
+func Test_HasPlaintextKey_Should_Report_Keys_Only(t *testing.T) {
+	assert.True(t, HasPlaintextKey("Endpoint=sb://test/;SharedAccessKeyName=root;SharedAccessKey=abc"))
+	assert.True(t, HasPlaintextKey("Endpoint=sb://test/;SharedAccessSignature=SharedAccessSignature sr=x"))
+	assert.False(t, HasPlaintextKey("Endpoint=sb://test/;Authentication=Managed Identity"))
+	assert.False(t, HasPlaintextKey("env:CONNECTION_STRING"))
+	assert.False(t, HasPlaintextKey(""))
+}
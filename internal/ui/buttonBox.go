@@ -77,3 +77,8 @@ func (b *BoxButton) Blur() {
 func (b *BoxButton) HasFocus() bool {
 	return b.focused
 }
+
+func (b *BoxButton) SetLabel(label string) *BoxButton {
+	b.label = label
+	return b
+}
@@ -1,6 +1,7 @@
 package ui
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
@@ -16,34 +17,59 @@ type ConfigPage struct {
 	controller *controller.Controller
 	switchPage switchPageFunc
 	closeApp   closeAppFunc
+	confirm    confirmFunc
+	selectOpt  selectOptionFunc
 
 	flex   *tview.Flex
 	config *tview.TextArea
 	logs   *tview.TextView
 
 	sending       *BoxButton
+	editor        *BoxButton
 	defaultConfig *BoxButton
 	save          *BoxButton
+	validate      *BoxButton
+	reload        *BoxButton
+	restore       *BoxButton
 	close         *BoxButton
 
+	// config text as last loaded into the editor, to detect unsaved changes
+	loadedConfig string
+	// revision of the config the editor text is based on
+	loadedRevision int
+
 	inputs []tview.Primitive
 }
 
-func newConfigPage(theme Theme, closeApp closeAppFunc, switchPage switchPageFunc) *ConfigPage {
+func newConfigPage(
+	theme Theme,
+	closeApp closeAppFunc,
+	switchPage switchPageFunc,
+	confirm confirmFunc,
+	selectOpt selectOptionFunc,
+) *ConfigPage {
 
 	flex := tview.NewFlex()
 
 	config := tview.NewTextArea()
 	logs := tview.NewTextView()
 	sending := newBoxButton("To Sending Page")
+	editor := newBoxButton("To Editor")
 	defaultConfig := newBoxButton("Default config")
 	save := newBoxButton("Save")
+	validate := newBoxButton("Validate")
+	reload := newBoxButton("Reload")
+	restore := newBoxButton("Restore backup")
 	close := newBoxButton("Close")
 
 	inputs := []tview.Primitive{
 		config,
 		save,
+		validate,
+		reload,
+		restore,
 		defaultConfig,
+		editor,
 		sending,
 		close,
 	}
@@ -52,14 +78,20 @@ func newConfigPage(theme Theme, closeApp closeAppFunc, switchPage switchPageFunc
 		theme:      theme,
 		switchPage: switchPage,
 		closeApp:   closeApp,
+		confirm:    confirm,
+		selectOpt:  selectOpt,
 
 		flex:   flex,
 		config: config,
 		logs:   logs,
 
 		sending:       sending,
+		editor:        editor,
 		defaultConfig: defaultConfig,
 		save:          save,
+		validate:      validate,
+		reload:        reload,
+		restore:       restore,
 		close:         close,
 
 		inputs: inputs,
@@ -100,7 +132,11 @@ func (configPage *ConfigPage) setLayout() {
 	actions.
 		AddItem(tview.NewBox().SetBackgroundColor(configPage.theme.backgroundColor), 0, 1, false).
 		AddItem(configPage.save, configPage.save.GetWidth(), 0, false).
+		AddItem(configPage.validate, configPage.validate.GetWidth(), 0, false).
+		AddItem(configPage.reload, configPage.reload.GetWidth(), 0, false).
+		AddItem(configPage.restore, configPage.restore.GetWidth(), 0, false).
 		AddItem(configPage.defaultConfig, configPage.defaultConfig.GetWidth(), 0, false).
+		AddItem(configPage.editor, configPage.editor.GetWidth(), 0, false).
 		AddItem(configPage.sending, configPage.sending.GetWidth(), 0, false).
 		AddItem(configPage.close, configPage.close.GetWidth(), 0, false)
 
@@ -128,36 +164,150 @@ func (configPage *ConfigPage) setActions() {
 	configPage.sending.SetSelectedFunc(func() {
 		configPage.switchPage("sending")
 	})
+	configPage.editor.SetSelectedFunc(func() {
+		configPage.switchPage("editor")
+	})
 
 	configPage.defaultConfig.SetSelectedFunc(func() {
-        testConfig := config.GetTestConfig()
-        configPage.controller.Config = testConfig
-        configPage.refresh()
+		configPage.confirm(
+			"Replace the editor content with the default config? Unsaved changes will be lost.",
+			func() {
+				json, err := configPage.controller.GetDefaultConfigString()
+				if err != nil {
+					configPage.printError(err)
+					return
+				}
+				configPage.printConfig(json)
+			})
 	})
 
 	configPage.save.SetSelectedFunc(func() {
-		err := configPage.controller.SaveConfigJson(configPage.config.GetText())
-		if err != nil {
-			configPage.printError(err)
+		if configPage.loadedRevision != configPage.controller.GetConfigRevision() {
+			configPage.confirm(
+				"Config changed on disk since it was loaded into the editor. Overwrite the changes made on disk?",
+				configPage.saveConfig)
+			return
+		}
+		configPage.saveConfig()
+	})
+	configPage.validate.SetSelectedFunc(func() {
+		validationErrors := configPage.controller.ValidateConfigJson(configPage.config.GetText())
+		if len(validationErrors) == 0 {
+			configPage.printLog(fmt.Sprintf(
+				"[%v]: Info - Config is valid\n",
+				time.Now().Format("2006-01-02 15:04:05"),
+			))
+			return
 		}
+		configPage.printValidationErrors(validationErrors)
+	})
+	configPage.reload.SetSelectedFunc(func() {
+		configPage.confirm(
+			"Reload the config from disk? Unsaved changes will be lost.",
+			func() {
+				err := configPage.controller.ReloadConfig()
+				if err != nil {
+					configPage.printError(err)
+					return
+				}
+				configPage.refresh()
+			})
+	})
+	configPage.restore.SetSelectedFunc(func() {
+		configPage.restoreBackup()
 	})
 	configPage.close.SetSelectedFunc(func() {
 		configPage.closeApp()
 	})
 }
 
+func (configPage *ConfigPage) saveConfig() {
+	err := configPage.controller.SaveConfigJson(configPage.config.GetText())
+	var validationErrors config.ValidationErrors
+	if errors.As(err, &validationErrors) {
+		configPage.printValidationErrors(validationErrors)
+		return
+	}
+	if err != nil {
+		configPage.printError(err)
+		return
+	}
+	configPage.refresh()
+}
+
+func (configPage *ConfigPage) restoreBackup() {
+	backups, err := configPage.controller.GetConfigBackups()
+	if err != nil {
+		configPage.printError(err)
+		return
+	}
+	if len(backups) == 0 {
+		configPage.printError(errors.New("No backups available"))
+		return
+	}
+
+	options := []string{}
+	for _, backup := range backups {
+		options = append(options, fmt.Sprintf(
+			"#%v saved at %v",
+			backup.ID,
+			backup.ModTime.Format("2006-01-02 15:04:05"),
+		))
+	}
+
+	configPage.selectOpt("Restore backup", options, func(index int) {
+		backup := backups[index]
+		configPage.confirm(
+			fmt.Sprintf("Restore backup #%v? The current config will be kept as a backup.", backup.ID),
+			func() {
+				err := configPage.controller.RestoreConfigBackup(backup.ID)
+				if err != nil {
+					configPage.printError(err)
+					return
+				}
+				configPage.refresh()
+			})
+	})
+}
+
 func (configPage *ConfigPage) printConfig(currentConfig string) {
+	configPage.loadedConfig = currentConfig
+	configPage.loadedRevision = configPage.controller.GetConfigRevision()
 	configPage.config.SetText(currentConfig, false)
 }
 
+func (configPage *ConfigPage) hasUnsavedChanges() bool {
+	return configPage.config.GetText() != configPage.loadedConfig
+}
+
+// reloadData refreshes the editor unless it contains unsaved changes
+func (configPage *ConfigPage) reloadData() {
+	if configPage.hasUnsavedChanges() {
+		configPage.printError(errors.New(
+			"Config changed on disk, the editor has unsaved changes. Use Reload to load the new config",
+		))
+		return
+	}
+	configPage.refresh()
+}
+
 func (configPage *ConfigPage) printError(err error) {
 	configPage.printLog(fmt.Sprintf(
         "[red][%v]: [red]Error - [red]%v[-]\n",
 		time.Now().Format("2006-01-02 15:04:05"),
-		err.Error(),
+		tview.Escape(err.Error()),
 	))
 }
 
+// printValidationErrors logs every problem and moves the editor cursor to the first one
+func (configPage *ConfigPage) printValidationErrors(validationErrors config.ValidationErrors) {
+	for _, validationError := range validationErrors {
+		configPage.printError(validationError)
+	}
+	offset := int(validationErrors[0].Offset)
+	configPage.config.Select(offset, offset)
+}
+
 func (configPage *ConfigPage) printLog(logMsg string) {
 	fmt.Fprintf(configPage.logs, "%v", logMsg)
 
@@ -174,8 +324,12 @@ func (configPage *ConfigPage) setAfterDrawFunc(focusedElement tview.Primitive) {
 
 	configPage.config.SetBorderColor(tcell.ColorWhite)
 	configPage.sending.SetBorderColor(tcell.ColorWhite)
+	configPage.editor.SetBorderColor(tcell.ColorWhite)
 	configPage.defaultConfig.SetBorderColor(tcell.ColorWhite)
 	configPage.save.SetBorderColor(tcell.ColorWhite)
+	configPage.validate.SetBorderColor(tcell.ColorWhite)
+	configPage.reload.SetBorderColor(tcell.ColorWhite)
+	configPage.restore.SetBorderColor(tcell.ColorWhite)
 	configPage.close.SetBorderColor(tcell.ColorWhite)
 
 	switch focusedElement {
@@ -183,10 +337,18 @@ func (configPage *ConfigPage) setAfterDrawFunc(focusedElement tview.Primitive) {
 		configPage.config.SetBorderColor(tcell.ColorBlue)
 	case configPage.sending:
 		configPage.sending.SetBorderColor(tcell.ColorBlue)
+	case configPage.editor:
+		configPage.editor.SetBorderColor(tcell.ColorBlue)
 	case configPage.defaultConfig:
 		configPage.defaultConfig.SetBorderColor(tcell.ColorBlue)
 	case configPage.save:
 		configPage.save.SetBorderColor(tcell.ColorBlue)
+	case configPage.validate:
+		configPage.validate.SetBorderColor(tcell.ColorBlue)
+	case configPage.reload:
+		configPage.reload.SetBorderColor(tcell.ColorBlue)
+	case configPage.restore:
+		configPage.restore.SetBorderColor(tcell.ColorBlue)
 	case configPage.close:
 		configPage.close.SetBorderColor(tcell.ColorBlue)
 	}
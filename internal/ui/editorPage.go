@@ -0,0 +1,506 @@
+package ui
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+
+	"github.com/rafalpienkowski/busgopher/internal/asb"
+	"github.com/rafalpienkowski/busgopher/internal/controller"
+)
+
+const newConnectionItem = "+ New connection"
+const newMessageItem = "+ New message"
+
+type EditorPage struct {
+	theme      Theme
+	controller *controller.Controller
+	closeApp   closeAppFunc
+	switchPage switchPageFunc
+	confirm    confirmFunc
+
+	flex        *tview.Flex
+	connections *tview.List
+	messages    *tview.List
+	form        *tview.Form
+	errors      *tview.TextView
+	logs        *tview.TextView
+	rawJson     *BoxButton
+	sending     *BoxButton
+	close       *BoxButton
+
+	inputs []tview.Primitive
+
+	// revision of the config the open form is based on
+	formRevision int
+}
+
+func newEditorPage(
+	theme Theme,
+	closeApp closeAppFunc,
+	switchPage switchPageFunc,
+	confirm confirmFunc,
+) *EditorPage {
+
+	flex := tview.NewFlex()
+	connections := tview.NewList()
+	messages := tview.NewList()
+	form := tview.NewForm()
+	errors := tview.NewTextView()
+	logs := tview.NewTextView()
+	rawJson := newBoxButton("Raw JSON (advanced)")
+	sending := newBoxButton("To Sending Page")
+	close := newBoxButton("Close")
+
+	inputs := []tview.Primitive{
+		connections,
+		messages,
+		form,
+		rawJson,
+		sending,
+		close,
+	}
+
+	editorPage := EditorPage{
+		theme:       theme,
+		closeApp:    closeApp,
+		switchPage:  switchPage,
+		confirm:     confirm,
+		flex:        flex,
+		connections: connections,
+		messages:    messages,
+		form:        form,
+		errors:      errors,
+		logs:        logs,
+		rawJson:     rawJson,
+		sending:     sending,
+		close:       close,
+		inputs:      inputs,
+	}
+	editorPage.configureAppearence()
+	editorPage.setLayout()
+
+	return &editorPage
+}
+
+func (editorPage *EditorPage) configureAppearence() {
+
+	editorPage.connections.
+		ShowSecondaryText(false).
+		SetWrapAround(true).
+		SetHighlightFullLine(true).
+		SetTitle(" Connections: ").
+		SetBorder(true).
+		SetBackgroundColor(editorPage.theme.backgroundColor)
+	editorPage.connections.SetMainTextStyle(editorPage.theme.style)
+
+	editorPage.messages.
+		ShowSecondaryText(false).
+		SetWrapAround(true).
+		SetHighlightFullLine(true).
+		SetTitle(" Messages: ").
+		SetBorder(true).
+		SetBackgroundColor(editorPage.theme.backgroundColor)
+	editorPage.messages.SetMainTextStyle(editorPage.theme.style)
+
+	editorPage.form.
+		SetFieldBackgroundColor(tcell.ColorBlack).
+		SetButtonsAlign(tview.AlignRight).
+		SetBorder(true).
+		SetBackgroundColor(editorPage.theme.backgroundColor)
+
+	editorPage.errors.
+		SetDynamicColors(true).
+		SetBackgroundColor(editorPage.theme.backgroundColor)
+
+	editorPage.logs.
+		SetTitle(" Logs: ").
+		SetBorder(true)
+	editorPage.logs.SetDynamicColors(true)
+	editorPage.logs.SetBackgroundColor(editorPage.theme.backgroundColor)
+
+	editorPage.flex.
+		SetBorder(true).
+		SetBackgroundColor(editorPage.theme.backgroundColor).
+		SetTitle("Configuration editor")
+}
+
+func (editorPage *EditorPage) setLayout() {
+	left := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(editorPage.connections, 0, 1, true).
+		AddItem(editorPage.messages, 0, 1, false)
+
+	actions := tview.NewFlex()
+	actions.
+		AddItem(tview.NewBox().SetBackgroundColor(editorPage.theme.backgroundColor), 0, 1, false).
+		AddItem(editorPage.rawJson, editorPage.rawJson.GetWidth(), 0, false).
+		AddItem(editorPage.sending, editorPage.sending.GetWidth(), 0, false).
+		AddItem(editorPage.close, editorPage.close.GetWidth(), 0, false)
+
+	right := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(editorPage.form, 0, 3, false).
+		AddItem(editorPage.errors, 3, 0, false).
+		AddItem(actions, 3, 0, false).
+		AddItem(editorPage.logs, 0, 1, false)
+
+	editorPage.flex.
+		AddItem(left, 0, 1, false).
+		AddItem(right, 0, 3, false)
+}
+
+func (editorPage *EditorPage) loadData(controller *controller.Controller) {
+	editorPage.controller = controller
+	editorPage.setActions()
+	editorPage.refresh()
+}
+
+func (editorPage *EditorPage) refresh() {
+	editorPage.refreshConnections()
+	editorPage.refreshMessages()
+	editorPage.form.Clear(true)
+	editorPage.form.SetTitle("")
+	editorPage.errors.Clear()
+}
+
+func (editorPage *EditorPage) setActions() {
+	editorPage.rawJson.SetSelectedFunc(func() {
+		editorPage.switchPage("config")
+	})
+	editorPage.sending.SetSelectedFunc(func() {
+		editorPage.switchPage("sending")
+	})
+	editorPage.close.SetSelectedFunc(func() {
+		editorPage.closeApp()
+	})
+}
+
+func (editorPage *EditorPage) refreshConnections() {
+	editorPage.connections.Clear()
+	editorPage.connections.AddItem(newConnectionItem, "", 0, func() {
+		editorPage.editConnection("", asb.Connection{})
+	})
+
+	connections := editorPage.controller.GetConnections()
+	slices.SortFunc(connections, func(a, b controller.Connection) int { return strings.Compare(a.Name, b.Name) })
+	for _, conn := range connections {
+		editorPage.connections.AddItem(conn.Name, conn.Namespace, 0, func() {
+			editorPage.editConnection(conn.Name, editorPage.controller.Config.Connections[conn.Name])
+		})
+	}
+}
+
+func (editorPage *EditorPage) refreshMessages() {
+	editorPage.messages.Clear()
+	editorPage.messages.AddItem(newMessageItem, "", 0, func() {
+		editorPage.editMessage("", asb.Message{})
+	})
+
+	messages := editorPage.controller.GetMessages()
+	slices.SortFunc(messages, func(a, b controller.Message) int { return strings.Compare(a.Name, b.Name) })
+	for _, msg := range messages {
+		editorPage.messages.AddItem(msg.Name, msg.Message.Subject, 0, func() {
+			editorPage.editMessage(msg.Name, msg.Message)
+		})
+	}
+}
+
+// editConnection shows the connection form, an empty name means a new connection
+func (editorPage *EditorPage) editConnection(name string, connection asb.Connection) {
+	form := editorPage.form
+	form.Clear(true)
+	editorPage.errors.Clear()
+	editorPage.formRevision = editorPage.controller.GetConfigRevision()
+
+	if len(name) == 0 {
+		form.SetTitle(" New connection ")
+	} else {
+		form.SetTitle(" Connection: " + name + " ")
+	}
+
+	form.AddInputField("Name", name, 0, nil, nil)
+	form.AddInputField("Namespace", connection.Namespace, 0, nil, nil)
+	form.AddInputField("Connection string", connection.ConnectionString, 0, nil, nil)
+	// One empty row is always available to add a destination, clear a row to delete it
+	destinations := append(slices.Clone(connection.Destinations), "")
+	for i, destination := range destinations {
+		form.AddInputField(fmt.Sprintf("Destination %v", i+1), destination, 0, nil, nil)
+	}
+
+	save := func() {
+		edited := asb.Connection{
+			Namespace:        editorPage.getFieldText("Namespace"),
+			ConnectionString: editorPage.getFieldText("Connection string"),
+		}
+		for i := range destinations {
+			destination := editorPage.getFieldText(fmt.Sprintf("Destination %v", i+1))
+			if len(destination) > 0 {
+				edited.Destinations = append(edited.Destinations, destination)
+			}
+		}
+		newName := editorPage.getFieldText("Name")
+
+		var err error
+		if len(name) == 0 {
+			err = editorPage.controller.AddConnection(newName, edited)
+		} else {
+			err = editorPage.controller.UpdateConnection(name, newName, edited)
+		}
+		if err != nil {
+			editorPage.printFieldErrors(err)
+			return
+		}
+		editorPage.refreshConnections()
+		selectListItem(editorPage.connections, newName)
+		editorPage.editConnection(newName, edited)
+	}
+	form.AddButton("Save", func() {
+		editorPage.saveForm(save)
+	})
+
+	if len(name) == 0 {
+		return
+	}
+
+	form.AddButton("Duplicate", func() {
+		newName, err := editorPage.controller.DuplicateConnection(name)
+		if err != nil {
+			editorPage.printFieldErrors(err)
+			return
+		}
+		editorPage.refreshConnections()
+		selectListItem(editorPage.connections, newName)
+		editorPage.editConnection(newName, editorPage.controller.Config.Connections[newName])
+	})
+	form.AddButton("Delete", func() {
+		editorPage.confirm("Delete connection '"+name+"'?", func() {
+			err := editorPage.controller.DeleteConnection(name)
+			if err != nil {
+				editorPage.printFieldErrors(err)
+				return
+			}
+			editorPage.refresh()
+		})
+	})
+}
+
+// editMessage shows the message form, an empty name means a new message
+func (editorPage *EditorPage) editMessage(name string, message asb.Message) {
+	form := editorPage.form
+	form.Clear(true)
+	editorPage.errors.Clear()
+	editorPage.formRevision = editorPage.controller.GetConfigRevision()
+
+	if len(name) == 0 {
+		form.SetTitle(" New message ")
+	} else {
+		form.SetTitle(" Message: " + name + " ")
+	}
+
+	form.AddInputField("Name", name, 0, nil, nil)
+	form.AddInputField("Subject", message.Subject, 0, nil, nil)
+	form.AddInputField("Correlation ID", message.CorrelationID, 0, nil, nil)
+	form.AddInputField("Message ID", message.MessageID, 0, nil, nil)
+	form.AddInputField("Reply to", message.ReplayTo, 0, nil, nil)
+	form.AddInputField("Reply to session ID", message.ReplyToSessionID, 0, nil, nil)
+	form.AddInputField("Session ID", message.SessionID, 0, nil, nil)
+	form.AddTextArea("Body", message.Body, 0, 6, 0, nil)
+	// Properties are edited as key=value rows, one empty row is always available to add a property
+	properties := formatProperties(message.CustomProperties)
+	properties = append(properties, "")
+	for i, property := range properties {
+		form.AddInputField(fmt.Sprintf("Property %v", i+1), property, 0, nil, nil)
+	}
+
+	save := func() {
+		edited := asb.Message{
+			Subject:          editorPage.getFieldText("Subject"),
+			CorrelationID:    editorPage.getFieldText("Correlation ID"),
+			MessageID:        editorPage.getFieldText("Message ID"),
+			ReplayTo:         editorPage.getFieldText("Reply to"),
+			ReplyToSessionID: editorPage.getFieldText("Reply to session ID"),
+			SessionID:        editorPage.getFieldText("Session ID"),
+			Body:             form.GetFormItemByLabel("Body").(*tview.TextArea).GetText(),
+		}
+		rows := []string{}
+		for i := range properties {
+			rows = append(rows, editorPage.getFieldText(fmt.Sprintf("Property %v", i+1)))
+		}
+		customProperties, err := parseProperties(rows)
+		if err != nil {
+			editorPage.printFieldErrors(err)
+			return
+		}
+		edited.CustomProperties = customProperties
+		newName := editorPage.getFieldText("Name")
+
+		if len(name) == 0 {
+			err = editorPage.controller.AddMessage(newName, edited)
+		} else {
+			err = editorPage.controller.UpdateMessage(name, newName, edited)
+		}
+		if err != nil {
+			editorPage.printFieldErrors(err)
+			return
+		}
+		editorPage.refreshMessages()
+		selectListItem(editorPage.messages, newName)
+		editorPage.editMessage(newName, edited)
+	}
+	form.AddButton("Save", func() {
+		editorPage.saveForm(save)
+	})
+
+	if len(name) == 0 {
+		return
+	}
+
+	form.AddButton("Duplicate", func() {
+		newName, err := editorPage.controller.DuplicateMessage(name)
+		if err != nil {
+			editorPage.printFieldErrors(err)
+			return
+		}
+		editorPage.refreshMessages()
+		selectListItem(editorPage.messages, newName)
+		editorPage.editMessage(newName, editorPage.controller.Config.Messages[newName])
+	})
+	form.AddButton("Delete", func() {
+		editorPage.confirm("Delete message '"+name+"'?", func() {
+			err := editorPage.controller.DeleteMessage(name)
+			if err != nil {
+				editorPage.printFieldErrors(err)
+				return
+			}
+			editorPage.refresh()
+		})
+	})
+}
+
+// saveForm saves the form, after a confirmation when the config was reloaded since the form was opened
+func (editorPage *EditorPage) saveForm(save func()) {
+	if editorPage.formRevision == editorPage.controller.GetConfigRevision() {
+		save()
+		return
+	}
+	editorPage.confirm("Config changed on disk since the form was opened. Save the form over it?", save)
+}
+
+func (editorPage *EditorPage) getFieldText(label string) string {
+	return strings.TrimSpace(editorPage.form.GetFormItemByLabel(label).(*tview.InputField).GetText())
+}
+
+// formatProperties renders properties as key=value rows. Strings that would be
+// read back as another type are quoted.
+func formatProperties(properties map[string]any) []string {
+	rows := []string{}
+	for _, key := range slices.Sorted(maps.Keys(properties)) {
+		value := properties[key]
+		text, ok := value.(string)
+		if !ok || json.Valid([]byte(text)) {
+			encoded, _ := json.Marshal(value)
+			text = string(encoded)
+		}
+		rows = append(rows, key+"="+text)
+	}
+
+	return rows
+}
+
+// parseProperties reads key=value rows, see asb.ParsePropertyValue for the value types
+func parseProperties(rows []string) (map[string]any, error) {
+	errs := controller.FieldErrors{}
+	properties := make(map[string]any)
+	for i, row := range rows {
+		if len(row) == 0 {
+			continue
+		}
+		field := fmt.Sprintf("Property %v", i+1)
+
+		key, text, found := strings.Cut(row, "=")
+		key = strings.TrimSpace(key)
+		if !found || len(key) == 0 {
+			errs = append(errs, controller.FieldError{Field: field, Message: "expected key=value"})
+			continue
+		}
+		if _, ok := properties[key]; ok {
+			errs = append(errs, controller.FieldError{Field: field, Message: "property '" + key + "' is duplicated"})
+			continue
+		}
+
+		properties[key] = asb.ParsePropertyValue(strings.TrimSpace(text))
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	if len(properties) == 0 {
+		return nil, nil
+	}
+	return properties, nil
+}
+
+func (editorPage *EditorPage) printFieldErrors(err error) {
+	editorPage.errors.Clear()
+
+	var fieldErrors controller.FieldErrors
+	if errors.As(err, &fieldErrors) {
+		for _, fieldError := range fieldErrors {
+			fmt.Fprintf(editorPage.errors, "[red]%v:[-] %v\n", fieldError.Field, tview.Escape(fieldError.Message))
+		}
+		return
+	}
+
+	editorPage.printError(err)
+}
+
+func (editorPage *EditorPage) printError(err error) {
+	editorPage.printLog(fmt.Sprintf(
+		"[red][%v]: [red] Error - [red]%v[-]\n",
+		time.Now().Format("2006-01-02 15:04:05"),
+		tview.Escape(err.Error()),
+	))
+}
+
+func (editorPage *EditorPage) printLog(logMsg string) {
+	fmt.Fprintf(editorPage.logs, "%v", logMsg)
+
+	getAvailableRows := func() int {
+		_, _, _, height := editorPage.logs.GetRect()
+
+		return height - 2 // Minus border
+	}
+
+	editorPage.logs.SetMaxLines(getAvailableRows())
+}
+
+func (editorPage *EditorPage) setAfterDrawFunc(focusedElement tview.Primitive) {
+	editorPage.connections.SetBorderColor(tcell.ColorWhite)
+	editorPage.messages.SetBorderColor(tcell.ColorWhite)
+	editorPage.form.SetBorderColor(tcell.ColorWhite)
+	editorPage.rawJson.SetBorderColor(tcell.ColorWhite)
+	editorPage.sending.SetBorderColor(tcell.ColorWhite)
+	editorPage.close.SetBorderColor(tcell.ColorWhite)
+
+	switch focusedElement {
+	case editorPage.connections:
+		editorPage.connections.SetBorderColor(tcell.ColorBlue)
+	case editorPage.messages:
+		editorPage.messages.SetBorderColor(tcell.ColorBlue)
+	case editorPage.rawJson:
+		editorPage.rawJson.SetBorderColor(tcell.ColorBlue)
+	case editorPage.sending:
+		editorPage.sending.SetBorderColor(tcell.ColorBlue)
+	case editorPage.close:
+		editorPage.close.SetBorderColor(tcell.ColorBlue)
+	}
+	if editorPage.form.HasFocus() {
+		editorPage.form.SetBorderColor(tcell.ColorBlue)
+	}
+}
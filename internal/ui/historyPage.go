@@ -0,0 +1,351 @@
+package ui
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+
+	"github.com/rafalpienkowski/busgopher/internal/controller"
+	"github.com/rafalpienkowski/busgopher/internal/history"
+)
+
+// maxDiffOptions limits the sends offered to compare the selected one with
+const maxDiffOptions = 20
+
+type HistoryPage struct {
+	theme        Theme
+	controller   *controller.Controller
+	closeApp     closeAppFunc
+	switchPage   switchPageFunc
+	confirm      confirmFunc
+	selectOption selectOptionFunc
+	queueUpdate  queueUpdateFunc
+
+	flex    *tview.Flex
+	search  *tview.InputField
+	entries *tview.List
+	content *tview.TextView
+	logs    *tview.TextView
+	diff    *BoxButton
+	resend  *BoxButton
+	sending *BoxButton
+	close   *BoxButton
+
+	inputs []tview.Primitive
+
+	// shown sends, the most recent first
+	shown []history.Entry
+}
+
+func newHistoryPage(
+	theme Theme,
+	closeApp closeAppFunc,
+	switchPage switchPageFunc,
+	confirm confirmFunc,
+	selectOption selectOptionFunc,
+	queueUpdate queueUpdateFunc,
+) *HistoryPage {
+
+	flex := tview.NewFlex()
+	search := tview.NewInputField()
+	entries := tview.NewList()
+	content := tview.NewTextView()
+	logs := tview.NewTextView()
+	diff := newBoxButton("Diff")
+	resend := newBoxButton("Re-send")
+	sending := newBoxButton("To Sending")
+	close := newBoxButton("Close")
+
+	inputs := []tview.Primitive{
+		search,
+		entries,
+		content,
+		diff,
+		resend,
+		sending,
+		close,
+	}
+
+	historyPage := HistoryPage{
+		theme:        theme,
+		closeApp:     closeApp,
+		switchPage:   switchPage,
+		confirm:      confirm,
+		selectOption: selectOption,
+		queueUpdate:  queueUpdate,
+		flex:         flex,
+		search:       search,
+		entries:      entries,
+		content:      content,
+		logs:         logs,
+		diff:         diff,
+		resend:       resend,
+		sending:      sending,
+		close:        close,
+		inputs:       inputs,
+	}
+	historyPage.configureAppearence()
+	historyPage.setLayout()
+
+	return &historyPage
+}
+
+func (historyPage *HistoryPage) configureAppearence() {
+	historyPage.search.
+		SetFieldBackgroundColor(tcell.ColorBlack).
+		SetTitle(" Search: ").
+		SetBorder(true).
+		SetBackgroundColor(historyPage.theme.backgroundColor)
+
+	historyPage.entries.
+		SetWrapAround(true).
+		SetHighlightFullLine(true).
+		SetTitle(" Sent messages: ").
+		SetBorder(true).
+		SetBackgroundColor(historyPage.theme.backgroundColor)
+	historyPage.entries.SetMainTextStyle(historyPage.theme.style)
+
+	historyPage.content.
+		SetDynamicColors(true).
+		SetTitle(" Content: ").
+		SetBorder(true).
+		SetBackgroundColor(historyPage.theme.backgroundColor)
+
+	historyPage.logs.
+		SetDynamicColors(true).
+		SetTitle(" Logs: ").
+		SetBorder(true).
+		SetBackgroundColor(historyPage.theme.backgroundColor)
+
+	historyPage.flex.
+		SetBorder(true).
+		SetBackgroundColor(historyPage.theme.backgroundColor).
+		SetTitle("Send history")
+}
+
+func (historyPage *HistoryPage) setLayout() {
+	left := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(historyPage.search, 3, 0, true).
+		AddItem(historyPage.entries, 0, 1, false)
+
+	actions := tview.NewFlex()
+	actions.
+		AddItem(tview.NewBox().SetBackgroundColor(historyPage.theme.backgroundColor), 0, 1, false).
+		AddItem(historyPage.diff, historyPage.diff.GetWidth(), 0, false).
+		AddItem(historyPage.resend, historyPage.resend.GetWidth(), 0, false).
+		AddItem(historyPage.sending, historyPage.sending.GetWidth(), 0, false).
+		AddItem(historyPage.close, historyPage.close.GetWidth(), 0, false)
+
+	right := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(historyPage.content, 0, 3, false).
+		AddItem(actions, 3, 0, false).
+		AddItem(historyPage.logs, 0, 1, false)
+
+	historyPage.flex.
+		AddItem(left, 0, 1, false).
+		AddItem(right, 0, 2, false)
+}
+
+func (historyPage *HistoryPage) loadData(controller *controller.Controller) {
+	historyPage.controller = controller
+	historyPage.setActions()
+}
+
+// refresh reloads the sends matching the search
+func (historyPage *HistoryPage) refresh() {
+	entries, err := historyPage.controller.GetHistory(historyPage.search.GetText())
+	if err != nil {
+		historyPage.printError(err)
+		return
+	}
+
+	historyPage.shown = entries
+	historyPage.entries.Clear()
+	historyPage.content.Clear()
+	for _, entry := range entries {
+		historyPage.entries.AddItem(entryTitle(entry), entryDescription(entry), 0, nil)
+	}
+}
+
+func (historyPage *HistoryPage) setActions() {
+	historyPage.search.SetDoneFunc(func(key tcell.Key) {
+		historyPage.refresh()
+	})
+	historyPage.entries.SetChangedFunc(func(index int, mainText string, secondaryText string, shortcut rune) {
+		historyPage.printEntry(index)
+	})
+	historyPage.diff.SetSelectedFunc(historyPage.diffEntries)
+	historyPage.resend.SetSelectedFunc(historyPage.resendEntry)
+	historyPage.sending.SetSelectedFunc(func() {
+		historyPage.switchPage("sending")
+	})
+	historyPage.close.SetSelectedFunc(func() {
+		historyPage.closeApp()
+	})
+}
+
+func (historyPage *HistoryPage) selected() (history.Entry, error) {
+	index := historyPage.entries.GetCurrentItem()
+	if index < 0 || index >= len(historyPage.shown) {
+		return history.Entry{}, errors.New("Sent message not selected!")
+	}
+	return historyPage.shown[index], nil
+}
+
+// diffEntries compares the selected send with one of the other shown sends
+func (historyPage *HistoryPage) diffEntries() {
+	selected, err := historyPage.selected()
+	if err != nil {
+		historyPage.printError(err)
+		return
+	}
+	others := []history.Entry{}
+	options := []string{}
+	for i, entry := range historyPage.shown {
+		if len(others) == maxDiffOptions {
+			break
+		}
+		if i == historyPage.entries.GetCurrentItem() {
+			continue
+		}
+		others = append(others, entry)
+		options = append(options, entryTitle(entry)+" "+entryDescription(entry))
+	}
+	if len(others) == 0 {
+		historyPage.printError(errors.New("No other sent messages to compare with!"))
+		return
+	}
+
+	historyPage.selectOption("Compare with", options, func(index int) {
+		lines := history.Diff(others[index], selected)
+		historyPage.content.Clear()
+		historyPage.content.SetTitle(" Diff: ")
+		if len(lines) == 0 {
+			fmt.Fprintln(historyPage.content, "Same message")
+			return
+		}
+		for _, line := range lines {
+			switch {
+			case strings.HasPrefix(line, "- "):
+				fmt.Fprintf(historyPage.content, "[red]%v[-]\n", tview.Escape(line))
+			case strings.HasPrefix(line, "+ "):
+				fmt.Fprintf(historyPage.content, "[green]%v[-]\n", tview.Escape(line))
+			default:
+				fmt.Fprintln(historyPage.content, tview.Escape(line))
+			}
+		}
+	})
+}
+
+// resendEntry sends the selected message again, as it was sent, after a confirmation
+func (historyPage *HistoryPage) resendEntry() {
+	selected, err := historyPage.selected()
+	if err != nil {
+		historyPage.printError(err)
+		return
+	}
+
+	namespace := selected.Namespace
+	if len(namespace) == 0 {
+		namespace = "connection " + selected.Connection
+	}
+	question := fmt.Sprintf("Send the message again to %v in %v?", selected.Destination, namespace)
+	historyPage.confirm(question, func() {
+		snapshot := historyPage.controller.Snapshot()
+		go func() {
+			_, err := snapshot.Resend(selected)
+			historyPage.queueUpdate(func() {
+				if err != nil {
+					historyPage.printError(err)
+				}
+				historyPage.refresh()
+			})
+		}()
+	})
+}
+
+func (historyPage *HistoryPage) printEntry(index int) {
+	if index < 0 || index >= len(historyPage.shown) {
+		return
+	}
+	encoded, err := json.Marshal(historyPage.shown[index])
+	if err != nil {
+		historyPage.printError(err)
+		return
+	}
+	colorized, err := colorizeJSON(string(encoded))
+	if err != nil {
+		historyPage.printError(err)
+		return
+	}
+	historyPage.content.Clear()
+	historyPage.content.SetTitle(" Content: ")
+	fmt.Fprintf(historyPage.content, "%v", colorized)
+}
+
+func entryTitle(entry history.Entry) string {
+	result := "✔"
+	if entry.Result == history.ResultFailed {
+		result = "✘"
+	}
+	return fmt.Sprintf("%v %v %v", result, entry.Time.Local().Format("2006-01-02 15:04:05"), entry.Destination)
+}
+
+func entryDescription(entry history.Entry) string {
+	name := entry.MessageName
+	if len(name) == 0 {
+		name = "ad-hoc"
+	}
+	target := entry.Connection
+	if len(target) == 0 {
+		target = entry.Namespace
+	}
+	return name + " via " + target
+}
+
+func (historyPage *HistoryPage) printError(err error) {
+	historyPage.printLog(fmt.Sprintf(
+		"[red][%v]: [red] Error - [red]%v[-]\n",
+		time.Now().Format("2006-01-02 15:04:05"),
+		tview.Escape(err.Error()),
+	))
+}
+
+func (historyPage *HistoryPage) printLog(logMsg string) {
+	fmt.Fprintf(historyPage.logs, "%v", logMsg)
+
+	_, _, _, height := historyPage.logs.GetRect()
+	historyPage.logs.SetMaxLines(height - 2)
+}
+
+func (historyPage *HistoryPage) setAfterDrawFunc(focusedElement tview.Primitive) {
+	historyPage.search.SetBorderColor(tcell.ColorWhite)
+	historyPage.entries.SetBorderColor(tcell.ColorWhite)
+	historyPage.content.SetBorderColor(tcell.ColorWhite)
+	historyPage.diff.SetBorderColor(tcell.ColorWhite)
+	historyPage.resend.SetBorderColor(tcell.ColorWhite)
+	historyPage.sending.SetBorderColor(tcell.ColorWhite)
+	historyPage.close.SetBorderColor(tcell.ColorWhite)
+
+	switch focusedElement {
+	case historyPage.search:
+		historyPage.search.SetBorderColor(tcell.ColorBlue)
+	case historyPage.entries:
+		historyPage.entries.SetBorderColor(tcell.ColorBlue)
+	case historyPage.content:
+		historyPage.content.SetBorderColor(tcell.ColorBlue)
+	case historyPage.diff:
+		historyPage.diff.SetBorderColor(tcell.ColorBlue)
+	case historyPage.resend:
+		historyPage.resend.SetBorderColor(tcell.ColorBlue)
+	case historyPage.sending:
+		historyPage.sending.SetBorderColor(tcell.ColorBlue)
+	case historyPage.close:
+		historyPage.close.SetBorderColor(tcell.ColorBlue)
+	}
+}
@@ -0,0 +1,670 @@
+package ui
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+
+	"github.com/rafalpienkowski/busgopher/internal/asb"
+	"github.com/rafalpienkowski/busgopher/internal/controller"
+	"github.com/rafalpienkowski/busgopher/internal/filter"
+)
+
+// tailInterval is how often the live view checks for new messages
+const tailInterval = 2 * time.Second
+
+// purgeParallel is the number of parallel receivers purging messages
+const purgeParallel = 4
+
+// maxShownMessages limits the messages kept in the list while tailing
+const maxShownMessages = 1000
+
+type ReceivingPage struct {
+	theme        Theme
+	controller   *controller.Controller
+	closeApp     closeAppFunc
+	switchPage   switchPageFunc
+	confirm      confirmFunc
+	input        inputFunc
+	selectOption selectOptionFunc
+	queueUpdate  queueUpdateFunc
+
+	flex     *tview.Flex
+	source   *tview.Form
+	messages *tview.List
+	content  *tview.TextView
+	logs     *tview.TextView
+	peek     *BoxButton
+	receive  *BoxButton
+	tail     *BoxButton
+	lock     *BoxButton
+	settle   *BoxButton
+	state    *BoxButton
+	save     *BoxButton
+	purge    *BoxButton
+	sending  *BoxButton
+	close    *BoxButton
+
+	inputs []tview.Primitive
+
+	received []asb.ReceivedMessage
+	stopTail func()
+}
+
+func newReceivingPage(
+	theme Theme,
+	closeApp closeAppFunc,
+	switchPage switchPageFunc,
+	confirm confirmFunc,
+	input inputFunc,
+	selectOption selectOptionFunc,
+	queueUpdate queueUpdateFunc,
+) *ReceivingPage {
+
+	flex := tview.NewFlex()
+	source := tview.NewForm()
+	messages := tview.NewList()
+	content := tview.NewTextView()
+	logs := tview.NewTextView()
+	peek := newBoxButton("Peek")
+	receive := newBoxButton("Receive")
+	tail := newBoxButton("Tail")
+	lock := newBoxButton("Lock")
+	settle := newBoxButton("Settle")
+	state := newBoxButton("State")
+	save := newBoxButton("Save as")
+	purge := newBoxButton("Purge")
+	sending := newBoxButton("To Sending")
+	close := newBoxButton("Close")
+
+	inputs := []tview.Primitive{
+		source,
+		messages,
+		content,
+		peek,
+		receive,
+		tail,
+		lock,
+		settle,
+		state,
+		save,
+		purge,
+		sending,
+		close,
+	}
+
+	receivingPage := ReceivingPage{
+		theme:        theme,
+		closeApp:     closeApp,
+		switchPage:   switchPage,
+		confirm:      confirm,
+		input:        input,
+		selectOption: selectOption,
+		queueUpdate:  queueUpdate,
+		flex:         flex,
+		source:       source,
+		messages:     messages,
+		content:      content,
+		logs:         logs,
+		peek:         peek,
+		receive:      receive,
+		tail:         tail,
+		lock:         lock,
+		settle:       settle,
+		state:        state,
+		save:         save,
+		purge:        purge,
+		sending:      sending,
+		close:        close,
+		inputs:       inputs,
+	}
+	receivingPage.configureAppearence()
+	receivingPage.setLayout()
+
+	return &receivingPage
+}
+
+func (receivingPage *ReceivingPage) configureAppearence() {
+	receivingPage.source.
+		AddInputField("Destination", "", 0, nil, nil).
+		AddInputField("Subscription", "", 0, nil, nil).
+		AddCheckbox("Dead letter", false, nil).
+		AddInputField("Session", "", 0, nil, nil).
+		AddInputField("Count", "10", 0, tview.InputFieldInteger, nil).
+		AddInputField("Search", "", 0, nil, nil).
+		SetFieldBackgroundColor(tcell.ColorBlack).
+		SetTitle(" Source: ").
+		SetBorder(true).
+		SetBackgroundColor(receivingPage.theme.backgroundColor)
+
+	receivingPage.messages.
+		SetWrapAround(true).
+		SetHighlightFullLine(true).
+		SetTitle(" Messages: ").
+		SetBorder(true).
+		SetBackgroundColor(receivingPage.theme.backgroundColor)
+	receivingPage.messages.SetMainTextStyle(receivingPage.theme.style)
+
+	receivingPage.content.
+		SetDynamicColors(true).
+		SetTitle(" Content: ").
+		SetBorder(true).
+		SetBackgroundColor(receivingPage.theme.backgroundColor)
+
+	receivingPage.logs.
+		SetDynamicColors(true).
+		SetTitle(" Logs: ").
+		SetBorder(true).
+		SetBackgroundColor(receivingPage.theme.backgroundColor)
+
+	receivingPage.flex.
+		SetBorder(true).
+		SetBackgroundColor(receivingPage.theme.backgroundColor).
+		SetTitle("Receiving messages")
+}
+
+func (receivingPage *ReceivingPage) setLayout() {
+	left := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(receivingPage.source, 15, 0, true).
+		AddItem(receivingPage.messages, 0, 1, false)
+
+	actions := tview.NewFlex()
+	actions.
+		AddItem(tview.NewBox().SetBackgroundColor(receivingPage.theme.backgroundColor), 0, 1, false).
+		AddItem(receivingPage.peek, receivingPage.peek.GetWidth(), 0, false).
+		AddItem(receivingPage.receive, receivingPage.receive.GetWidth(), 0, false).
+		AddItem(receivingPage.tail, len("Stop tail")+4, 0, false).
+		AddItem(receivingPage.lock, receivingPage.lock.GetWidth(), 0, false).
+		AddItem(receivingPage.settle, receivingPage.settle.GetWidth(), 0, false).
+		AddItem(receivingPage.state, receivingPage.state.GetWidth(), 0, false).
+		AddItem(receivingPage.save, receivingPage.save.GetWidth(), 0, false).
+		AddItem(receivingPage.purge, receivingPage.purge.GetWidth(), 0, false).
+		AddItem(receivingPage.sending, receivingPage.sending.GetWidth(), 0, false).
+		AddItem(receivingPage.close, receivingPage.close.GetWidth(), 0, false)
+
+	right := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(receivingPage.content, 0, 3, false).
+		AddItem(actions, 3, 0, false).
+		AddItem(receivingPage.logs, 0, 1, false)
+
+	receivingPage.flex.
+		AddItem(left, 0, 1, false).
+		AddItem(right, 0, 2, false)
+}
+
+func (receivingPage *ReceivingPage) loadData(controller *controller.Controller) {
+	receivingPage.controller = controller
+	receivingPage.setActions()
+}
+
+// refresh prefills the destination with the one selected on the sending page
+func (receivingPage *ReceivingPage) refresh() {
+	destination := receivingPage.source.GetFormItemByLabel("Destination").(*tview.InputField)
+	if selected := receivingPage.controller.GetSelectedDestination(); len(selected) > 0 {
+		destination.SetText(selected)
+	}
+	receivingPage.flex.SetTitle("Receiving messages: " + receivingPage.controller.GetSelectedConnectionName())
+}
+
+func (receivingPage *ReceivingPage) setActions() {
+	receivingPage.messages.SetChangedFunc(func(index int, mainText string, secondaryText string, shortcut rune) {
+		receivingPage.printMessage(index)
+	})
+	receivingPage.peek.SetSelectedFunc(func() {
+		receivingPage.load(func(source asb.Source, count int, messageFilter filter.Filter) ([]asb.ReceivedMessage, error) {
+			return receivingPage.controller.PeekMatching(source, count, 0, messageFilter)
+		})
+	})
+	receivingPage.receive.SetSelectedFunc(func() {
+		receivingPage.load(func(source asb.Source, count int, messageFilter filter.Filter) ([]asb.ReceivedMessage, error) {
+			return receivingPage.controller.ReceiveMatching(source, count, 5*time.Second, messageFilter)
+		})
+	})
+	receivingPage.tail.SetSelectedFunc(receivingPage.toggleTail)
+	receivingPage.lock.SetSelectedFunc(receivingPage.lockMessages)
+	receivingPage.settle.SetSelectedFunc(receivingPage.settleMessage)
+	receivingPage.state.SetSelectedFunc(receivingPage.editSessionState)
+	receivingPage.save.SetSelectedFunc(receivingPage.saveMessage)
+	receivingPage.purge.SetSelectedFunc(receivingPage.purgeMessages)
+	receivingPage.sending.SetSelectedFunc(func() {
+		receivingPage.leave()
+		receivingPage.switchPage("sending")
+	})
+	receivingPage.close.SetSelectedFunc(func() {
+		receivingPage.leave()
+		receivingPage.closeApp()
+	})
+}
+
+// leave stops tailing and abandons the messages still locked
+func (receivingPage *ReceivingPage) leave() {
+	receivingPage.stop()
+	if err := receivingPage.controller.ReleaseLocked(); err != nil {
+		receivingPage.printError(err)
+	}
+}
+
+// getSource reads the source, the count and the filter from the form, the filter is parsed from
+// the search query, see filter.ParseQuery. The session * accepts the next available session.
+func (receivingPage *ReceivingPage) getSource() (asb.Source, int, filter.Filter, error) {
+	text := func(label string) string {
+		return receivingPage.source.GetFormItemByLabel(label).(*tview.InputField).GetText()
+	}
+	count, err := strconv.Atoi(text("Count"))
+	if err != nil || count <= 0 {
+		count = 10
+	}
+	messageFilter, err := filter.ParseQuery(text("Search"), time.Now())
+	if err != nil {
+		return asb.Source{}, 0, filter.Filter{}, err
+	}
+
+	source := asb.Source{
+		Destination:  text("Destination"),
+		Subscription: text("Subscription"),
+		DeadLetter:   receivingPage.source.GetFormItemByLabel("Dead letter").(*tview.Checkbox).IsChecked(),
+		SessionID:    text("Session"),
+	}
+	if source.SessionID == "*" {
+		source.SessionID = ""
+		source.NextSession = true
+	}
+
+	return source, count, messageFilter, nil
+}
+
+// load replaces the shown messages with the ones returned in the background by get
+func (receivingPage *ReceivingPage) load(
+	get func(source asb.Source, count int, messageFilter filter.Filter) ([]asb.ReceivedMessage, error),
+) {
+	source, count, messageFilter, err := receivingPage.getSource()
+	if err != nil {
+		receivingPage.printError(err)
+		return
+	}
+	go func() {
+		messages, err := get(source, count, messageFilter)
+		receivingPage.queueUpdate(func() {
+			if err != nil {
+				receivingPage.printError(err)
+			}
+			// Messages received before a failure are removed already, they are shown anyway
+			if err != nil && len(messages) == 0 {
+				return
+			}
+			receivingPage.clearMessages()
+			receivingPage.addMessages(messages)
+		})
+	}()
+}
+
+// toggleTail starts or stops following new messages of the source
+func (receivingPage *ReceivingPage) toggleTail() {
+	if receivingPage.stop() {
+		return
+	}
+
+	source, _, messageFilter, err := receivingPage.getSource()
+	if err != nil {
+		receivingPage.printError(err)
+		return
+	}
+	stop, err := receivingPage.controller.Tail(
+		source,
+		0,
+		tailInterval,
+		messageFilter,
+		func(messages []asb.ReceivedMessage) {
+			receivingPage.queueUpdate(func() {
+				receivingPage.addMessages(messages)
+			})
+		},
+		func(err error) {
+			receivingPage.queueUpdate(func() {
+				receivingPage.printError(err)
+			})
+		},
+	)
+	if err != nil {
+		receivingPage.printError(err)
+		return
+	}
+	receivingPage.clearMessages()
+	receivingPage.stopTail = stop
+	receivingPage.tail.SetLabel("Stop tail")
+}
+
+// stop stops tailing and tells whether it was running
+func (receivingPage *ReceivingPage) stop() bool {
+	if receivingPage.stopTail == nil {
+		return false
+	}
+	// Stopping waits for the running peek, pending updates must not be blocked meanwhile
+	go receivingPage.stopTail()
+	receivingPage.stopTail = nil
+	receivingPage.tail.SetLabel("Tail")
+
+	return true
+}
+
+// lockMessages receives the next messages, or the deferred ones with the entered sequence
+// numbers, in peek-lock mode. The previously locked messages are abandoned.
+func (receivingPage *ReceivingPage) lockMessages() {
+	receivingPage.selectOption(
+		"Lock messages",
+		[]string{"Receive next messages", "Retrieve deferred messages"},
+		func(option int) {
+			if option == 0 {
+				receivingPage.load(func(source asb.Source, count int, messageFilter filter.Filter) ([]asb.ReceivedMessage, error) {
+					if err := receivingPage.controller.ReleaseLocked(); err != nil {
+						return nil, err
+					}
+					return receivingPage.controller.ReceiveLocked(source, count, 5*time.Second)
+				})
+				return
+			}
+
+			receivingPage.input("Retrieve deferred messages", []string{"Sequence numbers (n, ...)"}, []string{""}, func(values []string) {
+				sequenceNumbers := []int64{}
+				for _, part := range strings.Split(values[0], ",") {
+					if len(strings.TrimSpace(part)) == 0 {
+						continue
+					}
+					sequenceNumber, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+					if err != nil {
+						receivingPage.printError(fmt.Errorf("Invalid sequence number: %v", part))
+						return
+					}
+					sequenceNumbers = append(sequenceNumbers, sequenceNumber)
+				}
+				receivingPage.load(func(source asb.Source, count int, messageFilter filter.Filter) ([]asb.ReceivedMessage, error) {
+					if err := receivingPage.controller.ReleaseLocked(); err != nil {
+						return nil, err
+					}
+					return receivingPage.controller.ReceiveDeferred(source, sequenceNumbers)
+				})
+			})
+		},
+	)
+}
+
+// settleMessage completes, abandons, defers or dead-letters the selected locked message. Abandoned,
+// deferred and dead-lettered messages can get modified properties.
+func (receivingPage *ReceivingPage) settleMessage() {
+	index := receivingPage.messages.GetCurrentItem()
+	if index < 0 || index >= len(receivingPage.received) {
+		receivingPage.printError(errors.New("Message not selected!"))
+		return
+	}
+	sequenceNumber := receivingPage.received[index].SequenceNumber
+
+	receivingPage.selectOption(
+		fmt.Sprintf("Settle message #%v", sequenceNumber),
+		[]string{"Complete", "Abandon", "Defer", "Dead-letter"},
+		func(option int) {
+			settlement := asb.Settlement{Action: asb.SettleActions[option]}
+			labels := []string{"Properties (name=value, ...)"}
+			switch settlement.Action {
+			case asb.SettleComplete:
+				receivingPage.settleLocked(sequenceNumber, settlement)
+				return
+			case asb.SettleDeadLetter:
+				labels = append([]string{"Reason", "Description"}, labels...)
+			}
+
+			receivingPage.input(
+				fmt.Sprintf("Settle (%v) message #%v", settlement.Action, sequenceNumber),
+				labels,
+				make([]string, len(labels)),
+				func(values []string) {
+					properties, err := parseProperties(strings.Split(values[len(values)-1], ","))
+					if err != nil {
+						receivingPage.printError(err)
+						return
+					}
+					settlement.Properties = properties
+					if settlement.Action == asb.SettleDeadLetter {
+						settlement.Reason = values[0]
+						settlement.Description = values[1]
+					}
+					receivingPage.settleLocked(sequenceNumber, settlement)
+				},
+			)
+		},
+	)
+}
+
+// settleLocked settles the message in the background and removes it from the list
+func (receivingPage *ReceivingPage) settleLocked(sequenceNumber int64, settlement asb.Settlement) {
+	go func() {
+		err := receivingPage.controller.Settle(sequenceNumber, settlement)
+		receivingPage.queueUpdate(func() {
+			if err != nil {
+				receivingPage.printError(err)
+				return
+			}
+			index := slices.IndexFunc(receivingPage.received, func(message asb.ReceivedMessage) bool {
+				return message.SequenceNumber == sequenceNumber
+			})
+			if index >= 0 {
+				receivingPage.received = slices.Delete(receivingPage.received, index, index+1)
+				receivingPage.messages.RemoveItem(index)
+			}
+		})
+	}()
+}
+
+// editSessionState shows the state of the source's session in a form, submitting it replaces the
+// state and an empty state clears it
+func (receivingPage *ReceivingPage) editSessionState() {
+	source, _, _, err := receivingPage.getSource()
+	if err == nil && source.NextSession {
+		// The state would be read and set in sessions accepted separately
+		err = errors.New("Session ID not entered!")
+	}
+	if err != nil {
+		receivingPage.printError(err)
+		return
+	}
+
+	go func() {
+		_, state, err := receivingPage.controller.GetSessionState(source)
+		receivingPage.queueUpdate(func() {
+			if err != nil {
+				receivingPage.printError(err)
+				return
+			}
+			receivingPage.input("Session state of "+source.String(), []string{"State"}, []string{string(state)}, func(values []string) {
+				go func() {
+					err := receivingPage.controller.SetSessionState(source, []byte(values[0]))
+					if err != nil {
+						receivingPage.queueUpdate(func() {
+							receivingPage.printError(err)
+						})
+					}
+				}()
+			})
+		})
+	}()
+}
+
+// saveMessage saves the selected message to the config under the entered name
+func (receivingPage *ReceivingPage) saveMessage() {
+	index := receivingPage.messages.GetCurrentItem()
+	if index < 0 || index >= len(receivingPage.received) {
+		receivingPage.printError(errors.New("Message not selected!"))
+		return
+	}
+	received := receivingPage.received[index]
+
+	receivingPage.input(
+		"Save as message",
+		[]string{"Name"},
+		[]string{fmt.Sprintf("received-%v", received.SequenceNumber)},
+		func(values []string) {
+			receivingPage.selectOption(
+				"IDs and timestamps in the body",
+				[]string{"Keep them as they are", "Replace them with generateUUID and utcNow"},
+				func(option int) {
+					err := receivingPage.controller.SaveReceivedMessage(values[0], received, option == 1)
+					if err != nil {
+						receivingPage.printError(err)
+					}
+				},
+			)
+		},
+	)
+}
+
+// purgeMessages deletes the messages matching the search after a confirmation, the number of
+// deleted messages is shown in the title of the message list meanwhile
+func (receivingPage *ReceivingPage) purgeMessages() {
+	source, _, messageFilter, err := receivingPage.getSource()
+	if err != nil {
+		receivingPage.printError(err)
+		return
+	}
+	connection := receivingPage.controller.GetSelectedConnection()
+	if connection == nil {
+		receivingPage.printError(errors.New("Connection not selected!"))
+		return
+	}
+	what := "all messages"
+	if !messageFilter.IsEmpty() {
+		what = "the messages matching the search"
+	}
+
+	question := fmt.Sprintf(
+		"Delete %v from %v of connection %v (%v)?",
+		what,
+		source,
+		receivingPage.controller.GetSelectedConnectionName(),
+		connection.Namespace,
+	)
+	receivingPage.confirm(question, func() {
+		receivingPage.clearMessages()
+		receivingPage.messages.SetTitle(" Purging: ")
+		go func() {
+			_, _, err := receivingPage.controller.Purge(source, messageFilter, purgeParallel, func(purged int) {
+				receivingPage.queueUpdate(func() {
+					receivingPage.messages.SetTitle(fmt.Sprintf(" Purging: %v message(s) deleted ", purged))
+				})
+			})
+			receivingPage.queueUpdate(func() {
+				receivingPage.messages.SetTitle(" Messages: ")
+				if err != nil {
+					receivingPage.printError(err)
+				}
+			})
+		}()
+	})
+}
+
+func (receivingPage *ReceivingPage) clearMessages() {
+	receivingPage.received = nil
+	receivingPage.messages.Clear()
+	receivingPage.content.Clear()
+}
+
+func (receivingPage *ReceivingPage) addMessages(messages []asb.ReceivedMessage) {
+	for _, message := range messages {
+		if len(receivingPage.received) == maxShownMessages {
+			receivingPage.received = receivingPage.received[1:]
+			receivingPage.messages.RemoveItem(0)
+		}
+		receivingPage.received = append(receivingPage.received, message)
+		receivingPage.messages.AddItem(
+			fmt.Sprintf("#%v %v", message.SequenceNumber, message.EnqueuedTime.Local().Format("15:04:05")),
+			message.Subject,
+			0,
+			nil,
+		)
+	}
+}
+
+func (receivingPage *ReceivingPage) printMessage(index int) {
+	if index < 0 || index >= len(receivingPage.received) {
+		return
+	}
+	encoded, err := json.Marshal(receivingPage.received[index])
+	if err != nil {
+		receivingPage.printError(err)
+		return
+	}
+	colorized, err := colorizeJSON(string(encoded))
+	if err != nil {
+		receivingPage.printError(err)
+		return
+	}
+	receivingPage.content.Clear()
+	fmt.Fprintf(receivingPage.content, "%v", colorized)
+}
+
+func (receivingPage *ReceivingPage) printError(err error) {
+	receivingPage.printLog(fmt.Sprintf(
+		"[red][%v]: [red] Error - [red]%v[-]\n",
+		time.Now().Format("2006-01-02 15:04:05"),
+		err.Error(),
+	))
+}
+
+func (receivingPage *ReceivingPage) printLog(logMsg string) {
+	fmt.Fprintf(receivingPage.logs, "%v", logMsg)
+
+	_, _, _, height := receivingPage.logs.GetRect()
+	receivingPage.logs.SetMaxLines(height - 2)
+}
+
+func (receivingPage *ReceivingPage) setAfterDrawFunc(focusedElement tview.Primitive) {
+	receivingPage.source.SetBorderColor(tcell.ColorWhite)
+	receivingPage.messages.SetBorderColor(tcell.ColorWhite)
+	receivingPage.content.SetBorderColor(tcell.ColorWhite)
+	receivingPage.peek.SetBorderColor(tcell.ColorWhite)
+	receivingPage.receive.SetBorderColor(tcell.ColorWhite)
+	receivingPage.tail.SetBorderColor(tcell.ColorWhite)
+	receivingPage.lock.SetBorderColor(tcell.ColorWhite)
+	receivingPage.settle.SetBorderColor(tcell.ColorWhite)
+	receivingPage.state.SetBorderColor(tcell.ColorWhite)
+	receivingPage.save.SetBorderColor(tcell.ColorWhite)
+	receivingPage.purge.SetBorderColor(tcell.ColorWhite)
+	receivingPage.sending.SetBorderColor(tcell.ColorWhite)
+	receivingPage.close.SetBorderColor(tcell.ColorWhite)
+
+	switch focusedElement {
+	case receivingPage.messages:
+		receivingPage.messages.SetBorderColor(tcell.ColorBlue)
+	case receivingPage.content:
+		receivingPage.content.SetBorderColor(tcell.ColorBlue)
+	case receivingPage.peek:
+		receivingPage.peek.SetBorderColor(tcell.ColorBlue)
+	case receivingPage.receive:
+		receivingPage.receive.SetBorderColor(tcell.ColorBlue)
+	case receivingPage.tail:
+		receivingPage.tail.SetBorderColor(tcell.ColorBlue)
+	case receivingPage.lock:
+		receivingPage.lock.SetBorderColor(tcell.ColorBlue)
+	case receivingPage.settle:
+		receivingPage.settle.SetBorderColor(tcell.ColorBlue)
+	case receivingPage.state:
+		receivingPage.state.SetBorderColor(tcell.ColorBlue)
+	case receivingPage.save:
+		receivingPage.save.SetBorderColor(tcell.ColorBlue)
+	case receivingPage.purge:
+		receivingPage.purge.SetBorderColor(tcell.ColorBlue)
+	case receivingPage.sending:
+		receivingPage.sending.SetBorderColor(tcell.ColorBlue)
+	case receivingPage.close:
+		receivingPage.close.SetBorderColor(tcell.ColorBlue)
+	default:
+		if receivingPage.source.HasFocus() {
+			receivingPage.source.SetBorderColor(tcell.ColorBlue)
+		}
+	}
+}
@@ -4,19 +4,31 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
 
+	"github.com/rafalpienkowski/busgopher/internal/asb"
 	"github.com/rafalpienkowski/busgopher/internal/controller"
 )
 
+// favoriteMark prefixes favorites in the lists
+const favoriteMark = "★ "
+
+// maxRecentCombinations limits the recently used combinations offered with the presets
+const maxRecentCombinations = 5
+
 type SendingPage struct {
-	theme      Theme
-	controller *controller.Controller
-	closeApp   closeAppFunc
-	switchPage switchPageFunc
+	theme        Theme
+	controller   *controller.Controller
+	closeApp     closeAppFunc
+	switchPage   switchPageFunc
+	confirm      confirmFunc
+	input        inputFunc
+	selectOption selectOptionFunc
+	queueUpdate  queueUpdateFunc
 
 	flex         *tview.Flex
 	connections  *tview.List
@@ -24,8 +36,15 @@ type SendingPage struct {
 	messages     *tview.List
 	content      *tview.TextView
 	logs         *tview.TextView
+	discover     *BoxButton
 	config       *BoxButton
 	send         *BoxButton
+	request      *BoxButton
+	routing      *BoxButton
+	presets      *BoxButton
+	receiving    *BoxButton
+	statistics   *BoxButton
+	history      *BoxButton
 	close        *BoxButton
 
 	inputs []tview.Primitive
@@ -35,6 +54,10 @@ func newSendingPage(
 	theme Theme,
 	closeApp closeAppFunc,
 	switchPage switchPageFunc,
+	confirm confirmFunc,
+	input inputFunc,
+	selectOption selectOptionFunc,
+	queueUpdate queueUpdateFunc,
 ) *SendingPage {
 
 	flex := tview.NewFlex()
@@ -44,6 +67,13 @@ func newSendingPage(
 	content := tview.NewTextView()
 	logs := tview.NewTextView()
 	send := newBoxButton("Send")
+	request := newBoxButton("Request")
+	routing := newBoxButton("Test Rules")
+	presets := newBoxButton("Presets")
+	discover := newBoxButton("Refresh")
+	receiving := newBoxButton("To Receiving")
+	statistics := newBoxButton("To Statistics")
+	history := newBoxButton("To History")
 	config := newBoxButton("To Configuration")
 	close := newBoxButton("Close")
 
@@ -53,6 +83,13 @@ func newSendingPage(
 		messages,
 		content,
 		send,
+		request,
+		routing,
+		presets,
+		discover,
+		receiving,
+		statistics,
+		history,
 		config,
 		close,
 	}
@@ -60,6 +97,10 @@ func newSendingPage(
 	sendingPage := SendingPage{
 		theme:        theme,
 		switchPage:   switchPage,
+		confirm:      confirm,
+		input:        input,
+		selectOption: selectOption,
+		queueUpdate:  queueUpdate,
 		closeApp:     closeApp,
 		flex:         flex,
 		connections:  connections,
@@ -68,6 +109,13 @@ func newSendingPage(
 		content:      content,
 		logs:         logs,
 		send:         send,
+		request:      request,
+		routing:      routing,
+		presets:      presets,
+		discover:     discover,
+		receiving:    receiving,
+		statistics:   statistics,
+		history:      history,
 		config:       config,
 		close:        close,
 		inputs:       inputs,
@@ -138,6 +186,13 @@ func (sendingPage *SendingPage) setLayout() {
 	actions.
 		AddItem(tview.NewBox().SetBackgroundColor(sendingPage.theme.backgroundColor), 0, 1, false).
 		AddItem(sendingPage.send, sendingPage.send.GetWidth(), 0, false).
+		AddItem(sendingPage.request, sendingPage.request.GetWidth(), 0, false).
+		AddItem(sendingPage.routing, sendingPage.routing.GetWidth(), 0, false).
+		AddItem(sendingPage.presets, sendingPage.presets.GetWidth(), 0, false).
+		AddItem(sendingPage.discover, sendingPage.discover.GetWidth(), 0, false).
+		AddItem(sendingPage.receiving, sendingPage.receiving.GetWidth(), 0, false).
+		AddItem(sendingPage.statistics, sendingPage.statistics.GetWidth(), 0, false).
+		AddItem(sendingPage.history, sendingPage.history.GetWidth(), 0, false).
 		AddItem(sendingPage.config, sendingPage.config.GetWidth(), 0, false).
 		AddItem(sendingPage.close, sendingPage.close.GetWidth(), 0, false)
 
@@ -170,25 +225,248 @@ func (sendingPage *SendingPage) refresh(){
 
 }
 
+// reloadData rebuilds the lists from the controller, keeping the current selections
+func (sendingPage *SendingPage) reloadData() {
+	sendingPage.refreshConnections()
+	sendingPage.refreshDestinations()
+	sendingPage.refreshMessages()
+
+	selectListItem(sendingPage.connections, sendingPage.controller.GetSelectedConnectionName())
+	selectListItem(sendingPage.destinations, sendingPage.controller.GetSelectedDestination())
+	selectListItem(sendingPage.messages, sendingPage.controller.GetSelectedMessageName())
+
+	sendingPage.content.Clear()
+	for _, msg := range sendingPage.controller.GetMessages() {
+		if msg.Name == sendingPage.controller.GetSelectedMessageName() {
+			sendingPage.printMessage(msg)
+		}
+	}
+}
+
 func (sendingPage *SendingPage) setActions() {
 	sendingPage.send.SetSelectedFunc(func() {
-		err := sendingPage.controller.Send()
+		_, err := sendingPage.controller.Send()
 		if err != nil {
 			sendingPage.printError(err)
 		}
 	})
+	sendingPage.request.SetSelectedFunc(sendingPage.sendRequest)
+	sendingPage.routing.SetSelectedFunc(sendingPage.testRouting)
+	sendingPage.presets.SetSelectedFunc(sendingPage.showPresets)
+	sendingPage.connections.SetInputCapture(sendingPage.toggleFavorite(sendingPage.connections, controller.FavoriteConnection))
+	sendingPage.destinations.SetInputCapture(sendingPage.toggleFavorite(sendingPage.destinations, controller.FavoriteDestination))
+	sendingPage.messages.SetInputCapture(sendingPage.toggleFavorite(sendingPage.messages, controller.FavoriteMessage))
+	sendingPage.discover.SetSelectedFunc(sendingPage.discoverDestinations)
+	sendingPage.receiving.SetSelectedFunc(func() {
+		sendingPage.switchPage("receiving")
+	})
+	sendingPage.statistics.SetSelectedFunc(func() {
+		sendingPage.switchPage("statistics")
+	})
+	sendingPage.history.SetSelectedFunc(func() {
+		sendingPage.switchPage("history")
+	})
     sendingPage.config.SetSelectedFunc(func (){
-        sendingPage.switchPage("config")
+        sendingPage.switchPage("editor")
     })
 	sendingPage.close.SetSelectedFunc(func() {
 		sendingPage.closeApp()
 	})
 }
 
+// sendRequest asks where the reply should be sent, then sends the message and waits for the reply
+// in the background
+func (sendingPage *SendingPage) sendRequest() {
+	replyTo, replyToSessionID := "", ""
+	for _, msg := range sendingPage.controller.GetMessages() {
+		if msg.Name == sendingPage.controller.GetSelectedMessageName() {
+			replyTo, replyToSessionID = msg.Message.ReplayTo, msg.Message.ReplyToSessionID
+		}
+	}
+
+	sendingPage.input(
+		"Request",
+		[]string{"Reply to", "Reply to session ID", "Timeout"},
+		[]string{replyTo, replyToSessionID, "30s"},
+		func(values []string) {
+			timeout, err := time.ParseDuration(values[2])
+			if err != nil {
+				sendingPage.printError(fmt.Errorf("Invalid timeout: %v", values[2]))
+				return
+			}
+
+			// The request is prepared here, so the controller isn't read while the reply is awaited
+			prepared, err := sendingPage.controller.PrepareRequest(values[0], values[1])
+			if err != nil {
+				sendingPage.printError(err)
+				return
+			}
+			go func() {
+				_, reply, err := sendingPage.controller.SendRequest(prepared, timeout)
+				sendingPage.queueUpdate(func() {
+					if err != nil {
+						sendingPage.printError(err)
+						return
+					}
+					sendingPage.printReply(reply)
+				})
+			}()
+		},
+	)
+}
+
+// showPresets offers the presets, the recently used combinations and saving the current selection
+// as a preset
+func (sendingPage *SendingPage) showPresets() {
+	presets := sendingPage.controller.GetPresets()
+	recent, err := sendingPage.controller.GetRecentCombinations(maxRecentCombinations)
+	if err != nil {
+		sendingPage.printError(err)
+	}
+
+	options := []string{}
+	for _, preset := range presets {
+		options = append(options, "Preset "+preset.Name+": "+controller.Combination{
+			Connection:  preset.Preset.Connection,
+			Destination: preset.Preset.Destination,
+			Message:     preset.Preset.Message,
+		}.String())
+	}
+	for _, combination := range recent {
+		options = append(options, "Recent: "+combination.String())
+	}
+	options = append(options, "Save current as preset")
+
+	sendingPage.selectOption("Presets", options, func(index int) {
+		var err error
+		switch {
+		case index < len(presets):
+			_, err = sendingPage.controller.ApplyPreset(presets[index].Name)
+		case index < len(presets)+len(recent):
+			err = sendingPage.controller.SelectCombination(recent[index-len(presets)])
+		default:
+			sendingPage.savePreset()
+			return
+		}
+		if err != nil {
+			sendingPage.printError(err)
+			return
+		}
+		sendingPage.reloadData()
+	})
+}
+
+func (sendingPage *SendingPage) savePreset() {
+	name := sendingPage.controller.GetSelectedMessageName() + "-" + sendingPage.controller.GetSelectedDestination()
+	sendingPage.input("Save preset", []string{"Name"}, []string{name}, func(values []string) {
+		err := sendingPage.controller.SaveSelectedAsPreset(strings.TrimSpace(values[0]))
+		if err != nil {
+			sendingPage.printError(err)
+		}
+	})
+}
+
+// toggleFavorite pins or unpins the current item of the list when * is pressed
+func (sendingPage *SendingPage) toggleFavorite(list *tview.List, kind string) func(event *tcell.EventKey) *tcell.EventKey {
+	return func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Rune() != '*' || list.GetItemCount() == 0 {
+			return event
+		}
+		main, _ := list.GetItemText(list.GetCurrentItem())
+		_, err := sendingPage.controller.ToggleFavorite(kind, strings.TrimPrefix(main, favoriteMark))
+		if err != nil {
+			sendingPage.printError(err)
+			return nil
+		}
+		sendingPage.reloadData()
+
+		return nil
+	}
+}
+
+// discoverDestinations lists the entities of the selected connection in the background and offers
+// to save the new queues and topics to the config
+func (sendingPage *SendingPage) discoverDestinations() {
+	// The connection is captured here, the controller is only touched on the UI thread
+	prepared, err := sendingPage.controller.PrepareDiscovery()
+	if err != nil {
+		sendingPage.printError(err)
+		return
+	}
+	connectionName := sendingPage.controller.GetSelectedConnectionName()
+	go func() {
+		entities, err := sendingPage.controller.ListEntities(prepared)
+		sendingPage.queueUpdate(func() {
+			if err != nil {
+				sendingPage.printError(err)
+				return
+			}
+			sendingPage.controller.AddDiscovered(prepared, entities)
+			sendingPage.refreshDestinations()
+			selectListItem(sendingPage.destinations, sendingPage.controller.GetSelectedDestination())
+
+			unsaved := sendingPage.controller.GetUnsavedDestinations()
+			if len(unsaved) == 0 || connectionName != sendingPage.controller.GetSelectedConnectionName() {
+				return
+			}
+			sendingPage.confirm(fmt.Sprintf("Save %v new destination(s) to the config?", len(unsaved)), func() {
+				_, err := sendingPage.controller.SaveDiscoveredDestinations()
+				if err != nil {
+					sendingPage.printError(err)
+				}
+			})
+		})
+	}()
+}
+
+// testRouting evaluates the subscription rules of the selected topic in the background and shows
+// which subscriptions would get the selected message
+func (sendingPage *SendingPage) testRouting() {
+	snapshot := sendingPage.controller.Snapshot()
+	go func() {
+		routes, err := snapshot.TestRouting()
+		sendingPage.queueUpdate(func() {
+			if err != nil {
+				sendingPage.printError(err)
+				return
+			}
+
+			content := ""
+			for _, route := range routes {
+				if route.Matched {
+					content += fmt.Sprintf("[green]✔ %v[-] (%v)\n", tview.Escape(route.Subscription), tview.Escape(strings.Join(route.Rules, ", ")))
+				} else {
+					content += fmt.Sprintf("[gray]✘ %v[-]\n", tview.Escape(route.Subscription))
+				}
+				for _, routeError := range route.Errors {
+					content += fmt.Sprintf("  [red]%v[-]\n", tview.Escape(routeError))
+				}
+			}
+			sendingPage.content.SetTitle(" Routing: ")
+			sendingPage.printContent(content)
+		})
+	}()
+}
+
+func (sendingPage *SendingPage) printReply(reply asb.ReceivedMessage) {
+	encoded, err := json.Marshal(reply)
+	if err != nil {
+		sendingPage.printError(err)
+		return
+	}
+	colorized, err := colorizeJSON(string(encoded))
+	if err != nil {
+		sendingPage.printError(err)
+		return
+	}
+	sendingPage.content.SetTitle(" Reply: ")
+	sendingPage.printContent(colorized)
+}
+
 func (sendingPage *SendingPage) refreshDestinations() {
 	sendingPage.destinations.Clear()
 	for _, name := range sendingPage.controller.GetDestiationNamesForSelectedConnection() {
-		sendingPage.destinations.AddItem(name, name, 0, func() {
+		sendingPage.destinations.AddItem(sendingPage.itemText(controller.FavoriteDestination, name), name, 0, func() {
 			err := sendingPage.controller.SelectDestinationByName(name)
 			if err != nil {
 				sendingPage.printError(err)
@@ -201,7 +479,7 @@ func (sendingPage *SendingPage) refreshConnections() {
 
 	sendingPage.connections.Clear()
 	for _, conn := range sendingPage.controller.GetConnections() {
-		sendingPage.connections.AddItem(conn.Name, conn.Namespace, 0, func() {
+		sendingPage.connections.AddItem(sendingPage.itemText(controller.FavoriteConnection, conn.Name), conn.Namespace, 0, func() {
 			err := sendingPage.controller.SelectConnectionByName(conn.Name)
 			if err != nil {
 				sendingPage.printError(err)
@@ -216,21 +494,34 @@ func (sendingPage *SendingPage) refreshMessages() {
 	sendingPage.messages.Clear()
 
 	for _, msg := range sendingPage.controller.GetMessages() {
-		sendingPage.messages.AddItem(msg.Name, msg.Message.Subject, 0, func() {
+		sendingPage.messages.AddItem(sendingPage.itemText(controller.FavoriteMessage, msg.Name), msg.Message.Subject, 0, func() {
 			err := sendingPage.controller.SelectMessageByName(msg.Name)
 			if err != nil {
 				sendingPage.printError(err)
 				return
 			}
-            colorized, err := colorizeJSON(msg.Message.Print())
-            if err != nil {
-                sendingPage.printError(err)
-            }
-			sendingPage.printContent(colorized)
+			sendingPage.printMessage(msg)
 		})
 	}
 }
 
+// itemText marks favorites
+func (sendingPage *SendingPage) itemText(kind string, name string) string {
+	if sendingPage.controller.IsFavorite(kind, name) {
+		return favoriteMark + name
+	}
+	return name
+}
+
+func (sendingPage *SendingPage) printMessage(msg controller.Message) {
+	sendingPage.content.SetTitle(" Content: ")
+	colorized, err := colorizeJSON(msg.Message.Print())
+	if err != nil {
+		sendingPage.printError(err)
+	}
+	sendingPage.printContent(colorized)
+}
+
 func (sendingPage *SendingPage) printContent(content string) {
 	sendingPage.content.Clear()
 	fmt.Fprintf(sendingPage.content, "%v", content)
@@ -263,6 +554,13 @@ func (sendingPage *SendingPage) setAfterDrawFunc(focusedElement tview.Primitive)
 	sendingPage.content.SetBorderColor(tcell.ColorWhite)
 	sendingPage.logs.SetBorderColor(tcell.ColorWhite)
 	sendingPage.send.SetBorderColor(tcell.ColorWhite)
+	sendingPage.request.SetBorderColor(tcell.ColorWhite)
+	sendingPage.routing.SetBorderColor(tcell.ColorWhite)
+	sendingPage.presets.SetBorderColor(tcell.ColorWhite)
+	sendingPage.discover.SetBorderColor(tcell.ColorWhite)
+	sendingPage.receiving.SetBorderColor(tcell.ColorWhite)
+	sendingPage.statistics.SetBorderColor(tcell.ColorWhite)
+	sendingPage.history.SetBorderColor(tcell.ColorWhite)
 	sendingPage.config.SetBorderColor(tcell.ColorWhite)
 	sendingPage.close.SetBorderColor(tcell.ColorWhite)
 
@@ -279,6 +577,20 @@ func (sendingPage *SendingPage) setAfterDrawFunc(focusedElement tview.Primitive)
 		sendingPage.logs.SetBorderColor(tcell.ColorBlue)
 	case sendingPage.send:
 		sendingPage.send.SetBorderColor(tcell.ColorBlue)
+	case sendingPage.request:
+		sendingPage.request.SetBorderColor(tcell.ColorBlue)
+	case sendingPage.routing:
+		sendingPage.routing.SetBorderColor(tcell.ColorBlue)
+	case sendingPage.presets:
+		sendingPage.presets.SetBorderColor(tcell.ColorBlue)
+	case sendingPage.discover:
+		sendingPage.discover.SetBorderColor(tcell.ColorBlue)
+	case sendingPage.receiving:
+		sendingPage.receiving.SetBorderColor(tcell.ColorBlue)
+	case sendingPage.statistics:
+		sendingPage.statistics.SetBorderColor(tcell.ColorBlue)
+	case sendingPage.history:
+		sendingPage.history.SetBorderColor(tcell.ColorBlue)
 	case sendingPage.config:
 		sendingPage.config.SetBorderColor(tcell.ColorBlue)
 	case sendingPage.close:
@@ -286,6 +598,16 @@ func (sendingPage *SendingPage) setAfterDrawFunc(focusedElement tview.Primitive)
 	}
 }
 
+func selectListItem(list *tview.List, text string) {
+	for i := 0; i < list.GetItemCount(); i++ {
+		main, _ := list.GetItemText(i)
+		if strings.TrimPrefix(main, favoriteMark) == text {
+			list.SetCurrentItem(i)
+			return
+		}
+	}
+}
+
 func colorizeJSON(data string) (string, error) {
 	var jsonData interface{}
 	if err := json.Unmarshal([]byte(data), &jsonData); err != nil {
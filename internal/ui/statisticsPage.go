@@ -0,0 +1,549 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+
+	"github.com/rafalpienkowski/busgopher/internal/asb"
+	"github.com/rafalpienkowski/busgopher/internal/controller"
+)
+
+// statisticsInterval is how often the statistics are refreshed while the page is shown
+const statisticsInterval = 5 * time.Second
+
+type StatisticsPage struct {
+	theme        Theme
+	controller   *controller.Controller
+	closeApp     closeAppFunc
+	switchPage   switchPageFunc
+	confirm      confirmFunc
+	input        inputFunc
+	selectOption selectOptionFunc
+	queueUpdate  queueUpdateFunc
+
+	flex    *tview.Flex
+	table   *tview.Table
+	logs    *tview.TextView
+	update  *BoxButton
+	create  *BoxButton
+	delete  *BoxButton
+	rules   *BoxButton
+	apply   *BoxButton
+	sending *BoxButton
+	close   *BoxButton
+
+	inputs []tview.Primitive
+
+	// previous statistics by entity path, changed counts are highlighted
+	previous    map[string]asb.EntityStatistics
+	stopRefresh chan struct{}
+	// loading is set while statistics are loaded, reload when another load was requested meanwhile
+	loading bool
+	reload  bool
+	// entities shown in the table, by row - 1
+	entities []asb.Entity
+}
+
+func newStatisticsPage(
+	theme Theme,
+	closeApp closeAppFunc,
+	switchPage switchPageFunc,
+	confirm confirmFunc,
+	input inputFunc,
+	selectOption selectOptionFunc,
+	queueUpdate queueUpdateFunc,
+) *StatisticsPage {
+
+	flex := tview.NewFlex()
+	table := tview.NewTable()
+	logs := tview.NewTextView()
+	update := newBoxButton("Refresh")
+	create := newBoxButton("Create")
+	delete := newBoxButton("Delete")
+	rules := newBoxButton("Rules")
+	apply := newBoxButton("Apply")
+	sending := newBoxButton("To Sending")
+	close := newBoxButton("Close")
+
+	inputs := []tview.Primitive{
+		table,
+		update,
+		create,
+		delete,
+		rules,
+		apply,
+		sending,
+		close,
+	}
+
+	statisticsPage := StatisticsPage{
+		theme:        theme,
+		closeApp:     closeApp,
+		switchPage:   switchPage,
+		confirm:      confirm,
+		input:        input,
+		selectOption: selectOption,
+		queueUpdate:  queueUpdate,
+		flex:         flex,
+		table:        table,
+		logs:         logs,
+		update:       update,
+		create:       create,
+		delete:       delete,
+		rules:        rules,
+		apply:        apply,
+		sending:      sending,
+		close:        close,
+		inputs:       inputs,
+	}
+	statisticsPage.configureAppearence()
+	statisticsPage.setLayout()
+
+	return &statisticsPage
+}
+
+func (statisticsPage *StatisticsPage) configureAppearence() {
+	statisticsPage.table.
+		SetFixed(1, 1).
+		SetSelectable(true, false).
+		SetTitle(" Entities: ").
+		SetBorder(true).
+		SetBackgroundColor(statisticsPage.theme.backgroundColor)
+
+	statisticsPage.logs.
+		SetDynamicColors(true).
+		SetTitle(" Logs: ").
+		SetBorder(true).
+		SetBackgroundColor(statisticsPage.theme.backgroundColor)
+
+	statisticsPage.flex.
+		SetBorder(true).
+		SetBackgroundColor(statisticsPage.theme.backgroundColor).
+		SetTitle("Statistics")
+}
+
+func (statisticsPage *StatisticsPage) setLayout() {
+	actions := tview.NewFlex()
+	actions.
+		AddItem(tview.NewBox().SetBackgroundColor(statisticsPage.theme.backgroundColor), 0, 1, false).
+		AddItem(statisticsPage.update, statisticsPage.update.GetWidth(), 0, false).
+		AddItem(statisticsPage.create, statisticsPage.create.GetWidth(), 0, false).
+		AddItem(statisticsPage.delete, statisticsPage.delete.GetWidth(), 0, false).
+		AddItem(statisticsPage.rules, statisticsPage.rules.GetWidth(), 0, false).
+		AddItem(statisticsPage.apply, statisticsPage.apply.GetWidth(), 0, false).
+		AddItem(statisticsPage.sending, statisticsPage.sending.GetWidth(), 0, false).
+		AddItem(statisticsPage.close, statisticsPage.close.GetWidth(), 0, false)
+
+	statisticsPage.flex.SetDirection(tview.FlexRow).
+		AddItem(statisticsPage.table, 0, 3, false).
+		AddItem(actions, 3, 0, false).
+		AddItem(statisticsPage.logs, 0, 1, false)
+}
+
+func (statisticsPage *StatisticsPage) loadData(controller *controller.Controller) {
+	statisticsPage.controller = controller
+	statisticsPage.setActions()
+}
+
+// refresh shows the statistics of the selected connection and keeps refreshing them until stopped
+func (statisticsPage *StatisticsPage) refresh() {
+	statisticsPage.stop()
+	statisticsPage.previous = nil
+	statisticsPage.table.Clear()
+	statisticsPage.flex.SetTitle("Statistics: " + statisticsPage.controller.GetSelectedConnectionName())
+
+	stopRefresh := make(chan struct{})
+	statisticsPage.stopRefresh = stopRefresh
+	statisticsPage.load()
+	go func() {
+		ticker := time.NewTicker(statisticsInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopRefresh:
+				return
+			case <-ticker.C:
+				statisticsPage.queueUpdate(func() {
+					if statisticsPage.stopRefresh == stopRefresh {
+						statisticsPage.load()
+					}
+				})
+			}
+		}
+	}()
+}
+
+// stop stops refreshing the statistics
+func (statisticsPage *StatisticsPage) stop() {
+	if statisticsPage.stopRefresh != nil {
+		close(statisticsPage.stopRefresh)
+		statisticsPage.stopRefresh = nil
+	}
+}
+
+func (statisticsPage *StatisticsPage) setActions() {
+	statisticsPage.update.SetSelectedFunc(func() {
+		statisticsPage.load()
+	})
+	statisticsPage.create.SetSelectedFunc(func() {
+		statisticsPage.createEntity()
+	})
+	statisticsPage.delete.SetSelectedFunc(func() {
+		statisticsPage.deleteEntity()
+	})
+	statisticsPage.rules.SetSelectedFunc(func() {
+		statisticsPage.manageRules()
+	})
+	statisticsPage.apply.SetSelectedFunc(func() {
+		statisticsPage.applyTopology()
+	})
+	statisticsPage.sending.SetSelectedFunc(func() {
+		statisticsPage.stop()
+		statisticsPage.switchPage("sending")
+	})
+	statisticsPage.close.SetSelectedFunc(func() {
+		statisticsPage.stop()
+		statisticsPage.closeApp()
+	})
+}
+
+// load gets the statistics in the background and shows them. Only one load runs at a time, a load
+// requested meanwhile starts after it instead of showing its outdated statistics.
+func (statisticsPage *StatisticsPage) load() {
+	if statisticsPage.loading {
+		statisticsPage.reload = true
+		return
+	}
+	statisticsPage.loading = true
+	snapshot := statisticsPage.controller.Snapshot()
+	go func() {
+		statistics, err := snapshot.GetStatistics("")
+		statisticsPage.queueUpdate(func() {
+			statisticsPage.loading = false
+			if statisticsPage.reload {
+				statisticsPage.reload = false
+				statisticsPage.load()
+				return
+			}
+			if err != nil {
+				statisticsPage.printError(err)
+				return
+			}
+			statisticsPage.show(statistics)
+		})
+	}()
+}
+
+// selectedEntity returns the entity of the selected table row
+func (statisticsPage *StatisticsPage) selectedEntity() (asb.Entity, error) {
+	row, _ := statisticsPage.table.GetSelection()
+	if row < 1 || row > len(statisticsPage.entities) {
+		return asb.Entity{}, errors.New("Entity not selected!")
+	}
+
+	return statisticsPage.entities[row-1], nil
+}
+
+// manage runs the change in the background on a snapshot of the controller, logs its error and
+// reloads the statistics
+func (statisticsPage *StatisticsPage) manage(change func(snapshot *controller.Controller) error) {
+	snapshot := statisticsPage.controller.Snapshot()
+	go func() {
+		err := change(snapshot)
+		statisticsPage.queueUpdate(func() {
+			if err != nil {
+				statisticsPage.printError(err)
+			}
+			statisticsPage.load()
+		})
+	}()
+}
+
+func (statisticsPage *StatisticsPage) createEntity() {
+	kinds := []string{asb.EntityQueue, asb.EntityTopic, asb.EntitySubscription}
+	statisticsPage.selectOption("Create", kinds, func(index int) {
+		switch kinds[index] {
+		case asb.EntityTopic:
+			statisticsPage.input(
+				"Create topic",
+				[]string{"Name", "Time to live"},
+				[]string{"", ""},
+				func(values []string) {
+					statisticsPage.manage(func(snapshot *controller.Controller) error {
+						return snapshot.CreateTopic(asb.TopicDefinition{
+							Name:                     values[0],
+							DefaultMessageTimeToLive: values[1],
+						})
+					})
+				},
+			)
+		case asb.EntityQueue:
+			statisticsPage.input(
+				"Create queue",
+				[]string{"Name", "Lock duration", "Time to live", "Max delivery count", "Forward to"},
+				[]string{"", "", "", "", ""},
+				func(values []string) {
+					maxDeliveryCount, err := parseCount(values[3])
+					if err != nil {
+						statisticsPage.printError(err)
+						return
+					}
+					statisticsPage.manage(func(snapshot *controller.Controller) error {
+						return snapshot.CreateQueue(asb.QueueDefinition{
+							Name:                     values[0],
+							LockDuration:             values[1],
+							DefaultMessageTimeToLive: values[2],
+							MaxDeliveryCount:         maxDeliveryCount,
+							ForwardTo:                values[4],
+						})
+					})
+				},
+			)
+		case asb.EntitySubscription:
+			topic := ""
+			if entity, err := statisticsPage.selectedEntity(); err == nil {
+				topic = entity.Topic
+				if entity.Kind == asb.EntityTopic {
+					topic = entity.Name
+				}
+			}
+			statisticsPage.input(
+				"Create subscription",
+				[]string{"Topic", "Name", "Lock duration", "Time to live", "Max delivery count", "Forward to"},
+				[]string{topic, "", "", "", "", ""},
+				func(values []string) {
+					maxDeliveryCount, err := parseCount(values[4])
+					if err != nil {
+						statisticsPage.printError(err)
+						return
+					}
+					statisticsPage.manage(func(snapshot *controller.Controller) error {
+						return snapshot.CreateSubscription(values[0], asb.SubscriptionDefinition{
+							Name:                     values[1],
+							LockDuration:             values[2],
+							DefaultMessageTimeToLive: values[3],
+							MaxDeliveryCount:         maxDeliveryCount,
+							ForwardTo:                values[5],
+						})
+					})
+				},
+			)
+		}
+	})
+}
+
+func (statisticsPage *StatisticsPage) deleteEntity() {
+	entity, err := statisticsPage.selectedEntity()
+	if err != nil {
+		statisticsPage.printError(err)
+		return
+	}
+
+	statisticsPage.confirm(fmt.Sprintf("Delete %v %v with all its messages?", entity.Kind, entity.Path()), func() {
+		statisticsPage.manage(func(snapshot *controller.Controller) error {
+			return snapshot.DeleteEntity(entity)
+		})
+	})
+}
+
+// manageRules lists the rules of the selected subscription to delete one of them or add a new one
+func (statisticsPage *StatisticsPage) manageRules() {
+	entity, err := statisticsPage.selectedEntity()
+	if err == nil && entity.Kind != asb.EntitySubscription {
+		err = errors.New("Subscription not selected!")
+	}
+	if err != nil {
+		statisticsPage.printError(err)
+		return
+	}
+
+	snapshot := statisticsPage.controller.Snapshot()
+	go func() {
+		rules, err := snapshot.GetRules(entity.Topic, entity.Name)
+		statisticsPage.queueUpdate(func() {
+			if err != nil {
+				statisticsPage.printError(err)
+				return
+			}
+
+			options := []string{}
+			for _, rule := range rules {
+				options = append(options, fmt.Sprintf("Delete %v: %v", rule.Name, rule.FilterText()))
+			}
+			options = append(options, "Add SQL rule", "Add correlation rule")
+			statisticsPage.selectOption("Rules of "+entity.Path(), options, func(index int) {
+				switch {
+				case index < len(rules):
+					name := rules[index].Name
+					statisticsPage.confirm("Delete rule "+name+"?", func() {
+						statisticsPage.manage(func(snapshot *controller.Controller) error {
+							return snapshot.DeleteRule(entity.Topic, entity.Name, name)
+						})
+					})
+				case index == len(rules):
+					statisticsPage.addRule(entity, "SQL filter")
+				default:
+					statisticsPage.addRule(entity, "Properties (name=value, ...)")
+				}
+			})
+		})
+	}()
+}
+
+// addRule asks for a rule with a SQL filter or correlation filter properties, e.g. subject=created, app.tenant=acme
+func (statisticsPage *StatisticsPage) addRule(entity asb.Entity, filterLabel string) {
+	statisticsPage.input(
+		"Add rule to "+entity.Path(),
+		[]string{"Name", filterLabel, "Action"},
+		[]string{"", "", ""},
+		func(values []string) {
+			rule := asb.RuleDefinition{Name: values[0], SQL: values[1], Action: values[2]}
+			if filterLabel != "SQL filter" {
+				rule.SQL = ""
+				rule.Correlation = &asb.CorrelationFilter{}
+				for _, term := range strings.Split(values[1], ",") {
+					if len(strings.TrimSpace(term)) == 0 {
+						continue
+					}
+					if err := rule.Correlation.Set(term); err != nil {
+						statisticsPage.printError(err)
+						return
+					}
+				}
+			}
+			if err := rule.Validate(); err != nil {
+				statisticsPage.printError(err)
+				return
+			}
+
+			statisticsPage.manage(func(snapshot *controller.Controller) error {
+				return snapshot.AddRule(entity.Topic, entity.Name, rule)
+			})
+		},
+	)
+}
+
+// applyTopology applies a topology from the config to the selected connection's namespace
+func (statisticsPage *StatisticsPage) applyTopology() {
+	names := statisticsPage.controller.GetTopologyNames()
+	if len(names) == 0 {
+		statisticsPage.printError(errors.New("No topologies in the config!"))
+		return
+	}
+
+	statisticsPage.selectOption("Apply topology", names, func(index int) {
+		statisticsPage.manage(func(snapshot *controller.Controller) error {
+			_, err := snapshot.Apply(names[index], false)
+			return err
+		})
+	})
+}
+
+func parseCount(text string) (int32, error) {
+	if len(strings.TrimSpace(text)) == 0 {
+		return 0, nil
+	}
+	count, err := strconv.ParseInt(strings.TrimSpace(text), 10, 32)
+	if err != nil || count < 0 {
+		return 0, fmt.Errorf("Invalid count: %v", text)
+	}
+
+	return int32(count), nil
+}
+
+func (statisticsPage *StatisticsPage) show(statistics []asb.EntityStatistics) {
+	table := statisticsPage.table
+	table.Clear()
+	for column, header := range []string{"Entity", "Kind", "Active", "Dead-letter", "Scheduled", "Transfer", "Size", "Accessed"} {
+		table.SetCell(0, column, tview.NewTableCell(header).
+			SetTextColor(tcell.ColorYellow).
+			SetAttributes(tcell.AttrBold).
+			SetSelectable(false))
+	}
+
+	current := make(map[string]asb.EntityStatistics)
+	statisticsPage.entities = []asb.Entity{}
+	for i, entity := range statistics {
+		row := i + 1
+		previous, known := statisticsPage.previous[entity.Path()]
+		current[entity.Path()] = entity
+		statisticsPage.entities = append(statisticsPage.entities, entity.Entity)
+
+		count := func(column int, value int32, previousValue int32) {
+			cell := tview.NewTableCell(strconv.Itoa(int(value))).SetAlign(tview.AlignRight)
+			if known && value != previousValue {
+				cell.SetTextColor(tcell.ColorYellow)
+			}
+			table.SetCell(row, column, cell)
+		}
+		accessed := "-"
+		if !entity.AccessedAt.IsZero() {
+			accessed = entity.AccessedAt.Local().Format("2006-01-02 15:04:05")
+		}
+
+		table.SetCell(row, 0, tview.NewTableCell(entity.Path()).SetExpansion(1))
+		table.SetCell(row, 1, tview.NewTableCell(entity.Kind))
+		count(2, entity.ActiveMessageCount, previous.ActiveMessageCount)
+		count(3, entity.DeadLetterMessageCount, previous.DeadLetterMessageCount)
+		count(4, entity.ScheduledMessageCount, previous.ScheduledMessageCount)
+		count(5, entity.TransferMessageCount, previous.TransferMessageCount)
+		table.SetCell(row, 6, tview.NewTableCell(strconv.FormatInt(entity.SizeInBytes, 10)).SetAlign(tview.AlignRight))
+		table.SetCell(row, 7, tview.NewTableCell(accessed))
+		if entity.DeadLetterMessageCount > 0 {
+			table.GetCell(row, 3).SetTextColor(tcell.ColorRed)
+		}
+	}
+	statisticsPage.previous = current
+	table.SetTitle(fmt.Sprintf(" Entities (refreshed at %v): ", time.Now().Format("15:04:05")))
+}
+
+func (statisticsPage *StatisticsPage) printError(err error) {
+	statisticsPage.printLog(fmt.Sprintf(
+		"[red][%v]: [red] Error - [red]%v[-]\n",
+		time.Now().Format("2006-01-02 15:04:05"),
+		err.Error(),
+	))
+}
+
+func (statisticsPage *StatisticsPage) printLog(logMsg string) {
+	fmt.Fprintf(statisticsPage.logs, "%v", logMsg)
+
+	_, _, _, height := statisticsPage.logs.GetRect()
+	statisticsPage.logs.SetMaxLines(height - 2)
+}
+
+func (statisticsPage *StatisticsPage) setAfterDrawFunc(focusedElement tview.Primitive) {
+	statisticsPage.table.SetBorderColor(tcell.ColorWhite)
+	statisticsPage.update.SetBorderColor(tcell.ColorWhite)
+	statisticsPage.create.SetBorderColor(tcell.ColorWhite)
+	statisticsPage.delete.SetBorderColor(tcell.ColorWhite)
+	statisticsPage.rules.SetBorderColor(tcell.ColorWhite)
+	statisticsPage.apply.SetBorderColor(tcell.ColorWhite)
+	statisticsPage.sending.SetBorderColor(tcell.ColorWhite)
+	statisticsPage.close.SetBorderColor(tcell.ColorWhite)
+
+	switch focusedElement {
+	case statisticsPage.table:
+		statisticsPage.table.SetBorderColor(tcell.ColorBlue)
+	case statisticsPage.update:
+		statisticsPage.update.SetBorderColor(tcell.ColorBlue)
+	case statisticsPage.create:
+		statisticsPage.create.SetBorderColor(tcell.ColorBlue)
+	case statisticsPage.delete:
+		statisticsPage.delete.SetBorderColor(tcell.ColorBlue)
+	case statisticsPage.rules:
+		statisticsPage.rules.SetBorderColor(tcell.ColorBlue)
+	case statisticsPage.apply:
+		statisticsPage.apply.SetBorderColor(tcell.ColorBlue)
+	case statisticsPage.sending:
+		statisticsPage.sending.SetBorderColor(tcell.ColorBlue)
+	case statisticsPage.close:
+		statisticsPage.close.SetBorderColor(tcell.ColorBlue)
+	}
+}
@@ -12,31 +12,69 @@ import (
 
 type UI struct {
 	controller *controller.Controller
+	stopWatch  func()
 
 	// View components
 	theme Theme
 	app   *tview.Application
+	logs  chan string
 
 	// Pages
-	pages   *tview.Pages
-	sending *SendingPage
-	config  *ConfigPage
+	pages      *tview.Pages
+	sending    *SendingPage
+	receiving  *ReceivingPage
+	statistics *StatisticsPage
+	history    *HistoryPage
+	editor     *EditorPage
+	config     *ConfigPage
 }
 
 type closeAppFunc func()
 type switchPageFunc func(string)
+type confirmFunc func(text string, onConfirm func())
+type selectOptionFunc func(title string, options []string, onSelected func(index int))
+type inputFunc func(title string, labels []string, values []string, onSubmit func(values []string))
+type queueUpdateFunc func(update func())
+
+const modalPage = "modal"
 
 func NewUI() *UI {
 	ui := UI{}
 
 	ui.theme = Dark()
 	ui.app = tview.NewApplication()
+	ui.logs = make(chan string, 100)
+	go ui.printLogs()
 	ui.pages = tview.NewPages()
-	ui.sending = newSendingPage(ui.theme, ui.app.Stop, ui.switchToPage)
-	ui.config = newConfigPage(ui.theme, ui.app.Stop, ui.switchToPage)
+	ui.sending = newSendingPage(ui.theme, ui.app.Stop, ui.switchToPage, ui.confirm, ui.input, ui.selectOption, ui.queueUpdateDraw)
+	ui.receiving = newReceivingPage(
+		ui.theme,
+		ui.app.Stop,
+		ui.switchToPage,
+		ui.confirm,
+		ui.input,
+		ui.selectOption,
+		ui.queueUpdateDraw,
+	)
+	ui.statistics = newStatisticsPage(
+		ui.theme,
+		ui.app.Stop,
+		ui.switchToPage,
+		ui.confirm,
+		ui.input,
+		ui.selectOption,
+		ui.queueUpdateDraw,
+	)
+	ui.history = newHistoryPage(ui.theme, ui.app.Stop, ui.switchToPage, ui.confirm, ui.selectOption, ui.queueUpdateDraw)
+	ui.editor = newEditorPage(ui.theme, ui.app.Stop, ui.switchToPage, ui.confirm)
+	ui.config = newConfigPage(ui.theme, ui.app.Stop, ui.switchToPage, ui.confirm, ui.selectOption)
 
 	ui.pages.
 		AddPage("sending", ui.sending.flex, true, true).
+		AddPage("receiving", ui.receiving.flex, true, false).
+		AddPage("statistics", ui.statistics.flex, true, false).
+		AddPage("history", ui.history.flex, true, false).
+		AddPage("editor", ui.editor.flex, true, false).
 		AddPage("config", ui.config.flex, true, false)
 
 	ui.app.SetAfterDrawFunc(ui.setAfterDrawFunc)
@@ -48,10 +86,32 @@ func NewUI() *UI {
 func (ui *UI) LoadData(controller *controller.Controller) {
 	ui.controller = controller
 	ui.sending.loadData(ui.controller)
+	ui.receiving.loadData(ui.controller)
+	ui.statistics.loadData(ui.controller)
+	ui.history.loadData(ui.controller)
+	ui.editor.loadData(ui.controller)
 	ui.config.loadData(ui.controller)
+	ui.stopWatch = ui.controller.WatchConfig(func() {
+		ui.app.QueueUpdateDraw(ui.reloadConfig)
+	})
+}
+
+func (ui *UI) reloadConfig() {
+	err := ui.controller.ReloadConfig()
+	if err != nil {
+		ui.WriteLog("Failed to reload config: " + err.Error())
+		return
+	}
+	ui.sending.reloadData()
+	ui.editor.refresh()
+	ui.config.reloadData()
 }
 
 func (ui *UI) Start() error {
+	if ui.stopWatch != nil {
+		defer ui.stopWatch()
+	}
+
 	return ui.app.SetRoot(ui.pages, true).
 		SetFocus(ui.sending.connections).
 		EnableMouse(false).
@@ -64,25 +124,150 @@ func (ui *UI) switchToPage(page string) {
 	case "sending":
 		ui.sending.refresh()
 		ui.app.SetFocus(ui.sending.connections)
+	case "receiving":
+		ui.receiving.refresh()
+		ui.app.SetFocus(ui.receiving.source)
+	case "statistics":
+		ui.statistics.refresh()
+		ui.app.SetFocus(ui.statistics.table)
+	case "history":
+		ui.history.refresh()
+		ui.app.SetFocus(ui.history.entries)
+	case "editor":
+		ui.editor.refresh()
+		ui.app.SetFocus(ui.editor.connections)
 	case "config":
 		ui.config.refresh()
 		ui.app.SetFocus(ui.config.config)
 	}
 }
 
+func (ui *UI) confirm(text string, onConfirm func()) {
+	focused := ui.app.GetFocus()
+	modal := tview.NewModal().
+		SetText(text).
+		AddButtons([]string{"Yes", "No"}).
+		SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+			ui.closeModal(focused)
+			if buttonLabel == "Yes" {
+				onConfirm()
+			}
+		})
+	modal.SetBackgroundColor(ui.theme.backgroundColor)
+
+	ui.pages.AddPage(modalPage, modal, true, true)
+	ui.app.SetFocus(modal)
+}
+
+func (ui *UI) selectOption(title string, options []string, onSelected func(index int)) {
+	focused := ui.app.GetFocus()
+	list := tview.NewList().
+		ShowSecondaryText(false).
+		SetHighlightFullLine(true)
+	list.
+		SetTitle(" " + title + " (Esc to cancel) ").
+		SetBorder(true).
+		SetBackgroundColor(ui.theme.backgroundColor)
+	list.SetMainTextStyle(ui.theme.style)
+
+	for i, option := range options {
+		list.AddItem(option, "", 0, func() {
+			ui.closeModal(focused)
+			onSelected(i)
+		})
+	}
+	list.SetDoneFunc(func() {
+		ui.closeModal(focused)
+	})
+
+	width := len(title) + 20
+	for _, option := range options {
+		width = max(width, len(option)+4)
+	}
+
+	ui.pages.AddPage(modalPage, centered(list, width, len(options)+2), true, true)
+	ui.app.SetFocus(list)
+}
+
+// input shows a form with a field for every label, values are the initial field texts
+func (ui *UI) input(title string, labels []string, values []string, onSubmit func(values []string)) {
+	focused := ui.app.GetFocus()
+	form := tview.NewForm()
+	form.
+		SetFieldBackgroundColor(tcell.ColorBlack).
+		SetButtonsAlign(tview.AlignRight).
+		SetTitle(" " + title + " (Esc to cancel) ").
+		SetBorder(true).
+		SetBackgroundColor(ui.theme.backgroundColor)
+
+	for i, label := range labels {
+		form.AddInputField(label, values[i], 0, nil, nil)
+	}
+	form.AddButton("OK", func() {
+		submitted := []string{}
+		for i := range labels {
+			submitted = append(submitted, form.GetFormItem(i).(*tview.InputField).GetText())
+		}
+		ui.closeModal(focused)
+		onSubmit(submitted)
+	})
+	form.AddButton("Cancel", func() {
+		ui.closeModal(focused)
+	})
+	form.SetCancelFunc(func() {
+		ui.closeModal(focused)
+	})
+
+	ui.pages.AddPage(modalPage, centered(form, 70, len(labels)*2+5), true, true)
+	ui.app.SetFocus(form)
+}
+
+func centered(primitive tview.Primitive, width int, height int) tview.Primitive {
+	return tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(primitive, height, 0, true).
+			AddItem(nil, 0, 1, false), width, 0, true).
+		AddItem(nil, 0, 1, false)
+}
+
+func (ui *UI) closeModal(focused tview.Primitive) {
+	ui.pages.RemovePage(modalPage)
+	ui.app.SetFocus(focused)
+}
+
+// WriteLog can be called from any goroutine, logs are printed in order on the current page
 func (ui *UI) WriteLog(log string) {
+	ui.logs <- fmt.Sprintf(
+		"[%v]: Info - %v\n",
+		time.Now().Format("2006-01-02 15:04:05"),
+		log)
+}
+
+func (ui *UI) printLogs() {
+	for log := range ui.logs {
+		ui.app.QueueUpdateDraw(func() {
+			ui.printLog(log)
+		})
+	}
+}
+
+func (ui *UI) printLog(log string) {
 	page, _ := ui.pages.GetFrontPage()
 	switch page {
 	case "sending":
-		ui.sending.printLog(fmt.Sprintf(
-			"[%v]: Info - %v\n",
-			time.Now().Format("2006-01-02 15:04:05"),
-			log))
+		ui.sending.printLog(log)
+	case "receiving":
+		ui.receiving.printLog(log)
+	case "statistics":
+		ui.statistics.printLog(log)
+	case "editor":
+		ui.editor.printLog(log)
 	case "config":
-		ui.config.printLog(fmt.Sprintf(
-			"[%v]: Info - %v\n",
-			time.Now().Format("2006-01-02 15:04:05"),
-			log))
+		ui.config.printLog(log)
+	case "history":
+		ui.history.printLog(log)
 	}
 }
 
@@ -99,6 +284,14 @@ func (ui *UI) setAfterDrawFunc(screen tcell.Screen) {
 		switch currentPage {
 		case "sending":
 			ui.sending.setAfterDrawFunc(focusedElement)
+		case "receiving":
+			ui.receiving.setAfterDrawFunc(focusedElement)
+		case "statistics":
+			ui.statistics.setAfterDrawFunc(focusedElement)
+		case "history":
+			ui.history.setAfterDrawFunc(focusedElement)
+		case "editor":
+			ui.editor.setAfterDrawFunc(focusedElement)
 		case "config":
 			ui.config.setAfterDrawFunc(focusedElement)
 		}
@@ -106,6 +299,10 @@ func (ui *UI) setAfterDrawFunc(screen tcell.Screen) {
 }
 
 func (ui *UI) setInputCapture(event *tcell.EventKey) *tcell.EventKey {
+	if ui.pages.HasPage(modalPage) {
+		return event
+	}
+
 	switch event.Key() {
 	case tcell.KeyTab:
 		ui.cycleFocus(false)
@@ -143,6 +340,14 @@ func (ui *UI) cycleFocus(reverse bool) {
 	switch currentPage {
 	case "sending":
 		input = ui.getNextFocusInput(ui.sending.inputs, reverse)
+	case "receiving":
+		input = ui.getNextFocusInput(ui.receiving.inputs, reverse)
+	case "statistics":
+		input = ui.getNextFocusInput(ui.statistics.inputs, reverse)
+	case "history":
+		input = ui.getNextFocusInput(ui.history.inputs, reverse)
+	case "editor":
+		input = ui.getNextFocusInput(ui.editor.inputs, reverse)
 	case "config":
 		input = ui.getNextFocusInput(ui.config.inputs, reverse)
 	}